@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/spf13/viper"
+)
+
+// initPIIKeys returns keys of `PII_KEY_FILE` which encrypt PII of users and responses stored for idempotency keys.
+//
+// Returns nil when `PII_KEY_FILE` is not set which means PII encryption is disabled.
+func initPIIKeys() (crypt.KeyProvider, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("PII_KEY_FILE", "")
+
+	keyFile := vi.GetString("PII_KEY_FILE")
+	if keyFile == "" {
+		slog.Warn("PII_KEY_FILE is not set. PII encryption is disabled.")
+		return nil, nil
+	}
+
+	keys, err := crypt.NewFileKeyProvider(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PII keys: %w", err)
+	}
+	return keys, nil
+}
+
+// initUserEncryption returns UserEncryption with the PII keys configured with `PII_ENCRYPTED_FIELDS` and
+// `PII_PREFIX_INDEXED_FIELDS`.
+//
+// Returns nil when keys are nil which means PII encryption is disabled.
+func initUserEncryption(keys crypt.KeyProvider) (*repository.UserEncryption, error) {
+	if keys == nil {
+		return nil, nil
+	}
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("PII_ENCRYPTED_FIELDS", "email,nickName,firstName,lastName")
+	vi.SetDefault("PII_PREFIX_INDEXED_FIELDS", "")
+
+	fields := splitFields(vi.GetString("PII_ENCRYPTED_FIELDS"))
+	prefixFields := splitFields(vi.GetString("PII_PREFIX_INDEXED_FIELDS"))
+	encryption, err := repository.NewUserEncryption(crypt.NewEnvelopeEncryptor(keys), fields, prefixFields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PII_ENCRYPTED_FIELDS or PII_PREFIX_INDEXED_FIELDS: %w", err)
+	}
+	slog.Info("PII encryption is enabled", slog.Any("fields", fields), slog.Any("prefixIndexedFields", prefixFields),
+		slog.String("currentKeyId", keys.CurrentKeyId()))
+	return encryption, nil
+}
+
+// splitFields returns the fields of the comma separated list
+func splitFields(list string) []string {
+	fields := []string{}
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// initMfaEncryption returns the encryptor of TOTP secrets configured with `MFA_KEY_FILE`. Keys of the file can be
+// rotated like PII keys, secrets are decrypted with the key they are encrypted with.
+//
+// Returns nil when `MFA_KEY_FILE` is not set which means MFA is unavailable.
+func initMfaEncryption() (*crypt.EnvelopeEncryptor, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("MFA_KEY_FILE", "")
+
+	keyFile := vi.GetString("MFA_KEY_FILE")
+	if keyFile == "" {
+		slog.Warn("MFA_KEY_FILE is not set. MFA is unavailable.")
+		return nil, nil
+	}
+
+	keys, err := crypt.NewFileKeyProvider(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MFA keys: %w", err)
+	}
+	slog.Info("MFA is available", slog.String("currentKeyId", keys.CurrentKeyId()))
+	return crypt.NewEnvelopeEncryptor(keys), nil
+}
+
+// initNotificationEncryption returns the encryptor of queued notifications configured with `NOTIFICATION_KEY_FILE`.
+// Recipients and bodies of queued messages are encrypted since bodies contain tokens of users.
+//
+// Returns nil when `NOTIFICATION_KEY_FILE` is not set which means queued notifications are stored in plaintext.
+func initNotificationEncryption() (*crypt.EnvelopeEncryptor, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("NOTIFICATION_KEY_FILE", "")
+
+	keyFile := vi.GetString("NOTIFICATION_KEY_FILE")
+	if keyFile == "" {
+		slog.Warn("NOTIFICATION_KEY_FILE is not set. Queued notifications are stored in plaintext.")
+		return nil, nil
+	}
+
+	keys, err := crypt.NewFileKeyProvider(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification keys: %w", err)
+	}
+	slog.Info("Notification encryption is enabled", slog.String("currentKeyId", keys.CurrentKeyId()))
+	return crypt.NewEnvelopeEncryptor(keys), nil
+}
+
+// runReencryptPII runs `reencrypt-pii` subcommand which encrypts plaintext PII of existing users
+// and re-encrypts PII encrypted with old keys after a key rotation.
+func runReencryptPII() error {
+	keys, err := initPIIKeys()
+	if err != nil {
+		return err
+	}
+	encryption, err := initUserEncryption(keys)
+	if err != nil {
+		return err
+	}
+	if encryption == nil {
+		return errors.New("PII_KEY_FILE should be set to re-encrypt PII")
+	}
+
+	mongodb := mongohandler.New()
+	mongodb.InitMongoDB()
+	defer mongodb.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	updated, err := encryption.ReencryptAll(ctx, mongodb.Database)
+	if err != nil {
+		return fmt.Errorf("re-encryption failed after %d users: %w", updated, err)
+	}
+	fmt.Printf("re-encrypted %d users\n", updated)
+	return nil
+}
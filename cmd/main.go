@@ -1,38 +1,258 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"log/slog"
+	"os"
+	"time"
 
+	"github.com/nsaltun/userapi/internal/grpcapi"
+	"github.com/nsaltun/userapi/internal/handler/country"
+	"github.com/nsaltun/userapi/internal/handler/gql"
+	"github.com/nsaltun/userapi/internal/handler/scim"
 	"github.com/nsaltun/userapi/internal/handler/user"
 	"github.com/nsaltun/userapi/internal/repository"
 	"github.com/nsaltun/userapi/internal/router"
 	"github.com/nsaltun/userapi/internal/service"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
 	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/db/pghandler"
+	"github.com/nsaltun/userapi/pkg/lib/grpcserver"
 	"github.com/nsaltun/userapi/pkg/lib/health"
 	"github.com/nsaltun/userapi/pkg/lib/httpserver"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/nsaltun/userapi/pkg/lib/job"
 	"github.com/nsaltun/userapi/pkg/lib/logging"
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+	"github.com/nsaltun/userapi/pkg/lib/password"
+	"github.com/nsaltun/userapi/pkg/lib/server"
+	userv1 "github.com/nsaltun/userapi/pkg/pb/user/v1"
+	"github.com/spf13/viper"
+)
+
+// Supported values of `STORAGE_TYPE` config
+const (
+	StorageMongo    = "mongo"
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
 )
 
 func main() {
 	logging.InitSlog()
+
+	// `user migrate [command]` runs a MongoDB migration command and exits, see runMigrate
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		exitOnError("Migration command failed", runMigrate(os.Args[2:]))
+		return
+	}
+	// `user reencrypt-pii` encrypts existing PII with the current key and exits
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt-pii" {
+		exitOnError("Re-encryption failed", runReencryptPII())
+		return
+	}
+
+	exitOnError("User API stopped with error", serve())
+}
+
+// exitOnError logs the error with the message and exits with status 1 if err isn't nil. Commands return their errors
+// instead of exiting themselves, so that their deferred cleanups such as closing storage run before exiting.
+func exitOnError(msg string, err error) {
+	if err != nil {
+		slog.Error(msg, slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// serve runs the API servers and background jobs until termination and closes storage after they are shut down.
+// Initialization errors are returned after closing storage which is already connected.
+func serve() error {
 	slog.Info("----USER API----")
 
-	mongodb := mongohandler.New()
-	mongodb.InitMongoDB()
-	defer mongodb.Disconnect()
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("STORAGE_TYPE", StorageMongo)
+	vi.SetDefault("MIGRATE_ON_STARTUP", true)
+	vi.SetDefault("UNVERIFIED_USER_SWEEP_INTERVAL", time.Hour)
+	vi.SetDefault("NOTIFICATION_POLL_INTERVAL", 5*time.Second)
+	vi.SetDefault("NOTIFICATION_PURGE_INTERVAL", time.Hour)
+
+	store, err := initStorage(vi.GetString("STORAGE_TYPE"), vi.GetBool("MIGRATE_ON_STARTUP"))
+	if err != nil {
+		return err
+	}
+	defer store.close()
 
-	userRepo, err := repository.NewUserRepository(mongodb)
+	passwordPolicy, err := password.NewPolicy()
+	if err != nil {
+		return fmt.Errorf("failed to initialize password policy: %w", err)
+	}
+	passwordHashing, err := crypt.NewPasswordHashing()
+	if err != nil {
+		return fmt.Errorf("failed to initialize password hashing: %w", err)
+	}
+	notificationTemplates, err := service.NewNotificationTemplates()
+	if err != nil {
+		return fmt.Errorf("failed to parse notification templates: %w", err)
+	}
+	notificationSender, err := notification.NewSender()
+	if err != nil {
+		return fmt.Errorf("failed to initialize notification sender: %w", err)
+	}
+	notificationEncryptor, err := initNotificationEncryption()
+	if err != nil {
+		return err
+	}
+	notifications := store.notifications
+	if notificationEncryptor != nil {
+		notifications = notification.NewEncryptedQueue(notifications, notificationEncryptor)
+	}
+	mfaSecrets, err := initMfaEncryption()
 	if err != nil {
-		log.Fatalf("Failed to initialize MongoDB: %v", err)
+		return err
 	}
-	userSvc := service.NewUserService(userRepo)
+	notifier := service.NewQueueNotifier(notificationTemplates, notifications)
+	userSvc := service.NewUserService(store.users, store.transactor, passwordPolicy, passwordHashing, service.NewNoopSessionRevoker(), store.userTokens, notifier, store.userMfa, mfaSecrets)
 	userHandler := user.NewUserHandler(userSvc)
 
-	healthChecker := health.NewHealthCheck(mongodb.HealthChecker())
+	healthChecker := health.NewHealthCheck(store.healthChecks)
 	// httpHandler := router.NewRouter(userHandler, healthChecker)
 
+	graphQLHandler, err := gql.NewGraphQLHandler(userSvc)
+	if err != nil {
+		return fmt.Errorf("failed to initialize GraphQL schema: %w", err)
+	}
+
 	fiberApp := httpserver.NewFiberServer()
-	router.NewFiberRouter(fiberApp.App, userHandler, healthChecker)
-	fiberApp.Listen()
+	if err := router.NewFiberRouter(fiberApp.App, userHandler, country.NewCountryHandler(), scim.NewScimHandler(userSvc), graphQLHandler, store.idempotency, healthChecker); err != nil {
+		return fmt.Errorf("failed to initialize router: %w", err)
+	}
+
+	grpcApp := grpcserver.NewGrpcServer()
+	userv1.RegisterUserServiceServer(grpcApp.Server, grpcapi.NewUserServer(userSvc))
+
+	// users pending verification longer than `UNVERIFIED_USER_TTL` are deleted periodically
+	unverifiedUserSweep := job.NewPeriodic("expire-unverified-users", vi.GetDuration("UNVERIFIED_USER_SWEEP_INTERVAL"), func(ctx context.Context) error {
+		_, err := userSvc.ExpireUnverifiedUsers(ctx)
+		return err
+	})
+
+	// queued notifications are delivered in the background, failed deliveries are retried and purged after retention
+	dispatcher := notification.NewDispatcher(notifications, notificationSender)
+	notificationDelivery := job.NewPeriodic("deliver-notifications", vi.GetDuration("NOTIFICATION_POLL_INTERVAL"), dispatcher.Dispatch)
+	notificationPurge := job.NewPeriodic("purge-failed-notifications", vi.GetDuration("NOTIFICATION_PURGE_INTERVAL"), dispatcher.Purge)
+
+	// servers and jobs are shut down together on termination
+	return server.Run(fiberApp, grpcApp, unverifiedUserSweep, notificationDelivery, notificationPurge)
+}
+
+// storage is the set of repositories on the configured storage
+type storage struct {
+	users       repository.UserRepository
+	userTokens  repository.UserTokenRepository
+	userMfa     repository.UserMfaRepository
+	idempotency idempotency.Store
+	// transactor runs transactions across the repositories
+	transactor repository.Transactor
+	// notifications is the queue of notifications to be delivered
+	notifications notification.Queue
+	// healthChecks are health checks of the storage
+	healthChecks map[string]func(context.Context) error
+	// close closes the connection
+	close func()
+}
+
+// initStorage connects to the configured storage and returns repositories on it.
+//
+// Pending MongoDB migrations are applied when migrate is true. Idempotency keys are kept in memory for storages other than MongoDB.
+// Notifications are queued in memory for in-memory storage only. The connection is closed when an error is returned.
+func initStorage(storageType string, migrate bool) (storage, error) {
+	switch storageType {
+	case StorageMongo:
+		mongodb := mongohandler.New()
+		mongodb.InitMongoDB()
+		userRepo, idempotencyStore, err := initMongoRepositories(mongodb, migrate)
+		if err != nil {
+			mongodb.Disconnect()
+			return storage{}, err
+		}
+		return storage{
+			users:         userRepo,
+			userTokens:    repository.NewUserTokenRepository(mongodb),
+			userMfa:       repository.NewUserMfaRepository(mongodb),
+			idempotency:   idempotencyStore,
+			transactor:    mongodb,
+			notifications: repository.NewNotificationQueue(mongodb),
+			healthChecks:  map[string]func(context.Context) error{"MongoDB": mongodb.HealthChecker()},
+			close:         mongodb.Disconnect,
+		}, nil
+	case StoragePostgres:
+		pg := pghandler.New()
+		pg.InitPostgres()
+
+		userRepo, err := repository.NewPostgresUserRepository(pg)
+		if err != nil {
+			pg.Disconnect()
+			return storage{}, fmt.Errorf("failed to initialize PostgreSQL: %w", err)
+		}
+		slog.Warn("Idempotency keys are kept in memory and not shared between instances with PostgreSQL storage.")
+		return storage{
+			users:         userRepo,
+			userTokens:    repository.NewPostgresUserTokenRepository(pg),
+			userMfa:       repository.NewPostgresUserMfaRepository(pg),
+			idempotency:   idempotency.NewMemoryStore(),
+			transactor:    pg,
+			notifications: repository.NewPostgresNotificationQueue(pg),
+			healthChecks:  map[string]func(context.Context) error{"PostgreSQL": pg.HealthChecker()},
+			close:         pg.Disconnect,
+		}, nil
+	case StorageMemory:
+		slog.Warn("Using in-memory storage. Data will be lost on shutdown.")
+		return storage{
+			users:         repository.NewInMemoryUserRepository(),
+			userTokens:    repository.NewInMemoryUserTokenRepository(),
+			userMfa:       repository.NewInMemoryUserMfaRepository(),
+			idempotency:   idempotency.NewMemoryStore(),
+			transactor:    repository.NoopTransactor{},
+			notifications: notification.NewMemoryQueue(),
+			healthChecks:  map[string]func(context.Context) error{},
+			close:         func() {},
+		}, nil
+	default:
+		return storage{}, fmt.Errorf("unknown STORAGE_TYPE: %s", storageType)
+	}
+}
+
+// initMongoRepositories applies pending migrations when migrate is true and returns the user repository and the
+// idempotency store on MongoDB. Stored responses of idempotency keys have PII of users, they are encrypted with the PII
+// keys when PII encryption is enabled.
+func initMongoRepositories(mongodb *mongohandler.MongoDBWrapper, migrate bool) (repository.UserRepository, idempotency.Store, error) {
+	if migrate {
+		if err := migrateMongoOnStartup(mongodb); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	piiKeys, err := initPIIKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	idempotencyStore := repository.NewIdempotencyStore(mongodb)
+	if piiKeys == nil {
+		userRepo, err := repository.NewUserRepository(mongodb)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize MongoDB: %w", err)
+		}
+		return userRepo, idempotencyStore, nil
+	}
+
+	encryption, err := initUserEncryption(piiKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	userRepo, err := repository.NewEncryptedUserRepository(mongodb, encryption)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize MongoDB: %w", err)
+	}
+	return userRepo, idempotency.NewEncryptedStore(idempotencyStore, crypt.NewEnvelopeEncryptor(piiKeys)), nil
 }
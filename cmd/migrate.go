@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+)
+
+// migrationTimeout is the max duration of running migrations including waiting for the lock
+const migrationTimeout = 10 * time.Minute
+
+// Supported commands of `migrate` subcommand
+const (
+	MigrateUp     = "up"
+	MigrateStatus = "status"
+	MigrateDryRun = "dry-run"
+	// MigrateEmailCollisions lists users having the same canonical email which block the unique index of canonical emails
+	MigrateEmailCollisions = "email-collisions"
+	// MigrateNickNameCollisions lists users having look-alike nicknames which block the unique index of nickname skeletons
+	MigrateNickNameCollisions = "nickname-collisions"
+	// MigrateInvalidCountries lists users whose countries aren't ISO 3166-1 alpha-2 codes
+	MigrateInvalidCountries = "invalid-countries"
+)
+
+// runMigrate runs `migrate [up|status|dry-run|email-collisions|nickname-collisions|invalid-countries]` subcommand against MongoDB.
+// Default command is `up`.
+func runMigrate(args []string) error {
+	command := MigrateUp
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	mongodb := mongohandler.New()
+	mongodb.InitMongoDB()
+	defer mongodb.Disconnect()
+
+	migrator, err := mongohandler.NewMigrator(mongodb.Database, repository.MongoMigrations())
+	if err != nil {
+		return fmt.Errorf("invalid migrations: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	switch command {
+	case MigrateUp:
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			fmt.Fprintf(out, "applied\t%d\t%s\n", mig.Version, mig.Description)
+		}
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+	case MigrateStatus:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		fmt.Fprintln(out, "VERSION\tSTATUS\tAPPLIED AT\tDESCRIPTION")
+		for _, s := range statuses {
+			state, appliedAt := "pending", "-"
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\t%s\n", s.Version, state, appliedAt, s.Description)
+		}
+	case MigrateDryRun:
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get pending migrations: %w", err)
+		}
+		for _, mig := range pending {
+			fmt.Fprintf(out, "would apply\t%d\t%s\n", mig.Version, mig.Description)
+		}
+		if len(pending) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+	case MigrateEmailCollisions:
+		collisions, err := repository.EmailCollisions(ctx, mongodb.Database)
+		if err != nil {
+			return fmt.Errorf("failed to find email collisions: %w", err)
+		}
+		printCollisions(out, "CANONICAL EMAIL", collisions)
+	case MigrateNickNameCollisions:
+		collisions, err := repository.NickNameCollisions(ctx, mongodb.Database)
+		if err != nil {
+			return fmt.Errorf("failed to find nickname collisions: %w", err)
+		}
+		printCollisions(out, "NICKNAME SKELETON", collisions)
+	case MigrateInvalidCountries:
+		invalid, err := repository.InvalidCountries(ctx, mongodb.Database)
+		if err != nil {
+			return fmt.Errorf("failed to find invalid countries: %w", err)
+		}
+		fmt.Fprintln(out, "COUNTRY\tUSER IDS")
+		for _, c := range invalid {
+			fmt.Fprintf(out, "%q\t%s\n", c.Country, strings.Join(c.UserIds, ","))
+		}
+		if len(invalid) == 0 {
+			fmt.Fprintln(out, "no invalid countries")
+		}
+	default:
+		return fmt.Errorf("unknown migrate command: %s. Supported commands: %s, %s, %s, %s, %s, %s", command, MigrateUp, MigrateStatus,
+			MigrateDryRun, MigrateEmailCollisions, MigrateNickNameCollisions, MigrateInvalidCountries)
+	}
+	return nil
+}
+
+// printCollisions prints values of unique forms which are used by more than one user with ids of the users
+func printCollisions(out io.Writer, header string, collisions []repository.Collision) {
+	fmt.Fprintln(out, header+"\tUSER IDS")
+	for _, c := range collisions {
+		fmt.Fprintf(out, "%s\t%s\n", c.Value, strings.Join(c.UserIds, ","))
+	}
+	if len(collisions) == 0 {
+		fmt.Fprintln(out, "no collisions")
+	}
+}
+
+// migrateMongoOnStartup applies pending MongoDB migrations
+func migrateMongoOnStartup(mongodb *mongohandler.MongoDBWrapper) error {
+	migrator, err := mongohandler.NewMigrator(mongodb.Database, repository.MongoMigrations())
+	if err != nil {
+		return fmt.Errorf("invalid migrations: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate MongoDB: %w", err)
+	}
+	return nil
+}
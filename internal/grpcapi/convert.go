@@ -0,0 +1,36 @@
+package grpcapi
+
+import (
+	"github.com/nsaltun/userapi/internal/model"
+	userv1 "github.com/nsaltun/userapi/pkg/pb/user/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toProtoUser converts the user model into its protobuf message. Password is never included.
+func toProtoUser(user *model.User) *userv1.User {
+	if user == nil {
+		return nil
+	}
+	return &userv1.User{
+		Id:        user.Id,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		NickName:  user.NickName,
+		Email:     user.Email,
+		Country:   user.Country,
+		Status:    userv1.UserStatus(user.Status),
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+		Version:   user.Version,
+	}
+}
+
+// toProtoUsers converts items of pagination into protobuf messages
+func toProtoUsers(items interface{}) []*userv1.User {
+	users, _ := items.([]model.User)
+	protoUsers := make([]*userv1.User, 0, len(users))
+	for i := range users {
+		protoUsers = append(protoUsers, toProtoUser(&users[i]))
+	}
+	return protoUsers
+}
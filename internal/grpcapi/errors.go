@@ -0,0 +1,60 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpToGrpcCodes maps http codes of errwrap.IError to gRPC status codes
+var httpToGrpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// errorDomain is the domain of ErrorInfo details of status errors
+const errorDomain = "userapi"
+
+// toStatus converts the error into a gRPC status error.
+//
+// Message of errwrap.IError is kept and its http code is mapped to gRPC code. Its error code is in ErrorInfo details
+// as reason. Internal errors are returned without exposing their details, see errwrap.Public.
+func toStatus(err error) error {
+	var iErr errwrap.IError
+	if errors.As(err, &iErr) {
+		iErr = errwrap.Public(iErr)
+		code, ok := httpToGrpcCodes[iErr.HttpCode()]
+		if !ok {
+			code = codes.Unknown
+		}
+		st := status.New(code, iErr.ErrorResp().Message)
+		if reason := iErr.ErrorResp().Code; reason != "" {
+			if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain}); err == nil {
+				st = detailed
+			}
+		}
+		return st.Err()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, errwrap.ErrInternal.ErrorResp().Message)
+}
@@ -0,0 +1,126 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/nsaltun/userapi/internal/handler"
+	"github.com/nsaltun/userapi/internal/handler/user"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/service"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	userv1 "github.com/nsaltun/userapi/pkg/pb/user/v1"
+)
+
+// userServer is the gRPC implementation of user operations. It delegates to UserService like the REST handlers do
+// and validates requests with the same rules.
+type userServer struct {
+	userv1.UnimplementedUserServiceServer
+	userService service.UserService
+}
+
+// NewUserServer returns gRPC server of user operations to register it with userv1.RegisterUserServiceServer
+func NewUserServer(userService service.UserService) userv1.UserServiceServer {
+	return &userServer{userService: userService}
+}
+
+// CreateUser creates a user. Returns InvalidArgument for validation errors and AlreadyExists when nickName or email is taken.
+func (s *userServer) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.CreateUserResponse, error) {
+	newUser := &model.User{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		NickName:  req.GetNickName(),
+		Email:     req.GetEmail(),
+		Password:  req.GetPassword(),
+		Country:   req.GetCountry(),
+	}
+	if err := handler.Validate(&user.CreateUserRequest{User: newUser}); err != nil {
+		return nil, toStatus(err)
+	}
+
+	createdUser, err := s.userService.CreateUser(ctx, newUser)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.CreateUserResponse{User: toProtoUser(createdUser)}, nil
+}
+
+// GetUser returns the user by id. Returns NotFound when the user doesn't exist.
+func (s *userServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	if req.GetId() == "" {
+		return nil, toStatus(errwrap.ErrBadRequest.SetMessage("id can't be empty"))
+	}
+
+	foundUser, err := s.userService.GetUserById(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.GetUserResponse{User: toProtoUser(foundUser)}, nil
+}
+
+// UpdateUser replaces updatable fields of the user. Returns NotFound when the user doesn't exist and
+// AlreadyExists when nickName or email is taken.
+func (s *userServer) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error) {
+	updatedFields := &model.User{
+		Id:        req.GetId(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		NickName:  req.GetNickName(),
+		Email:     req.GetEmail(),
+		Country:   req.GetCountry(),
+		Status:    model.UserStatus(req.GetStatus()),
+	}
+	if err := handler.Validate(&user.UpdateUserByIdRequest{User: updatedFields}); err != nil {
+		return nil, toStatus(err)
+	}
+
+	updatedUser, err := s.userService.UpdateUserById(ctx, req.GetId(), *updatedFields)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.UpdateUserResponse{User: toProtoUser(updatedUser)}, nil
+}
+
+// DeleteUser deactivates the user. Returns NotFound when the user doesn't exist.
+func (s *userServer) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+	if err := handler.Validate(&user.DeleteUserByIdRequest{Id: req.GetId()}); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := s.userService.DeleteUserById(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+// ListUsers lists users by filter. Limit is user.DefaultLimit when it is not set.
+func (s *userServer) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	listReq := user.ListUsersByFilterRequest{
+		Limit:  int(req.GetLimit()),
+		Offset: int(req.GetOffset()),
+		UserFilter: &model.UserFilter{
+			Id:        req.GetId(),
+			FirstName: req.GetFirstName(),
+			LastName:  req.GetLastName(),
+			NickName:  req.GetNickName(),
+			Email:     req.GetEmail(),
+			Country:   req.GetCountry(),
+			Status:    model.UserStatus(req.GetStatus()),
+		},
+	}
+	if err := handler.Validate(&listReq); err != nil {
+		return nil, toStatus(err)
+	}
+
+	page, err := s.userService.ListUsers(ctx, *listReq.UserFilter, listReq.Limit, listReq.Offset)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.ListUsersResponse{
+		Users:        toProtoUsers(page.Items),
+		TotalRecords: page.TotalRecords,
+		Limit:        int32(page.Limit),
+		Offset:       int32(page.Offset),
+		HasNext:      page.HasNext,
+		HasPrevious:  page.HasPrevious,
+	}, nil
+}
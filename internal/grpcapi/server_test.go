@@ -0,0 +1,179 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	mocks "github.com/nsaltun/userapi/internal/mocks/service"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	userv1 "github.com/nsaltun/userapi/pkg/pb/user/v1"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient serves the user server over an in-memory connection and returns a client of it
+func newTestClient(t *testing.T, svc *mocks.UserService) userv1.UserServiceClient {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	userv1.RegisterUserServiceServer(srv, NewUserServer(svc))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return userv1.NewUserServiceClient(conn)
+}
+
+func TestCreateUser(t *testing.T) {
+	id := uuid.NewString()
+	tests := []struct {
+		name     string
+		req      *userv1.CreateUserRequest
+		setup    func(*mocks.UserService)
+		expected *userv1.User
+		code     codes.Code
+		message  string
+	}{
+		{
+			name: "success",
+			req:  &userv1.CreateUserRequest{FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Password: "pwd"},
+			setup: func(s *mocks.UserService) {
+				s.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.NickName == "johndoe" && u.Password == "pwd"
+				})).Return(&model.User{Id: id, FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Password: "hash", Status: model.UserStatus_Active}, nil).Once()
+			},
+			expected: &userv1.User{Id: id, FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Status: userv1.UserStatus_USER_STATUS_ACTIVE},
+			code:     codes.OK,
+		},
+		{
+			name:    "validation error",
+			req:     &userv1.CreateUserRequest{FirstName: "John"},
+			setup:   func(s *mocks.UserService) {},
+			code:    codes.InvalidArgument,
+			message: "nickName can't be empty; email can't be empty; country can't be empty",
+		},
+		{
+			name: "conflict",
+			req:  &userv1.CreateUserRequest{FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Password: "Correct-Horse7"},
+			setup: func(s *mocks.UserService) {
+				s.On("CreateUser", mock.Anything, mock.Anything).Return(nil, errwrap.ErrConflict.SetMessage("email already exists")).Once()
+			},
+			code:    codes.AlreadyExists,
+			message: "email already exists",
+		},
+		{
+			name: "unexpected error is not exposed",
+			req:  &userv1.CreateUserRequest{FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Password: "Correct-Horse7"},
+			setup: func(s *mocks.UserService) {
+				s.On("CreateUser", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused")).Once()
+			},
+			code:    codes.Internal,
+			message: "internal server error",
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			svc := new(mocks.UserService)
+			tCase.setup(svc)
+			client := newTestClient(tt, svc)
+
+			resp, err := client.CreateUser(context.Background(), tCase.req)
+
+			st := status.Convert(err)
+			require.Equal(tt, tCase.code, st.Code())
+			require.Equal(tt, tCase.message, st.Message())
+			if tCase.expected != nil {
+				require.Equal(tt, tCase.expected.Id, resp.GetUser().GetId())
+				require.Equal(tt, tCase.expected.NickName, resp.GetUser().GetNickName())
+				require.Equal(tt, tCase.expected.Status, resp.GetUser().GetStatus())
+			}
+			svc.AssertExpectations(tt)
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	id := uuid.NewString()
+	svc := new(mocks.UserService)
+	svc.On("GetUserById", mock.Anything, id).Return(&model.User{Id: id, NickName: "johndoe"}, nil).Once()
+	svc.On("GetUserById", mock.Anything, "missing").Return(nil, errwrap.ErrNotFound.SetMessage("record not found")).Once()
+	client := newTestClient(t, svc)
+
+	resp, err := client.GetUser(context.Background(), &userv1.GetUserRequest{Id: id})
+	require.NoError(t, err)
+	require.Equal(t, "johndoe", resp.GetUser().GetNickName())
+
+	_, err = client.GetUser(context.Background(), &userv1.GetUserRequest{Id: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetUser(context.Background(), &userv1.GetUserRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	svc.AssertExpectations(t)
+}
+
+func TestListUsers(t *testing.T) {
+	svc := new(mocks.UserService)
+	filter := model.UserFilter{Country: "TR"}
+	svc.On("ListUsers", mock.Anything, filter, 20, 0).Return(&model.Pagination{
+		TotalRecords: 21,
+		Limit:        20,
+		HasNext:      true,
+		Items:        []model.User{{Id: "1"}, {Id: "2"}},
+	}, nil).Once()
+	client := newTestClient(t, svc)
+
+	resp, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{Country: "TR"})
+	require.NoError(t, err)
+	require.Len(t, resp.GetUsers(), 2)
+	require.Equal(t, int64(21), resp.GetTotalRecords())
+	require.True(t, resp.GetHasNext())
+
+	_, err = client.ListUsers(context.Background(), &userv1.ListUsersRequest{Offset: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	svc.AssertExpectations(t)
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"bad request", errwrap.ErrBadRequest, codes.InvalidArgument},
+		{"not found", errwrap.ErrNotFound, codes.NotFound},
+		{"conflict", errwrap.ErrConflict, codes.AlreadyExists},
+		{"internal", errwrap.ErrInternal, codes.Internal},
+		{"unmapped http code", errwrap.NewError("teapot", "418").SetHttpCode(418), codes.Unknown},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"plain error", errors.New("boom"), codes.Internal},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			require.Equal(tt, tCase.code, status.Code(toStatus(tCase.err)))
+		})
+	}
+}
+
+func TestToStatusErrorInfo(t *testing.T) {
+	st := status.Convert(toStatus(model.ErrUserEmailTaken))
+	require.Equal(t, codes.AlreadyExists, st.Code())
+	require.Equal(t, "email is already taken", st.Message())
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	require.Equal(t, "USER_EMAIL_TAKEN", info.GetReason())
+	require.Equal(t, errorDomain, info.GetDomain())
+}
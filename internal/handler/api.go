@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/middleware/fiber_middleware"
+	"github.com/nsaltun/userapi/pkg/lib/openapi"
+)
+
+// defaultErrorStatuses are documented for routes which don't specify their error statuses
+var defaultErrorStatuses = []int{http.StatusBadRequest, http.StatusInternalServerError}
+
+// pathParamRegex matches fiber path parameters like `:id`
+var pathParamRegex = regexp.MustCompile(`:(\w+)`)
+
+// RouteDoc is the documentation of a route which can't be derived from its request and response types
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// SuccessStatus is the status code returned by the handler on success. Default is 200.
+	SuccessStatus int
+	// ErrorStatuses are status codes of possible errors. Default is 400 and 500.
+	ErrorStatuses []int
+	// Idempotent documents the optional Idempotency-Key header of fiber_middleware.IdempotencyMiddleware
+	Idempotent bool
+}
+
+// route is a registered route with types of its handler
+type route struct {
+	method      string
+	path        string
+	operationId string
+	request     reflect.Type
+	response    reflect.Type
+	doc         RouteDoc
+}
+
+// API is a fiber router which records routes registered with Route to generate their OpenAPI document
+type API struct {
+	router fiber.Router
+	prefix string
+	routes *[]route
+}
+
+// NewAPI returns an API registering routes to the given router
+func NewAPI(router fiber.Router) *API {
+	return &API{router: router, routes: &[]route{}}
+}
+
+// Group returns an API for the sub path with the given handlers(middlewares). Routes are recorded to the parent API.
+func (a *API) Group(prefix string, handlers ...fiber.Handler) *API {
+	return &API{
+		router: a.router.Group(prefix, handlers...),
+		prefix: a.prefix + prefix,
+		routes: a.routes,
+	}
+}
+
+// Route registers the handler with Serve for the method and path and records it for the OpenAPI document
+func Route[I Request, O Response](a *API, method, path string, h HandlerFunc[I, O], doc RouteDoc) {
+	a.router.Add(method, path, Serve(h))
+	*a.routes = append(*a.routes, route{
+		method:      method,
+		path:        a.prefix + path,
+		operationId: funcName(h),
+		request:     reflect.TypeOf((*I)(nil)).Elem(),
+		response:    reflect.TypeOf((*O)(nil)).Elem(),
+		doc:         doc,
+	})
+}
+
+// funcName returns the name of the function without its package and receiver, e.g. `CreateUser`
+func funcName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// OpenAPI generates the OpenAPI document of the registered routes.
+//
+// Request fields are read from path parameters, `query` tagged fields from the query string and the rest from json body
+// as Serve does. Responses are wrapped by APIResponse of fiber_middleware.ResponseMiddleware and errors are its Problem.
+func (a *API) OpenAPI(info openapi.Info) *openapi.Document {
+	gen := openapi.NewGenerator()
+	envelope := gen.Schema(reflect.TypeOf(fiber_middleware.APIResponse{}))
+	problem := gen.Schema(reflect.TypeOf(fiber_middleware.Problem{}))
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    info,
+		Paths:   map[string]*openapi.PathItem{},
+	}
+	for _, r := range *a.routes {
+		path := pathParamRegex.ReplaceAllString(r.path, "{$1}")
+		if path == "" {
+			path = "/"
+		}
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		if op := item.Operation(r.method); op != nil {
+			*op = r.operation(gen, envelope, problem)
+		}
+	}
+	doc.Components.Schemas = gen.Components()
+	return doc
+}
+
+// operation returns OpenAPI operation of the route
+func (r route) operation(gen *openapi.Generator, envelope, problem *openapi.Schema) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationId: r.operationId,
+		Summary:     r.doc.Summary,
+		Description: r.doc.Description,
+		Tags:        r.doc.Tags,
+		Responses:   map[string]*openapi.Response{},
+	}
+
+	pathParams := map[string]bool{}
+	for _, m := range pathParamRegex.FindAllStringSubmatch(r.path, -1) {
+		pathParams[strings.ToLower(m[1])] = true
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "string"},
+		})
+	}
+	for _, f := range openapi.Fields(r.request) {
+		if name := f.Tag.Get("query"); name != "" {
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:   name,
+				In:     "query",
+				Schema: gen.Schema(f.Type),
+			})
+		}
+	}
+
+	if r.doc.Idempotent {
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:        fiber_middleware.HeaderIdempotencyKey,
+			In:          "header",
+			Description: "Unique key of the request, at most 255 characters. The first response of the key is replayed for retries with the same body.",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+	}
+
+	if r.method != fiber.MethodGet && r.method != fiber.MethodDelete {
+		body := gen.Object(r.request, func(f openapi.Field) bool {
+			return pathParams[strings.ToLower(f.Name)] || f.Tag.Get("query") != ""
+		})
+		if len(body.Properties) > 0 {
+			op.RequestBody = &openapi.RequestBody{
+				Content: map[string]*openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: body}},
+			}
+		}
+	}
+
+	successStatus := r.doc.SuccessStatus
+	if successStatus == 0 {
+		successStatus = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(successStatus)] = &openapi.Response{
+		Description: http.StatusText(successStatus),
+		Content: map[string]*openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: &openapi.Schema{
+			AllOf: []*openapi.Schema{envelope, {
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"data": gen.Schema(r.response)},
+			}},
+		}}},
+	}
+
+	errorStatuses := r.doc.ErrorStatuses
+	if len(errorStatuses) == 0 {
+		errorStatuses = defaultErrorStatuses
+	}
+	for _, status := range errorStatuses {
+		op.Responses[strconv.Itoa(status)] = &openapi.Response{
+			Description: http.StatusText(status),
+			Content:     map[string]*openapi.MediaType{fiber_middleware.MIMEApplicationProblemJSON: {Schema: problem}},
+		}
+	}
+	return op
+}
@@ -0,0 +1,28 @@
+package country
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/nsaltun/userapi/pkg/lib/country"
+)
+
+// CountryHandler is an interface for http handler methods for country metadata
+type CountryHandler interface {
+	ListCountries(ctx context.Context, req *ListCountriesRequest) (*ListCountriesResponse, int, error)
+}
+
+// Implementor of country handler
+type countryHandler struct{}
+
+// NewCountryHandler returns an instance of country handler to be able to use it in http router
+func NewCountryHandler() CountryHandler {
+	return &countryHandler{}
+}
+
+// ListCountries returns ISO 3166-1 countries ordered by alpha-2 code with 200 http status code.
+//
+// `code` of a country is the value which `country` fields of users accept.
+func (h *countryHandler) ListCountries(ctx context.Context, req *ListCountriesRequest) (*ListCountriesResponse, int, error) {
+	return &ListCountriesResponse{country.All()}, http.StatusOK, nil
+}
@@ -0,0 +1,19 @@
+package country
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nsaltun/userapi/pkg/lib/country"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCountries(t *testing.T) {
+	resp, status, err := NewCountryHandler().ListCountries(context.Background(), &ListCountriesRequest{})
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, resp.Items, 249)
+	require.Equal(t, country.Country{Code: "AD", Alpha3: "AND", Numeric: "020", Name: "Andorra"}, resp.Items[0])
+}
@@ -0,0 +1,11 @@
+package country
+
+import (
+	"github.com/nsaltun/userapi/pkg/lib/country"
+)
+
+type ListCountriesRequest struct{}
+
+type ListCountriesResponse struct {
+	Items []country.Country `json:"items"`
+}
@@ -0,0 +1,5 @@
+package country
+
+func (req ListCountriesRequest) Validate() error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package gql
+
+import (
+	"net/http"
+
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// Error codes of GraphQL errors in `extensions.code`
+const (
+	CodeBadUserInput        = "BAD_USER_INPUT"
+	CodeUnauthenticated     = "UNAUTHENTICATED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeNotFound            = "NOT_FOUND"
+	CodeConflict            = "CONFLICT"
+	CodeInternalServerError = "INTERNAL_SERVER_ERROR"
+	CodeQueryTooComplex     = "QUERY_TOO_COMPLEX"
+)
+
+// httpToErrorCodes maps http codes of errwrap.IError to GraphQL error codes
+var httpToErrorCodes = map[int]string{
+	http.StatusBadRequest:   CodeBadUserInput,
+	http.StatusUnauthorized: CodeUnauthenticated,
+	http.StatusForbidden:    CodeForbidden,
+	http.StatusNotFound:     CodeNotFound,
+	http.StatusConflict:     CodeConflict,
+}
+
+// graphQLError is an error having `code`, `status` and `errorCode` in extensions of GraphQL error.
+//
+// code is the category of the error whereas errorCode is the code of errwrap catalog.
+type graphQLError struct {
+	message   string
+	code      string
+	httpCode  int
+	errorCode string
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+// Extensions implements gqlerrors.ExtendedError
+func (e *graphQLError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code":   e.code,
+		"status": e.httpCode,
+	}
+	if e.errorCode != "" {
+		extensions["errorCode"] = e.errorCode
+	}
+	return extensions
+}
+
+// toGraphQLError converts the error into GraphQL error.
+//
+// Message of errwrap.IError is kept and its http code is mapped to error code. Internal errors are returned without
+// exposing their details, see errwrap.Public.
+func toGraphQLError(err error) error {
+	iErr := errwrap.Public(err)
+	code, ok := httpToErrorCodes[iErr.HttpCode()]
+	if !ok {
+		code = CodeInternalServerError
+	}
+	return &graphQLError{
+		message:   iErr.ErrorResp().Message,
+		code:      code,
+		httpCode:  iErr.HttpCode(),
+		errorCode: iErr.ErrorResp().Code,
+	}
+}
@@ -0,0 +1,100 @@
+package gql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/nsaltun/userapi/internal/service"
+	"github.com/spf13/viper"
+)
+
+const (
+	// Path is the path GraphQL endpoint is served on
+	Path = "/graphql"
+	// DefaultMaxDepth is the default maximum depth of operations. Introspection queries of GraphQL tools are 13 deep.
+	DefaultMaxDepth = 15
+	// DefaultMaxComplexity is the default maximum complexity of operations
+	DefaultMaxComplexity = 1000
+)
+
+// GraphQLHandler is an interface for GraphQL endpoint of users
+type GraphQLHandler interface {
+	Query(c *fiber.Ctx) error
+}
+
+// Request is the body of GraphQL requests
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Implementor of GraphQL handler
+type graphQLHandler struct {
+	schema graphql.Schema
+	limits limits
+}
+
+// NewGraphQLHandler returns GraphQL handler over UserService.
+//
+// Operations deeper than `GRAPHQL_MAX_DEPTH` or more complex than `GRAPHQL_MAX_COMPLEXITY` are rejected, zero
+// disables the limit.
+func NewGraphQLHandler(userService service.UserService) (GraphQLHandler, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("GRAPHQL_MAX_DEPTH", DefaultMaxDepth)
+	vi.SetDefault("GRAPHQL_MAX_COMPLEXITY", DefaultMaxComplexity)
+
+	schema, err := newSchema(userService)
+	if err != nil {
+		return nil, err
+	}
+	return &graphQLHandler{
+		schema: schema,
+		limits: limits{
+			maxDepth:      vi.GetInt("GRAPHQL_MAX_DEPTH"),
+			maxComplexity: vi.GetInt("GRAPHQL_MAX_COMPLEXITY"),
+		},
+	}, nil
+}
+
+// Query executes the GraphQL request in body.
+//
+// Requests which can't be executed, i.e. invalid or too complex ones, are responded with http 400. Otherwise the
+// result is responded with http 200 even when resolvers fail, errors of resolvers are in `errors` of the result.
+func (h *graphQLHandler) Query(c *fiber.Ctx) error {
+	var req Request
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.reject(c, gqlerrors.NewFormattedError("request body is not a valid GraphQL request"))
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return h.reject(c, gqlerrors.FormatErrors(err)...)
+	}
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		return h.reject(c, validation.Errors...)
+	}
+	if err := h.limits.check(doc, req.OperationName, req.Variables); err != nil {
+		return h.reject(c, gqlerrors.FormatError(gqlerrors.NewError(err.Error(), nil, "", nil, nil, err)))
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       c.UserContext(),
+	})
+	return c.JSON(result)
+}
+
+// reject responds the errors with http 400 without executing the request
+func (h *graphQLHandler) reject(c *fiber.Ctx, errs ...gqlerrors.FormattedError) error {
+	return c.Status(http.StatusBadRequest).JSON(graphql.Result{Errors: errs})
+}
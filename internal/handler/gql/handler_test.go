@@ -0,0 +1,212 @@
+package gql
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	mocks "github.com/nsaltun/userapi/internal/mocks/service"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// response is the decoded GraphQL result
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// do executes the GraphQL request with a handler over the service
+func do(t *testing.T, svc *mocks.UserService, query string, variables map[string]interface{}) (int, response) {
+	t.Setenv("GRAPHQL_MAX_DEPTH", "5")
+	t.Setenv("GRAPHQL_MAX_COMPLEXITY", "100")
+	h, err := NewGraphQLHandler(svc)
+	require.NoError(t, err)
+	app := fiber.New()
+	app.Post(Path, h.Query)
+
+	body, err := json.Marshal(Request{Query: query, Variables: variables})
+	require.NoError(t, err)
+	req := httptest.NewRequest(fiber.MethodPost, Path, strings.NewReader(string(body)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var out response
+	require.NoError(t, json.Unmarshal(raw, &out))
+	return resp.StatusCode, out
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		setup     func(*mocks.UserService)
+		status    int
+		data      string
+		errCode   string
+		errMsg    string
+		// extensions are expected extensions of the error in addition to code
+		extensions map[string]interface{}
+	}{
+		{
+			name:  "users in one round trip",
+			query: `{ a: user(id: "1") { id nickName status } b: user(id: "2") { email } }`,
+			setup: func(s *mocks.UserService) {
+				s.On("GetUserById", mock.Anything, "1").Return(&model.User{Id: "1", NickName: "john", Status: model.UserStatus_Inactive}, nil).Once()
+				s.On("GetUserById", mock.Anything, "2").Return(&model.User{Id: "2", Email: "jane@doe.com"}, nil).Once()
+			},
+			status: http.StatusOK,
+			data:   `{"a":{"id":"1","nickName":"john","status":"INACTIVE"},"b":{"email":"jane@doe.com"}}`,
+		},
+		{
+			name:      "users by filter",
+			query:     `query($limit: Int) { users(filter: {country: "TR", status: ACTIVE}, limit: $limit, offset: 2) { totalRecords hasNext items { id } } }`,
+			variables: map[string]interface{}{"limit": 3},
+			setup: func(s *mocks.UserService) {
+				s.On("ListUsers", mock.Anything, model.UserFilter{Country: "TR", Status: model.UserStatus_Active}, 3, 2).
+					Return(&model.Pagination{TotalRecords: 10, Limit: 3, Offset: 2, HasNext: true, Items: []model.User{{Id: "3"}}}, nil).Once()
+			},
+			status: http.StatusOK,
+			data:   `{"users":{"totalRecords":10,"hasNext":true,"items":[{"id":"3"}]}}`,
+		},
+		{
+			name:  "users with default limit",
+			query: `{ users(limit: 0) { limit } }`,
+			setup: func(s *mocks.UserService) {
+				s.On("ListUsers", mock.Anything, model.UserFilter{}, 20, 0).Return(&model.Pagination{Limit: 20, Items: []model.User{}}, nil).Once()
+			},
+			status: http.StatusOK,
+			data:   `{"users":{"limit":20}}`,
+		},
+		{
+			name:  "create user",
+			query: `mutation { createUser(input: {firstName: "John", nickName: "john", email: "john@doe.com", country: "TR", password: "pwd"}) { id } }`,
+			setup: func(s *mocks.UserService) {
+				s.On("CreateUser", mock.Anything, &model.User{FirstName: "John", NickName: "john", Email: "john@doe.com", Country: "TR", Password: "pwd"}).
+					Return(&model.User{Id: "1"}, nil).Once()
+			},
+			status: http.StatusOK,
+			data:   `{"createUser":{"id":"1"}}`,
+		},
+		{
+			name:    "create user with empty field",
+			query:   `mutation { createUser(input: {firstName: "", nickName: "john", email: "john@doe.com", country: "TR"}) { id } }`,
+			setup:   func(s *mocks.UserService) {},
+			status:  http.StatusOK,
+			errCode: CodeBadUserInput,
+			errMsg:  "firstName can't be empty",
+		},
+		{
+			name:  "update provided fields",
+			query: `mutation { updateUser(id: "1", input: {lastName: "Doe", status: INACTIVE}) { lastName } }`,
+			setup: func(s *mocks.UserService) {
+				current := &model.User{Id: "1", FirstName: "John", NickName: "john", Email: "john@doe.com", Country: "TR", Status: model.UserStatus_Active}
+				s.On("GetUserById", mock.Anything, "1").Return(current, nil).Once()
+				s.On("UpdateUserById", mock.Anything, "1", model.User{
+					Id: "1", FirstName: "John", LastName: "Doe", NickName: "john", Email: "john@doe.com", Country: "TR", Status: model.UserStatus_Inactive,
+				}).Return(&model.User{Id: "1", LastName: "Doe"}, nil).Once()
+			},
+			status: http.StatusOK,
+			data:   `{"updateUser":{"lastName":"Doe"}}`,
+		},
+		{
+			name:  "delete user",
+			query: `mutation { deleteUser(id: "1") }`,
+			setup: func(s *mocks.UserService) {
+				s.On("DeleteUserById", mock.Anything, "1").Return(nil).Once()
+			},
+			status: http.StatusOK,
+			data:   `{"deleteUser":true}`,
+		},
+		{
+			name:  "not found",
+			query: `{ user(id: "1") { id } }`,
+			setup: func(s *mocks.UserService) {
+				s.On("GetUserById", mock.Anything, "1").Return(nil, model.ErrUserNotFound).Once()
+			},
+			status:     http.StatusOK,
+			errCode:    CodeNotFound,
+			errMsg:     "user not found",
+			extensions: map[string]interface{}{"errorCode": "USER_NOT_FOUND"},
+		},
+		{
+			name:  "unexpected error is not exposed",
+			query: `{ user(id: "1") { id } }`,
+			setup: func(s *mocks.UserService) {
+				s.On("GetUserById", mock.Anything, "1").Return(nil, errors.New("connection refused")).Once()
+			},
+			status:  http.StatusOK,
+			errCode: CodeInternalServerError,
+			errMsg:  "internal server error",
+		},
+		{
+			name:    "too deep",
+			query:   `{ __schema { types { fields { type { fields { name } } } } } }`,
+			setup:   func(s *mocks.UserService) {},
+			status:  http.StatusBadRequest,
+			errCode: CodeQueryTooComplex,
+			errMsg:  "query depth 6 exceeds maximum depth 5",
+		},
+		{
+			name:    "too complex",
+			query:   `{ users(limit: 50) { items { id email } } }`,
+			setup:   func(s *mocks.UserService) {},
+			status:  http.StatusBadRequest,
+			errCode: CodeQueryTooComplex,
+			errMsg:  "query complexity 102 exceeds maximum complexity 100",
+		},
+		{
+			name:   "unknown field",
+			query:  `{ user(id: "1") { password } }`,
+			setup:  func(s *mocks.UserService) {},
+			status: http.StatusBadRequest,
+			errMsg: `Cannot query field "password" on type "User".`,
+		},
+		{
+			name:   "syntax error",
+			query:  `{ user(id: "1") {`,
+			setup:  func(s *mocks.UserService) {},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := mocks.NewUserService(t)
+			tt.setup(svc)
+
+			status, resp := do(t, svc, tt.query, tt.variables)
+			require.Equal(t, tt.status, status)
+			if tt.data != "" {
+				require.Empty(t, resp.Errors)
+				data, err := json.Marshal(resp.Data)
+				require.NoError(t, err)
+				require.JSONEq(t, tt.data, string(data))
+				return
+			}
+			require.NotEmpty(t, resp.Errors)
+			if tt.errMsg != "" {
+				require.Equal(t, tt.errMsg, resp.Errors[0].Message)
+			}
+			if tt.errCode != "" {
+				require.Equal(t, tt.errCode, resp.Errors[0].Extensions["code"])
+			}
+			for key, value := range tt.extensions {
+				require.Equal(t, value, resp.Errors[0].Extensions[key])
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package gql
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/nsaltun/userapi/internal/handler/user"
+)
+
+// cost is depth and complexity of a selection set
+type cost struct {
+	depth      int
+	complexity int
+}
+
+// limits rejects operations which are deeper or more complex than configured maximums before they are executed.
+//
+// Every field costs 1. `items` of `users` costs `limit` times its selection since that many users can be returned.
+type limits struct {
+	maxDepth      int
+	maxComplexity int
+}
+
+// check analyzes the operation to be executed. The document should be validated beforehand so that fragments exist
+// and don't have cycles.
+func (l limits) check(doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	c := analyze(doc, operationName, variables)
+	if l.maxDepth > 0 && c.depth > l.maxDepth {
+		return &graphQLError{
+			message:  fmt.Sprintf("query depth %d exceeds maximum depth %d", c.depth, l.maxDepth),
+			code:     CodeQueryTooComplex,
+			httpCode: http.StatusBadRequest,
+		}
+	}
+	if l.maxComplexity > 0 && c.complexity > l.maxComplexity {
+		return &graphQLError{
+			message:  fmt.Sprintf("query complexity %d exceeds maximum complexity %d", c.complexity, l.maxComplexity),
+			code:     CodeQueryTooComplex,
+			httpCode: http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// analyze returns cost of the operation. Unknown operations cost nothing since the executor reports them.
+func analyze(doc *ast.Document, operationName string, variables map[string]interface{}) cost {
+	var operation *ast.OperationDefinition
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range doc.Definitions {
+		switch def := definition.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		}
+	}
+	if operation == nil {
+		return cost{}
+	}
+	return analyzer{fragments: fragments, variables: variables}.selectionSet(operation.SelectionSet, 0)
+}
+
+type analyzer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// selectionSet returns cost of the selection set. Cost of `items` field is multiplied by itemsMultiplier when it is
+// selected from users page.
+func (a analyzer) selectionSet(set *ast.SelectionSet, itemsMultiplier int) cost {
+	var total cost
+	if set == nil {
+		return total
+	}
+	for _, selection := range set.Selections {
+		var c cost
+		switch s := selection.(type) {
+		case *ast.Field:
+			c = a.field(s)
+			if itemsMultiplier > 0 && s.Name.Value == "items" {
+				c.complexity = 1 + (c.complexity-1)*itemsMultiplier
+			}
+		case *ast.InlineFragment:
+			c = a.selectionSet(s.SelectionSet, itemsMultiplier)
+		case *ast.FragmentSpread:
+			if fragment, ok := a.fragments[s.Name.Value]; ok {
+				c = a.selectionSet(fragment.SelectionSet, itemsMultiplier)
+			}
+		}
+		total.depth = max(total.depth, c.depth)
+		total.complexity += c.complexity
+	}
+	return total
+}
+
+func (a analyzer) field(field *ast.Field) cost {
+	itemsMultiplier := 0
+	if field.Name.Value == "users" {
+		itemsMultiplier = a.intArgument(field, "limit", user.DefaultLimit)
+	}
+	c := a.selectionSet(field.SelectionSet, itemsMultiplier)
+	return cost{depth: c.depth + 1, complexity: c.complexity + 1}
+}
+
+// intArgument returns value of the int argument given as literal or variable. fallback is returned when it isn't
+// provided or is zero.
+func (a analyzer) intArgument(field *ast.Field, name string, fallback int) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != name {
+			continue
+		}
+		value := 0
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			value, _ = strconv.Atoi(v.Value)
+		case *ast.Variable:
+			// variables are decoded from json
+			switch n := a.variables[v.Name.Value].(type) {
+			case float64:
+				value = int(n)
+			case int:
+				value = n
+			}
+		}
+		if value > 0 {
+			return value
+		}
+	}
+	return fallback
+}
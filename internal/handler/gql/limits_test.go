@@ -0,0 +1,75 @@
+package gql
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		variables  map[string]interface{}
+		depth      int
+		complexity int
+	}{
+		{
+			name:       "fields",
+			query:      `{ user(id: "1") { id email } }`,
+			depth:      2,
+			complexity: 3,
+		},
+		{
+			name:       "items are multiplied by limit",
+			query:      `{ users(limit: 5) { totalRecords items { id email } } }`,
+			depth:      3,
+			complexity: 1 + 1 + (1 + 2*5),
+		},
+		{
+			name:       "items are multiplied by default limit",
+			query:      `{ users { items { id } } }`,
+			depth:      3,
+			complexity: 1 + (1 + 20),
+		},
+		{
+			name:       "limit from variable",
+			query:      `query($n: Int) { users(limit: $n) { items { id } } }`,
+			variables:  map[string]interface{}{"n": float64(3)},
+			depth:      3,
+			complexity: 1 + (1 + 3),
+		},
+		{
+			name: "fragments",
+			query: `{ users(limit: 2) { ...page } }
+				fragment page on UserPage { items { ... on User { id nickName } } }`,
+			depth:      3,
+			complexity: 1 + (1 + 2*2),
+		},
+		{
+			name:       "items of other fields are not multiplied",
+			query:      `{ user(id: "1") { id } }`,
+			depth:      2,
+			complexity: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			require.NoError(t, err)
+
+			c := analyze(doc, "", tt.variables)
+			require.Equal(t, tt.depth, c.depth)
+			require.Equal(t, tt.complexity, c.complexity)
+		})
+	}
+}
+
+func TestDefaultLimitsAllowIntrospection(t *testing.T) {
+	doc, err := parser.Parse(parser.ParseParams{Source: testutil.IntrospectionQuery})
+	require.NoError(t, err)
+	require.NoError(t, limits{maxDepth: DefaultMaxDepth, maxComplexity: DefaultMaxComplexity}.check(doc, "", nil))
+}
@@ -0,0 +1,149 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/nsaltun/userapi/internal/handler"
+	"github.com/nsaltun/userapi/internal/handler/user"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/service"
+)
+
+// resolver resolves root fields by delegating to UserService. Requests are validated with the rules of REST handlers.
+type resolver struct {
+	userService service.UserService
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	found, err := r.userService.GetUserById(p.Context, p.Args["id"].(string))
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return toUserMap(found), nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (interface{}, error) {
+	req := user.ListUsersByFilterRequest{
+		Limit:      p.Args["limit"].(int),
+		Offset:     p.Args["offset"].(int),
+		UserFilter: &model.UserFilter{},
+	}
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		req.UserFilter = &model.UserFilter{
+			Id:        stringArg(filter, "id"),
+			FirstName: stringArg(filter, "firstName"),
+			LastName:  stringArg(filter, "lastName"),
+			NickName:  stringArg(filter, "nickName"),
+			Email:     stringArg(filter, "email"),
+			Country:   stringArg(filter, "country"),
+		}
+		if status, ok := filter["status"].(model.UserStatus); ok {
+			req.Status = status
+		}
+	}
+	if err := handler.Validate(&req); err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	page, err := r.userService.ListUsers(p.Context, *req.UserFilter, req.Limit, req.Offset)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	users, _ := page.Items.([]model.User)
+	items := make([]map[string]interface{}, 0, len(users))
+	for i := range users {
+		items = append(items, toUserMap(&users[i]))
+	}
+	return map[string]interface{}{
+		"totalRecords": page.TotalRecords,
+		"limit":        page.Limit,
+		"offset":       page.Offset,
+		"hasNext":      page.HasNext,
+		"hasPrevious":  page.HasPrevious,
+		"items":        items,
+	}, nil
+}
+
+func (r *resolver) createUser(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["input"].(map[string]interface{})
+	newUser := &model.User{
+		FirstName: stringArg(input, "firstName"),
+		LastName:  stringArg(input, "lastName"),
+		NickName:  stringArg(input, "nickName"),
+		Email:     stringArg(input, "email"),
+		Password:  stringArg(input, "password"),
+		Country:   stringArg(input, "country"),
+	}
+	if err := handler.Validate(&user.CreateUserRequest{User: newUser}); err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	created, err := r.userService.CreateUser(p.Context, newUser)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return toUserMap(created), nil
+}
+
+// updateUser updates provided fields of the user keeping the others
+func (r *resolver) updateUser(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(string)
+	current, err := r.userService.GetUserById(p.Context, id)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	input := p.Args["input"].(map[string]interface{})
+	fields := map[string]*string{
+		"firstName": &current.FirstName,
+		"lastName":  &current.LastName,
+		"nickName":  &current.NickName,
+		"email":     &current.Email,
+		"country":   &current.Country,
+	}
+	for name, field := range fields {
+		if value, ok := input[name].(string); ok {
+			*field = value
+		}
+	}
+	if status, ok := input["status"].(model.UserStatus); ok {
+		current.Status = status
+	}
+	if err := handler.Validate(&user.ProvisionUserRequest{User: current}); err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	updated, err := r.userService.UpdateUserById(p.Context, id, *current)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return toUserMap(updated), nil
+}
+
+func (r *resolver) deleteUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := r.userService.DeleteUserById(p.Context, p.Args["id"].(string)); err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return true, nil
+}
+
+// stringArg returns the string argument or empty string when it isn't provided
+func stringArg(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+// toUserMap converts the user into a map resolved by field names. Password is never included.
+func toUserMap(u *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        u.Id,
+		"firstName": u.FirstName,
+		"lastName":  u.LastName,
+		"nickName":  u.NickName,
+		"email":     u.Email,
+		"country":   u.Country,
+		"status":    u.Status,
+		"createdAt": u.CreatedAt,
+		"updatedAt": u.UpdatedAt,
+		"version":   u.Version,
+	}
+}
@@ -0,0 +1,143 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/nsaltun/userapi/internal/handler/user"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/service"
+)
+
+var userStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserStatus",
+	Values: graphql.EnumValueConfigMap{
+		"ACTIVE":               {Value: model.UserStatus_Active},
+		"INACTIVE":             {Value: model.UserStatus_Inactive},
+		"PENDING_VERIFICATION": {Value: model.UserStatus_PendingVerification},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id":        {Type: graphql.NewNonNull(graphql.ID)},
+		"firstName": {Type: graphql.NewNonNull(graphql.String)},
+		"lastName":  {Type: graphql.NewNonNull(graphql.String)},
+		"nickName":  {Type: graphql.NewNonNull(graphql.String)},
+		"email":     {Type: graphql.NewNonNull(graphql.String)},
+		"country":   {Type: graphql.NewNonNull(graphql.String)},
+		"status":    {Type: graphql.NewNonNull(userStatusEnum)},
+		"createdAt": {Type: graphql.NewNonNull(graphql.DateTime)},
+		"updatedAt": {Type: graphql.NewNonNull(graphql.DateTime)},
+		"version":   {Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var userPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "UserPage",
+	Description: "Paginated users",
+	Fields: graphql.Fields{
+		"totalRecords": {Type: graphql.NewNonNull(graphql.Int)},
+		"limit":        {Type: graphql.NewNonNull(graphql.Int)},
+		"offset":       {Type: graphql.NewNonNull(graphql.Int)},
+		"hasNext":      {Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPrevious":  {Type: graphql.NewNonNull(graphql.Boolean)},
+		"items":        {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+	},
+})
+
+var userFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "UserFilterInput",
+	Description: "firstName and lastName match by case insensitive prefix, the rest match exactly. Only active users are matched when status is not set.",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"id":        {Type: graphql.ID},
+		"firstName": {Type: graphql.String},
+		"lastName":  {Type: graphql.String},
+		"nickName":  {Type: graphql.String},
+		"email":     {Type: graphql.String},
+		"country":   {Type: graphql.String},
+		"status":    {Type: userStatusEnum},
+	},
+})
+
+var createUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CreateUserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName": {Type: graphql.NewNonNull(graphql.String)},
+		"lastName":  {Type: graphql.String},
+		"nickName":  {Type: graphql.NewNonNull(graphql.String)},
+		"email":     {Type: graphql.NewNonNull(graphql.String)},
+		"password":  {Type: graphql.String},
+		"country":   {Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var updateUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "UpdateUserInput",
+	Description: "Fields which are not provided are kept as they are.",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName": {Type: graphql.String},
+		"lastName":  {Type: graphql.String},
+		"nickName":  {Type: graphql.String},
+		"email":     {Type: graphql.String},
+		"country":   {Type: graphql.String},
+		"status":    {Type: userStatusEnum},
+	},
+})
+
+// newSchema returns the GraphQL schema whose resolvers delegate to UserService
+func newSchema(userService service.UserService) (graphql.Schema, error) {
+	r := &resolver{userService}
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"user": {
+					Type:        userType,
+					Description: "User by id regardless of its status",
+					Args: graphql.FieldConfigArgument{
+						"id": {Type: graphql.NewNonNull(graphql.ID)},
+					},
+					Resolve: r.user,
+				},
+				"users": {
+					Type:        graphql.NewNonNull(userPageType),
+					Description: "Users by filter with pagination",
+					Args: graphql.FieldConfigArgument{
+						"filter": {Type: userFilterInput},
+						"limit":  {Type: graphql.Int, DefaultValue: user.DefaultLimit},
+						"offset": {Type: graphql.Int, DefaultValue: 0},
+					},
+					Resolve: r.users,
+				},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"createUser": {
+					Type: graphql.NewNonNull(userType),
+					Args: graphql.FieldConfigArgument{
+						"input": {Type: graphql.NewNonNull(createUserInput)},
+					},
+					Resolve: r.createUser,
+				},
+				"updateUser": {
+					Type: graphql.NewNonNull(userType),
+					Args: graphql.FieldConfigArgument{
+						"id":    {Type: graphql.NewNonNull(graphql.ID)},
+						"input": {Type: graphql.NewNonNull(updateUserInput)},
+					},
+					Resolve: r.updateUser,
+				},
+				"deleteUser": {
+					Type:        graphql.NewNonNull(graphql.Boolean),
+					Description: "Deactivates the user",
+					Args: graphql.FieldConfigArgument{
+						"id": {Type: graphql.NewNonNull(graphql.ID)},
+					},
+					Resolve: r.deleteUser,
+				},
+			},
+		}),
+	})
+}
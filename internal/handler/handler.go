@@ -3,13 +3,14 @@ package handler
 import (
 	"context"
 	"errors"
-	"net/http"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nsaltun/userapi/pkg/lib/errwrap"
 	"github.com/nsaltun/userapi/pkg/lib/middleware"
+	"github.com/nsaltun/userapi/pkg/lib/validation"
 )
 
+// Request is a request of a handler. Validate checks the rules which can't be declared with `validate` tags.
 type Request interface {
 	Validate() error
 }
@@ -36,8 +37,8 @@ func Serve[I Request, O Response](h HandlerFunc[I, O]) fiber.Handler {
 			return err
 		}
 
-		if err := req.Validate(); err != nil {
-			return fiber.NewError(http.StatusBadRequest, err.Error())
+		if err := Validate(&req); err != nil {
+			return errorRespWithMapping(err)
 		}
 
 		ctx := c.UserContext()
@@ -52,6 +53,14 @@ func Serve[I Request, O Response](h HandlerFunc[I, O]) fiber.Handler {
 	}
 }
 
+// Validate applies `default` tags of the request and validates it with its `validate` tags and its Validate method.
+func Validate[I Request](req *I) error {
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
+	return (*req).Validate()
+}
+
 // successResp is taking status and data as input and writing as json data into http response writer.
 func successResp(c *middleware.HttpContext, httpStatus int, data interface{}) error {
 	if data == nil {
@@ -65,11 +74,13 @@ func errorResp(c *middleware.HttpContext, httpstatus int, err errwrap.IError) er
 	return c.JSON(httpstatus, err.ErrorResp())
 }
 
-// errorResp is taking error as input and mapping it according to error code and writing as json data into http response writer.
-func errorRespWithMapping(err error) *fiber.Error {
-	iError, ok := err.(errwrap.IError)
-	if !ok {
-		return fiber.NewError(http.StatusInternalServerError, err.Error())
+// errorRespWithMapping is taking error as input and mapping it to an error which fiber_middleware.ResponseMiddleware
+// responds with its status code. errwrap.IError is kept as it is to keep its field errors, other errors are wrapped
+// by internal error to only log them.
+func errorRespWithMapping(err error) error {
+	var iError errwrap.IError
+	if !errors.As(err, &iError) || iError.HttpCode() == 0 {
+		return errwrap.ErrInternal.SetOriginError(err)
 	}
-	return fiber.NewError(iError.HttpCode(), iError.Error())
+	return err
 }
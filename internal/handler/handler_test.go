@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/middleware/fiber_middleware"
+	"github.com/stretchr/testify/require"
+)
+
+type listRequest struct {
+	Limit int    `query:"limit" default:"20" validate:"min=1"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (req listRequest) Validate() error {
+	if req.Email == "admin@example.com" {
+		return errwrap.NewValidationError(errwrap.FieldError{Field: "email", Code: errwrap.FieldCodeInvalid, Message: "email is reserved"})
+	}
+	return nil
+}
+
+type listResponse struct {
+	Limit int `json:"limit"`
+}
+
+func TestServeValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name:   "default is applied",
+			body:   `{"email":"john@doe.com"}`,
+			status: http.StatusOK,
+			want:   `{"status":"success","code":200,"data":{"limit":20}}`,
+		},
+		{
+			name:   "provided value is kept",
+			query:  "?limit=5",
+			body:   `{"email":"john@doe.com"}`,
+			status: http.StatusOK,
+			want:   `{"status":"success","code":200,"data":{"limit":5}}`,
+		},
+		{
+			name:   "tag rules",
+			query:  "?limit=-1",
+			body:   `{"email":"john"}`,
+			status: http.StatusBadRequest,
+			want: `{"type":"about:blank","title":"Bad Request","status":400,"instance":"/list","code":"VALIDATION_FAILED",
+				"detail":"limit must be at least 1; email must be a valid email address",
+				"errors":[{"field":"limit","code":"invalid","message":"limit must be at least 1"},
+					{"field":"email","code":"invalid","message":"email must be a valid email address"}]}`,
+		},
+		{
+			name:   "Validate method",
+			body:   `{"email":"admin@example.com"}`,
+			status: http.StatusBadRequest,
+			want: `{"type":"about:blank","title":"Bad Request","status":400,"instance":"/list","code":"VALIDATION_FAILED",
+				"detail":"email is reserved","errors":[{"field":"email","code":"invalid","message":"email is reserved"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/list", fiber_middleware.ResponseMiddleware(), Serve(func(ctx context.Context, req *listRequest) (*listResponse, int, error) {
+				return &listResponse{Limit: req.Limit}, http.StatusOK, nil
+			}))
+
+			req := httptest.NewRequest(fiber.MethodPost, "/list"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.JSONEq(t, tt.want, string(body))
+		})
+	}
+}
+
+func TestValidateAppliesDefaults(t *testing.T) {
+	req := listRequest{Email: "john@doe.com"}
+	require.NoError(t, Validate(&req))
+	require.Equal(t, 20, req.Limit)
+}
@@ -0,0 +1,137 @@
+package scim
+
+// Supported is a feature flag of ServiceProviderConfig
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+type BulkConfig struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type FilterConfig struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+// ServiceProviderConfig describes supported SCIM features
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkConfig             `json:"bulk"`
+	Filter                FilterConfig           `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	Etag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  *Meta                  `json:"meta,omitempty"`
+}
+
+// Schema describes attributes of a resource
+type Schema struct {
+	Schemas     []string          `json:"schemas"`
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  []SchemaAttribute `json:"attributes"`
+	Meta        *Meta             `json:"meta,omitempty"`
+}
+
+type SchemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Description   string            `json:"description,omitempty"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []SchemaAttribute `json:"subAttributes,omitempty"`
+}
+
+// stringAttribute returns a single valued, read-write string attribute
+func stringAttribute(name, description string, required bool) SchemaAttribute {
+	return SchemaAttribute{
+		Name:        name,
+		Type:        "string",
+		Description: description,
+		Required:    required,
+		Mutability:  "readWrite",
+		Returned:    "default",
+		Uniqueness:  "none",
+	}
+}
+
+// userSchema returns the schema of supported attributes of the core user
+func userSchema() Schema {
+	userName := stringAttribute("userName", "Unique nickname of the user.", true)
+	userName.Uniqueness = "server"
+
+	givenName := stringAttribute("givenName", "First name of the user.", true)
+	formatted := stringAttribute("formatted", "Full name of the user.", false)
+	formatted.Mutability = "readOnly"
+
+	emailValue := stringAttribute("value", "Unique email address of the user.", true)
+	emailValue.Uniqueness = "server"
+
+	country := stringAttribute("country", "ISO 3166-1 alpha-2 country code.", true)
+
+	id := stringAttribute("id", "Unique identifier of the user.", false)
+	id.CaseExact, id.Mutability, id.Returned, id.Uniqueness = true, "readOnly", "always", "server"
+
+	password := stringAttribute("password", "Password of the user. It can only be set on creation.", false)
+	password.Mutability, password.Returned = "writeOnly", "never"
+
+	return Schema{
+		Schemas:     []string{SchemaSchema},
+		Id:          UserSchema,
+		Name:        "User",
+		Description: "User Account. Only one email and address are stored, the primary one is used when multiple are provided.",
+		Attributes: []SchemaAttribute{
+			id,
+			userName,
+			{
+				Name: "name", Type: "complex", Description: "Name of the user.", Required: true,
+				Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+				SubAttributes: []SchemaAttribute{
+					formatted,
+					givenName,
+					stringAttribute("familyName", "Last name of the user.", false),
+				},
+			},
+			{
+				Name: "emails", Type: "complex", MultiValued: true, Description: "Email address of the user.", Required: true,
+				Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+				SubAttributes: []SchemaAttribute{
+					emailValue,
+					stringAttribute("type", "Type of the email, e.g. work.", false),
+					{Name: "primary", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"},
+				},
+			},
+			{
+				Name: "addresses", Type: "complex", MultiValued: true, Description: "Address of the user. Only country is stored.", Required: true,
+				Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+				SubAttributes: []SchemaAttribute{
+					country,
+					stringAttribute("type", "Type of the address, e.g. work.", false),
+					{Name: "primary", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"},
+				},
+			},
+			{
+				Name: "active", Type: "boolean", Description: "Inactive users are deactivated.",
+				Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+			},
+			password,
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package scim
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// scimType values of SCIM errors
+const (
+	ScimTypeInvalidFilter = "invalidFilter"
+	ScimTypeInvalidSyntax = "invalidSyntax"
+	ScimTypeInvalidPath   = "invalidPath"
+	ScimTypeInvalidValue  = "invalidValue"
+	ScimTypeMutability    = "mutability"
+	ScimTypeUniqueness    = "uniqueness"
+)
+
+// Error is an error with SCIM status and scimType
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// badRequest returns a http 400 SCIM error
+func badRequest(scimType, detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: detail}
+}
+
+// ErrorMiddleware writes errors of SCIM handlers as SCIM error responses.
+//
+// errwrap.IError is written with its http code and message. Other errors are written as internal errors
+// without exposing their details.
+func ErrorMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if err == nil {
+			return nil
+		}
+
+		resp := toErrorResponse(err)
+		if resp.Status == strconv.Itoa(http.StatusInternalServerError) {
+			slog.ErrorContext(c.UserContext(), "scim request failed", slog.Any("error", err), slog.String("path", c.Path()))
+		}
+		status, _ := strconv.Atoi(resp.Status)
+		return c.Status(status).JSON(resp, MediaType)
+	}
+}
+
+// toErrorResponse converts the error into SCIM error body
+func toErrorResponse(err error) ErrorResponse {
+	resp := ErrorResponse{Schemas: []string{ErrorSchema}}
+
+	var scimErr *Error
+	var iErr errwrap.IError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &scimErr):
+		resp.Status = strconv.Itoa(scimErr.Status)
+		resp.ScimType = scimErr.ScimType
+		resp.Detail = scimErr.Detail
+	case errors.As(err, &iErr) && iErr.HttpCode() != 0:
+		iErr = errwrap.Public(iErr)
+		resp.Status = strconv.Itoa(iErr.HttpCode())
+		resp.Detail = iErr.ErrorResp().Message
+		switch iErr.HttpCode() {
+		case http.StatusConflict:
+			resp.ScimType = ScimTypeUniqueness
+		case http.StatusBadRequest:
+			resp.ScimType = ScimTypeInvalidValue
+		}
+	case errors.As(err, &fiberErr):
+		resp.Status = strconv.Itoa(fiberErr.Code)
+		resp.Detail = fiberErr.Message
+	default:
+		resp.Status = strconv.Itoa(http.StatusInternalServerError)
+		resp.Detail = errwrap.ErrInternal.ErrorResp().Message
+	}
+	return resp
+}
@@ -0,0 +1,147 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nsaltun/userapi/internal/model"
+)
+
+// parseFilter translates a SCIM filter expression into a repository filter.
+//
+// Supported expressions are comparisons joined by `and`:
+//
+//	id eq "..."
+//	userName eq "..."
+//	emails eq "..." | emails.value eq "..."
+//	addresses.country eq "..."
+//	name.givenName sw "..." | name.familyName sw "..."
+//	active eq true|false
+//
+// Users of any status are matched unless `active` is filtered. Other attributes, operators and `or`/`not`/grouping
+// return invalidFilter error.
+func parseFilter(filter string) (model.UserFilter, error) {
+	userFilter := model.UserFilter{Status: -1}
+	if strings.TrimSpace(filter) == "" {
+		return userFilter, nil
+	}
+
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return userFilter, err
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < len(tokens); i += 4 {
+		if len(tokens)-i < 3 {
+			return userFilter, badRequest(ScimTypeInvalidFilter, "incomplete filter expression")
+		}
+		attr, op, value := strings.ToLower(tokens[i]), strings.ToLower(tokens[i+1]), tokens[i+2]
+		if i+3 < len(tokens) && !strings.EqualFold(tokens[i+3], "and") {
+			return userFilter, badRequest(ScimTypeInvalidFilter, fmt.Sprintf("unsupported logical operator %q, only `and` is supported", tokens[i+3]))
+		}
+		if i+3 == len(tokens)-1 {
+			return userFilter, badRequest(ScimTypeInvalidFilter, "incomplete filter expression")
+		}
+		if attr == "emails.value" {
+			attr = "emails"
+		}
+		if seen[attr] {
+			return userFilter, badRequest(ScimTypeInvalidFilter, fmt.Sprintf("attribute %q is filtered more than once", tokens[i]))
+		}
+		seen[attr] = true
+
+		if err := applyComparison(&userFilter, attr, op, value); err != nil {
+			return userFilter, err
+		}
+	}
+	return userFilter, nil
+}
+
+// applyComparison sets the field of the filter for a single comparison
+func applyComparison(f *model.UserFilter, attr, op, value string) error {
+	// supported operator by attribute
+	ops := map[string]string{
+		"id":                "eq",
+		"username":          "eq",
+		"emails":            "eq",
+		"addresses.country": "eq",
+		"name.givenname":    "sw",
+		"name.familyname":   "sw",
+		"active":            "eq",
+	}
+	supportedOp, ok := ops[attr]
+	if !ok {
+		return badRequest(ScimTypeInvalidFilter, fmt.Sprintf("filtering by %q is not supported", attr))
+	}
+	if op != supportedOp {
+		return badRequest(ScimTypeInvalidFilter, fmt.Sprintf("operator %q is not supported for %q, use %q", op, attr, supportedOp))
+	}
+
+	if attr == "active" {
+		switch strings.ToLower(value) {
+		case "true":
+			f.Status = model.UserStatus_Active
+		case "false":
+			f.Status = model.UserStatus_Inactive
+		default:
+			return badRequest(ScimTypeInvalidFilter, "active should be compared with true or false")
+		}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return badRequest(ScimTypeInvalidFilter, fmt.Sprintf("value of %q should be a string", attr))
+	}
+	switch attr {
+	case "id":
+		f.Id = s
+	case "username":
+		f.NickName = s
+	case "emails":
+		f.Email = s
+	case "addresses.country":
+		f.Country = s
+	case "name.givenname":
+		f.FirstName = s
+	case "name.familyname":
+		f.LastName = s
+	}
+	return nil
+}
+
+// tokenize splits the filter by spaces keeping quoted strings(with their quotes) as single tokens
+func tokenize(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		switch c := filter[i]; {
+		case c == ' ':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			return nil, badRequest(ScimTypeInvalidFilter, "grouping and value filters are not supported")
+		case c == '"':
+			end := i + 1
+			for end < len(filter) && filter[end] != '"' {
+				if filter[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(filter) {
+				return nil, badRequest(ScimTypeInvalidFilter, "unterminated string in filter")
+			}
+			tokens = append(tokens, filter[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(filter) && filter[end] != ' ' && filter[end] != '"' {
+				end++
+			}
+			tokens = append(tokens, filter[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
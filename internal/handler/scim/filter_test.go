@@ -0,0 +1,91 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		expected model.UserFilter
+		scimType string
+	}{
+		{
+			name:     "empty filter matches any status",
+			filter:   "",
+			expected: model.UserFilter{Status: -1},
+		},
+		{
+			name:     "userName eq",
+			filter:   `userName eq "johndoe"`,
+			expected: model.UserFilter{NickName: "johndoe", Status: -1},
+		},
+		{
+			name:     "case insensitive attributes and operators joined by and",
+			filter:   `USERNAME EQ "john doe" AND emails.value eq "john@doe.com" and active eq false`,
+			expected: model.UserFilter{NickName: "john doe", Email: "john@doe.com", Status: model.UserStatus_Inactive},
+		},
+		{
+			name:     "name prefixes and country",
+			filter:   `name.givenName sw "Jo" and name.familyName sw "D" and addresses.country eq "TR" and id eq "1"`,
+			expected: model.UserFilter{Id: "1", FirstName: "Jo", LastName: "D", Country: "TR", Status: -1},
+		},
+		{
+			name:     "escaped quote in value",
+			filter:   `userName eq "john\"doe"`,
+			expected: model.UserFilter{NickName: `john"doe`, Status: -1},
+		},
+		{
+			name:     "unsupported attribute",
+			filter:   `externalId eq "x"`,
+			scimType: ScimTypeInvalidFilter,
+		},
+		{
+			name:     "unsupported operator",
+			filter:   `userName co "john"`,
+			scimType: ScimTypeInvalidFilter,
+		},
+		{
+			name:     "or is not supported",
+			filter:   `userName eq "a" or userName eq "b"`,
+			scimType: ScimTypeInvalidFilter,
+		},
+		{
+			name:     "grouping is not supported",
+			filter:   `(userName eq "a")`,
+			scimType: ScimTypeInvalidFilter,
+		},
+		{
+			name:     "incomplete expression",
+			filter:   `userName eq "a" and`,
+			scimType: ScimTypeInvalidFilter,
+		},
+		{
+			name:     "unterminated string",
+			filter:   `userName eq "a`,
+			scimType: ScimTypeInvalidFilter,
+		},
+		{
+			name:     "non boolean active",
+			filter:   `active eq "yes"`,
+			scimType: ScimTypeInvalidFilter,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			filter, err := parseFilter(tCase.filter)
+			if tCase.scimType == "" {
+				require.NoError(tt, err)
+				require.Equal(tt, tCase.expected, filter)
+				return
+			}
+			var scimErr *Error
+			require.ErrorAs(tt, err, &scimErr)
+			require.Equal(tt, tCase.scimType, scimErr.ScimType)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/internal/handler"
+	"github.com/nsaltun/userapi/internal/handler/user"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/service"
+	"github.com/spf13/viper"
+)
+
+// BasePath is the path SCIM endpoints are served under
+const BasePath = "/scim/v2"
+
+// ScimHandler is an interface for SCIM 2.0 endpoints of users and service discovery
+type ScimHandler interface {
+	Authenticate(c *fiber.Ctx) error
+	ListUsers(c *fiber.Ctx) error
+	GetUser(c *fiber.Ctx) error
+	CreateUser(c *fiber.Ctx) error
+	ReplaceUser(c *fiber.Ctx) error
+	PatchUser(c *fiber.Ctx) error
+	DeleteUser(c *fiber.Ctx) error
+	ServiceProviderConfig(c *fiber.Ctx) error
+	Schemas(c *fiber.Ctx) error
+	GetSchema(c *fiber.Ctx) error
+}
+
+// Implementor of SCIM handler
+type scimHandler struct {
+	userService service.UserService
+	bearerToken string
+}
+
+// NewScimHandler returns SCIM handler of users.
+//
+// Requests should have `Authorization: Bearer <SCIM_BEARER_TOKEN>` header when `SCIM_BEARER_TOKEN` is set.
+func NewScimHandler(userService service.UserService) ScimHandler {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	token := vi.GetString("SCIM_BEARER_TOKEN")
+	if token == "" {
+		slog.Warn("SCIM_BEARER_TOKEN is not set, SCIM endpoints are not authenticated")
+	}
+	return &scimHandler{userService: userService, bearerToken: token}
+}
+
+// Authenticate checks bearer token of the request when a token is configured
+func (h *scimHandler) Authenticate(c *fiber.Ctx) error {
+	if h.bearerToken == "" {
+		return c.Next()
+	}
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.bearerToken)) != 1 {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="scim"`)
+		return &Error{Status: http.StatusUnauthorized, Detail: "authorization failure"}
+	}
+	return c.Next()
+}
+
+// ListUsers lists users of any status matching `filter` with 1-based `startIndex` and `count` pagination.
+//
+// count is DefaultCount when not provided and can be MaxResults at most. count=0 returns only totalResults.
+func (h *scimHandler) ListUsers(c *fiber.Ctx) error {
+	filter, err := parseFilter(c.Query("filter"))
+	if err != nil {
+		return err
+	}
+
+	startIndex := max(c.QueryInt("startIndex", 1), 1)
+	count := min(max(c.QueryInt("count", DefaultCount), 0), MaxResults)
+	// repository can't be queried with zero limit, only total is used in that case
+	limit := max(count, 1)
+
+	page, err := h.userService.ListUsers(c.UserContext(), filter, limit, startIndex-1)
+	if err != nil {
+		return err
+	}
+
+	users, _ := page.Items.([]model.User)
+	resources := make([]User, 0, len(users))
+	for i := 0; i < len(users) && i < count; i++ {
+		resources = append(resources, toScimUser(&users[i], h.userLocation(c, users[i].Id)))
+	}
+	return c.JSON(ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: page.TotalRecords,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, MediaType)
+}
+
+// GetUser returns the user by id regardless of its status
+func (h *scimHandler) GetUser(c *fiber.Ctx) error {
+	found, err := h.userService.GetUserById(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(toScimUser(found, h.userLocation(c, found.Id)), MediaType)
+}
+
+// CreateUser creates the user and returns it with http 201. The user is deactivated right after creation when
+// `active` is false.
+func (h *scimHandler) CreateUser(c *fiber.Ctx) error {
+	var scimUser User
+	if err := json.Unmarshal(c.Body(), &scimUser); err != nil {
+		return badRequest(ScimTypeInvalidSyntax, "request body is not a valid SCIM user")
+	}
+	newUser := toModelUser(&scimUser)
+	if err := handler.Validate(&user.ProvisionUserRequest{User: newUser}); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	inactive := newUser.Status == model.UserStatus_Inactive
+	created, err := h.userService.ProvisionUser(ctx, newUser)
+	if err != nil {
+		return err
+	}
+	if inactive {
+		created.Status = model.UserStatus_Inactive
+		if created, err = h.userService.UpdateUserById(ctx, created.Id, *created); err != nil {
+			return err
+		}
+	}
+
+	location := h.userLocation(c, created.Id)
+	c.Location(location)
+	return c.Status(http.StatusCreated).JSON(toScimUser(created, location), MediaType)
+}
+
+// ReplaceUser replaces the user with the request body. Password can't be changed.
+func (h *scimHandler) ReplaceUser(c *fiber.Ctx) error {
+	var scimUser User
+	if err := json.Unmarshal(c.Body(), &scimUser); err != nil {
+		return badRequest(ScimTypeInvalidSyntax, "request body is not a valid SCIM user")
+	}
+	if scimUser.Password != "" {
+		return &Error{Status: http.StatusBadRequest, ScimType: ScimTypeMutability, Detail: "password can't be changed with SCIM"}
+	}
+
+	return h.update(c, toModelUser(&scimUser))
+}
+
+// PatchUser applies PATCH operations to the user. See applyPatch for supported paths.
+func (h *scimHandler) PatchUser(c *fiber.Ctx) error {
+	var patch PatchRequest
+	if err := json.Unmarshal(c.Body(), &patch); err != nil {
+		return badRequest(ScimTypeInvalidSyntax, "request body is not a valid SCIM patch request")
+	}
+	if len(patch.Operations) == 0 {
+		return badRequest(ScimTypeInvalidValue, "Operations can't be empty")
+	}
+
+	current, err := h.userService.GetUserById(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if err := applyPatch(current, patch.Operations); err != nil {
+		return err
+	}
+	return h.update(c, current)
+}
+
+// update validates and saves the user with the id in path and writes the updated user
+func (h *scimHandler) update(c *fiber.Ctx, updated *model.User) error {
+	if err := handler.Validate(&user.ProvisionUserRequest{User: updated}); err != nil {
+		return err
+	}
+
+	saved, err := h.userService.UpdateUserById(c.UserContext(), c.Params("id"), *updated)
+	if err != nil {
+		return err
+	}
+	return c.JSON(toScimUser(saved, h.userLocation(c, saved.Id)), MediaType)
+}
+
+// DeleteUser deactivates the user and returns http 204
+func (h *scimHandler) DeleteUser(c *fiber.Ctx) error {
+	if err := h.userService.DeleteUserById(c.UserContext(), c.Params("id")); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ServiceProviderConfig returns supported SCIM features
+func (h *scimHandler) ServiceProviderConfig(c *fiber.Ctx) error {
+	config := ServiceProviderConfig{
+		Schemas:               []string{ServiceProviderConfigSchema},
+		Patch:                 Supported{true},
+		Filter:                FilterConfig{Supported: true, MaxResults: MaxResults},
+		AuthenticationSchemes: []AuthenticationScheme{},
+		Meta: &Meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     c.BaseURL() + BasePath + "/ServiceProviderConfig",
+		},
+	}
+	if h.bearerToken != "" {
+		config.AuthenticationSchemes = append(config.AuthenticationSchemes, AuthenticationScheme{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with a static bearer token",
+			Primary:     true,
+		})
+	}
+	return c.JSON(config, MediaType)
+}
+
+// Schemas lists schemas of supported resources
+func (h *scimHandler) Schemas(c *fiber.Ctx) error {
+	schemas := []Schema{h.userSchema(c)}
+	return c.JSON(ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: int64(len(schemas)),
+		StartIndex:   1,
+		ItemsPerPage: len(schemas),
+		Resources:    schemas,
+	}, MediaType)
+}
+
+// GetSchema returns the schema by its URN
+func (h *scimHandler) GetSchema(c *fiber.Ctx) error {
+	id, err := url.PathUnescape(c.Params("id"))
+	if err != nil || id != UserSchema {
+		return &Error{Status: http.StatusNotFound, Detail: "schema not found"}
+	}
+	return c.JSON(h.userSchema(c), MediaType)
+}
+
+func (h *scimHandler) userSchema(c *fiber.Ctx) Schema {
+	schema := userSchema()
+	schema.Meta = &Meta{ResourceType: "Schema", Location: c.BaseURL() + BasePath + "/Schemas/" + UserSchema}
+	return schema
+}
+
+// userLocation returns url of the user resource
+func (h *scimHandler) userLocation(c *fiber.Ctx, id string) string {
+	return c.BaseURL() + BasePath + "/Users/" + id
+}
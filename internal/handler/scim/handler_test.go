@@ -0,0 +1,225 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	mocks "github.com/nsaltun/userapi/internal/mocks/service"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestApp returns an app serving SCIM endpoints of the handler like the router does
+func newTestApp(h ScimHandler) *fiber.App {
+	app := fiber.New()
+	api := app.Group(BasePath, ErrorMiddleware(), h.Authenticate)
+	api.Get("/Users", h.ListUsers)
+	api.Post("/Users", h.CreateUser)
+	api.Get("/Users/:id", h.GetUser)
+	api.Put("/Users/:id", h.ReplaceUser)
+	api.Patch("/Users/:id", h.PatchUser)
+	api.Delete("/Users/:id", h.DeleteUser)
+	api.Get("/ServiceProviderConfig", h.ServiceProviderConfig)
+	api.Get("/Schemas/:id", h.GetSchema)
+	return app
+}
+
+// do sends the request and decodes the response body into out
+func do(t *testing.T, app *fiber.App, method, target, body string, out interface{}) *http.Response {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, MediaType)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer token")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if out != nil && len(raw) > 0 {
+		require.NoError(t, json.Unmarshal(raw, out))
+	}
+	return resp
+}
+
+func TestCreateUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		setup    func(*mocks.UserService)
+		status   int
+		scimType string
+	}{
+		{
+			name: "created",
+			body: `{"schemas":["` + UserSchema + `"],"userName":"johndoe","name":{"givenName":"John"},
+				"emails":[{"value":"john@doe.com","primary":true}],"addresses":[{"country":"TR"}],"password":"pwd"}`,
+			setup: func(s *mocks.UserService) {
+				s.On("ProvisionUser", mock.Anything, &model.User{
+					FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Password: "pwd", Status: model.UserStatus_Active,
+				}).Return(&model.User{Id: "1", FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Status: model.UserStatus_Active}, nil).Once()
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "created inactive",
+			body: `{"userName":"johndoe","name":{"givenName":"John"},"emails":[{"value":"john@doe.com"}],"addresses":[{"country":"TR"}],"active":false}`,
+			setup: func(s *mocks.UserService) {
+				s.On("ProvisionUser", mock.Anything, mock.Anything).Return(&model.User{Id: "1", Status: model.UserStatus_Active}, nil).Once()
+				s.On("UpdateUserById", mock.Anything, "1", model.User{Id: "1", Status: model.UserStatus_Inactive}).
+					Return(&model.User{Id: "1", Status: model.UserStatus_Inactive}, nil).Once()
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:     "missing required attributes",
+			body:     `{"userName":"johndoe"}`,
+			setup:    func(s *mocks.UserService) {},
+			status:   http.StatusBadRequest,
+			scimType: ScimTypeInvalidValue,
+		},
+		{
+			name:     "invalid json",
+			body:     `{`,
+			setup:    func(s *mocks.UserService) {},
+			status:   http.StatusBadRequest,
+			scimType: ScimTypeInvalidSyntax,
+		},
+		{
+			name: "uniqueness",
+			body: `{"userName":"johndoe","name":{"givenName":"John"},"emails":[{"value":"john@doe.com"}],"addresses":[{"country":"TR"}]}`,
+			setup: func(s *mocks.UserService) {
+				s.On("ProvisionUser", mock.Anything, mock.Anything).Return(nil, errwrap.ErrConflict.SetMessage("already exists")).Once()
+			},
+			status:   http.StatusConflict,
+			scimType: ScimTypeUniqueness,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			svc := new(mocks.UserService)
+			tCase.setup(svc)
+			app := newTestApp(&scimHandler{userService: svc})
+
+			var body map[string]interface{}
+			resp := do(tt, app, fiber.MethodPost, BasePath+"/Users", tCase.body, &body)
+
+			require.Equal(tt, tCase.status, resp.StatusCode)
+			require.Equal(tt, MediaType, resp.Header.Get(fiber.HeaderContentType))
+			if tCase.scimType != "" {
+				require.Equal(tt, []interface{}{ErrorSchema}, body["schemas"])
+				require.Equal(tt, tCase.scimType, body["scimType"])
+			} else {
+				require.Equal(tt, "1", body["id"])
+				require.Equal(tt, "http://example.com"+BasePath+"/Users/1", resp.Header.Get(fiber.HeaderLocation))
+			}
+			svc.AssertExpectations(tt)
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	svc := new(mocks.UserService)
+	svc.On("ListUsers", mock.Anything, model.UserFilter{NickName: "johndoe", Status: -1}, 10, 4).Return(&model.Pagination{
+		TotalRecords: 6,
+		Items:        []model.User{{Id: "1", NickName: "johndoe"}},
+	}, nil).Once()
+	svc.On("ListUsers", mock.Anything, model.UserFilter{Status: -1}, 1, 0).Return(&model.Pagination{
+		TotalRecords: 6,
+		Items:        []model.User{{Id: "1"}},
+	}, nil).Once()
+	app := newTestApp(&scimHandler{userService: svc})
+
+	var list ListResponse
+	resp := do(t, app, fiber.MethodGet, BasePath+`/Users?filter=userName%20eq%20%22johndoe%22&startIndex=5&count=10`, "", &list)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(6), list.TotalResults)
+	require.Equal(t, 5, list.StartIndex)
+	require.Equal(t, 1, list.ItemsPerPage)
+
+	// count=0 returns only total results
+	resp = do(t, app, fiber.MethodGet, BasePath+`/Users?count=0`, "", &list)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(6), list.TotalResults)
+	require.Equal(t, 0, list.ItemsPerPage)
+
+	var scimErr ErrorResponse
+	resp = do(t, app, fiber.MethodGet, BasePath+`/Users?filter=title%20eq%20%22x%22`, "", &scimErr)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, ScimTypeInvalidFilter, scimErr.ScimType)
+	svc.AssertExpectations(t)
+}
+
+func TestPatchUser(t *testing.T) {
+	svc := new(mocks.UserService)
+	current := &model.User{Id: "1", FirstName: "John", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Status: model.UserStatus_Active}
+	svc.On("GetUserById", mock.Anything, "1").Return(current, nil).Once()
+	expected := *current
+	expected.Status = model.UserStatus_Inactive
+	svc.On("UpdateUserById", mock.Anything, "1", expected).Return(&expected, nil).Once()
+	svc.On("GetUserById", mock.Anything, "2").Return(nil, errwrap.ErrNotFound.SetMessage("record not found")).Once()
+	app := newTestApp(&scimHandler{userService: svc})
+
+	var user User
+	resp := do(t, app, fiber.MethodPatch, BasePath+"/Users/1",
+		`{"schemas":["`+PatchOpSchema+`"],"Operations":[{"op":"replace","path":"active","value":false}]}`, &user)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.False(t, *user.Active)
+
+	var scimErr ErrorResponse
+	resp = do(t, app, fiber.MethodPatch, BasePath+"/Users/2",
+		`{"Operations":[{"op":"replace","path":"active","value":false}]}`, &scimErr)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "404", scimErr.Status)
+	require.Equal(t, "record not found", scimErr.Detail)
+	svc.AssertExpectations(t)
+}
+
+func TestDeleteUser(t *testing.T) {
+	svc := new(mocks.UserService)
+	svc.On("DeleteUserById", mock.Anything, "1").Return(nil).Once()
+	svc.On("DeleteUserById", mock.Anything, "2").Return(errors.New("connection refused")).Once()
+	app := newTestApp(&scimHandler{userService: svc})
+
+	resp := do(t, app, fiber.MethodDelete, BasePath+"/Users/1", "", nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	var scimErr ErrorResponse
+	resp = do(t, app, fiber.MethodDelete, BasePath+"/Users/2", "", &scimErr)
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, "internal server error", scimErr.Detail)
+	svc.AssertExpectations(t)
+}
+
+func TestAuthenticate(t *testing.T) {
+	app := newTestApp(&scimHandler{userService: new(mocks.UserService), bearerToken: "other-token"})
+
+	var scimErr ErrorResponse
+	resp := do(t, app, fiber.MethodGet, BasePath+"/ServiceProviderConfig", "", &scimErr)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, "401", scimErr.Status)
+
+	app = newTestApp(&scimHandler{userService: new(mocks.UserService), bearerToken: "token"})
+	var config ServiceProviderConfig
+	resp = do(t, app, fiber.MethodGet, BasePath+"/ServiceProviderConfig", "", &config)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, config.Patch.Supported)
+	require.Equal(t, "oauthbearertoken", config.AuthenticationSchemes[0].Type)
+}
+
+func TestGetSchema(t *testing.T) {
+	app := newTestApp(&scimHandler{userService: new(mocks.UserService)})
+
+	var schema Schema
+	resp := do(t, app, fiber.MethodGet, BasePath+"/Schemas/"+UserSchema, "", &schema)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, UserSchema, schema.Id)
+
+	resp = do(t, app, fiber.MethodGet, BasePath+"/Schemas/urn:unknown", "", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,83 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsaltun/userapi/internal/model"
+)
+
+// toScimUser maps the user model onto SCIM core user. location is the url of the resource.
+func toScimUser(user *model.User, location string) User {
+	active := user.Status == model.UserStatus_Active
+	created, lastModified := user.CreatedAt, user.UpdatedAt
+
+	scimUser := User{
+		Schemas:  []string{UserSchema},
+		Id:       user.Id,
+		UserName: user.NickName,
+		Name: &Name{
+			Formatted:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		},
+		Active: &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &lastModified,
+			Version:      fmt.Sprintf(`W/"%d"`, user.Version),
+			Location:     location,
+		},
+	}
+	if user.Email != "" {
+		scimUser.Emails = []Email{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.Country != "" {
+		scimUser.Addresses = []Address{{Country: user.Country, Type: "work", Primary: true}}
+	}
+	return scimUser
+}
+
+// toModelUser maps SCIM user onto the user model. Primary email and address are used when there are multiple of them.
+func toModelUser(scimUser *User) *model.User {
+	user := &model.User{
+		NickName: scimUser.UserName,
+		Password: scimUser.Password,
+		Email:    primaryEmail(scimUser.Emails),
+		Country:  primaryCountry(scimUser.Addresses),
+		Status:   model.UserStatus_Active,
+	}
+	if scimUser.Name != nil {
+		user.FirstName = scimUser.Name.GivenName
+		user.LastName = scimUser.Name.FamilyName
+	}
+	if scimUser.Active != nil && !*scimUser.Active {
+		user.Status = model.UserStatus_Inactive
+	}
+	return user
+}
+
+func primaryEmail(emails []Email) string {
+	for _, e := range emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func primaryCountry(addresses []Address) string {
+	for _, a := range addresses {
+		if a.Primary {
+			return a.Country
+		}
+	}
+	if len(addresses) > 0 {
+		return addresses[0].Country
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package scim
+
+import "time"
+
+const (
+	// MediaType is the content type of SCIM requests and responses
+	MediaType = "application/scim+json"
+
+	UserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+const (
+	// DefaultCount is the page size when `count` is not provided
+	DefaultCount = 100
+	// MaxResults is the max page size. Larger counts are reduced to it.
+	MaxResults = 200
+)
+
+// User is the SCIM core user resource. Only one email and one address(its country) are stored,
+// the primary one is used when multiple values are provided.
+type User struct {
+	Schemas   []string  `json:"schemas"`
+	Id        string    `json:"id,omitempty"`
+	UserName  string    `json:"userName"`
+	Name      *Name     `json:"name,omitempty"`
+	Emails    []Email   `json:"emails,omitempty"`
+	Addresses []Address `json:"addresses,omitempty"`
+	Active    *bool     `json:"active,omitempty"`
+	Password  string    `json:"password,omitempty"`
+	Meta      *Meta     `json:"meta,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Address struct {
+	Country string `json:"country"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Version      string     `json:"version,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// ListResponse is the paginated response of list endpoints. StartIndex is 1-based.
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// PatchRequest is the body of PATCH requests
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is an `add`, `replace` or `remove` operation. Value is applied to the resource itself when Path is empty.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ErrorResponse is the body of SCIM errors
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
@@ -0,0 +1,170 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nsaltun/userapi/internal/model"
+)
+
+// applyPatch applies PATCH operations to the user in order.
+//
+// Supported paths are `userName`, `name`, `name.givenName`, `name.familyName`, `emails`, `emails.value`,
+// `addresses`, `addresses.country` and `active`. Value filters like `emails[type eq "work"].value` address
+// the only stored value. Operations without path apply attributes of their value object and ignore unknown ones
+// like create and replace do.
+func applyPatch(user *model.User, operations []PatchOperation) error {
+	for _, op := range operations {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" && opName != "remove" {
+			return badRequest(ScimTypeInvalidSyntax, fmt.Sprintf("unsupported patch operation %q", op.Op))
+		}
+
+		if op.Path == "" {
+			if opName == "remove" {
+				return badRequest(ScimTypeInvalidPath, "path is required for remove operation")
+			}
+			attrs, ok := op.Value.(map[string]interface{})
+			if !ok {
+				return badRequest(ScimTypeInvalidValue, "value should be an object when path is not provided")
+			}
+			for attr, value := range attrs {
+				if err := setAttribute(user, attr, value, true); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value := op.Value
+		if opName == "remove" {
+			value = nil
+		}
+		if err := setAttribute(user, op.Path, value, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setAttribute sets the attribute of the user at path. nil value removes it.
+// Unknown attributes are ignored when ignoreUnknown is true.
+func setAttribute(user *model.User, path string, value interface{}, ignoreUnknown bool) error {
+	attr := strings.ToLower(path)
+	// value filters address the only stored value
+	if start := strings.IndexByte(attr, '['); start >= 0 {
+		end := strings.IndexByte(attr, ']')
+		if end < start {
+			return badRequest(ScimTypeInvalidPath, fmt.Sprintf("invalid path %q", path))
+		}
+		attr = attr[:start] + attr[end+1:]
+	}
+	attr = strings.TrimPrefix(attr, strings.ToLower(UserSchema)+":")
+
+	var err error
+	switch attr {
+	case "username":
+		user.NickName, err = stringValue(path, value)
+	case "name.givenname":
+		user.FirstName, err = stringValue(path, value)
+	case "name.familyname":
+		user.LastName, err = stringValue(path, value)
+	case "name":
+		// sub attributes which are not provided are kept
+		switch v := value.(type) {
+		case nil:
+			user.FirstName, user.LastName = "", ""
+		case map[string]interface{}:
+			for subAttr, subValue := range v {
+				if err = setAttribute(user, "name."+subAttr, subValue, true); err != nil {
+					break
+				}
+			}
+		default:
+			err = badRequest(ScimTypeInvalidValue, fmt.Sprintf("value of %q should be an object", path))
+		}
+	case "emails":
+		var emails []Email
+		if err = decodeValue(path, value, &emails); err == nil {
+			user.Email = primaryEmail(emails)
+		}
+	case "emails.value":
+		user.Email, err = stringValue(path, value)
+	case "addresses":
+		var addresses []Address
+		if err = decodeValue(path, value, &addresses); err == nil {
+			user.Country = primaryCountry(addresses)
+		}
+	case "addresses.country":
+		user.Country, err = stringValue(path, value)
+	case "active":
+		var active bool
+		if active, err = boolValue(path, value); err == nil {
+			user.Status = model.UserStatus_Inactive
+			if active {
+				user.Status = model.UserStatus_Active
+			}
+		}
+	case "password":
+		err = &Error{Status: http.StatusBadRequest, ScimType: ScimTypeMutability, Detail: "password can't be changed with SCIM"}
+	case "id", "meta", "schemas":
+		err = &Error{Status: http.StatusBadRequest, ScimType: ScimTypeMutability, Detail: fmt.Sprintf("%q is read only", path)}
+	default:
+		if !ignoreUnknown {
+			err = badRequest(ScimTypeInvalidPath, fmt.Sprintf("unsupported path %q", path))
+		}
+	}
+	return err
+}
+
+// stringValue returns the value as string. nil is an empty string.
+func stringValue(path string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", badRequest(ScimTypeInvalidValue, fmt.Sprintf("value of %q should be a string", path))
+}
+
+// boolValue returns the value as bool. Strings like "True" are accepted as some identity providers send them.
+func boolValue(path string, value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, badRequest(ScimTypeInvalidValue, fmt.Sprintf("value of %q should be a boolean", path))
+}
+
+// decodeValue decodes a complex or multi-valued value into out. nil leaves out empty.
+// A single object is accepted for multi-valued attributes.
+func decodeValue(path string, value interface{}, out interface{}) error {
+	if value == nil {
+		return nil
+	}
+	if obj, ok := value.(map[string]interface{}); ok {
+		if _, isSlice := out.(*[]Email); isSlice {
+			value = []interface{}{obj}
+		} else if _, isSlice := out.(*[]Address); isSlice {
+			value = []interface{}{obj}
+		}
+	}
+	raw, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(raw, out)
+	}
+	if err != nil {
+		return badRequest(ScimTypeInvalidValue, fmt.Sprintf("invalid value of %q", path))
+	}
+	return nil
+}
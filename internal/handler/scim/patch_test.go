@@ -0,0 +1,101 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	current := func() *model.User {
+		return &model.User{Id: "1", FirstName: "John", LastName: "Doe", NickName: "johndoe", Email: "john@doe.com", Country: "TR", Status: model.UserStatus_Active}
+	}
+	tests := []struct {
+		name       string
+		operations []PatchOperation
+		expected   func(*model.User)
+		scimType   string
+	}{
+		{
+			name:       "replace simple attribute",
+			operations: []PatchOperation{{Op: "replace", Path: "userName", Value: "jdoe"}},
+			expected:   func(u *model.User) { u.NickName = "jdoe" },
+		},
+		{
+			name: "deactivate with string boolean",
+			operations: []PatchOperation{
+				{Op: "Replace", Path: "active", Value: "False"},
+			},
+			expected: func(u *model.User) { u.Status = model.UserStatus_Inactive },
+		},
+		{
+			name:       "value filter path",
+			operations: []PatchOperation{{Op: "replace", Path: `emails[type eq "work"].value`, Value: "jd@doe.com"}},
+			expected:   func(u *model.User) { u.Email = "jd@doe.com" },
+		},
+		{
+			name: "multi valued attribute uses primary value",
+			operations: []PatchOperation{{Op: "add", Path: "emails", Value: []interface{}{
+				map[string]interface{}{"value": "other@doe.com"},
+				map[string]interface{}{"value": "primary@doe.com", "primary": true},
+			}}},
+			expected: func(u *model.User) { u.Email = "primary@doe.com" },
+		},
+		{
+			name:       "partial name keeps other sub attributes",
+			operations: []PatchOperation{{Op: "replace", Path: "name", Value: map[string]interface{}{"familyName": "Smith"}}},
+			expected:   func(u *model.User) { u.LastName = "Smith" },
+		},
+		{
+			name: "no path applies attributes and ignores unknown ones",
+			operations: []PatchOperation{{Op: "replace", Value: map[string]interface{}{
+				"name.givenName": "Jack",
+				"addresses":      map[string]interface{}{"country": "DE"},
+				"externalId":     "ext-1",
+			}}},
+			expected: func(u *model.User) { u.FirstName, u.Country = "Jack", "DE" },
+		},
+		{
+			name:       "remove attribute",
+			operations: []PatchOperation{{Op: "remove", Path: "name.familyName"}},
+			expected:   func(u *model.User) { u.LastName = "" },
+		},
+		{
+			name:       "unsupported path",
+			operations: []PatchOperation{{Op: "replace", Path: "title", Value: "Dr"}},
+			scimType:   ScimTypeInvalidPath,
+		},
+		{
+			name:       "password is not mutable",
+			operations: []PatchOperation{{Op: "replace", Path: "password", Value: "secret"}},
+			scimType:   ScimTypeMutability,
+		},
+		{
+			name:       "unsupported operation",
+			operations: []PatchOperation{{Op: "move", Path: "userName"}},
+			scimType:   ScimTypeInvalidSyntax,
+		},
+		{
+			name:       "invalid value type",
+			operations: []PatchOperation{{Op: "replace", Path: "userName", Value: 1.0}},
+			scimType:   ScimTypeInvalidValue,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			user := current()
+			err := applyPatch(user, tCase.operations)
+			if tCase.scimType != "" {
+				var scimErr *Error
+				require.ErrorAs(tt, err, &scimErr)
+				require.Equal(tt, tCase.scimType, scimErr.ScimType)
+				return
+			}
+			require.NoError(tt, err)
+			expected := current()
+			tCase.expected(expected)
+			require.Equal(tt, expected, user)
+		})
+	}
+}
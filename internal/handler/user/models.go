@@ -5,20 +5,27 @@ import (
 )
 
 const (
-	// DefaultLimit is the default limit for pagination
+	// DefaultLimit is the default limit for pagination, it is the `default` tag of ListUsersByFilterRequest.Limit
 	DefaultLimit = 20
 )
 
 type CreateUserRequest struct {
-	*model.User
+	*model.User `validate:"required"`
+}
+
+// ProvisionUserRequest validates a whole user whose password is optional, e.g. users provisioned by SCIM
+// authenticate with their identity provider and stored users don't expose their password
+type ProvisionUserRequest struct {
+	*model.User `validate:"required"`
 }
 
 type CreateUserResponse struct {
 	*model.User
 }
 
+// UpdateUserByIdRequest validates only provided fields of the user
 type UpdateUserByIdRequest struct {
-	*model.User
+	*model.User `validate:"partial"`
 }
 
 type UpdateUserByIdResponse struct {
@@ -26,9 +33,9 @@ type UpdateUserByIdResponse struct {
 }
 
 type ListUsersByFilterRequest struct {
-	Limit  int
-	Offset int
-	*model.UserFilter
+	Limit             int `query:"limit" default:"20" validate:"min=1"`
+	Offset            int `query:"offset" validate:"min=0"`
+	*model.UserFilter `validate:"required"`
 }
 
 type ListUsersByFilterResponse struct {
@@ -36,7 +43,108 @@ type ListUsersByFilterResponse struct {
 }
 
 type DeleteUserByIdRequest struct {
-	Id string `json:"id"`
+	Id string `json:"id" validate:"required"`
 }
 
 type DeleteUserByIdResponse struct{}
+
+// ChangePasswordRequest changes the password of the user of the id path parameter
+type ChangePasswordRequest struct {
+	Id              string `json:"id" validate:"required"`
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+type ChangePasswordResponse struct{}
+
+// ForgotPasswordRequest requests a password reset token for the user of the email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordResponse struct{}
+
+// ResetPasswordRequest sets the new password of the user of the reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
+type ResetPasswordResponse struct{}
+
+// ConfirmEmailRequest confirms the email of a verification or email change token
+type ConfirmEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ConfirmEmailResponse struct{}
+
+// ResendVerificationRequest requests a new verification token for the user pending verification with the email
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResendVerificationResponse struct{}
+
+// LoginRequest verifies the password of the active user of the email
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// LoginResponse has the user when the login is completed, otherwise the MFA challenge token
+type LoginResponse struct {
+	*model.LoginResult
+}
+
+// VerifyMfaLoginRequest completes the login of the MFA challenge token by a TOTP code or a recovery code
+type VerifyMfaLoginRequest struct {
+	MfaToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type VerifyMfaLoginResponse struct {
+	*model.User
+}
+
+// EnrollMfaRequest starts an MFA enrollment of the user of the id path parameter
+type EnrollMfaRequest struct {
+	Id       string `json:"id" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type EnrollMfaResponse struct {
+	*model.MfaEnrollment
+}
+
+// ConfirmMfaRequest confirms the started enrollment or reset by a code of the new secret
+type ConfirmMfaRequest struct {
+	Id   string `json:"id" validate:"required"`
+	Code string `json:"code" validate:"required"`
+}
+
+// ConfirmMfaResponse has the recovery codes which are shown once
+type ConfirmMfaResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// ResetMfaRequest starts a new enrollment when the password and a code of the current secret or a recovery code are
+// verified
+type ResetMfaRequest struct {
+	Id       string `json:"id" validate:"required"`
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type ResetMfaResponse struct {
+	*model.MfaEnrollment
+}
+
+// DisableMfaRequest disables MFA when the password and a code of the secret or a recovery code are verified
+type DisableMfaRequest struct {
+	Id       string `json:"id" validate:"required"`
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type DisableMfaResponse struct{}
@@ -13,6 +13,17 @@ type UserHandler interface {
 	UpdateUserById(context.Context, *UpdateUserByIdRequest) (*UpdateUserByIdResponse, int, error)
 	DeleteUserById(context.Context, *DeleteUserByIdRequest) (*DeleteUserByIdResponse, int, error)
 	ListUsers(ctx context.Context, req *ListUsersByFilterRequest) (*ListUsersByFilterResponse, int, error)
+	ChangePassword(context.Context, *ChangePasswordRequest) (*ChangePasswordResponse, int, error)
+	ForgotPassword(context.Context, *ForgotPasswordRequest) (*ForgotPasswordResponse, int, error)
+	ResetPassword(context.Context, *ResetPasswordRequest) (*ResetPasswordResponse, int, error)
+	ConfirmEmail(context.Context, *ConfirmEmailRequest) (*ConfirmEmailResponse, int, error)
+	ResendVerification(context.Context, *ResendVerificationRequest) (*ResendVerificationResponse, int, error)
+	Login(context.Context, *LoginRequest) (*LoginResponse, int, error)
+	VerifyMfaLogin(context.Context, *VerifyMfaLoginRequest) (*VerifyMfaLoginResponse, int, error)
+	EnrollMfa(context.Context, *EnrollMfaRequest) (*EnrollMfaResponse, int, error)
+	ConfirmMfa(context.Context, *ConfirmMfaRequest) (*ConfirmMfaResponse, int, error)
+	ResetMfa(context.Context, *ResetMfaRequest) (*ResetMfaResponse, int, error)
+	DisableMfa(context.Context, *DisableMfaRequest) (*DisableMfaResponse, int, error)
 }
 
 // Implementor of user handler
@@ -36,6 +47,8 @@ func NewUserHandler(userService service.UserService) UserHandler {
 //
 // If error occurs it returns structured json data which composed with error code and error message
 func (u *userHandler) CreateUser(ctx context.Context, req *CreateUserRequest) (*CreateUserResponse, int, error) {
+	// status of new users is decided by the service, e.g. they are pending verification
+	req.User.Status = 0
 	createdUser, err := u.userService.CreateUser(ctx, req.User)
 	if err != nil {
 		return nil, 0, err
@@ -92,3 +105,160 @@ func (u *userHandler) DeleteUserById(ctx context.Context, req *DeleteUserByIdReq
 	}
 	return &DeleteUserByIdResponse{}, http.StatusOK, nil
 }
+
+// ChangePassword is handling password change. If there is no error it returns empty response and HTTP 200 status code
+//
+// Getting id from path. Getting current and new passwords from request body.
+//
+// The current password is verified and the new password should follow the password policy. Version of the user is
+// bumped and its sessions and tokens are revoked.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ChangePassword(ctx context.Context, req *ChangePasswordRequest) (*ChangePasswordResponse, int, error) {
+	err := u.userService.ChangePassword(ctx, req.Id, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ChangePasswordResponse{}, http.StatusOK, nil
+}
+
+// ForgotPassword is handling password reset requests. It returns empty response and HTTP 202 status code whether or
+// not there is a user of the email, so that registered emails can't be found out.
+//
+// A single use and expiring reset token is sent to the user by the notifier. Requests of a user are throttled.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ForgotPassword(ctx context.Context, req *ForgotPasswordRequest) (*ForgotPasswordResponse, int, error) {
+	err := u.userService.ForgotPassword(ctx, req.Email)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ForgotPasswordResponse{}, http.StatusAccepted, nil
+}
+
+// ResetPassword is handling password reset. If there is no error it returns empty response and HTTP 200 status code
+//
+// Getting reset token and new password from request body. The token is consumed and the new password should follow the
+// password policy. Version of the user is bumped and its sessions and tokens are revoked.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ResetPassword(ctx context.Context, req *ResetPasswordRequest) (*ResetPasswordResponse, int, error) {
+	err := u.userService.ResetPassword(ctx, req.Token, req.NewPassword)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ResetPasswordResponse{}, http.StatusOK, nil
+}
+
+// ConfirmEmail is handling email confirmation. If there is no error it returns empty response and HTTP 200 status code
+//
+// Getting verification or email change token from request body. The token is consumed, the user of a verification
+// token becomes active and the new email of an email change token is applied.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ConfirmEmail(ctx context.Context, req *ConfirmEmailRequest) (*ConfirmEmailResponse, int, error) {
+	err := u.userService.ConfirmEmail(ctx, req.Token)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ConfirmEmailResponse{}, http.StatusOK, nil
+}
+
+// ResendVerification is handling verification token requests. It returns empty response and HTTP 202 status code
+// whether or not there is a user pending verification with the email, so that registered emails can't be found out.
+//
+// Requests of a user are throttled.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ResendVerification(ctx context.Context, req *ResendVerificationRequest) (*ResendVerificationResponse, int, error) {
+	err := u.userService.ResendVerification(ctx, req.Email)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ResendVerificationResponse{}, http.StatusAccepted, nil
+}
+
+// Login is handling password verification. If there is no error it returns the user without password and HTTP 200
+// status code when MFA of the user isn't enabled, otherwise an MFA challenge token which is verified by VerifyMfaLogin.
+//
+// Sessions aren't issued, they are up to the authentication system in front of the service. Unknown emails and wrong
+// passwords return the same error, so that registered emails can't be found out.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, int, error) {
+	result, err := u.userService.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &LoginResponse{result}, http.StatusOK, nil
+}
+
+// VerifyMfaLogin is handling the second step of logins with MFA. If there is no error it returns the user without
+// password and HTTP 200 status code
+//
+// Getting MFA challenge token and a TOTP code or a recovery code from request body. Codes and the token are used once.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) VerifyMfaLogin(ctx context.Context, req *VerifyMfaLoginRequest) (*VerifyMfaLoginResponse, int, error) {
+	user, err := u.userService.VerifyMfaLogin(ctx, req.MfaToken, req.Code)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &VerifyMfaLoginResponse{user}, http.StatusOK, nil
+}
+
+// EnrollMfa is handling MFA enrollment. If there is no error it returns the TOTP secret and its provisioning URI with
+// HTTP 200 status code
+//
+// Getting id from path. Getting password from request body. The enrollment is confirmed by ConfirmMfa.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) EnrollMfa(ctx context.Context, req *EnrollMfaRequest) (*EnrollMfaResponse, int, error) {
+	enrollment, err := u.userService.EnrollMfa(ctx, req.Id, req.Password)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &EnrollMfaResponse{enrollment}, http.StatusOK, nil
+}
+
+// ConfirmMfa is handling confirmation of MFA enrollments and resets. If there is no error it returns the recovery
+// codes with HTTP 200 status code. Recovery codes are shown once.
+//
+// Getting id from path. Getting a code of the new secret from request body.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ConfirmMfa(ctx context.Context, req *ConfirmMfaRequest) (*ConfirmMfaResponse, int, error) {
+	codes, err := u.userService.ConfirmMfa(ctx, req.Id, req.Code)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ConfirmMfaResponse{codes}, http.StatusOK, nil
+}
+
+// ResetMfa is handling MFA reset. If there is no error it returns the new TOTP secret and its provisioning URI with
+// HTTP 200 status code
+//
+// Getting id from path. Getting password and a code of the current secret or a recovery code from request body. The
+// current secret is used until the reset is confirmed by ConfirmMfa.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) ResetMfa(ctx context.Context, req *ResetMfaRequest) (*ResetMfaResponse, int, error) {
+	enrollment, err := u.userService.ResetMfa(ctx, req.Id, req.Password, req.Code)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ResetMfaResponse{enrollment}, http.StatusOK, nil
+}
+
+// DisableMfa is handling MFA disabling. If there is no error it returns empty response and HTTP 200 status code
+//
+// Getting id from path. Getting password and a code of the secret or a recovery code from request body.
+//
+// If error occurs it returns structured json data which composed with error code and error message
+func (u *userHandler) DisableMfa(ctx context.Context, req *DisableMfaRequest) (*DisableMfaResponse, int, error) {
+	err := u.userService.DisableMfa(ctx, req.Id, req.Password, req.Code)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &DisableMfaResponse{}, http.StatusOK, nil
+}
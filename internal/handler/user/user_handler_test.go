@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	mocks "github.com/nsaltun/userapi/internal/mocks/service"
 	"github.com/nsaltun/userapi/internal/model"
@@ -98,3 +99,324 @@ func TestDeleteUserById(t *testing.T) {
 func TestListUsers(t *testing.T) {
 	//TODO
 }
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns success",
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: model.ErrUserInvalidPassword,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, model.ErrUserInvalidPassword, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &ChangePasswordRequest{Id: "1", CurrentPassword: "Current-Horse7", NewPassword: "Battery-Staple9"}
+			userSvcMock.On("ChangePassword", mock.Anything, "1", "Current-Horse7", "Battery-Staple9").Return(tCase.serviceErr).Once()
+
+			//execute
+			_, statusCode, err := h.ChangePassword(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+		})
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns success",
+			assertError: require.NoError,
+			statusCode:  202,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: errwrap.ErrInternal,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, errwrap.ErrInternal, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &ForgotPasswordRequest{Email: "john@email.com"}
+			userSvcMock.On("ForgotPassword", mock.Anything, "john@email.com").Return(tCase.serviceErr).Once()
+
+			//execute
+			_, statusCode, err := h.ForgotPassword(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns success",
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: model.ErrUserTokenInvalid,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, model.ErrUserTokenInvalid, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &ResetPasswordRequest{Token: "token", NewPassword: "Battery-Staple9"}
+			userSvcMock.On("ResetPassword", mock.Anything, "token", "Battery-Staple9").Return(tCase.serviceErr).Once()
+
+			//execute
+			_, statusCode, err := h.ResetPassword(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+		})
+	}
+}
+
+func TestConfirmEmail(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns success",
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: model.ErrUserTokenInvalid,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, model.ErrUserTokenInvalid, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &ConfirmEmailRequest{Token: "token"}
+			userSvcMock.On("ConfirmEmail", mock.Anything, "token").Return(tCase.serviceErr).Once()
+
+			//execute
+			_, statusCode, err := h.ConfirmEmail(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns success",
+			assertError: require.NoError,
+			statusCode:  202,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: errwrap.ErrInternal,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, errwrap.ErrInternal, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &ResendVerificationRequest{Email: "john@email.com"}
+			userSvcMock.On("ResendVerification", mock.Anything, "john@email.com").Return(tCase.serviceErr).Once()
+
+			//execute
+			_, statusCode, err := h.ResendVerification(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	expiresAt := time.Now()
+	tests := []struct {
+		name        string
+		result      *model.LoginResult
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns user",
+			result:      &model.LoginResult{User: &model.User{Id: "1"}},
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:        "service returns mfa challenge",
+			result:      &model.LoginResult{MfaRequired: true, MfaToken: "token", ExpiresAt: &expiresAt},
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: model.ErrUserInvalidPassword,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, model.ErrUserInvalidPassword, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &LoginRequest{Email: "john@email.com", Password: "password"}
+			userSvcMock.On("Login", mock.Anything, "john@email.com", "password").Return(tCase.result, tCase.serviceErr).Once()
+
+			//execute
+			resp, statusCode, err := h.Login(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+			if tCase.result != nil {
+				require.Equal(tt, tCase.result, resp.LoginResult)
+			}
+		})
+	}
+}
+
+func TestConfirmMfa(t *testing.T) {
+	tests := []struct {
+		name        string
+		codes       []string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns recovery codes",
+			codes:       []string{"aaaa-bbbb-cccc-dddd"},
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: model.ErrUserMfaCodeInvalid,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, model.ErrUserMfaCodeInvalid, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &ConfirmMfaRequest{Id: "1", Code: "123456"}
+			userSvcMock.On("ConfirmMfa", mock.Anything, "1", "123456").Return(tCase.codes, tCase.serviceErr).Once()
+
+			//execute
+			resp, statusCode, err := h.ConfirmMfa(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+			if tCase.codes != nil {
+				require.Equal(tt, tCase.codes, resp.RecoveryCodes)
+			}
+		})
+	}
+}
+
+func TestDisableMfa(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceErr  error
+		assertError require.ErrorAssertionFunc
+		statusCode  int
+	}{
+		{
+			name:        "service returns success",
+			assertError: require.NoError,
+			statusCode:  200,
+		},
+		{
+			name:       "service returns error",
+			serviceErr: model.ErrUserMfaLocked,
+			assertError: func(tt require.TestingT, err error, _ ...interface{}) {
+				require.Equal(tt, model.ErrUserMfaLocked, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//setup
+			userSvcMock := mocks.NewUserService(tt)
+			h := NewUserHandler(userSvcMock)
+			req := &DisableMfaRequest{Id: "1", Password: "password", Code: "123456"}
+			userSvcMock.On("DisableMfa", mock.Anything, "1", "password", "123456").Return(tCase.serviceErr).Once()
+
+			//execute
+			_, statusCode, err := h.DisableMfa(context.Background(), req)
+
+			//assert
+			tCase.assertError(tt, err)
+			require.Equal(tt, tCase.statusCode, statusCode)
+		})
+	}
+}
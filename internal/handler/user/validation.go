@@ -1,80 +1,84 @@
 package user
 
 import (
-	"strings"
-
 	"github.com/nsaltun/userapi/pkg/lib/errwrap"
 )
 
+// Rules of requests are declared with `validate` tags, see handler.Validate. Validate methods check the rest.
+
 func (req CreateUserRequest) Validate() error {
-	validationErrs := []string{}
-	if req.FirstName == "" {
-		validationErrs = append(validationErrs, "firstName can't be empty")
-	}
-	if req.Email == "" {
-		validationErrs = append(validationErrs, "email can't be empty")
-	}
-	if req.NickName == "" {
-		validationErrs = append(validationErrs, "nickName can't be empty")
-	}
-	if req.Country == "" {
-		validationErrs = append(validationErrs, "country can't be empty")
+	// password isn't required by the user model, see ProvisionUserRequest
+	if req.User != nil && req.Password == "" {
+		return errwrap.NewValidationError(required("password"))
 	}
+	return nil
+}
 
-	if len(validationErrs) > 0 {
-		errMsg := strings.Join(validationErrs, ";;")
-		return errwrap.ErrBadRequest.SetMessage(errMsg)
+func (req ProvisionUserRequest) Validate() error {
+	return nil
+}
+
+func (req UpdateUserByIdRequest) Validate() error {
+	// id is the path parameter and it isn't required by the user model
+	if req.User == nil || req.Id == "" {
+		return errwrap.NewValidationError(required("id"))
 	}
+	return nil
+}
 
+func (req ListUsersByFilterRequest) Validate() error {
 	return nil
 }
 
-func (req UpdateUserByIdRequest) Validate() error {
-	validationErrs := []string{}
+func (req DeleteUserByIdRequest) Validate() error {
+	return nil
+}
 
-	if req.Id == "" {
-		validationErrs = append(validationErrs, "id can't be empty")
-	}
+func (req ChangePasswordRequest) Validate() error {
+	return nil
+}
 
-	if len(validationErrs) > 0 {
-		errMsg := strings.Join(validationErrs, ";;")
-		return errwrap.ErrBadRequest.SetMessage(errMsg)
-	}
+func (req ForgotPasswordRequest) Validate() error {
 	return nil
 }
 
-func (req ListUsersByFilterRequest) Validate() error {
-	validationErrs := []string{}
-	if req.Limit == 0 {
-		req.Limit = 20
-	}
-	if req.Limit < 0 {
-		validationErrs = append(validationErrs, "limit can't be negative")
-	}
-	if req.Offset < 0 {
-		validationErrs = append(validationErrs, "offset can't be negative")
-	}
-	if req.UserFilter == nil {
-		validationErrs = append(validationErrs, "user filter can't be nil")
-	}
+func (req ResetPasswordRequest) Validate() error {
+	return nil
+}
 
-	if len(validationErrs) > 0 {
-		errMsg := strings.Join(validationErrs, ";;")
-		return errwrap.ErrBadRequest.SetMessage(errMsg)
-	}
+func (req ConfirmEmailRequest) Validate() error {
 	return nil
 }
 
-func (req DeleteUserByIdRequest) Validate() error {
-	validationErrs := []string{}
+func (req ResendVerificationRequest) Validate() error {
+	return nil
+}
 
-	if req.Id == "" {
-		validationErrs = append(validationErrs, "id can't be empty")
-	}
+func (req LoginRequest) Validate() error {
+	return nil
+}
 
-	if len(validationErrs) > 0 {
-		errMsg := strings.Join(validationErrs, ";;")
-		return errwrap.ErrBadRequest.SetMessage(errMsg)
-	}
+func (req VerifyMfaLoginRequest) Validate() error {
 	return nil
 }
+
+func (req EnrollMfaRequest) Validate() error {
+	return nil
+}
+
+func (req ConfirmMfaRequest) Validate() error {
+	return nil
+}
+
+func (req ResetMfaRequest) Validate() error {
+	return nil
+}
+
+func (req DisableMfaRequest) Validate() error {
+	return nil
+}
+
+// required returns the field error of an empty required field
+func required(field string) errwrap.FieldError {
+	return errwrap.FieldError{Field: field, Code: errwrap.FieldCodeRequired, Message: field + " can't be empty"}
+}
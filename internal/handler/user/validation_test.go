@@ -0,0 +1,145 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/nsaltun/userapi/internal/handler"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func() error
+		errs []errwrap.FieldError
+	}{
+		{
+			name: "create with all fields",
+			req: func() error {
+				return handler.Validate(&CreateUserRequest{&model.User{FirstName: "John", NickName: "john", Email: "john@doe.com", Country: "TR", Password: "Correct-Horse7"}})
+			},
+		},
+		{
+			name: "create without password",
+			req: func() error {
+				return handler.Validate(&CreateUserRequest{&model.User{FirstName: "John", NickName: "john", Email: "john@doe.com", Country: "TR"}})
+			},
+			errs: []errwrap.FieldError{{Field: "password", Code: errwrap.FieldCodeRequired, Message: "password can't be empty"}},
+		},
+		{
+			name: "provision without password",
+			req: func() error {
+				return handler.Validate(&ProvisionUserRequest{&model.User{FirstName: "John", NickName: "john", Email: "john@doe.com", Country: "TR"}})
+			},
+		},
+		{
+			name: "create without fields",
+			req:  func() error { return handler.Validate(&CreateUserRequest{&model.User{Email: "john"}}) },
+			errs: []errwrap.FieldError{
+				{Field: "firstName", Code: errwrap.FieldCodeRequired, Message: "firstName can't be empty"},
+				{Field: "nickName", Code: errwrap.FieldCodeRequired, Message: "nickName can't be empty"},
+				{Field: "email", Code: errwrap.FieldCodeInvalid, Message: "email must be a valid email address"},
+				{Field: "country", Code: errwrap.FieldCodeRequired, Message: "country can't be empty"},
+			},
+		},
+		{
+			name: "create without user",
+			req:  func() error { return handler.Validate(&CreateUserRequest{}) },
+			errs: []errwrap.FieldError{{Field: "user", Code: errwrap.FieldCodeRequired, Message: "user can't be empty"}},
+		},
+		{
+			name: "update validates provided fields",
+			req:  func() error { return handler.Validate(&UpdateUserByIdRequest{&model.User{Id: "1", Email: "john"}}) },
+			errs: []errwrap.FieldError{{Field: "email", Code: errwrap.FieldCodeInvalid, Message: "email must be a valid email address"}},
+		},
+		{
+			name: "update without id",
+			req:  func() error { return handler.Validate(&UpdateUserByIdRequest{&model.User{FirstName: "John"}}) },
+			errs: []errwrap.FieldError{{Field: "id", Code: errwrap.FieldCodeRequired, Message: "id can't be empty"}},
+		},
+		{
+			name: "list with negative values",
+			req: func() error {
+				return handler.Validate(&ListUsersByFilterRequest{Limit: -1, Offset: -1, UserFilter: &model.UserFilter{}})
+			},
+			errs: []errwrap.FieldError{
+				{Field: "limit", Code: errwrap.FieldCodeInvalid, Message: "limit must be at least 1"},
+				{Field: "offset", Code: errwrap.FieldCodeInvalid, Message: "offset must be at least 0"},
+			},
+		},
+		{
+			name: "list without filter",
+			req:  func() error { return handler.Validate(&ListUsersByFilterRequest{}) },
+			errs: []errwrap.FieldError{{Field: "userFilter", Code: errwrap.FieldCodeRequired, Message: "userFilter can't be empty"}},
+		},
+		{
+			name: "delete without id",
+			req:  func() error { return handler.Validate(&DeleteUserByIdRequest{}) },
+			errs: []errwrap.FieldError{{Field: "id", Code: errwrap.FieldCodeRequired, Message: "id can't be empty"}},
+		},
+		{
+			name: "change password without passwords",
+			req:  func() error { return handler.Validate(&ChangePasswordRequest{Id: "1"}) },
+			errs: []errwrap.FieldError{
+				{Field: "currentPassword", Code: errwrap.FieldCodeRequired, Message: "currentPassword can't be empty"},
+				{Field: "newPassword", Code: errwrap.FieldCodeRequired, Message: "newPassword can't be empty"},
+			},
+		},
+		{
+			name: "forgot password with invalid email",
+			req:  func() error { return handler.Validate(&ForgotPasswordRequest{Email: "john"}) },
+			errs: []errwrap.FieldError{{Field: "email", Code: errwrap.FieldCodeInvalid, Message: "email must be a valid email address"}},
+		},
+		{
+			name: "reset password without token",
+			req:  func() error { return handler.Validate(&ResetPasswordRequest{NewPassword: "Correct-Horse7"}) },
+			errs: []errwrap.FieldError{{Field: "token", Code: errwrap.FieldCodeRequired, Message: "token can't be empty"}},
+		},
+		{
+			name: "confirm email without token",
+			req:  func() error { return handler.Validate(&ConfirmEmailRequest{}) },
+			errs: []errwrap.FieldError{{Field: "token", Code: errwrap.FieldCodeRequired, Message: "token can't be empty"}},
+		},
+		{
+			name: "resend verification with invalid email",
+			req:  func() error { return handler.Validate(&ResendVerificationRequest{Email: "john"}) },
+			errs: []errwrap.FieldError{{Field: "email", Code: errwrap.FieldCodeInvalid, Message: "email must be a valid email address"}},
+		},
+		{
+			name: "login without password",
+			req:  func() error { return handler.Validate(&LoginRequest{Email: "john@email.com"}) },
+			errs: []errwrap.FieldError{{Field: "password", Code: errwrap.FieldCodeRequired, Message: "password can't be empty"}},
+		},
+		{
+			name: "verify mfa login without code",
+			req:  func() error { return handler.Validate(&VerifyMfaLoginRequest{MfaToken: "token"}) },
+			errs: []errwrap.FieldError{{Field: "code", Code: errwrap.FieldCodeRequired, Message: "code can't be empty"}},
+		},
+		{
+			name: "disable mfa without code",
+			req:  func() error { return handler.Validate(&DisableMfaRequest{Id: "1", Password: "password"}) },
+			errs: []errwrap.FieldError{{Field: "code", Code: errwrap.FieldCodeRequired, Message: "code can't be empty"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req()
+			if len(tt.errs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			iErr, ok := err.(errwrap.IError)
+			require.True(t, ok)
+			require.Equal(t, tt.errs, iErr.FieldErrors())
+		})
+	}
+}
+
+func TestListUsersDefaultLimit(t *testing.T) {
+	req := &ListUsersByFilterRequest{UserFilter: &model.UserFilter{}}
+	require.NoError(t, handler.Validate(req))
+	require.Equal(t, DefaultLimit, req.Limit)
+}
@@ -3,7 +3,9 @@
 package mocks
 
 import (
-	middleware "github.com/nsaltun/userapi/pkg/lib/middleware"
+	context "context"
+
+	user "github.com/nsaltun/userapi/internal/handler/user"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -12,76 +14,559 @@ type UserHandler struct {
 	mock.Mock
 }
 
-// CreateUser provides a mock function with given fields: c
-func (_m *UserHandler) CreateUser(c *middleware.HttpContext) error {
-	ret := _m.Called(c)
+// ChangePassword provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ChangePassword(_a0 context.Context, _a1 *user.ChangePasswordRequest) (*user.ChangePasswordResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 *user.ChangePasswordResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ChangePasswordRequest) (*user.ChangePasswordResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ChangePasswordRequest) *user.ChangePasswordResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ChangePasswordResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ChangePasswordRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ChangePasswordRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ConfirmEmail provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ConfirmEmail(_a0 context.Context, _a1 *user.ConfirmEmailRequest) (*user.ConfirmEmailResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmEmail")
+	}
+
+	var r0 *user.ConfirmEmailResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ConfirmEmailRequest) (*user.ConfirmEmailResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ConfirmEmailRequest) *user.ConfirmEmailResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ConfirmEmailResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ConfirmEmailRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ConfirmEmailRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ConfirmMfa provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ConfirmMfa(_a0 context.Context, _a1 *user.ConfirmMfaRequest) (*user.ConfirmMfaResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmMfa")
+	}
+
+	var r0 *user.ConfirmMfaResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ConfirmMfaRequest) (*user.ConfirmMfaResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ConfirmMfaRequest) *user.ConfirmMfaResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ConfirmMfaResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ConfirmMfaRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ConfirmMfaRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// CreateUser provides a mock function with given fields: ctx, req
+func (_m *UserHandler) CreateUser(ctx context.Context, req *user.CreateUserRequest) (*user.CreateUserResponse, int, error) {
+	ret := _m.Called(ctx, req)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateUser")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(*middleware.HttpContext) error); ok {
-		r0 = rf(c)
+	var r0 *user.CreateUserResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.CreateUserRequest) (*user.CreateUserResponse, int, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.CreateUserRequest) *user.CreateUserResponse); ok {
+		r0 = rf(ctx, req)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.CreateUserResponse)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, *user.CreateUserRequest) int); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.CreateUserRequest) error); ok {
+		r2 = rf(ctx, req)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// DeleteUserById provides a mock function with given fields: c
-func (_m *UserHandler) DeleteUserById(c *middleware.HttpContext) error {
-	ret := _m.Called(c)
+// DeleteUserById provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) DeleteUserById(_a0 context.Context, _a1 *user.DeleteUserByIdRequest) (*user.DeleteUserByIdResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteUserById")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(*middleware.HttpContext) error); ok {
-		r0 = rf(c)
+	var r0 *user.DeleteUserByIdResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.DeleteUserByIdRequest) (*user.DeleteUserByIdResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.DeleteUserByIdRequest) *user.DeleteUserByIdResponse); ok {
+		r0 = rf(_a0, _a1)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.DeleteUserByIdResponse)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, *user.DeleteUserByIdRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.DeleteUserByIdRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// ListUsers provides a mock function with given fields: c
-func (_m *UserHandler) ListUsers(c *middleware.HttpContext) error {
-	ret := _m.Called(c)
+// DisableMfa provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) DisableMfa(_a0 context.Context, _a1 *user.DisableMfaRequest) (*user.DisableMfaResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisableMfa")
+	}
+
+	var r0 *user.DisableMfaResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.DisableMfaRequest) (*user.DisableMfaResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.DisableMfaRequest) *user.DisableMfaResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.DisableMfaResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.DisableMfaRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.DisableMfaRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// EnrollMfa provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) EnrollMfa(_a0 context.Context, _a1 *user.EnrollMfaRequest) (*user.EnrollMfaResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnrollMfa")
+	}
+
+	var r0 *user.EnrollMfaResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.EnrollMfaRequest) (*user.EnrollMfaResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.EnrollMfaRequest) *user.EnrollMfaResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.EnrollMfaResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.EnrollMfaRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.EnrollMfaRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ForgotPassword provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ForgotPassword(_a0 context.Context, _a1 *user.ForgotPasswordRequest) (*user.ForgotPasswordResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ForgotPassword")
+	}
+
+	var r0 *user.ForgotPasswordResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ForgotPasswordRequest) (*user.ForgotPasswordResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ForgotPasswordRequest) *user.ForgotPasswordResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ForgotPasswordResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ForgotPasswordRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ForgotPasswordRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListUsers provides a mock function with given fields: ctx, req
+func (_m *UserHandler) ListUsers(ctx context.Context, req *user.ListUsersByFilterRequest) (*user.ListUsersByFilterResponse, int, error) {
+	ret := _m.Called(ctx, req)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListUsers")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(*middleware.HttpContext) error); ok {
-		r0 = rf(c)
+	var r0 *user.ListUsersByFilterResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ListUsersByFilterRequest) (*user.ListUsersByFilterResponse, int, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ListUsersByFilterRequest) *user.ListUsersByFilterResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ListUsersByFilterResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ListUsersByFilterRequest) int); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ListUsersByFilterRequest) error); ok {
+		r2 = rf(ctx, req)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Login provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) Login(_a0 context.Context, _a1 *user.LoginRequest) (*user.LoginResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Login")
+	}
+
+	var r0 *user.LoginResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.LoginRequest) (*user.LoginResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.LoginRequest) *user.LoginResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.LoginResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.LoginRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.LoginRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ResendVerification provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ResendVerification(_a0 context.Context, _a1 *user.ResendVerificationRequest) (*user.ResendVerificationResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResendVerification")
+	}
+
+	var r0 *user.ResendVerificationResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ResendVerificationRequest) (*user.ResendVerificationResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ResendVerificationRequest) *user.ResendVerificationResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ResendVerificationResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ResendVerificationRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ResendVerificationRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ResetMfa provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ResetMfa(_a0 context.Context, _a1 *user.ResetMfaRequest) (*user.ResetMfaResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetMfa")
+	}
+
+	var r0 *user.ResetMfaResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ResetMfaRequest) (*user.ResetMfaResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ResetMfaRequest) *user.ResetMfaResponse); ok {
+		r0 = rf(_a0, _a1)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ResetMfaResponse)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ResetMfaRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ResetMfaRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// UpdateUserById provides a mock function with given fields: c
-func (_m *UserHandler) UpdateUserById(c *middleware.HttpContext) error {
-	ret := _m.Called(c)
+// ResetPassword provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) ResetPassword(_a0 context.Context, _a1 *user.ResetPasswordRequest) (*user.ResetPasswordResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
+	}
+
+	var r0 *user.ResetPasswordResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ResetPasswordRequest) (*user.ResetPasswordResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.ResetPasswordRequest) *user.ResetPasswordResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.ResetPasswordResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.ResetPasswordRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.ResetPasswordRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UpdateUserById provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) UpdateUserById(_a0 context.Context, _a1 *user.UpdateUserByIdRequest) (*user.UpdateUserByIdResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateUserById")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(*middleware.HttpContext) error); ok {
-		r0 = rf(c)
+	var r0 *user.UpdateUserByIdResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.UpdateUserByIdRequest) (*user.UpdateUserByIdResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.UpdateUserByIdRequest) *user.UpdateUserByIdResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.UpdateUserByIdResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.UpdateUserByIdRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.UpdateUserByIdRequest) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// VerifyMfaLogin provides a mock function with given fields: _a0, _a1
+func (_m *UserHandler) VerifyMfaLogin(_a0 context.Context, _a1 *user.VerifyMfaLoginRequest) (*user.VerifyMfaLoginResponse, int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyMfaLogin")
+	}
+
+	var r0 *user.VerifyMfaLoginResponse
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *user.VerifyMfaLoginRequest) (*user.VerifyMfaLoginResponse, int, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *user.VerifyMfaLoginRequest) *user.VerifyMfaLoginResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*user.VerifyMfaLoginResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *user.VerifyMfaLoginRequest) int); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *user.VerifyMfaLoginRequest) error); ok {
+		r2 = rf(_a0, _a1)
 	} else {
-		r0 = ret.Error(0)
+		r2 = ret.Error(2)
 	}
 
-	return r0
+	return r0, r1, r2
 }
 
 // NewUserHandler creates a new instance of UserHandler. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
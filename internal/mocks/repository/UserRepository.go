@@ -8,7 +8,7 @@ import (
 	model "github.com/nsaltun/userapi/internal/model"
 	mock "github.com/stretchr/testify/mock"
 
-	primitive "go.mongodb.org/mongo-driver/bson/primitive"
+	time "time"
 )
 
 // UserRepository is an autogenerated mock type for the UserRepository type
@@ -52,6 +52,34 @@ func (_m *UserRepository) Delete(ctx context.Context, id string) error {
 	return r0
 }
 
+// DeleteUnverified provides a mock function with given fields: ctx, createdBefore
+func (_m *UserRepository) DeleteUnverified(ctx context.Context, createdBefore time.Time) (int64, error) {
+	ret := _m.Called(ctx, createdBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUnverified")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, createdBefore)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, createdBefore)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, createdBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Get provides a mock function with given fields: ctx, id
 func (_m *UserRepository) Get(ctx context.Context, id string) (*model.User, error) {
 	ret := _m.Called(ctx, id)
@@ -83,7 +111,7 @@ func (_m *UserRepository) Get(ctx context.Context, id string) (*model.User, erro
 }
 
 // ListByFilter provides a mock function with given fields: ctx, filter, limit, offset
-func (_m *UserRepository) ListByFilter(ctx context.Context, filter primitive.M, limit int, offset int) ([]model.User, int64, error) {
+func (_m *UserRepository) ListByFilter(ctx context.Context, filter model.UserFilter, limit int, offset int) ([]model.User, int64, error) {
 	ret := _m.Called(ctx, filter, limit, offset)
 
 	if len(ret) == 0 {
@@ -93,10 +121,10 @@ func (_m *UserRepository) ListByFilter(ctx context.Context, filter primitive.M,
 	var r0 []model.User
 	var r1 int64
 	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, primitive.M, int, int) ([]model.User, int64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter, int, int) ([]model.User, int64, error)); ok {
 		return rf(ctx, filter, limit, offset)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, primitive.M, int, int) []model.User); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter, int, int) []model.User); ok {
 		r0 = rf(ctx, filter, limit, offset)
 	} else {
 		if ret.Get(0) != nil {
@@ -104,13 +132,13 @@ func (_m *UserRepository) ListByFilter(ctx context.Context, filter primitive.M,
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, primitive.M, int, int) int64); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter, int, int) int64); ok {
 		r1 = rf(ctx, filter, limit, offset)
 	} else {
 		r1 = ret.Get(1).(int64)
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, primitive.M, int, int) error); ok {
+	if rf, ok := ret.Get(2).(func(context.Context, model.UserFilter, int, int) error); ok {
 		r2 = rf(ctx, filter, limit, offset)
 	} else {
 		r2 = ret.Error(2)
@@ -119,6 +147,42 @@ func (_m *UserRepository) ListByFilter(ctx context.Context, filter primitive.M,
 	return r0, r1, r2
 }
 
+// ReplacePasswordHash provides a mock function with given fields: ctx, id, current, hash
+func (_m *UserRepository) ReplacePasswordHash(ctx context.Context, id string, current string, hash string) error {
+	ret := _m.Called(ctx, id, current, hash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReplacePasswordHash")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, current, hash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPassword provides a mock function with given fields: ctx, id, hash
+func (_m *UserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	ret := _m.Called(ctx, id, hash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, hash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Update provides a mock function with given fields: ctx, id, user
 func (_m *UserRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
 	ret := _m.Called(ctx, id, user)
@@ -14,6 +14,72 @@ type UserService struct {
 	mock.Mock
 }
 
+// ChangePassword provides a mock function with given fields: ctx, id, currentPassword, newPassword
+func (_m *UserService) ChangePassword(ctx context.Context, id string, currentPassword string, newPassword string) error {
+	ret := _m.Called(ctx, id, currentPassword, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, currentPassword, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ConfirmEmail provides a mock function with given fields: ctx, token
+func (_m *UserService) ConfirmEmail(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ConfirmMfa provides a mock function with given fields: ctx, id, code
+func (_m *UserService) ConfirmMfa(ctx context.Context, id string, code string) ([]string, error) {
+	ret := _m.Called(ctx, id, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmMfa")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]string, error)); ok {
+		return rf(ctx, id, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = rf(ctx, id, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateUser provides a mock function with given fields: ctx, user
 func (_m *UserService) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
 	ret := _m.Called(ctx, user)
@@ -62,6 +128,130 @@ func (_m *UserService) DeleteUserById(ctx context.Context, id string) error {
 	return r0
 }
 
+// DisableMfa provides a mock function with given fields: ctx, id, password, code
+func (_m *UserService) DisableMfa(ctx context.Context, id string, password string, code string) error {
+	ret := _m.Called(ctx, id, password, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisableMfa")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, password, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnrollMfa provides a mock function with given fields: ctx, id, password
+func (_m *UserService) EnrollMfa(ctx context.Context, id string, password string) (*model.MfaEnrollment, error) {
+	ret := _m.Called(ctx, id, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnrollMfa")
+	}
+
+	var r0 *model.MfaEnrollment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*model.MfaEnrollment, error)); ok {
+		return rf(ctx, id, password)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.MfaEnrollment); ok {
+		r0 = rf(ctx, id, password)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MfaEnrollment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, password)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExpireUnverifiedUsers provides a mock function with given fields: ctx
+func (_m *UserService) ExpireUnverifiedUsers(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExpireUnverifiedUsers")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ForgotPassword provides a mock function with given fields: ctx, email
+func (_m *UserService) ForgotPassword(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ForgotPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetUserById provides a mock function with given fields: ctx, id
+func (_m *UserService) GetUserById(ctx context.Context, id string) (*model.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserById")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListUsers provides a mock function with given fields: ctx, userFilter, limit, offset
 func (_m *UserService) ListUsers(ctx context.Context, userFilter model.UserFilter, limit int, offset int) (*model.Pagination, error) {
 	ret := _m.Called(ctx, userFilter, limit, offset)
@@ -92,6 +282,132 @@ func (_m *UserService) ListUsers(ctx context.Context, userFilter model.UserFilte
 	return r0, r1
 }
 
+// Login provides a mock function with given fields: ctx, email, password
+func (_m *UserService) Login(ctx context.Context, email string, password string) (*model.LoginResult, error) {
+	ret := _m.Called(ctx, email, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Login")
+	}
+
+	var r0 *model.LoginResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*model.LoginResult, error)); ok {
+		return rf(ctx, email, password)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.LoginResult); ok {
+		r0 = rf(ctx, email, password)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.LoginResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, email, password)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ProvisionUser provides a mock function with given fields: ctx, user
+func (_m *UserService) ProvisionUser(ctx context.Context, user *model.User) (*model.User, error) {
+	ret := _m.Called(ctx, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ProvisionUser")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.User) (*model.User, error)); ok {
+		return rf(ctx, user)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.User) *model.User); ok {
+		r0 = rf(ctx, user)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.User) error); ok {
+		r1 = rf(ctx, user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResendVerification provides a mock function with given fields: ctx, email
+func (_m *UserService) ResendVerification(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResendVerification")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetMfa provides a mock function with given fields: ctx, id, password, code
+func (_m *UserService) ResetMfa(ctx context.Context, id string, password string, code string) (*model.MfaEnrollment, error) {
+	ret := _m.Called(ctx, id, password, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetMfa")
+	}
+
+	var r0 *model.MfaEnrollment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (*model.MfaEnrollment, error)); ok {
+		return rf(ctx, id, password, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *model.MfaEnrollment); ok {
+		r0 = rf(ctx, id, password, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MfaEnrollment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, id, password, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResetPassword provides a mock function with given fields: ctx, token, newPassword
+func (_m *UserService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	ret := _m.Called(ctx, token, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, token, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateUserById provides a mock function with given fields: ctx, id, user
 func (_m *UserService) UpdateUserById(ctx context.Context, id string, user model.User) (*model.User, error) {
 	ret := _m.Called(ctx, id, user)
@@ -122,6 +438,54 @@ func (_m *UserService) UpdateUserById(ctx context.Context, id string, user model
 	return r0, r1
 }
 
+// VerifyMfaLogin provides a mock function with given fields: ctx, mfaToken, code
+func (_m *UserService) VerifyMfaLogin(ctx context.Context, mfaToken string, code string) (*model.User, error) {
+	ret := _m.Called(ctx, mfaToken, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyMfaLogin")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*model.User, error)); ok {
+		return rf(ctx, mfaToken, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.User); ok {
+		r0 = rf(ctx, mfaToken, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mfaToken, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// VerifyPassword provides a mock function with given fields: ctx, id, password
+func (_m *UserService) VerifyPassword(ctx context.Context, id string, password string) error {
+	ret := _m.Called(ctx, id, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserService creates a new instance of UserService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserService(t interface {
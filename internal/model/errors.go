@@ -0,0 +1,113 @@
+package model
+
+import (
+	"net/http"
+
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// Errors of user operations
+var (
+	ErrUserNotFound = errwrap.Register(errwrap.Definition{
+		Code:        "USER_NOT_FOUND",
+		HttpCode:    http.StatusNotFound,
+		Message:     "user not found",
+		Description: "There is no user with the given id.",
+	})
+	ErrUserEmailTaken = errwrap.Register(errwrap.Definition{
+		Code:        "USER_EMAIL_TAKEN",
+		HttpCode:    http.StatusConflict,
+		Message:     "email is already taken",
+		Description: "Another user, active or not, has the same email.",
+	})
+	ErrUserNicknameTaken = errwrap.Register(errwrap.Definition{
+		Code:        "USER_NICKNAME_TAKEN",
+		HttpCode:    http.StatusConflict,
+		Message:     "nickname is already taken",
+		Description: "Another user, active or not, has the same nickName.",
+	})
+	ErrUserAlreadyExists = errwrap.Register(errwrap.Definition{
+		Code:        "USER_ALREADY_EXISTS",
+		HttpCode:    http.StatusConflict,
+		Message:     "already exists with the same nickname or email",
+		Description: "A unique field of the user collided with another user and the storage didn't tell which one.",
+	})
+	ErrUserPasswordTooLong = errwrap.Register(errwrap.Definition{
+		Code:        "USER_PASSWORD_TOO_LONG",
+		HttpCode:    http.StatusBadRequest,
+		Message:     "password is too long",
+		Description: "Password is longer than 72 bytes which is the maximum of bcrypt when passwords are hashed with bcrypt.",
+	})
+	ErrUserInvalidPassword = errwrap.Register(errwrap.Definition{
+		Code:        "USER_INVALID_PASSWORD",
+		HttpCode:    http.StatusForbidden,
+		Message:     "invalid password",
+		Description: "Password doesn't match the password of the user or the user has no password.",
+	})
+	ErrUserTokenInvalid = errwrap.Register(errwrap.Definition{
+		Code:        "USER_TOKEN_INVALID",
+		HttpCode:    http.StatusBadRequest,
+		Message:     "token is invalid or expired",
+		Description: "The token doesn't exist, is already used or expired.",
+	})
+	ErrUserEmailChangeThrottled = errwrap.Register(errwrap.Definition{
+		Code:        "USER_EMAIL_CHANGE_THROTTLED",
+		HttpCode:    http.StatusTooManyRequests,
+		Message:     "too many email change requests, try again later",
+		Description: "The user has requested too many email changes recently. The email isn't changed.",
+	})
+	ErrUserMfaCodeInvalid = errwrap.Register(errwrap.Definition{
+		Code:        "USER_MFA_CODE_INVALID",
+		HttpCode:    http.StatusForbidden,
+		Message:     "invalid mfa code",
+		Description: "The code doesn't match the TOTP code of the user, is already used or isn't an unused recovery code.",
+	})
+	ErrUserMfaLocked = errwrap.Register(errwrap.Definition{
+		Code:        "USER_MFA_LOCKED",
+		HttpCode:    http.StatusTooManyRequests,
+		Message:     "too many invalid mfa codes, try again later",
+		Description: "Too many invalid codes are sent for the user recently. Codes aren't checked until the lockout ends.",
+	})
+	ErrUserMfaNotEnabled = errwrap.Register(errwrap.Definition{
+		Code:        "USER_MFA_NOT_ENABLED",
+		HttpCode:    http.StatusConflict,
+		Message:     "mfa is not enabled",
+		Description: "The user has no confirmed mfa enrollment.",
+	})
+	ErrUserMfaAlreadyEnabled = errwrap.Register(errwrap.Definition{
+		Code:        "USER_MFA_ALREADY_ENABLED",
+		HttpCode:    http.StatusConflict,
+		Message:     "mfa is already enabled",
+		Description: "The user has a confirmed mfa enrollment. It should be reset to get a new secret.",
+	})
+	ErrUserMfaNotPending = errwrap.Register(errwrap.Definition{
+		Code:        "USER_MFA_NOT_PENDING",
+		HttpCode:    http.StatusConflict,
+		Message:     "there is no mfa enrollment to confirm",
+		Description: "Mfa enrollment or reset isn't started for the user or it is already confirmed.",
+	})
+	ErrUserMfaUnavailable = errwrap.Register(errwrap.Definition{
+		Code:        "USER_MFA_UNAVAILABLE",
+		HttpCode:    http.StatusServiceUnavailable,
+		Message:     "mfa is not available",
+		Description: "Mfa secrets can't be encrypted since MFA_KEY_FILE is not configured.",
+	})
+)
+
+// Unique fields of users
+const (
+	UniqueFieldEmail    = "email"
+	UniqueFieldNickName = "nickName"
+)
+
+// ErrUserFieldTaken returns the conflict error of the unique field. ErrUserAlreadyExists is returned for unknown fields.
+func ErrUserFieldTaken(field string) errwrap.IError {
+	switch field {
+	case UniqueFieldEmail:
+		return ErrUserEmailTaken
+	case UniqueFieldNickName:
+		return ErrUserNicknameTaken
+	default:
+		return ErrUserAlreadyExists
+	}
+}
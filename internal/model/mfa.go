@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// UserMfa is the TOTP multi-factor authentication of a user
+type UserMfa struct {
+	UserId string `bson:"_id"`
+	// Enabled is true when an enrollment is confirmed. Login needs a code of Secret when it is true.
+	Enabled bool `bson:"enabled"`
+	// Secret is the encrypted TOTP secret of the confirmed enrollment
+	Secret string `bson:"secret,omitempty"`
+	// PendingSecret is the encrypted TOTP secret of an enrollment or reset until it is confirmed. Secret is used until then.
+	PendingSecret string `bson:"pendingSecret,omitempty"`
+	// RecoveryCodes are SHA-256 hashes of unused recovery codes. A recovery code is accepted once instead of a TOTP code.
+	RecoveryCodes []string `bson:"recoveryCodes"`
+	// LastUsedStep is the time step of the last accepted TOTP code, codes of it and earlier steps are rejected
+	LastUsedStep int64 `bson:"lastUsedStep"`
+	// FailedAttempts is the number of attempted codes since the last valid code, attempts are counted before codes
+	// are verified. LastFailureAt is the time of the last counted attempt.
+	FailedAttempts int       `bson:"failedAttempts"`
+	LastFailureAt  time.Time `bson:"lastFailureAt,omitempty"`
+	CreatedAt      time.Time `bson:"createdAt"`
+	UpdatedAt      time.Time `bson:"updatedAt"`
+}
+
+// MfaEnrollment is the secret of a started enrollment which is added to an authenticator app
+type MfaEnrollment struct {
+	// Secret is the base32 TOTP secret to be entered manually
+	Secret string `json:"secret"`
+	// ProvisioningUri is the `otpauth://` URI to be shown as a QR code
+	ProvisioningUri string `json:"provisioningUri"`
+}
+
+// LoginResult is the result of a login by password. User is set when the login is completed, otherwise a code of the
+// second factor should be verified with MfaToken until ExpiresAt.
+type LoginResult struct {
+	User        *User      `json:"user,omitempty"`
+	MfaRequired bool       `json:"mfaRequired"`
+	MfaToken    string     `json:"mfaToken,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
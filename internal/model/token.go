@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// UserTokenPurpose is the flow which a user token is issued for
+type UserTokenPurpose string
+
+const (
+	UserTokenPurpose_PasswordReset     UserTokenPurpose = "password_reset"
+	UserTokenPurpose_EmailVerification UserTokenPurpose = "email_verification"
+	UserTokenPurpose_EmailChange       UserTokenPurpose = "email_change"
+	// UserTokenPurpose_MfaChallenge is the purpose of tokens which complete a login by a code of the second factor
+	UserTokenPurpose_MfaChallenge UserTokenPurpose = "mfa_challenge"
+)
+
+// UserToken is a single use and expiring token sent to a user, e.g. to reset its password.
+// Only the hash of the token is stored so that stored tokens can't be used.
+type UserToken struct {
+	Hash      string           `bson:"_id"`
+	UserId    string           `bson:"userId"`
+	Purpose   UserTokenPurpose `bson:"purpose"`
+	CreatedAt time.Time        `bson:"createdAt"`
+	ExpiresAt time.Time        `bson:"expiresAt"`
+	// Email is the address which the token is sent to for email verification and change tokens. It is the new
+	// address of email change tokens which is applied when the token is confirmed.
+	Email string `bson:"email,omitempty"`
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -9,19 +11,28 @@ type UserStatus int
 const (
 	UserStatus_Active   UserStatus = 1 //Default
 	UserStatus_Inactive UserStatus = 2
+	// UserStatus_PendingVerification is the status of users until their email is verified. Unverified users expire.
+	UserStatus_PendingVerification UserStatus = 3
 )
 
 // User represents the user model
 type User struct {
 	Id        string           `bson:"_id,omitempty" json:"id"` // UUID as string
-	FirstName string           `bson:"firstName" json:"firstName"`
+	FirstName string           `bson:"firstName" json:"firstName" validate:"required"`
 	LastName  string           `bson:"lastName" json:"lastName"`
-	NickName  string           `bson:"nickName" json:"nickName"`
+	NickName  string           `bson:"nickName" json:"nickName" validate:"required"`
 	Password  string           `bson:"password" json:"password,omitempty"`
-	Email     string           `bson:"email" json:"email"`
-	Country   string           `bson:"country" json:"country"`
+	Email     string           `bson:"email" json:"email" validate:"required,email"`
+	Country   string           `bson:"country" json:"country" validate:"required,iso3166"` // ISO 3166-1 alpha-2 code
 	Status    UserStatus       `bson:"status" json:"status"`
 	Meta      `bson:",inline"` // Embed Meta fields directly
+
+	// EmailCanonical is the canonical form of Email which is unique among users, see email.Canonicalizer.
+	// It is the blind index of the canonical form when email is encrypted.
+	EmailCanonical string `bson:"emailCanonical,omitempty" json:"-"`
+	// NickNameSkeleton is the confusable skeleton of NickName which is unique among users, see nickname.Skeleton.
+	// It is the blind index of the skeleton when nickName is encrypted.
+	NickNameSkeleton string `bson:"nickNameSkeleton,omitempty" json:"-"`
 }
 
 // UserFilter defines the criteria to filter users in MongoDB
@@ -31,7 +42,7 @@ type UserFilter struct {
 	LastName  string     `json:"lastName"`
 	NickName  string     `json:"nickName"`
 	Email     string     `json:"email"`
-	Country   string     `json:"country"`
+	Country   string     `json:"country" validate:"iso3166"`
 	Status    UserStatus `json:"status"`
 }
 
@@ -68,3 +79,40 @@ func (f *UserFilter) ToBson() bson.M {
 
 	return mongoFilter
 }
+
+// Matches reports whether the user satisfies the filter.
+//
+// Semantics are the same as ToBson so that storages without a query language behave like MongoDB:
+// prefix and case insensitive match for names, exact match for the rest and active status by default.
+func (f *UserFilter) Matches(user *User) bool {
+	if f.Id != "" && user.Id != f.Id {
+		return false
+	}
+	if f.FirstName != "" && !hasPrefixFold(user.FirstName, f.FirstName) {
+		return false
+	}
+	if f.LastName != "" && !hasPrefixFold(user.LastName, f.LastName) {
+		return false
+	}
+	if f.NickName != "" && user.NickName != f.NickName {
+		return false
+	}
+	if f.Email != "" && user.Email != f.Email {
+		return false
+	}
+	if f.Country != "" && user.Country != f.Country {
+		return false
+	}
+	if f.Status == 0 && user.Status != UserStatus_Active {
+		return false
+	} else if f.Status > 0 && user.Status != f.Status {
+		return false
+	}
+
+	return true
+}
+
+// hasPrefixFold is case insensitive version of strings.HasPrefix
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// idempotencyKeysCollection is the name of MongoDB collection of idempotency keys. Expired keys are removed by its TTL index.
+const idempotencyKeysCollection = "idempotency_keys"
+
+// idempotencyRecord is the document of an idempotency key
+type idempotencyRecord struct {
+	Key         string    `bson:"_id"`
+	LockToken   string    `bson:"lockToken"`
+	Fingerprint string    `bson:"fingerprint"`
+	Completed   bool      `bson:"completed"`
+	Status      int       `bson:"status,omitempty"`
+	ContentType string    `bson:"contentType,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	ExpiresAt   time.Time `bson:"expiresAt"`
+}
+
+// idempotencyStore is the MongoDB implementor of idempotency.Store
+type idempotencyStore struct {
+	collection *mongo.Collection
+}
+
+// NewIdempotencyStore returns idempotency.Store on MongoDB. TTL index of the collection is created by MongoMigrations.
+func NewIdempotencyStore(db *mongohandler.MongoDBWrapper) idempotency.Store {
+	return &idempotencyStore{collection: db.Database.Collection(idempotencyKeysCollection)}
+}
+
+func (s *idempotencyStore) Lock(ctx context.Context, key, token, fingerprint string, expiresAt time.Time) (*idempotency.Record, error) {
+	lock := idempotencyRecord{Key: key, LockToken: token, Fingerprint: fingerprint, ExpiresAt: expiresAt}
+	_, err := s.collection.InsertOne(ctx, lock)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// TTL monitor removes expired documents periodically, an expired key is taken over until then
+	res, err := s.collection.ReplaceOne(ctx, bson.M{"_id": key, "expiresAt": bson.M{"$lte": time.Now()}}, lock)
+	if err != nil {
+		return nil, err
+	}
+	if res.ModifiedCount == 1 {
+		return nil, nil
+	}
+
+	var found idempotencyRecord
+	err = s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&found)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the key is unlocked in the meantime, try again
+		return s.Lock(ctx, key, token, fingerprint, expiresAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &idempotency.Record{
+		Key:         found.Key,
+		Fingerprint: found.Fingerprint,
+		Completed:   found.Completed,
+		Response:    idempotency.Response{Status: found.Status, ContentType: found.ContentType, Body: found.Body},
+		ExpiresAt:   found.ExpiresAt,
+	}, nil
+}
+
+func (s *idempotencyStore) Save(ctx context.Context, key, token string, resp idempotency.Response, expiresAt time.Time) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": key, "lockToken": token}, bson.M{"$set": bson.M{
+		"completed":   true,
+		"status":      resp.Status,
+		"contentType": resp.ContentType,
+		"body":        resp.Body,
+		"expiresAt":   expiresAt,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return idempotency.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *idempotencyStore) Unlock(ctx context.Context, key, token string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key, "lockToken": token, "completed": false})
+	return err
+}
+
+// createIdempotencyKeysTTLIndex creates TTL index which removes idempotency keys when they expire
+func createIdempotencyKeysTTLIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(idempotencyKeysCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
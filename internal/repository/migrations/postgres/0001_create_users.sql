@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users (
+    id          TEXT PRIMARY KEY,
+    first_name  TEXT NOT NULL DEFAULT '',
+    last_name   TEXT NOT NULL DEFAULT '',
+    nick_name   TEXT NOT NULL,
+    password    TEXT NOT NULL DEFAULT '',
+    email       TEXT NOT NULL,
+    country     TEXT NOT NULL DEFAULT '',
+    status      SMALLINT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL,
+    version     INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT users_email_key UNIQUE (email),
+    CONSTRAINT users_nick_name_key UNIQUE (nick_name)
+);
+
+CREATE INDEX IF NOT EXISTS users_country_idx ON users (country);
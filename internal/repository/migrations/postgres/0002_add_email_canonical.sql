@@ -0,0 +1,2 @@
+-- canonical emails are set and their unique index is created by Go migration 0008_backfill_email_canonical after collisions are checked
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_canonical TEXT;
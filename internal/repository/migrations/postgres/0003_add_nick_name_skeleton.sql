@@ -0,0 +1,2 @@
+-- nickname skeletons are set and their unique index is created by Go migration 0009_backfill_nick_name_skeleton after collisions are checked
+ALTER TABLE users ADD COLUMN IF NOT EXISTS nick_name_skeleton TEXT;
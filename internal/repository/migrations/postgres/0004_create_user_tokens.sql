@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_tokens (
+    hash        TEXT PRIMARY KEY,
+    user_id     TEXT NOT NULL REFERENCES users (id),
+    purpose     TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_purpose_created_at_idx ON user_tokens (user_id, purpose, created_at);
+CREATE INDEX IF NOT EXISTS user_tokens_expires_at_idx ON user_tokens (expires_at);
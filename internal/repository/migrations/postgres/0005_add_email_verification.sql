@@ -0,0 +1,7 @@
+ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT '';
+
+-- tokens are deleted together with unverified users which expire
+ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_user_id_fkey;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS users_status_created_at_idx ON users (status, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id         TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    enabled         BOOLEAN NOT NULL,
+    secret          TEXT NOT NULL DEFAULT '',
+    pending_secret  TEXT NOT NULL DEFAULT '',
+    recovery_codes  TEXT[] NOT NULL DEFAULT '{}',
+    last_used_step  BIGINT NOT NULL DEFAULT 0,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL,
+    updated_at      TIMESTAMPTZ NOT NULL
+);
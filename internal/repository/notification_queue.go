@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notificationsCollection is the name of MongoDB collection of queued notifications
+const notificationsCollection = "notifications"
+
+// notificationJob is the document of a queued notification
+type notificationJob struct {
+	Id            string    `bson:"_id"`
+	To            string    `bson:"to"`
+	Subject       string    `bson:"subject"`
+	Text          string    `bson:"text"`
+	HTML          string    `bson:"html,omitempty"`
+	Status        string    `bson:"status"`
+	Attempts      int       `bson:"attempts"`
+	LastError     string    `bson:"lastError,omitempty"`
+	CreatedAt     time.Time `bson:"createdAt"`
+	NextAttemptAt time.Time `bson:"nextAttemptAt"`
+}
+
+func (j notificationJob) toJob() notification.Job {
+	return notification.Job{
+		Id:        j.Id,
+		Message:   notification.Message{To: j.To, Subject: j.Subject, Text: j.Text, HTML: j.HTML},
+		Attempts:  j.Attempts,
+		LastError: j.LastError,
+		CreatedAt: j.CreatedAt,
+	}
+}
+
+// notificationQueue is the MongoDB implementor of notification.Queue
+type notificationQueue struct {
+	collection *mongo.Collection
+}
+
+// NewNotificationQueue returns notification.Queue on MongoDB. Indexes of the collection are created by MongoMigrations.
+func NewNotificationQueue(db *mongohandler.MongoDBWrapper) notification.Queue {
+	return &notificationQueue{collection: db.Database.Collection(notificationsCollection)}
+}
+
+func (q *notificationQueue) Enqueue(ctx context.Context, msg notification.Message) error {
+	now := time.Now().UTC()
+	_, err := q.collection.InsertOne(ctx, notificationJob{
+		Id:            uuid.NewString(),
+		To:            msg.To,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		Status:        notification.JobStatusPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	})
+	return err
+}
+
+// Claim leases due jobs one by one since MongoDB can't update and return many documents atomically
+func (q *notificationQueue) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]notification.Job, error) {
+	now := time.Now().UTC()
+	claimed := []notification.Job{}
+	claimedIds := bson.A{}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	for len(claimed) < limit {
+		var job notificationJob
+		err := q.collection.FindOneAndUpdate(ctx,
+			bson.M{"status": notification.JobStatusPending, "nextAttemptAt": bson.M{"$lte": now}, "_id": bson.M{"$nin": claimedIds}},
+			bson.M{"$set": bson.M{"nextAttemptAt": leaseUntil.UTC()}},
+			opts,
+		).Decode(&job)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, job.toJob())
+		claimedIds = append(claimedIds, job.Id)
+	}
+	return claimed, nil
+}
+
+func (q *notificationQueue) Complete(ctx context.Context, id string) error {
+	_, err := q.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (q *notificationQueue) Retry(ctx context.Context, id string, nextAttemptAt time.Time, lastError string) error {
+	_, err := q.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"lastError": lastError, "nextAttemptAt": nextAttemptAt.UTC()},
+	})
+	return err
+}
+
+func (q *notificationQueue) Fail(ctx context.Context, id string, lastError string) error {
+	_, err := q.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"lastError": lastError, "status": notification.JobStatusFailed},
+	})
+	return err
+}
+
+func (q *notificationQueue) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := q.collection.DeleteMany(ctx, bson.M{"status": notification.JobStatusFailed, "createdAt": bson.M{"$lt": before.UTC()}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// createNotificationsIndex creates the index of claiming due notifications
+func createNotificationsIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(notificationsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	})
+	return err
+}
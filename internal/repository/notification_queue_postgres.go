@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsaltun/userapi/pkg/lib/db/pghandler"
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+)
+
+// postgresNotificationQueue is an implementor of notification.Queue on PostgreSQL
+type postgresNotificationQueue struct {
+	db *sql.DB
+}
+
+// NewPostgresNotificationQueue returns notification.Queue backed by PostgreSQL. The table is created by SQL migrations
+// of NewPostgresUserRepository.
+func NewPostgresNotificationQueue(pg *pghandler.PostgresWrapper) notification.Queue {
+	return &postgresNotificationQueue{pg.DB}
+}
+
+func (q *postgresNotificationQueue) Enqueue(ctx context.Context, msg notification.Message) error {
+	now := time.Now().UTC()
+	_, err := pghandler.Conn(ctx, q.db).ExecContext(ctx,
+		`INSERT INTO notifications (id, recipient, subject, text_body, html_body, status, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`,
+		uuid.NewString(), msg.To, msg.Subject, msg.Text, msg.HTML, notification.JobStatusPending, now)
+	return err
+}
+
+// Claim leases due jobs in a single statement. Rows locked by concurrent claims are skipped.
+func (q *postgresNotificationQueue) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]notification.Job, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`UPDATE notifications SET next_attempt_at = $1
+		WHERE id IN (
+			SELECT id FROM notifications WHERE status = $2 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at LIMIT $4 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, subject, text_body, html_body, attempts, last_error, created_at`,
+		leaseUntil.UTC(), notification.JobStatusPending, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := []notification.Job{}
+	for rows.Next() {
+		var job notification.Job
+		if err := rows.Scan(&job.Id, &job.Message.To, &job.Message.Subject, &job.Message.Text, &job.Message.HTML,
+			&job.Attempts, &job.LastError, &job.CreatedAt); err != nil {
+			return nil, err
+		}
+		job.CreatedAt = job.CreatedAt.UTC()
+		claimed = append(claimed, job)
+	}
+	return claimed, rows.Err()
+}
+
+func (q *postgresNotificationQueue) Complete(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, "DELETE FROM notifications WHERE id = $1", id)
+	return err
+}
+
+func (q *postgresNotificationQueue) Retry(ctx context.Context, id string, nextAttemptAt time.Time, lastError string) error {
+	_, err := q.db.ExecContext(ctx,
+		"UPDATE notifications SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1",
+		id, lastError, nextAttemptAt.UTC())
+	return err
+}
+
+func (q *postgresNotificationQueue) Fail(ctx context.Context, id string, lastError string) error {
+	_, err := q.db.ExecContext(ctx,
+		"UPDATE notifications SET attempts = attempts + 1, last_error = $2, status = $3 WHERE id = $1",
+		id, lastError, notification.JobStatusFailed)
+	return err
+}
+
+func (q *postgresNotificationQueue) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := q.db.ExecContext(ctx, "DELETE FROM notifications WHERE status = $1 AND created_at < $2",
+		notification.JobStatusFailed, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
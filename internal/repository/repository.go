@@ -2,16 +2,62 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/nsaltun/userapi/internal/model"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 // UserRepository interface
 type UserRepository interface {
+	// Create inserts the user with a new id. Status of the user is Active unless it is set.
 	Create(ctx context.Context, user *model.User) error
 	Update(ctx context.Context, id string, user *model.User) (*model.User, error)
-	ListByFilter(ctx context.Context, filter bson.M, limit int, offset int) ([]model.User, int64, error)
+	ListByFilter(ctx context.Context, filter model.UserFilter, limit int, offset int) ([]model.User, int64, error)
 	Delete(ctx context.Context, id string) error
 	Get(ctx context.Context, id string) (*model.User, error)
+	// SetPassword sets the password hash of the user and bumps Version and UpdatedAt.
+	// Returns NotFound when there is no user with the id.
+	SetPassword(ctx context.Context, id string, hash string) error
+	// ReplacePasswordHash replaces the password hash of the user only when it is still the current hash so that
+	// a concurrent password change isn't overwritten. Version and UpdatedAt are not changed.
+	// Returns NotFound when there is no user with the id and the current hash.
+	ReplacePasswordHash(ctx context.Context, id string, current string, hash string) error
+	// DeleteUnverified deletes users pending verification which are created before the time permanently so that
+	// their email and nickName can be registered again. Returns the number of deleted users.
+	DeleteUnverified(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// UserTokenRepository stores single use tokens of users by their hashes. Expired tokens are never returned.
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *model.UserToken) error
+	// Get returns the unexpired token of the hash and purpose. Returns ErrUserTokenInvalid when there is none.
+	Get(ctx context.Context, hash string, purpose model.UserTokenPurpose) (*model.UserToken, error)
+	// Consume deletes the unexpired token of the hash and purpose so that it is used once.
+	// Returns ErrUserTokenInvalid when there is none, e.g. it is consumed concurrently.
+	Consume(ctx context.Context, hash string, purpose model.UserTokenPurpose) error
+	// CountCreatedSince returns the number of tokens of the user and purpose created since the time, consumed tokens
+	// and expired tokens which are removed aren't counted
+	CountCreatedSince(ctx context.Context, userId string, purpose model.UserTokenPurpose, since time.Time) (int64, error)
+	// DeleteByUser deletes tokens of the user with the purpose
+	DeleteByUser(ctx context.Context, userId string, purpose model.UserTokenPurpose) error
+}
+
+// UserMfaRepository stores multi-factor authentication of users. Methods return ErrUserMfaNotEnabled when the user
+// has no MFA.
+type UserMfaRepository interface {
+	Get(ctx context.Context, userId string) (*model.UserMfa, error)
+	// Save creates or replaces MFA of the user
+	Save(ctx context.Context, mfa *model.UserMfa) error
+	Delete(ctx context.Context, userId string) error
+	// UseStep records the TOTP time step as used and resets failed attempts when the step is after the last used step.
+	// Returns ErrUserMfaCodeInvalid when the step or a later step is already used, e.g. the code is replayed.
+	UseStep(ctx context.Context, userId string, step int64) error
+	// UseRecoveryCode removes the recovery code hash so that the code is used once and resets failed attempts.
+	// Returns ErrUserMfaCodeInvalid when there is no such unused code.
+	UseRecoveryCode(ctx context.Context, userId string, hash string) error
+	// RecordAttempt counts an attempt of a code of the user at the time, before the code is verified, and returns the
+	// number of attempts since the last valid code. Attempts older than the window are forgotten. The check and the
+	// increment are atomic so that concurrent attempts can't exceed max.
+	// Returns ErrUserMfaLocked without counting when the user has max attempts in the window before the time.
+	RecordAttempt(ctx context.Context, userId string, at time.Time, max int, window time.Duration) (int, error)
 }
@@ -0,0 +1,205 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// UserMfaRepositoryFactory returns empty user and MFA repositories on the same storage for each test case, MFA
+// belongs to users of the user repository.
+type UserMfaRepositoryFactory func(t *testing.T) (repository.UserRepository, repository.UserMfaRepository)
+
+// RunUserMfaRepositorySuite runs the UserMfaRepository contract tests against repositories created by the factory.
+func RunUserMfaRepositorySuite(t *testing.T, newRepos UserMfaRepositoryFactory) {
+	tests := []struct {
+		name string
+		run  func(*testing.T, string, repository.UserMfaRepository)
+	}{
+		{"save and get", testMfaSave},
+		{"get and delete without mfa", testMfaNotEnabled},
+		{"use step rejects replayed steps", testMfaUseStep},
+		{"recovery code is single use", testMfaUseRecoveryCode},
+		{"concurrent recovery codes succeed once", testMfaConcurrentRecoveryCode},
+		{"record attempts until lockout or a valid code", testMfaRecordAttempt},
+		{"concurrent attempts don't exceed max", testMfaConcurrentAttempts},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			users, mfa := newRepos(tt)
+			user := newUser("john")
+			require.NoError(tt, users.Create(context.Background(), user))
+			tCase.run(tt, user.Id, mfa)
+		})
+	}
+}
+
+func testMfaSave(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	mfa := newMfa(userId)
+	require.NoError(t, repo.Save(ctx, mfa))
+
+	found, err := repo.Get(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, mfa.UserId, found.UserId)
+	require.True(t, found.Enabled)
+	require.Equal(t, mfa.Secret, found.Secret)
+	require.Empty(t, found.PendingSecret)
+	require.Equal(t, mfa.RecoveryCodes, found.RecoveryCodes)
+	require.WithinDuration(t, mfa.CreatedAt, found.CreatedAt, time.Millisecond)
+
+	mfa.PendingSecret = "pending"
+	mfa.RecoveryCodes = nil
+	require.NoError(t, repo.Save(ctx, mfa))
+
+	found, err = repo.Get(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, "pending", found.PendingSecret)
+	require.Empty(t, found.RecoveryCodes)
+
+	require.NoError(t, repo.Delete(ctx, userId))
+	_, err = repo.Get(ctx, userId)
+	requireError(t, model.ErrUserMfaNotEnabled, err)
+}
+
+func testMfaNotEnabled(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	_, err := repo.Get(ctx, userId)
+	requireError(t, model.ErrUserMfaNotEnabled, err)
+	requireError(t, model.ErrUserMfaNotEnabled, repo.Delete(ctx, userId))
+	_, err = repo.RecordAttempt(ctx, userId, time.Now(), 3, time.Minute)
+	requireError(t, model.ErrUserMfaNotEnabled, err)
+	requireError(t, model.ErrUserMfaCodeInvalid, repo.UseStep(ctx, userId, 1))
+	requireError(t, model.ErrUserMfaCodeInvalid, repo.UseRecoveryCode(ctx, userId, "first"))
+}
+
+func testMfaUseStep(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, newMfa(userId)))
+
+	require.NoError(t, repo.UseStep(ctx, userId, 100))
+	requireError(t, model.ErrUserMfaCodeInvalid, repo.UseStep(ctx, userId, 100))
+	requireError(t, model.ErrUserMfaCodeInvalid, repo.UseStep(ctx, userId, 99))
+	require.NoError(t, repo.UseStep(ctx, userId, 101))
+
+	found, err := repo.Get(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, int64(101), found.LastUsedStep)
+}
+
+func testMfaUseRecoveryCode(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, newMfa(userId)))
+
+	require.NoError(t, repo.UseRecoveryCode(ctx, userId, "first"))
+	requireError(t, model.ErrUserMfaCodeInvalid, repo.UseRecoveryCode(ctx, userId, "first"))
+	requireError(t, model.ErrUserMfaCodeInvalid, repo.UseRecoveryCode(ctx, userId, "unknown"))
+
+	found, err := repo.Get(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, []string{"second"}, found.RecoveryCodes)
+}
+
+func testMfaConcurrentRecoveryCode(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, newMfa(userId)))
+
+	const workers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.UseRecoveryCode(ctx, userId, "first")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		requireError(t, model.ErrUserMfaCodeInvalid, err)
+	}
+	require.Equal(t, 1, succeeded)
+}
+
+func testMfaRecordAttempt(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, newMfa(userId)))
+
+	at := time.Now().UTC()
+	for expected := 1; expected <= 3; expected++ {
+		count, err := repo.RecordAttempt(ctx, userId, at, 3, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, expected, count)
+	}
+	_, err := repo.RecordAttempt(ctx, userId, at.Add(59*time.Second), 3, time.Minute)
+	requireError(t, model.ErrUserMfaLocked, err)
+	found, err := repo.Get(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, 3, found.FailedAttempts)
+	require.WithinDuration(t, at, found.LastFailureAt, time.Millisecond)
+
+	// attempts are forgotten after the window, an attempt doesn't lock the user again
+	count, err := repo.RecordAttempt(ctx, userId, at.Add(time.Minute), 3, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	require.NoError(t, repo.UseStep(ctx, userId, 1))
+	found, err = repo.Get(ctx, userId)
+	require.NoError(t, err)
+	require.Equal(t, 0, found.FailedAttempts)
+}
+
+func testMfaConcurrentAttempts(t *testing.T, userId string, repo repository.UserMfaRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, newMfa(userId)))
+
+	const workers, max = 20, 5
+	at := time.Now().UTC()
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.RecordAttempt(ctx, userId, at, max, time.Minute)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	counted := 0
+	for err := range errs {
+		if err == nil {
+			counted++
+			continue
+		}
+		requireError(t, model.ErrUserMfaLocked, err)
+	}
+	require.Equal(t, max, counted)
+}
+
+// newMfa returns enabled MFA of the user with recovery code hashes `first` and `second`
+func newMfa(userId string) *model.UserMfa {
+	now := time.Now().UTC()
+	return &model.UserMfa{
+		UserId:        userId,
+		Enabled:       true,
+		Secret:        "secret",
+		RecoveryCodes: []string{"first", "second"},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
@@ -0,0 +1,520 @@
+// Package repositorytest provides a conformance test suite for repository.UserRepository implementations.
+//
+// Every implementation should pass the same suite so that storages are interchangeable:
+//
+//	func TestMyRepository(t *testing.T) {
+//		repositorytest.RunUserRepositorySuite(t, func(t *testing.T) repository.UserRepository {
+//			return newEmptyRepository(t)
+//		})
+//	}
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/nickname"
+	"github.com/stretchr/testify/require"
+)
+
+// RepositoryFactory returns an empty repository for each test case. Cleanup can be registered with t.Cleanup.
+type RepositoryFactory func(t *testing.T) repository.UserRepository
+
+// RunUserRepositorySuite runs the UserRepository contract tests against repositories created by the factory.
+func RunUserRepositorySuite(t *testing.T, newRepo RepositoryFactory) {
+	tests := []struct {
+		name string
+		run  func(*testing.T, repository.UserRepository)
+	}{
+		{"create assigns id, status and meta", testCreate},
+		{"create keeps the given status", testCreateStatus},
+		{"create conflicts on email and nickName", testCreateConflict},
+		{"canonical emails are unique", testCanonicalEmailConflict},
+		{"nickname skeletons are unique", testNickNameSkeletonConflict},
+		{"get returns not found", testGetNotFound},
+		{"update changes only updatable fields", testUpdateFields},
+		{"update bumps version", testUpdateVersion},
+		{"update conflicts with other users", testUpdateConflict},
+		{"updated nickname skeletons stay unique", testUpdateNickNameSkeleton},
+		{"update returns not found", testUpdateNotFound},
+		{"delete is soft", testDelete},
+		{"delete returns not found", testDeleteNotFound},
+		{"set password bumps version", testSetPassword},
+		{"set password returns not found", testSetPasswordNotFound},
+		{"replace password hash only when it is current", testReplacePasswordHash},
+		{"delete unverified deletes old pending users", testDeleteUnverified},
+		{"list filter semantics", testListFilter},
+		{"list pagination and ordering", testListPagination},
+		{"concurrent creates with the same email", testConcurrentCreate},
+		{"concurrent updates bump version once each", testConcurrentUpdate},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			tCase.run(tt, newRepo(tt))
+		})
+	}
+}
+
+func testCreate(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	user.Password = "hash"
+
+	require.NoError(t, repo.Create(ctx, user))
+
+	require.NotEmpty(t, user.Id)
+	require.Empty(t, user.Password, "password should not be returned")
+	require.Equal(t, model.UserStatus_Active, user.Status)
+	require.False(t, user.CreatedAt.IsZero())
+	require.Equal(t, int32(0), user.Version)
+
+	stored, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, "hash", stored.Password, "password hash should be stored")
+	require.Equal(t, user.Email, stored.Email)
+}
+
+func testCreateStatus(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	user.Status = model.UserStatus_PendingVerification
+	require.NoError(t, repo.Create(ctx, user))
+
+	stored, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, model.UserStatus_PendingVerification, stored.Status)
+
+	unset := newUser("jane")
+	unset.Status = 0
+	require.NoError(t, repo.Create(ctx, unset))
+	require.Equal(t, model.UserStatus_Active, unset.Status)
+}
+
+func testCreateConflict(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newUser("john")))
+
+	sameEmail := newUser("other")
+	sameEmail.Email, sameEmail.EmailCanonical = "john@email.com", "john@email.com"
+	requireError(t, model.ErrUserEmailTaken, repo.Create(ctx, sameEmail))
+
+	sameNick := newUser("john")
+	sameNick.Email, sameNick.EmailCanonical = "other@email.com", "other@email.com"
+	requireError(t, model.ErrUserNicknameTaken, repo.Create(ctx, sameNick))
+}
+
+func testCanonicalEmailConflict(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	john := newUser("john")
+	john.Email = "John@Email.com"
+	require.NoError(t, repo.Create(ctx, john))
+
+	sameCanonical := newUser("other")
+	sameCanonical.Email, sameCanonical.EmailCanonical = "john@email.com", "john@email.com"
+	requireError(t, model.ErrUserEmailTaken, repo.Create(ctx, sameCanonical))
+
+	jane := newUser("jane")
+	require.NoError(t, repo.Create(ctx, jane))
+	jane.Email, jane.EmailCanonical = "JOHN@email.com", "john@email.com"
+	_, err := repo.Update(ctx, jane.Id, jane)
+	requireError(t, model.ErrUserEmailTaken, err)
+
+	// users without canonical email don't conflict with each other
+	for _, nickName := range []string{"a", "b"} {
+		user := newUser(nickName)
+		user.EmailCanonical = ""
+		require.NoError(t, repo.Create(ctx, user))
+	}
+}
+
+func testNickNameSkeletonConflict(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newUser("john.doe")))
+
+	lookAlike := newUser("J0hn_Doe")
+	requireError(t, model.ErrUserNicknameTaken, repo.Create(ctx, lookAlike))
+
+	jane := newUser("jane")
+	require.NoError(t, repo.Create(ctx, jane))
+	jane.NickName, jane.NickNameSkeleton = "jоhndое", nickname.Skeleton("jоhndое") // Cyrillic о and е
+	_, err := repo.Update(ctx, jane.Id, jane)
+	requireError(t, model.ErrUserNicknameTaken, err)
+
+	// users without skeleton don't conflict with each other
+	for _, nickName := range []string{"a", "b"} {
+		user := newUser(nickName)
+		user.NickNameSkeleton = ""
+		require.NoError(t, repo.Create(ctx, user))
+	}
+}
+
+func testGetNotFound(t *testing.T, repo repository.UserRepository) {
+	_, err := repo.Get(context.Background(), "unknown")
+	requireError(t, model.ErrUserNotFound, err)
+}
+
+func testUpdateFields(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	user.Password = "hash"
+	require.NoError(t, repo.Create(ctx, user))
+	created, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+
+	update := &model.User{
+		Id:        "ignored",
+		FirstName: "Johnny",
+		LastName:  "Doe",
+		NickName:  "johnny",
+		Email:     "johnny@email.com",
+		Country:   "DE",
+		Password:  "new_hash",
+		Status:    model.UserStatus_Active,
+	}
+	updated, err := repo.Update(ctx, user.Id, update)
+	require.NoError(t, err)
+
+	require.Equal(t, user.Id, updated.Id)
+	require.Equal(t, "Johnny", updated.FirstName)
+	require.Equal(t, "Doe", updated.LastName)
+	require.Equal(t, "johnny", updated.NickName)
+	require.Equal(t, "johnny@email.com", updated.Email)
+	require.Equal(t, "DE", updated.Country)
+	require.Empty(t, updated.Password, "password should not be returned")
+	require.True(t, created.CreatedAt.Equal(updated.CreatedAt), "createdAt should not change")
+	require.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+
+	stored, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, "hash", stored.Password, "password should not be updated")
+}
+
+func testUpdateVersion(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	require.NoError(t, repo.Create(ctx, user))
+
+	for expected := int32(1); expected <= 2; expected++ {
+		updated, err := repo.Update(ctx, user.Id, newUser("john"))
+		require.NoError(t, err)
+		require.Equal(t, expected, updated.Version)
+	}
+}
+
+func testUpdateConflict(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	john := newUser("john")
+	require.NoError(t, repo.Create(ctx, john))
+	require.NoError(t, repo.Create(ctx, newUser("jane")))
+
+	sameEmail := newUser("john")
+	sameEmail.Email, sameEmail.EmailCanonical = "jane@email.com", "jane@email.com"
+	_, err := repo.Update(ctx, john.Id, sameEmail)
+	requireError(t, model.ErrUserEmailTaken, err)
+
+	sameNick := newUser("jane")
+	sameNick.Email, sameNick.EmailCanonical = "john@email.com", "john@email.com"
+	_, err = repo.Update(ctx, john.Id, sameNick)
+	requireError(t, model.ErrUserNicknameTaken, err)
+
+	// keeping own email and nickName is not a conflict
+	_, err = repo.Update(ctx, john.Id, newUser("john"))
+	require.NoError(t, err)
+}
+
+func testUpdateNickNameSkeleton(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	john := newUser("john.doe")
+	require.NoError(t, repo.Create(ctx, john))
+	jane := newUser("jane")
+	require.NoError(t, repo.Create(ctx, jane))
+
+	renamed := newUser("J0hn_Doe")
+	renamed.Email, renamed.EmailCanonical = jane.Email, jane.EmailCanonical
+	_, err := repo.Update(ctx, jane.Id, renamed)
+	requireError(t, model.ErrUserNicknameTaken, err)
+
+	// the skeleton is updated with the nickname and still conflicts afterwards
+	_, err = repo.Update(ctx, john.Id, newUser("john.doe"))
+	require.NoError(t, err)
+	requireError(t, model.ErrUserNicknameTaken, repo.Create(ctx, newUser("JOHN-D0E")))
+}
+
+func testUpdateNotFound(t *testing.T, repo repository.UserRepository) {
+	_, err := repo.Update(context.Background(), "unknown", newUser("john"))
+	requireHttpCode(t, http.StatusNotFound, err)
+}
+
+func testDelete(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	require.NoError(t, repo.Create(ctx, user))
+
+	require.NoError(t, repo.Delete(ctx, user.Id))
+
+	deleted, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err, "deleted user should still be stored")
+	require.Equal(t, model.UserStatus_Inactive, deleted.Status)
+
+	users, total, err := repo.ListByFilter(ctx, model.UserFilter{}, 20, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), total)
+	require.Empty(t, users)
+
+	// email and nickName of a deleted user stay reserved
+	requireHttpCode(t, http.StatusConflict, repo.Create(ctx, newUser("john")))
+}
+
+func testDeleteNotFound(t *testing.T, repo repository.UserRepository) {
+	requireHttpCode(t, http.StatusNotFound, repo.Delete(context.Background(), "unknown"))
+}
+
+func testSetPassword(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	user.Password = "old-hash"
+	require.NoError(t, repo.Create(ctx, user))
+	created, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SetPassword(ctx, user.Id, "new-hash"))
+
+	stored, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, "new-hash", stored.Password)
+	require.Equal(t, created.Version+1, stored.Version)
+	require.False(t, stored.UpdatedAt.Before(created.UpdatedAt))
+}
+
+func testSetPasswordNotFound(t *testing.T, repo repository.UserRepository) {
+	requireError(t, model.ErrUserNotFound, repo.SetPassword(context.Background(), "unknown", "new-hash"))
+}
+
+func testReplacePasswordHash(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("john")
+	user.Password = "old-hash"
+	require.NoError(t, repo.Create(ctx, user))
+	created, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+
+	requireError(t, model.ErrUserNotFound, repo.ReplacePasswordHash(ctx, user.Id, "other-hash", "new-hash"))
+	requireError(t, model.ErrUserNotFound, repo.ReplacePasswordHash(ctx, "unknown", "old-hash", "new-hash"))
+	require.NoError(t, repo.ReplacePasswordHash(ctx, user.Id, "old-hash", "new-hash"))
+
+	stored, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, "new-hash", stored.Password)
+	require.Equal(t, created.Version, stored.Version, "version should not change")
+	require.Equal(t, created.UpdatedAt, stored.UpdatedAt, "updatedAt should not change")
+}
+
+func testDeleteUnverified(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	pending := newUser("john")
+	pending.Status = model.UserStatus_PendingVerification
+	require.NoError(t, repo.Create(ctx, pending))
+	active := newUser("jane")
+	require.NoError(t, repo.Create(ctx, active))
+
+	deleted, err := repo.DeleteUnverified(ctx, pending.CreatedAt.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), deleted, "users created after the time should be kept")
+
+	deleted, err = repo.DeleteUnverified(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	_, err = repo.Get(ctx, pending.Id)
+	requireError(t, model.ErrUserNotFound, err)
+	_, err = repo.Get(ctx, active.Id)
+	require.NoError(t, err, "active users should be kept")
+
+	// email and nickName of deleted users can be registered again
+	require.NoError(t, repo.Create(ctx, newUser("john")))
+}
+
+func testListFilter(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	ids := map[string]string{}
+	for _, u := range []*model.User{
+		{FirstName: "John", LastName: "Doe", NickName: "john", Email: "john@email.com", Country: "TR"},
+		{FirstName: "johnny", LastName: "Smith", NickName: "johnny", Email: "johnny@email.com", Country: "DE"},
+		{FirstName: "Jane", LastName: "doe", NickName: "jane", Email: "jane@email.com", Country: "TR"},
+		{FirstName: "Jo", LastName: "Black", NickName: "jo", Email: "jo@email.com", Country: "TR"},
+	} {
+		require.NoError(t, repo.Create(ctx, u))
+		ids[u.NickName] = u.Id
+	}
+	require.NoError(t, repo.Delete(ctx, ids["jo"]))
+
+	tests := []struct {
+		name     string
+		filter   model.UserFilter
+		expected []string
+	}{
+		{"empty filter returns only active users", model.UserFilter{}, []string{"john", "johnny", "jane"}},
+		{"id is exact match", model.UserFilter{Id: ids["jane"]}, []string{"jane"}},
+		{"firstName is case insensitive prefix", model.UserFilter{FirstName: "JOHN"}, []string{"john", "johnny"}},
+		{"lastName is case insensitive prefix", model.UserFilter{LastName: "do"}, []string{"john", "jane"}},
+		{"nickName is exact match", model.UserFilter{NickName: "john"}, []string{"john"}},
+		{"email is exact match", model.UserFilter{Email: "jane@email.com"}, []string{"jane"}},
+		{"country is exact match", model.UserFilter{Country: "TR"}, []string{"john", "jane"}},
+		{"fields are combined", model.UserFilter{FirstName: "j", Country: "DE"}, []string{"johnny"}},
+		{"inactive status", model.UserFilter{Status: model.UserStatus_Inactive}, []string{"jo"}},
+		{"negative status returns all", model.UserFilter{Status: -1}, []string{"john", "johnny", "jane", "jo"}},
+		{"no match", model.UserFilter{Country: "US"}, []string{}},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			users, total, err := repo.ListByFilter(ctx, tCase.filter, 20, 0)
+
+			require.NoError(tt, err)
+			require.Equal(tt, int64(len(tCase.expected)), total)
+			require.ElementsMatch(tt, tCase.expected, nickNames(users))
+			for _, u := range users {
+				require.Empty(tt, u.Password, "password should not be returned")
+			}
+		})
+	}
+}
+
+func testListPagination(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repo.Create(ctx, newUser(fmt.Sprintf("user%d", i))))
+	}
+
+	// users are ordered by creation time. Storages may keep timestamps with less precision
+	// so order of users created at the same time is only required to be stable.
+	all, _, err := repo.ListByFilter(ctx, model.UserFilter{}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+	for i := 1; i < len(all); i++ {
+		require.False(t, all[i].CreatedAt.Before(all[i-1].CreatedAt), "users should be ordered by creation")
+	}
+	expected := nickNames(all)
+
+	tests := []struct {
+		name     string
+		limit    int
+		offset   int
+		expected []string
+	}{
+		{"first page", 2, 0, expected[0:2]},
+		{"middle page", 2, 2, expected[2:4]},
+		{"last page", 2, 4, expected[4:5]},
+		{"offset after the end", 2, 10, []string{}},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			users, total, err := repo.ListByFilter(ctx, model.UserFilter{}, tCase.limit, tCase.offset)
+
+			require.NoError(tt, err)
+			require.Equal(tt, int64(5), total, "total should not depend on pagination")
+			require.Equal(tt, tCase.expected, nickNames(users), "pages should follow the same order")
+		})
+	}
+}
+
+func testConcurrentCreate(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	const workers = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := newUser(fmt.Sprintf("user%d", i))
+			user.Email, user.EmailCanonical = "same@email.com", "same@email.com"
+			errs <- repo.Create(ctx, user)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		requireHttpCode(t, http.StatusConflict, err)
+	}
+	require.Equal(t, 1, succeeded)
+}
+
+func testConcurrentUpdate(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	const workers = 10
+	user := newUser("john")
+	require.NoError(t, repo.Create(ctx, user))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Update(ctx, user.Id, newUser("john"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	stored, err := repo.Get(ctx, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, int32(workers), stored.Version)
+}
+
+// newUser returns an active user with unique fields derived from nickName
+func newUser(nickName string) *model.User {
+	return &model.User{
+		FirstName:        nickName,
+		NickName:         nickName,
+		NickNameSkeleton: nickname.Skeleton(nickName),
+		Email:            nickName + "@email.com",
+		EmailCanonical:   strings.ToLower(nickName + "@email.com"),
+		Country:          "TR",
+		Status:           model.UserStatus_Active,
+	}
+}
+
+// nickNames returns nickNames of the users in the same order
+func nickNames(users []model.User) []string {
+	names := []string{}
+	for _, u := range users {
+		names = append(names, u.NickName)
+	}
+	return names
+}
+
+// requireHttpCode asserts that err is an errwrap.IError with the given http code
+func requireHttpCode(t *testing.T, expected int, err error) {
+	t.Helper()
+	require.Error(t, err)
+	iErr, ok := err.(errwrap.IError)
+	require.True(t, ok, "error should be errwrap.IError: %v", err)
+	require.Equal(t, expected, iErr.HttpCode(), "unexpected error: %v", err)
+}
+
+// requireError checks that err has the code and http code of the expected error
+func requireError(t *testing.T, expected errwrap.IError, err error) {
+	t.Helper()
+	requireHttpCode(t, expected.HttpCode(), err)
+	require.Equal(t, expected.ErrorResp().Code, err.(errwrap.IError).ErrorResp().Code, "unexpected error: %v", err)
+}
@@ -0,0 +1,154 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// UserTokenRepositoryFactory returns empty user and token repositories on the same storage for each test case, tokens
+// belong to users of the user repository.
+type UserTokenRepositoryFactory func(t *testing.T) (repository.UserRepository, repository.UserTokenRepository)
+
+// RunUserTokenRepositorySuite runs the UserTokenRepository contract tests against repositories created by the factory.
+func RunUserTokenRepositorySuite(t *testing.T, newRepos UserTokenRepositoryFactory) {
+	tests := []struct {
+		name string
+		run  func(*testing.T, string, repository.UserTokenRepository)
+	}{
+		{"get returns unexpired token of the purpose", testTokenGet},
+		{"get returns email of the token", testTokenEmail},
+		{"consume is single use", testTokenConsume},
+		{"concurrent consumes succeed once", testTokenConcurrentConsume},
+		{"count tokens created since", testTokenCount},
+		{"delete tokens of the user", testTokenDeleteByUser},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			users, tokens := newRepos(tt)
+			user := newUser("john")
+			require.NoError(tt, users.Create(context.Background(), user))
+			tCase.run(tt, user.Id, tokens)
+		})
+	}
+}
+
+func testTokenGet(t *testing.T, userId string, repo repository.UserTokenRepository) {
+	ctx := context.Background()
+	token := newToken("hash", userId, time.Hour)
+	require.NoError(t, repo.Create(ctx, token))
+	require.NoError(t, repo.Create(ctx, newToken("expired", userId, -time.Minute)))
+
+	found, err := repo.Get(ctx, "hash", model.UserTokenPurpose_PasswordReset)
+	require.NoError(t, err)
+	require.Equal(t, token.UserId, found.UserId)
+	require.Equal(t, token.Purpose, found.Purpose)
+	require.WithinDuration(t, token.ExpiresAt, found.ExpiresAt, time.Millisecond)
+
+	_, err = repo.Get(ctx, "hash", "other_purpose")
+	requireError(t, model.ErrUserTokenInvalid, err)
+	_, err = repo.Get(ctx, "expired", model.UserTokenPurpose_PasswordReset)
+	requireError(t, model.ErrUserTokenInvalid, err)
+	_, err = repo.Get(ctx, "unknown", model.UserTokenPurpose_PasswordReset)
+	requireError(t, model.ErrUserTokenInvalid, err)
+}
+
+func testTokenEmail(t *testing.T, userId string, repo repository.UserTokenRepository) {
+	ctx := context.Background()
+	token := newToken("hash", userId, time.Hour)
+	token.Purpose, token.Email = model.UserTokenPurpose_EmailChange, "new@email.com"
+	require.NoError(t, repo.Create(ctx, token))
+
+	found, err := repo.Get(ctx, "hash", model.UserTokenPurpose_EmailChange)
+	require.NoError(t, err)
+	require.Equal(t, "new@email.com", found.Email)
+}
+
+func testTokenConsume(t *testing.T, userId string, repo repository.UserTokenRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newToken("hash", userId, time.Hour)))
+	require.NoError(t, repo.Create(ctx, newToken("expired", userId, -time.Minute)))
+
+	requireError(t, model.ErrUserTokenInvalid, repo.Consume(ctx, "hash", "other_purpose"))
+	require.NoError(t, repo.Consume(ctx, "hash", model.UserTokenPurpose_PasswordReset))
+	requireError(t, model.ErrUserTokenInvalid, repo.Consume(ctx, "hash", model.UserTokenPurpose_PasswordReset))
+	requireError(t, model.ErrUserTokenInvalid, repo.Consume(ctx, "expired", model.UserTokenPurpose_PasswordReset))
+
+	_, err := repo.Get(ctx, "hash", model.UserTokenPurpose_PasswordReset)
+	requireError(t, model.ErrUserTokenInvalid, err)
+}
+
+func testTokenConcurrentConsume(t *testing.T, userId string, repo repository.UserTokenRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newToken("hash", userId, time.Hour)))
+
+	const workers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Consume(ctx, "hash", model.UserTokenPurpose_PasswordReset)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		requireError(t, model.ErrUserTokenInvalid, err)
+	}
+	require.Equal(t, 1, succeeded)
+}
+
+func testTokenCount(t *testing.T, userId string, repo repository.UserTokenRepository) {
+	ctx := context.Background()
+	old := newToken("old", userId, time.Hour)
+	old.CreatedAt = time.Now().UTC().Add(-2 * time.Hour)
+	require.NoError(t, repo.Create(ctx, old))
+	require.NoError(t, repo.Create(ctx, newToken("first", userId, time.Hour)))
+	require.NoError(t, repo.Create(ctx, newToken("second", userId, time.Hour)))
+
+	count, err := repo.CountCreatedSince(ctx, userId, model.UserTokenPurpose_PasswordReset, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	count, err = repo.CountCreatedSince(ctx, userId, "other_purpose", time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), count)
+}
+
+func testTokenDeleteByUser(t *testing.T, userId string, repo repository.UserTokenRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newToken("first", userId, time.Hour)))
+	require.NoError(t, repo.Create(ctx, newToken("second", userId, time.Hour)))
+
+	require.NoError(t, repo.DeleteByUser(ctx, userId, model.UserTokenPurpose_PasswordReset))
+
+	for _, hash := range []string{"first", "second"} {
+		_, err := repo.Get(ctx, hash, model.UserTokenPurpose_PasswordReset)
+		requireError(t, model.ErrUserTokenInvalid, err)
+	}
+}
+
+// newToken returns a password reset token of the user which expires after ttl
+func newToken(hash string, userId string, ttl time.Duration) *model.UserToken {
+	now := time.Now().UTC()
+	return &model.UserToken{
+		Hash:      hash,
+		UserId:    userId,
+		Purpose:   model.UserTokenPurpose_PasswordReset,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
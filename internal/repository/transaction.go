@@ -0,0 +1,19 @@
+package repository
+
+import "context"
+
+// Transactor runs a unit of work atomically. Repository calls made with the context given to fn
+// are part of the same transaction. fn may run more than once on transient errors so it should not have
+// side effects outside of the storage.
+//
+// Implemented by mongohandler.MongoDBWrapper and pghandler.PostgresWrapper.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// NoopTransactor runs fn without a transaction. It is meant for storages without transaction support like in-memory.
+type NoopTransactor struct{}
+
+func (NoopTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -3,73 +3,76 @@ package repository
 import (
 	"context"
 	"log/slog"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/nsaltun/userapi/internal/model"
 	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/email"
 	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/nickname"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Fields of unique forms of users
+const (
+	// canonicalEmailField is the field of canonical emails which has the unique index of emails
+	canonicalEmailField = "emailCanonical"
+	// nickNameSkeletonField is the field of confusable skeletons of nicknames which has the unique index of nicknames
+	nickNameSkeletonField = "nickNameSkeleton"
+)
+
+// uniqueFormSources maps fields of unique forms to the unique fields they are derived from. Unique forms have unique
+// indexes so that values which look the same, e.g. emails differing by case, can't belong to different users.
+var uniqueFormSources = map[string]string{
+	canonicalEmailField:   model.UniqueFieldEmail,
+	nickNameSkeletonField: model.UniqueFieldNickName,
+}
+
+// uniqueFormFuncs returns functions computing unique forms from plaintext values of their unique fields by form fields
+func uniqueFormFuncs() map[string]func(string) string {
+	return map[string]func(string) string{
+		canonicalEmailField:   email.NewCanonicalizer().Canonical,
+		nickNameSkeletonField: nickname.Skeleton,
+	}
+}
+
 // userRepository implementor
 type userRepository struct {
 	collection *mongo.Collection
+	encryption *UserEncryption // nil when PII encryption is disabled
 }
 
 // NewUserRepository returns new instance to be able to use UserRepository interface methods.
 //
-// Creates index in this method
+// NOTE: Indexes are managed by MongoMigrations so migrations should be applied before using the repository.
 func NewUserRepository(db *mongohandler.MongoDBWrapper) (UserRepository, error) {
-	repo := &userRepository{db.Collection("users")}
-	err := repo.createIndexes()
-	if err != nil {
-		return nil, err
-	}
-	return repo, nil
+	return &userRepository{db.Collection(usersCollection), nil}, nil
 }
 
-// createIndexes creates indexes specific to the User collection
-//
-// Creating index for `email`(unique) and `nickName`(unique) and `country`.
-func (r *userRepository) createIndexes() error {
-	// Define index models
-	indexModels := []mongo.IndexModel{
-		{
-			Keys:    bson.D{{Key: "email", Value: 1}}, // Ascending index on email
-			Options: options.Index().SetUnique(true),  // Unique constraint
-		},
-		{
-			Keys:    bson.D{{Key: "country", Value: 1}}, // Ascending index on country
-			Options: options.Index(),                    // Background creation
-		},
-		{
-			Keys:    bson.D{{Key: "nickName", Value: 1}}, // Ascending index on nickName
-			Options: options.Index().SetUnique(true),     // Unique constraint
-		},
-	}
-
-	// Create indexes
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_, err := r.collection.Indexes().CreateMany(ctx, indexModels)
-	if err != nil {
-		slog.ErrorContext(ctx, "Error creating indexes for users collection", slog.Any("error", err))
-		return err
-	}
-
-	slog.InfoContext(ctx, "Indexes created successfully for users collection.")
-	return nil
+// NewEncryptedUserRepository returns UserRepository which encrypts PII fields of users with the given UserEncryption.
+func NewEncryptedUserRepository(db *mongohandler.MongoDBWrapper, encryption *UserEncryption) (UserRepository, error) {
+	return &userRepository{db.Collection(usersCollection), encryption}, nil
 }
 
 // Create a new user
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
 	user.Id = uuid.NewString() // Generate a new UUID
-	user.Status = model.UserStatus_Active
+	if user.Status == 0 {
+		user.Status = model.UserStatus_Active
+	}
 	user.Meta = model.NewMeta()
-	_, err := r.collection.InsertOne(ctx, user)
+	doc, err := r.encryption.document(user)
+	if err != nil {
+		user.Password = ""
+		slog.ErrorContext(ctx, "user encryption error", slog.Any("error", err))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	_, err = r.collection.InsertOne(ctx, doc)
 
 	// empty password to not return in the api response
 	// NOTE: empty password before logging error to not leak password in logs
@@ -78,7 +81,7 @@ func (r *userRepository) Create(ctx context.Context, user *model.User) error {
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
 			slog.InfoContext(ctx, "already exists with the same nickname or email.", slog.Any("error", err))
-			return errwrap.ErrConflict.SetMessage("already exists with the same nickname or email")
+			return model.ErrUserFieldTaken(duplicateKeyField(err))
 		}
 		slog.ErrorContext(ctx, "mongo create user error", slog.Any("error", err), slog.Any("user", user))
 		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
@@ -93,21 +96,18 @@ func (r *userRepository) Create(ctx context.Context, user *model.User) error {
 //
 // Sanitizing fields for update.
 //
-// Checking uniqueness of user data.
+// Uniqueness is kept by the unique indexes of email, nickName and their unique forms. A separate check before the
+// update can't prevent races, even in a transaction, since concurrent transactions updating different users both
+// pass it.
 //
 // - Returns NotFound when record is not found
 //
-// - Returns Conflict when duplicated key error occured or uniqueness violated
+// - Returns Conflict when duplicated key error occured
 //
 // - Returns internal error for other error cases
 //
 // Returns updated user when it is successful with updated `UpdatedAt` and `Version` field
 func (r *userRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
-	err := r.checkUniqueness(ctx, id, user)
-	if err != nil {
-		return nil, err
-	}
-
 	// findOneAndUpdate options
 	opt := options.FindOneAndUpdate().
 		SetReturnDocument(options.After).
@@ -119,40 +119,42 @@ func (r *userRepository) Update(ctx context.Context, id string, user *model.User
 
 	// keeping some fields from updating.
 	userM := sanitizeUserForUpdate(user)
+	if err := r.encryption.encryptDocument(id, userM); err != nil {
+		slog.ErrorContext(ctx, "user encryption error", slog.Any("error", err))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
 
 	// filter by ID
 	filter := bson.M{"_id": id} // Using string ID (UUID)
 
 	// Use MongoDB's $set operator to update fields
-	updatedUserM := r.collection.FindOneAndUpdate(ctx,
+	var updatedUser *model.User
+	err := r.collection.FindOneAndUpdate(ctx,
 		filter,
 		bson.M{"$set": userM, "$inc": bson.M{"version": 1}},
-		opt)
-
-	if err := updatedUserM.Err(); err != nil {
+		opt).Decode(&updatedUser)
+	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errwrap.ErrNotFound.SetMessage("record not found")
+			return nil, model.ErrUserNotFound
 		} else if mongo.IsDuplicateKeyError(err) {
 			slog.InfoContext(ctx, "user update failed with duplicate key error", slog.Any("error", err), slog.Any("userBson", userM))
-			return nil, errwrap.ErrConflict.SetMessage("unique constraint violated").SetOriginError(err)
+			return nil, model.ErrUserFieldTaken(duplicateKeyField(err)).SetOriginError(err)
 		}
 
 		slog.InfoContext(ctx, "user update failed.", slog.Any("error", err), slog.Any("userBson", userM))
 		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
 
-	var updatedUser *model.User
-	if err := updatedUserM.Decode(&updatedUser); err != nil {
-		slog.InfoContext(ctx, "error while decoding bson user to user model", slog.Any("error", err), slog.Any("updatedUserBson", updatedUser))
-		return nil, errwrap.ErrInternal.SetMessage("user decode error").SetOriginError(err)
+	if err := r.encryption.decryptUser(updatedUser); err != nil {
+		return nil, errwrap.ErrInternal.SetMessage("user decrypt error").SetOriginError(err)
 	}
-
 	return updatedUser, nil
 }
 
 // ListByFilter fetches users based on a dynamic filter with pagination and cursor
-func (r *userRepository) ListByFilter(ctx context.Context, filter bson.M, limit int, offset int) ([]model.User, int64, error) {
+func (r *userRepository) ListByFilter(ctx context.Context, userFilter model.UserFilter, limit int, offset int) ([]model.User, int64, error) {
 	var users []model.User
+	filter := r.encryption.filter(userFilter)
 
 	// Find the total count of documents that match the filter
 	totalCount, err := r.collection.CountDocuments(ctx, filter)
@@ -161,8 +163,9 @@ func (r *userRepository) ListByFilter(ctx context.Context, filter bson.M, limit
 		return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
 
-	// Define MongoDB options for pagination
+	// Define MongoDB options for pagination. Sorting by creation to have a stable order between pages.
 	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
 		SetLimit(int64(limit)).
 		SetSkip(int64(offset)).
 		SetProjection(bson.M{
@@ -174,7 +177,7 @@ func (r *userRepository) ListByFilter(ctx context.Context, filter bson.M, limit
 	if err != nil {
 		slog.InfoContext(ctx, "error from mongo while finding docs by filter.", slog.Any("error", err))
 		if err == mongo.ErrNoDocuments {
-			return nil, 0, errwrap.ErrNotFound.SetMessage("user record not found")
+			return nil, 0, model.ErrUserNotFound
 		}
 		return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
@@ -185,6 +188,12 @@ func (r *userRepository) ListByFilter(ctx context.Context, filter bson.M, limit
 		slog.InfoContext(ctx, "error from mongo cursor while getting docs by filter.", slog.Any("error", err))
 		return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
+	for i := range users {
+		if err := r.encryption.decryptUser(&users[i]); err != nil {
+			slog.ErrorContext(ctx, "user decrypt error", slog.Any("error", err), slog.Any("id", users[i].Id))
+			return nil, 0, errwrap.ErrInternal.SetMessage("user decrypt error").SetOriginError(err)
+		}
+	}
 
 	return users, totalCount, nil
 }
@@ -208,7 +217,7 @@ func (r *userRepository) Delete(ctx context.Context, id string) error {
 
 	if err := updatedUserM.Err(); err != nil {
 		if err == mongo.ErrNoDocuments {
-			return errwrap.ErrNotFound.SetMessage("record not found")
+			return model.ErrUserNotFound
 		}
 		slog.ErrorContext(ctx, "mongo error while deleting user", slog.Any("error", err), slog.Any("id", id))
 		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
@@ -218,56 +227,114 @@ func (r *userRepository) Delete(ctx context.Context, id string) error {
 }
 
 // Get user by id
+//
+// - Returns NotFound when record is not found
 func (r *userRepository) Get(ctx context.Context, id string) (*model.User, error) {
 	filter := bson.M{"_id": id}
 	found := r.collection.FindOne(ctx, filter)
-	if found.Err() != nil {
-		return nil, found.Err()
+	if err := found.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrUserNotFound
+		}
+		slog.ErrorContext(ctx, "mongo error while getting user", slog.Any("error", err), slog.Any("id", id))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
 
 	var user *model.User
 	if err := found.Decode(&user); err != nil {
-		return nil, err
+		return nil, errwrap.ErrInternal.SetMessage("user decode error").SetOriginError(err)
+	}
+	if err := r.encryption.decryptUser(user); err != nil {
+		slog.ErrorContext(ctx, "user decrypt error", slog.Any("error", err), slog.Any("id", id))
+		return nil, errwrap.ErrInternal.SetMessage("user decrypt error").SetOriginError(err)
 	}
 	return user, nil
 }
 
-// checkUniqueness checks uniqueness by filtering with unique constraint fields.
+// SetPassword sets the password hash of the user with updated `UpdatedAt` and `Version` field
 //
-// Returns Conflict error if duplicated record exists.
-func (r *userRepository) checkUniqueness(ctx context.Context, id string, user *model.User) error {
-	// Pre-check for uniqueness
-	filter := bson.M{
-		"$or": []bson.M{
-			{"nickName": user.NickName},
-			{"email": user.Email},
-		},
-		"_id": bson.M{"$ne": id}, // Exclude the current document
+// - Returns NotFound when record is not found
+func (r *userRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"password": hash, "updatedAt": time.Now().UTC()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while setting password", slog.Any("error", err), slog.Any("id", id))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
+	if res.MatchedCount == 0 {
+		return model.ErrUserNotFound
+	}
+	return nil
+}
 
-	count, err := r.collection.CountDocuments(ctx, filter)
+// ReplacePasswordHash replaces the password hash of the user when it is the current hash
+//
+// - Returns NotFound when there is no user with the id and the current hash
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id string, current string, hash string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "password": current},
+		bson.M{"$set": bson.M{"password": hash}})
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to check user uniqueness from mongo", slog.Any("error", err.Error()), slog.Any("userFilter", filter))
+		slog.ErrorContext(ctx, "mongo error while replacing password hash", slog.Any("error", err), slog.Any("id", id))
 		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
 	}
-	if count > 0 {
-		return errwrap.ErrConflict.SetMessage("nickname or email should be unique").SetOriginError(err)
+	if res.MatchedCount == 0 {
+		return model.ErrUserNotFound
 	}
-
 	return nil
 }
 
+// DeleteUnverified deletes users pending verification created before the time. Their tokens are removed by the TTL
+// index of user tokens.
+func (r *userRepository) DeleteUnverified(ctx context.Context, createdBefore time.Time) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, bson.M{
+		"status":    model.UserStatus_PendingVerification,
+		"createdAt": bson.M{"$lt": createdBefore.UTC()},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while deleting unverified users", slog.Any("error", err))
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return res.DeletedCount, nil
+}
+
+// duplicateKeyIndexRegex matches the index name in duplicate key errors, e.g. `index: email_1 dup key: {...}`
+var duplicateKeyIndexRegex = regexp.MustCompile(`index: (\S+)`)
+
+// duplicateKeyField returns the unique field whose index raised the duplicate key error.
+//
+// Unique fields are indexed either directly or on their blind indexes when they are encrypted. Returns empty string
+// when the index is unknown.
+func duplicateKeyField(err error) string {
+	match := duplicateKeyIndexRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return ""
+	}
+	if field, ok := uniqueFormSources[strings.TrimSuffix(match[1], "_1")]; ok {
+		return field
+	}
+	for _, field := range []string{model.UniqueFieldEmail, model.UniqueFieldNickName} {
+		if match[1] == field+"_1" || match[1] == blindIndexField(field)+"_1" {
+			return field
+		}
+	}
+	return ""
+}
+
 // sanitizeUserForUpdate excludes fields that should not be updated
 func sanitizeUserForUpdate(user *model.User) bson.M {
 	// Manually create the update map, allowing only specific fields
 	return bson.M{
-		"firstName": user.FirstName,
-		"lastName":  user.LastName,
-		"nickName":  user.NickName,
-		"email":     user.Email,
-		"country":   user.Country,
-		"updatedAt": user.UpdatedAt,
-		"status":    user.Status,
+		"firstName":           user.FirstName,
+		"lastName":            user.LastName,
+		"nickName":            user.NickName,
+		nickNameSkeletonField: user.NickNameSkeleton,
+		"email":               user.Email,
+		canonicalEmailField:   user.EmailCanonical,
+		"country":             user.Country,
+		"updatedAt":           user.UpdatedAt,
+		"status":              user.Status,
 	}
 }
 
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// encryptableFields are the bson field names of model.User which can be encrypted
+var encryptableFields = map[string]func(*model.User) *string{
+	"firstName": func(u *model.User) *string { return &u.FirstName },
+	"lastName":  func(u *model.User) *string { return &u.LastName },
+	"nickName":  func(u *model.User) *string { return &u.NickName },
+	"email":     func(u *model.User) *string { return &u.Email },
+}
+
+// filterValues returns values of UserFilter by bson field names of encryptable fields
+func filterValues(f model.UserFilter) map[string]string {
+	return map[string]string{
+		"firstName": f.FirstName,
+		"lastName":  f.LastName,
+		"nickName":  f.NickName,
+		"email":     f.Email,
+	}
+}
+
+// nameFields are matched by case insensitive prefix(see UserFilter.ToBson) so their blind indexes are of lowercase
+// values. Encrypted names match only by case insensitive whole names unless they are prefix indexed.
+var nameFields = map[string]bool{
+	"firstName": true,
+	"lastName":  true,
+}
+
+// maxPrefixLength is the max length of prefixes having blind index. Longer filter values are truncated.
+const maxPrefixLength = 64
+
+// UserEncryption encrypts configured PII fields of users stored in MongoDB.
+//
+// Every encrypted field has a deterministic blind index in `<field>Hash` field which is used for unique indexes and
+// exact match filters. Encrypted values are bound to the id of the user and the field so that they can't be swapped
+// between fields or users.
+//
+// Prefix indexed names have blind indexes of all their prefixes to keep prefix filters working. It is opt-in since
+// blind indexes of short prefixes are shared by many users, their frequencies reveal the distribution of names.
+type UserEncryption struct {
+	encryptor    *crypt.EnvelopeEncryptor
+	fields       []string
+	prefixFields map[string]bool
+}
+
+// NewUserEncryption returns UserEncryption for the given fields. Names in prefixFields are prefix indexed.
+//
+// Returns error when a field is not encryptable or a prefix field isn't an encrypted name. Encryptable fields are
+// `firstName`, `lastName`, `nickName`, `email`.
+func NewUserEncryption(encryptor *crypt.EnvelopeEncryptor, fields []string, prefixFields []string) (*UserEncryption, error) {
+	for _, f := range fields {
+		if _, ok := encryptableFields[f]; !ok {
+			return nil, fmt.Errorf("field %q can't be encrypted", f)
+		}
+	}
+	encryption := &UserEncryption{encryptor, fields, map[string]bool{}}
+	for _, f := range prefixFields {
+		if !nameFields[f] || !encryption.isEncrypted(f) {
+			return nil, fmt.Errorf("field %q can't be prefix indexed, only encrypted names can", f)
+		}
+		encryption.prefixFields[f] = true
+	}
+	return encryption, nil
+}
+
+// blindIndexField returns the name of blind index field of an encrypted field
+func blindIndexField(field string) string {
+	return field + "Hash"
+}
+
+// blindIndex returns blind index of the field value which is used in filters
+func (e *UserEncryption) blindIndex(field, value string) string {
+	if nameFields[field] {
+		value = strings.ToLower(value)
+	}
+	if e.prefixFields[field] {
+		value = truncate(value, maxPrefixLength)
+	}
+	return e.encryptor.BlindIndex(field, value)
+}
+
+// storedBlindIndex returns blind index of the field value to be stored.
+// It is a list of blind indexes of all prefixes for prefix indexed fields.
+func (e *UserEncryption) storedBlindIndex(field, value string) interface{} {
+	if !e.prefixFields[field] {
+		return e.blindIndex(field, value)
+	}
+
+	runes := []rune(strings.ToLower(value))
+	indexes := []string{}
+	for i := 1; i <= len(runes) && i <= maxPrefixLength; i++ {
+		indexes = append(indexes, e.encryptor.BlindIndex(field, string(runes[:i])))
+	}
+	return indexes
+}
+
+// associatedData returns the associated data which binds the encrypted value of the field to the user
+func associatedData(id, field string) string {
+	return usersCollection + "/" + id + "/" + field
+}
+
+// truncate returns first n runes of s
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// document returns the document to be inserted for the user.
+// It is the user itself when encryption is disabled.
+func (e *UserEncryption) document(user *model.User) (interface{}, error) {
+	if e == nil {
+		return user, nil
+	}
+
+	raw, err := bson.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if err := e.encryptDocument(user.Id, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// encryptDocument encrypts configured fields of the document of the user with the id in place and sets their blind
+// indexes
+func (e *UserEncryption) encryptDocument(id string, doc bson.M) error {
+	if e == nil {
+		return nil
+	}
+
+	for _, f := range e.fields {
+		value, ok := doc[f].(string)
+		if !ok {
+			continue
+		}
+		encrypted, err := e.encryptor.EncryptWith(value, associatedData(id, f))
+		if err != nil {
+			return err
+		}
+		doc[f] = encrypted
+		doc[blindIndexField(f)] = e.storedBlindIndex(f, value)
+	}
+	for field := range uniqueFormSources {
+		if value, ok := doc[field].(string); ok {
+			doc[field] = e.uniqueForm(field, value)
+		}
+	}
+	return nil
+}
+
+// uniqueForm returns the stored value of the unique form, e.g. the canonical email. It is the blind index when the
+// unique field which the form is derived from is encrypted.
+func (e *UserEncryption) uniqueForm(field, value string) string {
+	if e == nil || value == "" || !e.isEncrypted(uniqueFormSources[field]) {
+		return value
+	}
+	return e.encryptor.BlindIndex(field, value)
+}
+
+// decryptUser decrypts configured fields of the user in place
+func (e *UserEncryption) decryptUser(user *model.User) error {
+	if e == nil || user == nil {
+		return nil
+	}
+
+	for _, f := range e.fields {
+		value := encryptableFields[f](user)
+		decrypted, err := e.encryptor.DecryptWith(*value, associatedData(user.Id, f))
+		if err != nil {
+			return err
+		}
+		*value = decrypted
+	}
+	return nil
+}
+
+// filter converts the UserFilter into a MongoDB filter replacing encrypted fields with their blind indexes
+func (e *UserEncryption) filter(userFilter model.UserFilter) bson.M {
+	filter := userFilter.ToBson()
+	if e == nil {
+		return filter
+	}
+
+	values := filterValues(userFilter)
+	for _, f := range e.fields {
+		if values[f] == "" {
+			continue
+		}
+		delete(filter, f)
+		filter[blindIndexField(f)] = e.blindIndex(f, values[f])
+	}
+	return filter
+}
+
+// isEncrypted reports whether the field is configured to be encrypted
+func (e *UserEncryption) isEncrypted(field string) bool {
+	for _, f := range e.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ReencryptAll encrypts configured fields of all users which are stored in plaintext or encrypted with an old key.
+//
+// It is used for encrypting existing data after enabling encryption and for key rotation. Unique forms, e.g. canonical
+// emails, which are in plaintext or missing are replaced with their blind indexes when their fields are encrypted.
+// A user is skipped if it is updated concurrently. Returns number of updated users.
+func (e *UserEncryption) ReencryptAll(ctx context.Context, db *mongo.Database) (int64, error) {
+	forms := uniqueFormFuncs()
+	collection := db.Collection(usersCollection)
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return updated, err
+		}
+
+		id, _ := doc["_id"].(string)
+		filter := bson.M{"_id": doc["_id"]}
+		set := bson.M{}
+		for _, f := range e.fields {
+			value, ok := doc[f].(string)
+			if !ok || !e.encryptor.NeedsReencryption(value) {
+				continue
+			}
+			plaintext, err := e.encryptor.DecryptWith(value, associatedData(id, f))
+			if err != nil {
+				return updated, fmt.Errorf("failed to decrypt %s of user %v: %w", f, doc["_id"], err)
+			}
+			encrypted, err := e.encryptor.EncryptWith(plaintext, associatedData(id, f))
+			if err != nil {
+				return updated, err
+			}
+			filter[f] = value // not overriding concurrent updates
+			set[f] = encrypted
+			set[blindIndexField(f)] = e.storedBlindIndex(f, plaintext)
+		}
+		for field, of := range forms {
+			if err := e.setUniqueForm(doc, filter, set, field, of); err != nil {
+				return updated, err
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+
+		res, err := collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+		if err != nil {
+			return updated, err
+		}
+		if res.ModifiedCount == 0 {
+			slog.WarnContext(ctx, "user is updated concurrently while re-encrypting, skipped", slog.Any("id", doc["_id"]))
+		}
+		updated += res.ModifiedCount
+	}
+
+	return updated, cursor.Err()
+}
+
+// setUniqueForm sets the blind index of the unique form of the document when it is in plaintext or missing, or its
+// source is re-encrypted since blind indexes of older encryptions are of the blind index key shared by all fields
+func (e *UserEncryption) setUniqueForm(doc, filter, set bson.M, field string, of func(string) string) error {
+	source := uniqueFormSources[field]
+	value, ok := doc[source].(string)
+	if !ok || value == "" || !e.isEncrypted(source) {
+		return nil
+	}
+	if _, hasForm := doc[field]; hasForm && !e.encryptor.NeedsReencryption(value) {
+		return nil
+	}
+
+	id, _ := doc["_id"].(string)
+	plaintext, err := e.encryptor.DecryptWith(value, associatedData(id, source))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s of user %v: %w", source, doc["_id"], err)
+	}
+	filter[source] = value
+	set[field] = e.uniqueForm(field, of(plaintext))
+	return nil
+}
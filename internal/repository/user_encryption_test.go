@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testKeys is a crypt.KeyProvider for tests
+type testKeys struct{}
+
+func (testKeys) CurrentKeyId() string          { return "test" }
+func (testKeys) Key(id string) ([]byte, error) { return bytes.Repeat([]byte{1}, crypt.KeySize), nil }
+func (testKeys) BlindIndexKey() []byte         { return bytes.Repeat([]byte{2}, crypt.KeySize) }
+
+func newTestUserEncryption(t *testing.T, fields []string, prefixFields ...string) *UserEncryption {
+	encryption, err := NewUserEncryption(crypt.NewEnvelopeEncryptor(testKeys{}), fields, prefixFields)
+	require.NoError(t, err)
+	return encryption
+}
+
+func TestNewUserEncryption(t *testing.T) {
+	_, err := NewUserEncryption(crypt.NewEnvelopeEncryptor(testKeys{}), []string{"email", "country"}, nil)
+	require.Error(t, err)
+	_, err = NewUserEncryption(crypt.NewEnvelopeEncryptor(testKeys{}), []string{"email", "firstName"}, []string{"email"})
+	require.Error(t, err, "only names can be prefix indexed")
+	_, err = NewUserEncryption(crypt.NewEnvelopeEncryptor(testKeys{}), []string{"email"}, []string{"firstName"})
+	require.Error(t, err, "only encrypted names can be prefix indexed")
+}
+
+func TestUserEncryptionDocument(t *testing.T) {
+	encryption := newTestUserEncryption(t, []string{"email", "firstName", "lastName"})
+	user := &model.User{Id: "id", FirstName: "John", LastName: "Doe", NickName: "john", Email: "john@email.com"}
+
+	doc, err := encryption.document(user)
+	require.NoError(t, err)
+
+	docM := doc.(bson.M)
+	require.True(t, crypt.IsEncrypted(docM["email"].(string)))
+	require.True(t, crypt.IsEncrypted(docM["firstName"].(string)))
+	require.Equal(t, "john", docM["nickName"], "not configured fields should not be encrypted")
+	require.Equal(t, encryption.blindIndex("email", "john@email.com"), docM["emailHash"])
+	require.Equal(t, encryption.blindIndex("firstName", "JOHN"), docM["firstNameHash"], "names should have a case insensitive index")
+	require.NotEqual(t, encryption.blindIndex("firstName", "doe"), encryption.blindIndex("lastName", "doe"), "fields should have their own blind index keys")
+
+	// decoding the document back into user
+	raw, err := bson.Marshal(docM)
+	require.NoError(t, err)
+	var decoded model.User
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	require.NoError(t, encryption.decryptUser(&decoded))
+	require.Equal(t, *user, decoded)
+}
+
+func TestUserEncryptionPrefixIndex(t *testing.T) {
+	encryption := newTestUserEncryption(t, []string{"firstName", "lastName"}, "firstName")
+	user := &model.User{Id: "id", FirstName: "John", LastName: "Doe"}
+
+	doc, err := encryption.document(user)
+	require.NoError(t, err)
+
+	docM := doc.(bson.M)
+	require.Equal(t, []string{
+		encryption.blindIndex("firstName", "j"),
+		encryption.blindIndex("firstName", "jo"),
+		encryption.blindIndex("firstName", "joh"),
+		encryption.blindIndex("firstName", "JOHN"),
+	}, docM["firstNameHash"], "prefix indexed names should have case insensitive prefix indexes")
+	require.Equal(t, encryption.blindIndex("lastName", "doe"), docM["lastNameHash"], "names are not prefix indexed by default")
+}
+
+func TestUserEncryptionBindsValuesToUserAndField(t *testing.T) {
+	encryption := newTestUserEncryption(t, []string{"email", "firstName"})
+	doc, err := encryption.document(&model.User{Id: "john", FirstName: "John", Email: "john@email.com"})
+	require.NoError(t, err)
+	docM := doc.(bson.M)
+
+	swappedField := &model.User{Id: "john", FirstName: docM["email"].(string)}
+	require.ErrorIs(t, encryption.decryptUser(swappedField), crypt.ErrInvalidCiphertext)
+
+	swappedUser := &model.User{Id: "jane", Email: docM["email"].(string)}
+	require.ErrorIs(t, encryption.decryptUser(swappedUser), crypt.ErrInvalidCiphertext)
+}
+
+func TestUserEncryptionFilter(t *testing.T) {
+	encryption := newTestUserEncryption(t, []string{"email", "firstName"}, "firstName")
+
+	filter := encryption.filter(model.UserFilter{FirstName: "JO", Email: "john@email.com", NickName: "john"})
+
+	require.Equal(t, bson.M{
+		"firstNameHash": encryption.blindIndex("firstName", "jo"),
+		"emailHash":     encryption.blindIndex("email", "john@email.com"),
+		"nickName":      "john",
+		"status":        model.UserStatus_Active,
+	}, filter)
+}
+
+func TestUserEncryptionDisabled(t *testing.T) {
+	var encryption *UserEncryption
+	user := &model.User{Email: "john@email.com"}
+
+	doc, err := encryption.document(user)
+	require.NoError(t, err)
+	require.Same(t, user, doc)
+	require.Equal(t, bson.M{"email": "a", "status": model.UserStatus_Active}, encryption.filter(model.UserFilter{Email: "a"}))
+	require.NoError(t, encryption.decryptUser(user))
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// inMemoryUserRepository is an implementor of UserRepository which keeps users in memory.
+//
+// It is honouring the same constraints with mongo implementation(unique email and nickName, soft delete, versioning)
+// so it can be used for running the service locally and in tests without any database.
+type inMemoryUserRepository struct {
+	mu    sync.RWMutex
+	users []*model.User // keeping insertion order to have a stable listing
+	byId  map[string]*model.User
+}
+
+// NewInMemoryUserRepository returns new instance of in-memory UserRepository.
+func NewInMemoryUserRepository() UserRepository {
+	return &inMemoryUserRepository{
+		byId: map[string]*model.User{},
+	}
+}
+
+// Create a new user
+//
+// - Returns Conflict when there is a user with the same email or nickName
+func (r *inMemoryUserRepository) Create(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.conflict("", user); err != nil {
+		return err
+	}
+
+	user.Id = uuid.NewString() // Generate a new UUID
+	if user.Status == 0 {
+		user.Status = model.UserStatus_Active
+	}
+	user.Meta = model.NewMeta()
+
+	stored := *user
+	r.users = append(r.users, &stored)
+	r.byId[stored.Id] = &stored
+
+	// empty password to not return in the api response
+	user.Password = ""
+	return nil
+}
+
+// Update user by id
+//
+// - Returns NotFound when record is not found
+//
+// - Returns Conflict when uniqueness violated
+//
+// Returns updated user without password when it is successful with updated `UpdatedAt` and `Version` field
+func (r *inMemoryUserRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.conflict(id, user); err != nil {
+		return nil, err
+	}
+
+	stored, ok := r.byId[id]
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+
+	user.Meta.Update()
+
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.NickName = user.NickName
+	stored.NickNameSkeleton = user.NickNameSkeleton
+	stored.Email = user.Email
+	stored.EmailCanonical = user.EmailCanonical
+	stored.Country = user.Country
+	stored.Status = user.Status
+	stored.UpdatedAt = user.UpdatedAt
+	stored.Version++
+
+	return withoutPassword(stored), nil
+}
+
+// ListByFilter fetches users matching with the filter with pagination. Limit `0` means no limit.
+func (r *inMemoryUserRepository) ListByFilter(ctx context.Context, filter model.UserFilter, limit int, offset int) ([]model.User, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []model.User{}
+	var totalCount int64
+	for _, u := range r.users {
+		if !filter.Matches(u) {
+			continue
+		}
+		totalCount++
+		if totalCount <= int64(offset) {
+			continue
+		}
+		if limit > 0 && len(users) >= limit {
+			continue
+		}
+		users = append(users, *withoutPassword(u))
+	}
+
+	return users, totalCount, nil
+}
+
+// Delete user by id. Sets status to `Inactive(2)`.
+//
+// - Returns NotFound when record is not found
+func (r *inMemoryUserRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.byId[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+
+	stored.Status = model.UserStatus_Inactive
+	stored.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// Get user by id
+//
+// - Returns NotFound when record is not found
+func (r *inMemoryUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.byId[id]
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+
+	user := *stored
+	return &user, nil
+}
+
+// SetPassword sets the password hash of the user with updated `UpdatedAt` and `Version` field
+//
+// - Returns NotFound when record is not found
+func (r *inMemoryUserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.byId[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+
+	stored.Password = hash
+	stored.UpdatedAt = time.Now().UTC()
+	stored.Version++
+	return nil
+}
+
+// ReplacePasswordHash replaces the password hash of the user when it is the current hash
+//
+// - Returns NotFound when there is no user with the id and the current hash
+func (r *inMemoryUserRepository) ReplacePasswordHash(ctx context.Context, id string, current string, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.byId[id]
+	if !ok || stored.Password != current {
+		return model.ErrUserNotFound
+	}
+
+	stored.Password = hash
+	return nil
+}
+
+// DeleteUnverified deletes users pending verification created before the time
+func (r *inMemoryUserRepository) DeleteUnverified(ctx context.Context, createdBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	users := r.users[:0]
+	for _, u := range r.users {
+		if u.Status == model.UserStatus_PendingVerification && u.CreatedAt.Before(createdBefore) {
+			delete(r.byId, u.Id)
+			deleted++
+			continue
+		}
+		users = append(users, u)
+	}
+	r.users = users
+	return deleted, nil
+}
+
+// conflict returns conflict error of email or nickName of the user when it is used by another user than the given id.
+// Emails conflict when they are the same or have the same canonical form, nicknames when they are the same or have
+// the same skeleton.
+//
+// NOTE: Inactive users are also taken into account as it is in mongo unique indexes.
+func (r *inMemoryUserRepository) conflict(id string, user *model.User) errwrap.IError {
+	for _, u := range r.users {
+		if u.Id == id {
+			continue
+		}
+		if u.Email == user.Email || (user.EmailCanonical != "" && u.EmailCanonical == user.EmailCanonical) {
+			return model.ErrUserFieldTaken(model.UniqueFieldEmail)
+		}
+		if u.NickName == user.NickName || (user.NickNameSkeleton != "" && u.NickNameSkeleton == user.NickNameSkeleton) {
+			return model.ErrUserFieldTaken(model.UniqueFieldNickName)
+		}
+	}
+	return nil
+}
+
+// withoutPassword returns a copy of the user with an empty password
+func withoutPassword(user *model.User) *model.User {
+	u := *user
+	u.Password = ""
+	return &u
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userMfaCollection is the name of MongoDB collection of MFA of users. Documents are keyed by user id.
+const userMfaCollection = "user_mfa"
+
+// userMfaRepository is the MongoDB implementor of UserMfaRepository
+type userMfaRepository struct {
+	collection *mongo.Collection
+}
+
+// NewUserMfaRepository returns UserMfaRepository on MongoDB
+func NewUserMfaRepository(db *mongohandler.MongoDBWrapper) UserMfaRepository {
+	return &userMfaRepository{db.Collection(userMfaCollection)}
+}
+
+func (r *userMfaRepository) Get(ctx context.Context, userId string) (*model.UserMfa, error) {
+	var mfa model.UserMfa
+	err := r.collection.FindOne(ctx, bson.M{"_id": userId}).Decode(&mfa)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, model.ErrUserMfaNotEnabled
+		}
+		slog.ErrorContext(ctx, "mongo error while getting user mfa", slog.Any("error", err), slog.Any("userId", userId))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return &mfa, nil
+}
+
+func (r *userMfaRepository) Save(ctx context.Context, mfa *model.UserMfa) error {
+	if mfa.RecoveryCodes == nil {
+		mfa.RecoveryCodes = []string{}
+	}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": mfa.UserId}, mfa, options.Replace().SetUpsert(true))
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while saving user mfa", slog.Any("error", err), slog.Any("userId", mfa.UserId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return nil
+}
+
+func (r *userMfaRepository) Delete(ctx context.Context, userId string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": userId})
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while deleting user mfa", slog.Any("error", err), slog.Any("userId", userId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if res.DeletedCount == 0 {
+		return model.ErrUserMfaNotEnabled
+	}
+	return nil
+}
+
+func (r *userMfaRepository) UseStep(ctx context.Context, userId string, step int64) error {
+	return r.use(ctx, bson.M{"_id": userId, "lastUsedStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"lastUsedStep": step, "failedAttempts": 0, "updatedAt": time.Now().UTC()}})
+}
+
+func (r *userMfaRepository) UseRecoveryCode(ctx context.Context, userId string, hash string) error {
+	return r.use(ctx, bson.M{"_id": userId, "recoveryCodes": hash}, bson.M{
+		"$pull": bson.M{"recoveryCodes": hash},
+		"$set":  bson.M{"failedAttempts": 0, "updatedAt": time.Now().UTC()},
+	})
+}
+
+// use applies the update of a valid code when the filter matches. The filter and the update are atomic so that a code
+// is used once under concurrent requests.
+func (r *userMfaRepository) use(ctx context.Context, filter bson.M, update bson.M) error {
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while using user mfa code", slog.Any("error", err))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if res.ModifiedCount == 0 {
+		return model.ErrUserMfaCodeInvalid
+	}
+	return nil
+}
+
+// RecordAttempt counts the attempt by an update pipeline so that the lockout is checked and the count is reset or
+// incremented in one atomic update
+func (r *userMfaRepository) RecordAttempt(ctx context.Context, userId string, at time.Time, max int, window time.Duration) (int, error) {
+	since := at.Add(-window).UTC()
+	var mfa model.UserMfa
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": userId, "$or": bson.A{
+			bson.M{"failedAttempts": bson.M{"$lt": max}},
+			bson.M{"lastFailureAt": bson.M{"$lte": since}},
+		}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"failedAttempts": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$lastFailureAt", since}},
+				bson.M{"$add": bson.A{"$failedAttempts", 1}},
+				1,
+			}},
+			"lastFailureAt": at.UTC(),
+		}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&mfa)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// either there is no mfa or the user is locked
+		count, err := r.collection.CountDocuments(ctx, bson.M{"_id": userId})
+		if err != nil {
+			slog.ErrorContext(ctx, "mongo error while recording user mfa attempt", slog.Any("error", err), slog.Any("userId", userId))
+			return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+		}
+		if count == 0 {
+			return 0, model.ErrUserMfaNotEnabled
+		}
+		return 0, model.ErrUserMfaLocked
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while recording user mfa attempt", slog.Any("error", err), slog.Any("userId", userId))
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return mfa.FailedAttempts, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+)
+
+// inMemoryUserMfaRepository is an implementor of UserMfaRepository which keeps MFA of users in memory
+type inMemoryUserMfaRepository struct {
+	mu  sync.Mutex
+	mfa map[string]model.UserMfa // by user id
+}
+
+// NewInMemoryUserMfaRepository returns new instance of in-memory UserMfaRepository
+func NewInMemoryUserMfaRepository() UserMfaRepository {
+	return &inMemoryUserMfaRepository{mfa: map[string]model.UserMfa{}}
+}
+
+func (r *inMemoryUserMfaRepository) Get(ctx context.Context, userId string) (*model.UserMfa, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userId]
+	if !ok {
+		return nil, model.ErrUserMfaNotEnabled
+	}
+	mfa.RecoveryCodes = slices.Clone(mfa.RecoveryCodes)
+	return &mfa, nil
+}
+
+func (r *inMemoryUserMfaRepository) Save(ctx context.Context, mfa *model.UserMfa) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := *mfa
+	saved.RecoveryCodes = slices.Clone(mfa.RecoveryCodes)
+	r.mfa[mfa.UserId] = saved
+	return nil
+}
+
+func (r *inMemoryUserMfaRepository) Delete(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.mfa[userId]; !ok {
+		return model.ErrUserMfaNotEnabled
+	}
+	delete(r.mfa, userId)
+	return nil
+}
+
+func (r *inMemoryUserMfaRepository) UseStep(ctx context.Context, userId string, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userId]
+	if !ok || mfa.LastUsedStep >= step {
+		return model.ErrUserMfaCodeInvalid
+	}
+	mfa.LastUsedStep = step
+	mfa.FailedAttempts = 0
+	r.mfa[userId] = mfa
+	return nil
+}
+
+func (r *inMemoryUserMfaRepository) UseRecoveryCode(ctx context.Context, userId string, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userId]
+	if !ok {
+		return model.ErrUserMfaCodeInvalid
+	}
+	i := slices.Index(mfa.RecoveryCodes, hash)
+	if i < 0 {
+		return model.ErrUserMfaCodeInvalid
+	}
+	mfa.RecoveryCodes = slices.Delete(slices.Clone(mfa.RecoveryCodes), i, i+1)
+	mfa.FailedAttempts = 0
+	r.mfa[userId] = mfa
+	return nil
+}
+
+func (r *inMemoryUserMfaRepository) RecordAttempt(ctx context.Context, userId string, at time.Time, max int, window time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userId]
+	if !ok {
+		return 0, model.ErrUserMfaNotEnabled
+	}
+	if !mfa.LastFailureAt.After(at.Add(-window)) {
+		mfa.FailedAttempts = 0
+	} else if mfa.FailedAttempts >= max {
+		return 0, model.ErrUserMfaLocked
+	}
+	mfa.FailedAttempts++
+	mfa.LastFailureAt = at
+	r.mfa[userId] = mfa
+	return mfa.FailedAttempts, nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/db/pghandler"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// postgresUserMfaRepository is an implementor of UserMfaRepository on PostgreSQL.
+//
+// Recovery code hashes are hex strings, they are passed to and read from the array column as comma separated values.
+type postgresUserMfaRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserMfaRepository returns UserMfaRepository backed by PostgreSQL. The table is created by SQL migrations
+// of NewPostgresUserRepository.
+func NewPostgresUserMfaRepository(pg *pghandler.PostgresWrapper) UserMfaRepository {
+	return &postgresUserMfaRepository{pg.DB}
+}
+
+func (r *postgresUserMfaRepository) Get(ctx context.Context, userId string) (*model.UserMfa, error) {
+	var mfa model.UserMfa
+	var recoveryCodes string
+	var lastFailureAt sql.NullTime
+	err := r.conn(ctx).QueryRowContext(ctx,
+		`SELECT user_id, enabled, secret, pending_secret, array_to_string(recovery_codes, ','), last_used_step,
+		failed_attempts, last_failure_at, created_at, updated_at FROM user_mfa WHERE user_id = $1`, userId).
+		Scan(&mfa.UserId, &mfa.Enabled, &mfa.Secret, &mfa.PendingSecret, &recoveryCodes, &mfa.LastUsedStep,
+			&mfa.FailedAttempts, &lastFailureAt, &mfa.CreatedAt, &mfa.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrUserMfaNotEnabled
+		}
+		slog.ErrorContext(ctx, "postgres error while getting user mfa", slog.Any("error", err), slog.Any("userId", userId))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	mfa.RecoveryCodes = []string{}
+	if recoveryCodes != "" {
+		mfa.RecoveryCodes = strings.Split(recoveryCodes, ",")
+	}
+	if lastFailureAt.Valid {
+		mfa.LastFailureAt = lastFailureAt.Time.UTC()
+	}
+	mfa.CreatedAt, mfa.UpdatedAt = mfa.CreatedAt.UTC(), mfa.UpdatedAt.UTC()
+	return &mfa, nil
+}
+
+func (r *postgresUserMfaRepository) Save(ctx context.Context, mfa *model.UserMfa) error {
+	var lastFailureAt sql.NullTime
+	if !mfa.LastFailureAt.IsZero() {
+		lastFailureAt = sql.NullTime{Time: mfa.LastFailureAt, Valid: true}
+	}
+	_, err := r.conn(ctx).ExecContext(ctx,
+		`INSERT INTO user_mfa (user_id, enabled, secret, pending_secret, recovery_codes, last_used_step, failed_attempts,
+		last_failure_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, string_to_array($5, ','), $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled, secret = EXCLUDED.secret,
+		pending_secret = EXCLUDED.pending_secret, recovery_codes = EXCLUDED.recovery_codes,
+		last_used_step = EXCLUDED.last_used_step, failed_attempts = EXCLUDED.failed_attempts,
+		last_failure_at = EXCLUDED.last_failure_at, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		mfa.UserId, mfa.Enabled, mfa.Secret, mfa.PendingSecret, strings.Join(mfa.RecoveryCodes, ","), mfa.LastUsedStep,
+		mfa.FailedAttempts, lastFailureAt, mfa.CreatedAt, mfa.UpdatedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while saving user mfa", slog.Any("error", err), slog.Any("userId", mfa.UserId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return nil
+}
+
+func (r *postgresUserMfaRepository) Delete(ctx context.Context, userId string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while deleting user mfa", slog.Any("error", err), slog.Any("userId", userId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return requireAffected(res, model.ErrUserMfaNotEnabled)
+}
+
+func (r *postgresUserMfaRepository) UseStep(ctx context.Context, userId string, step int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		"UPDATE user_mfa SET last_used_step = $2, failed_attempts = 0, updated_at = $3 WHERE user_id = $1 AND last_used_step < $2",
+		userId, step, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while using user mfa code", slog.Any("error", err), slog.Any("userId", userId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return requireAffected(res, model.ErrUserMfaCodeInvalid)
+}
+
+func (r *postgresUserMfaRepository) UseRecoveryCode(ctx context.Context, userId string, hash string) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		`UPDATE user_mfa SET recovery_codes = array_remove(recovery_codes, $2), failed_attempts = 0, updated_at = $3
+		WHERE user_id = $1 AND $2 = ANY(recovery_codes)`,
+		userId, hash, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while using user mfa recovery code", slog.Any("error", err), slog.Any("userId", userId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return requireAffected(res, model.ErrUserMfaCodeInvalid)
+}
+
+func (r *postgresUserMfaRepository) RecordAttempt(ctx context.Context, userId string, at time.Time, max int, window time.Duration) (int, error) {
+	var failedAttempts int
+	err := r.conn(ctx).QueryRowContext(ctx,
+		`UPDATE user_mfa SET failed_attempts = CASE WHEN last_failure_at > $3 THEN failed_attempts + 1 ELSE 1 END, last_failure_at = $2
+		WHERE user_id = $1 AND (failed_attempts < $4 OR last_failure_at IS NULL OR last_failure_at <= $3)
+		RETURNING failed_attempts`,
+		userId, at.UTC(), at.Add(-window).UTC(), max).Scan(&failedAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		// either there is no mfa or the user is locked
+		var exists bool
+		err := r.conn(ctx).QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM user_mfa WHERE user_id = $1)", userId).Scan(&exists)
+		if err != nil {
+			slog.ErrorContext(ctx, "postgres error while recording user mfa attempt", slog.Any("error", err), slog.Any("userId", userId))
+			return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+		}
+		if !exists {
+			return 0, model.ErrUserMfaNotEnabled
+		}
+		return 0, model.ErrUserMfaLocked
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while recording user mfa attempt", slog.Any("error", err), slog.Any("userId", userId))
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return failedAttempts, nil
+}
+
+// conn returns the transaction of the context if there is one so that queries join pghandler.WithTransaction
+func (r *postgresUserMfaRepository) conn(ctx context.Context) pghandler.Executor {
+	return pghandler.Conn(ctx, r.db)
+}
+
+// requireAffected returns the error when the statement affected no rows
+func requireAffected(res sql.Result, notAffected error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if affected == 0 {
+		return notAffected
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nsaltun/userapi/pkg/lib/country"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/email"
+	"github.com/nsaltun/userapi/pkg/lib/nickname"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// usersCollection is the name of MongoDB collection of users
+const usersCollection = "users"
+
+// MongoMigrations returns schema and data migrations of MongoDB in order.
+//
+// NOTE: Applied migrations should never be changed. Add a new migration with the next version instead.
+func MongoMigrations() []mongohandler.Migration {
+	return []mongohandler.Migration{
+		{
+			Version:     1,
+			Description: "create unique email and nickName indexes and country index on users",
+			Up:          createUsersIndexes,
+		},
+		{
+			Version:     2,
+			Description: "create createdAt index on users for ordered listing",
+			Up:          createUsersCreatedAtIndex,
+		},
+		{
+			Version:     3,
+			Description: "create blind index indexes of encrypted PII fields on users",
+			Up:          createUsersBlindIndexIndexes,
+		},
+		{
+			Version:     4,
+			Description: "create TTL index on idempotency_keys",
+			Up:          createIdempotencyKeysTTLIndex,
+		},
+		{
+			Version:     5,
+			Description: "backfill canonical emails of users and create their unique index",
+			Up:          createUsersEmailCanonicalIndex,
+		},
+		{
+			Version:     6,
+			Description: "backfill nickname skeletons of users and create their unique index",
+			Up:          createUsersNickNameSkeletonIndex,
+		},
+		{
+			Version:     7,
+			Description: "normalize country aliases of users and report invalid countries",
+			Up:          normalizeUsersCountries,
+		},
+		{
+			Version:     8,
+			Description: "create TTL and user indexes on user_tokens",
+			Up:          createUserTokensIndexes,
+		},
+		{
+			Version:     9,
+			Description: "create status and createdAt index on users for expiring unverified users",
+			Up:          createUsersStatusCreatedAtIndex,
+		},
+		{
+			Version:     10,
+			Description: "create status and nextAttemptAt index on notifications",
+			Up:          createNotificationsIndex,
+		},
+	}
+}
+
+// createUsersIndexes creates the initial indexes of users collection.
+//
+// Creating index for `email`(unique) and `nickName`(unique) and `country`.
+// Creating the same indexes again is a no-op so it is safe for databases which were created before migrations.
+func createUsersIndexes(ctx context.Context, db *mongo.Database) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}}, // Ascending index on email
+			Options: options.Index().SetUnique(true),  // Unique constraint
+		},
+		{
+			Keys:    bson.D{{Key: "country", Value: 1}}, // Ascending index on country
+			Options: options.Index(),                    // Background creation
+		},
+		{
+			Keys:    bson.D{{Key: "nickName", Value: 1}}, // Ascending index on nickName
+			Options: options.Index().SetUnique(true),     // Unique constraint
+		},
+	}
+
+	_, err := db.Collection(usersCollection).Indexes().CreateMany(ctx, indexModels)
+	return err
+}
+
+// createUsersCreatedAtIndex creates index which is used for sorting users while listing
+func createUsersCreatedAtIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(usersCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return err
+}
+
+// createUsersStatusCreatedAtIndex creates index which is used for deleting unverified users created before a time
+func createUsersStatusCreatedAtIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(usersCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+// createUsersBlindIndexIndexes creates indexes on blind indexes of encrypted fields.
+//
+// Uniqueness of email and nickName is kept on their blind indexes since encrypted values are random.
+// Indexes are partial so they don't affect users which are not encrypted.
+func createUsersBlindIndexIndexes(ctx context.Context, db *mongo.Database) error {
+	indexModels := []mongo.IndexModel{}
+	for _, field := range []string{"email", "nickName", "firstName", "lastName"} {
+		hashField := blindIndexField(field)
+		opts := options.Index().SetPartialFilterExpression(bson.M{hashField: bson.M{"$exists": true}})
+		if field == "email" || field == "nickName" {
+			opts.SetUnique(true)
+		}
+		indexModels = append(indexModels, mongo.IndexModel{Keys: bson.D{{Key: hashField, Value: 1}}, Options: opts})
+	}
+
+	_, err := db.Collection(usersCollection).Indexes().CreateMany(ctx, indexModels)
+	return err
+}
+
+// createUsersEmailCanonicalIndex sets canonical emails of existing users and creates the unique index on them.
+//
+// It fails without creating the index when canonical emails of existing users collide, colliding users are listed by
+// `migrate email-collisions`.
+func createUsersEmailCanonicalIndex(ctx context.Context, db *mongo.Database) error {
+	return createUniqueFormIndex(ctx, db, canonicalEmailField, email.NewCanonicalizer().Canonical)
+}
+
+// createUsersNickNameSkeletonIndex sets confusable skeletons of nicknames of existing users and creates the unique
+// index on them.
+//
+// It fails without creating the index when nicknames of existing users look alike, colliding users are listed by
+// `migrate nickname-collisions`.
+func createUsersNickNameSkeletonIndex(ctx context.Context, db *mongo.Database) error {
+	return createUniqueFormIndex(ctx, db, nickNameSkeletonField, nickname.Skeleton)
+}
+
+// createUniqueFormIndex sets the unique form of existing users which don't have it and creates its unique index.
+//
+// It fails without creating the index when unique forms of existing users collide and colliding users are logged.
+// Encrypted fields can't be read here, their unique forms are set by `reencrypt-pii`. Users without the unique form
+// are not indexed.
+func createUniqueFormIndex(ctx context.Context, db *mongo.Database, field string, of func(string) string) error {
+	collection := db.Collection(usersCollection)
+	source := uniqueFormSources[field]
+
+	cursor, err := collection.Find(ctx, bson.M{field: bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{source: 1, blindIndexField(source): 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var encrypted int
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		value, _ := doc[source].(string)
+		if _, hasBlindIndex := doc[blindIndexField(source)]; hasBlindIndex || crypt.IsEncrypted(value) {
+			encrypted++
+			continue
+		}
+		form := of(value)
+		if form == "" {
+			continue
+		}
+		// not overriding concurrent updates
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": doc["_id"], source: value}, bson.M{"$set": bson.M{field: form}})
+		if err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if encrypted > 0 {
+		slog.WarnContext(ctx, "unique forms of encrypted users are not set, run `reencrypt-pii` to set them",
+			slog.String("field", field), slog.Int("users", encrypted))
+	}
+
+	collisions, err := uniqueFormCollisions(ctx, db, field)
+	if err != nil {
+		return err
+	}
+	if len(collisions) > 0 {
+		for _, c := range collisions {
+			slog.WarnContext(ctx, "users have the same unique form", slog.String("field", field), slog.Any("userIds", c.UserIds))
+		}
+		return fmt.Errorf("%d values of %s are used by more than one user, resolve them and run migrations again", len(collisions), field)
+	}
+
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{field: bson.M{"$gt": ""}}),
+	})
+	return err
+}
+
+// Collision is a unique form, e.g. a canonical email, which is used by more than one user
+type Collision struct {
+	// Value is the unique form, or its blind index when its field is encrypted
+	Value   string   `bson:"_id"`
+	UserIds []string `bson:"userIds"`
+}
+
+// EmailCollisions returns canonical emails which are used by more than one user in MongoDB ordered by canonical email.
+//
+// Collisions should be resolved before the unique index of canonical emails can be created.
+func EmailCollisions(ctx context.Context, db *mongo.Database) ([]Collision, error) {
+	return uniqueFormCollisions(ctx, db, canonicalEmailField)
+}
+
+// NickNameCollisions returns confusable skeletons of nicknames which are used by more than one user in MongoDB ordered
+// by skeleton.
+//
+// Collisions should be resolved before the unique index of skeletons can be created.
+func NickNameCollisions(ctx context.Context, db *mongo.Database) ([]Collision, error) {
+	return uniqueFormCollisions(ctx, db, nickNameSkeletonField)
+}
+
+// uniqueFormCollisions returns values of the unique form which are used by more than one user ordered by value
+func uniqueFormCollisions(ctx context.Context, db *mongo.Database, field string) ([]Collision, error) {
+	cursor, err := db.Collection(usersCollection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{field: bson.M{"$gt": ""}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "userIds": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	collisions := []Collision{}
+	if err := cursor.All(ctx, &collisions); err != nil {
+		return nil, err
+	}
+	return collisions, nil
+}
+
+// normalizeUsersCountries replaces country aliases of users with their ISO 3166-1 alpha-2 codes, e.g. `UK` with `GB`.
+//
+// Users with invalid countries are logged and listed by `migrate invalid-countries`, they are kept as they are and
+// their countries should be fixed by updating the users.
+func normalizeUsersCountries(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection(usersCollection)
+	values, err := collection.Distinct(ctx, "country", bson.M{})
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		value, _ := v.(string)
+		code, ok := country.Normalize(value)
+		if !ok || code == value {
+			continue
+		}
+		res, err := collection.UpdateMany(ctx, bson.M{"country": value}, bson.M{"$set": bson.M{"country": code}})
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "country of users is normalized", slog.String("from", value), slog.String("to", code),
+			slog.Int64("users", res.ModifiedCount))
+	}
+
+	invalid, err := InvalidCountries(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, c := range invalid {
+		slog.WarnContext(ctx, "users have an invalid country", slog.String("country", c.Country), slog.Any("userIds", c.UserIds))
+	}
+	return nil
+}
+
+// InvalidCountry is a country value of users which isn't an ISO 3166-1 alpha-2 code
+type InvalidCountry struct {
+	Country string   `bson:"_id"`
+	UserIds []string `bson:"userIds"`
+}
+
+// InvalidCountries returns countries of users in MongoDB which aren't ISO 3166-1 alpha-2 codes ordered by country
+func InvalidCountries(ctx context.Context, db *mongo.Database) ([]InvalidCountry, error) {
+	cursor, err := db.Collection(usersCollection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"country": bson.M{"$nin": countryCodes()}}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"$ifNull": bson.A{"$country", ""}}, "userIds": bson.M{"$push": "$_id"}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := []InvalidCountry{}
+	if err := cursor.All(ctx, &invalid); err != nil {
+		return nil, err
+	}
+	return invalid, nil
+}
+
+// countryCodes returns ISO 3166-1 alpha-2 codes
+func countryCodes() []string {
+	codes := []string{}
+	for _, c := range country.All() {
+		codes = append(codes, c.Code)
+	}
+	return codes
+}
@@ -0,0 +1,490 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/country"
+	"github.com/nsaltun/userapi/pkg/lib/db/pghandler"
+	"github.com/nsaltun/userapi/pkg/lib/email"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/nickname"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// pgUniqueViolation is the postgres error code for unique constraint violations
+const pgUniqueViolation = "23505"
+
+// userColumns are the columns selected for a user. Order is the same with scanUser.
+const userColumns = "id, first_name, last_name, nick_name, password, email, country, status, created_at, updated_at, version, COALESCE(email_canonical, ''), COALESCE(nick_name_skeleton, '')"
+
+// insertUserColumns are the columns inserted for a user
+const insertUserColumns = "id, first_name, last_name, nick_name, password, email, country, status, created_at, updated_at, version, email_canonical, nick_name_skeleton"
+
+// postgresUserRepository is an implementor of UserRepository on PostgreSQL
+type postgresUserRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserRepository returns new instance of UserRepository backed by PostgreSQL.
+//
+// Runs pending SQL and Go migrations of users table in this method(see postgresFuncMigrations).
+func NewPostgresUserRepository(pg *pghandler.PostgresWrapper) (UserRepository, error) {
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := pg.Migrate(ctx, migrations, postgresFuncMigrations()...); err != nil {
+		slog.ErrorContext(ctx, "Error migrating users table", slog.Any("error", err))
+		return nil, err
+	}
+
+	return &postgresUserRepository{pg.DB}, nil
+}
+
+// postgresFuncMigrations returns Go migrations of users table which are applied with SQL migrations in order of their
+// versions. Canonical emails and nickname skeletons of existing users are set and their unique indexes are created,
+// they fail when unique forms collide. Country aliases of existing users are normalized and invalid countries are
+// logged.
+func postgresFuncMigrations() []pghandler.FuncMigration {
+	migrations := []pghandler.FuncMigration{}
+	for _, form := range postgresUniqueForms() {
+		migrations = append(migrations, pghandler.FuncMigration{
+			Version: form.migration,
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				return createPostgresUniqueFormIndex(ctx, tx, form)
+			},
+		})
+	}
+	return append(migrations, pghandler.FuncMigration{Version: "0010_normalize_countries", Up: normalizePostgresCountries})
+}
+
+// Create a new user
+//
+// - Returns Conflict when unique constraint of email or nickName violated
+func (r *postgresUserRepository) Create(ctx context.Context, user *model.User) error {
+	user.Id = uuid.NewString() // Generate a new UUID
+	if user.Status == 0 {
+		user.Status = model.UserStatus_Active
+	}
+	user.Meta = model.NewMeta()
+
+	_, err := r.conn(ctx).ExecContext(ctx,
+		"INSERT INTO users ("+insertUserColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''))",
+		user.Id, user.FirstName, user.LastName, user.NickName, user.Password, user.Email, user.Country,
+		user.Status, user.CreatedAt, user.UpdatedAt, user.Version, user.EmailCanonical, user.NickNameSkeleton)
+
+	// empty password to not return in the api response
+	// NOTE: empty password before logging error to not leak password in logs
+	user.Password = ""
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			slog.InfoContext(ctx, "already exists with the same nickname or email.", slog.Any("error", err))
+			return model.ErrUserFieldTaken(uniqueViolationField(err))
+		}
+		slog.ErrorContext(ctx, "postgres create user error", slog.Any("error", err), slog.Any("user", user))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	return nil
+}
+
+// Update user by id
+//
+// - Returns NotFound when record is not found
+//
+// - Returns Conflict when unique constraint violated
+//
+// - Returns internal error for other error cases
+//
+// Returns updated user without password when it is successful with updated `UpdatedAt` and `Version` field
+func (r *postgresUserRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	user.Meta.Update()
+
+	row := r.conn(ctx).QueryRowContext(ctx,
+		`UPDATE users SET first_name = $2, last_name = $3, nick_name = $4, email = $5, country = $6,
+			status = $7, updated_at = $8, email_canonical = NULLIF($9, ''), nick_name_skeleton = NULLIF($10, ''),
+			version = version + 1
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, user.FirstName, user.LastName, user.NickName, user.Email, user.Country, user.Status, user.UpdatedAt,
+		user.EmailCanonical, user.NickNameSkeleton)
+
+	updatedUser, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrUserNotFound
+		} else if isUniqueViolation(err) {
+			slog.InfoContext(ctx, "user update failed with unique violation", slog.Any("error", err))
+			return nil, model.ErrUserFieldTaken(uniqueViolationField(err)).SetOriginError(err)
+		}
+
+		slog.InfoContext(ctx, "user update failed.", slog.Any("error", err))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	updatedUser.Password = ""
+	return updatedUser, nil
+}
+
+// ListByFilter fetches users based on a dynamic filter with pagination ordered by creation time
+func (r *postgresUserRepository) ListByFilter(ctx context.Context, filter model.UserFilter, limit int, offset int) ([]model.User, int64, error) {
+	where, args := userFilterToSql(filter)
+
+	var totalCount int64
+	if err := r.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&totalCount); err != nil {
+		slog.ErrorContext(ctx, "error from postgres while counting users", slog.Any("error", err.Error()))
+		return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	query := "SELECT " + userColumns + " FROM users" + where + " ORDER BY created_at, id"
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	args = append(args, offset)
+	query += fmt.Sprintf(" OFFSET $%d", len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.InfoContext(ctx, "error from postgres while finding users by filter.", slog.Any("error", err))
+		return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			slog.InfoContext(ctx, "error from postgres while scanning users by filter.", slog.Any("error", err))
+			return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+		}
+		user.Password = ""
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		slog.InfoContext(ctx, "error from postgres rows while getting users by filter.", slog.Any("error", err))
+		return nil, 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	return users, totalCount, nil
+}
+
+// Delete user by id. Sets status to `Inactive(2)`.
+//
+// - Returns NotFound when record is not found
+func (r *postgresUserRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "UPDATE users SET status = $2, updated_at = $3 WHERE id = $1",
+		id, model.UserStatus_Inactive, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while deleting user", slog.Any("error", err), slog.Any("id", id))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Get user by id
+//
+// - Returns NotFound when record is not found
+func (r *postgresUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrUserNotFound
+		}
+		slog.ErrorContext(ctx, "postgres error while getting user", slog.Any("error", err), slog.Any("id", id))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return user, nil
+}
+
+// SetPassword sets the password hash of the user with updated `UpdatedAt` and `Version` field
+//
+// - Returns NotFound when record is not found
+func (r *postgresUserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		"UPDATE users SET password = $2, updated_at = $3, version = version + 1 WHERE id = $1",
+		id, hash, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while setting password", slog.Any("error", err), slog.Any("id", id))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash replaces the password hash of the user when it is the current hash
+//
+// - Returns NotFound when there is no user with the id and the current hash
+func (r *postgresUserRepository) ReplacePasswordHash(ctx context.Context, id string, current string, hash string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "UPDATE users SET password = $3 WHERE id = $1 AND password = $2",
+		id, current, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while replacing password hash", slog.Any("error", err), slog.Any("id", id))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteUnverified deletes users pending verification created before the time. Their tokens are deleted by the
+// foreign key of user tokens.
+func (r *postgresUserRepository) DeleteUnverified(ctx context.Context, createdBefore time.Time) (int64, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE status = $1 AND created_at < $2",
+		model.UserStatus_PendingVerification, createdBefore.UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while deleting unverified users", slog.Any("error", err))
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return affected, nil
+}
+
+// conn returns the transaction of the context if there is one so that queries join pghandler.WithTransaction
+func (r *postgresUserRepository) conn(ctx context.Context) pghandler.Executor {
+	return pghandler.Conn(ctx, r.db)
+}
+
+// scanUser scans a row selected with userColumns into user model
+func scanUser(row interface{ Scan(dest ...any) error }) (*model.User, error) {
+	var user model.User
+	err := row.Scan(&user.Id, &user.FirstName, &user.LastName, &user.NickName, &user.Password, &user.Email,
+		&user.Country, &user.Status, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.EmailCanonical,
+		&user.NickNameSkeleton)
+	if err != nil {
+		return nil, err
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return &user, nil
+}
+
+// userFilterToSql converts a UserFilter into a SQL where clause and its arguments.
+//
+// Semantics are the same with UserFilter.ToBson
+func userFilterToSql(f model.UserFilter) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Id != "" {
+		add("id = $%d", f.Id)
+	}
+	if f.FirstName != "" {
+		add("first_name ILIKE $%d", escapeLike(f.FirstName)+"%") // Prefix matching
+	}
+	if f.LastName != "" {
+		add("last_name ILIKE $%d", escapeLike(f.LastName)+"%")
+	}
+	if f.NickName != "" {
+		add("nick_name = $%d", f.NickName)
+	}
+	if f.Email != "" {
+		add("email = $%d", f.Email)
+	}
+	if f.Country != "" {
+		add("country = $%d", f.Country)
+	}
+	if f.Status == 0 {
+		add("status = $%d", model.UserStatus_Active) // Set active as default
+	} else if f.Status > 0 {
+		add("status = $%d", f.Status)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes wildcard characters of LIKE patterns
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// isUniqueViolation reports whether the error is a postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// uniqueConstraintFields maps unique constraints of users table to unique fields of users
+var uniqueConstraintFields = map[string]string{
+	"users_email_key":              model.UniqueFieldEmail,
+	"users_email_canonical_key":    model.UniqueFieldEmail,
+	"users_nick_name_key":          model.UniqueFieldNickName,
+	"users_nick_name_skeleton_key": model.UniqueFieldNickName,
+}
+
+// uniqueViolationField returns the unique field whose constraint is violated. Returns empty string when the constraint
+// is unknown.
+func uniqueViolationField(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	return uniqueConstraintFields[pgErr.ConstraintName]
+}
+
+// postgresUniqueForm is a column derived from a unique column which has the unique index instead of it
+type postgresUniqueForm struct {
+	column string
+	source string
+	index  string
+	of     func(string) string
+	// migration is the version of the migration which backfills the column and creates its index
+	migration string
+}
+
+// postgresUniqueForms returns unique forms of users table
+func postgresUniqueForms() []postgresUniqueForm {
+	return []postgresUniqueForm{
+		{"email_canonical", "email", "users_email_canonical_key", email.NewCanonicalizer().Canonical, "0008_backfill_email_canonical"},
+		{"nick_name_skeleton", "nick_name", "users_nick_name_skeleton_key", nickname.Skeleton, "0009_backfill_nick_name_skeleton"},
+	}
+}
+
+// createPostgresUniqueFormIndex sets the unique form of existing users which don't have it and creates its unique index.
+//
+// It fails without creating the index when unique forms of existing users collide, colliding users are logged.
+// Users without the unique form are not indexed.
+func createPostgresUniqueFormIndex(ctx context.Context, tx *sql.Tx, form postgresUniqueForm) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id, %s FROM users WHERE %s IS NULL", form.source, form.column))
+	if err != nil {
+		return err
+	}
+	values := map[string]string{}
+	for rows.Next() {
+		var id, value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		values[id] = form.of(value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, value := range values {
+		// not overriding concurrent updates
+		query := fmt.Sprintf("UPDATE users SET %[1]s = NULLIF($2, '') WHERE id = $1 AND %[1]s IS NULL", form.column)
+		if _, err := tx.ExecContext(ctx, query, id, value); err != nil {
+			return err
+		}
+	}
+
+	rows, err = tx.QueryContext(ctx, fmt.Sprintf(`SELECT string_agg(id, ',' ORDER BY id) FROM users
+		WHERE %[1]s IS NOT NULL GROUP BY %[1]s HAVING count(*) > 1`, form.column))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	collisions := 0
+	for rows.Next() {
+		var userIds string
+		if err := rows.Scan(&userIds); err != nil {
+			return err
+		}
+		collisions++
+		slog.WarnContext(ctx, "users have the same unique form", slog.String("column", form.column),
+			slog.Any("userIds", strings.Split(userIds, ",")))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if collisions > 0 {
+		return fmt.Errorf("%d values of %s are used by more than one user, resolve them and start again", collisions, form.column)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON users (%s)", form.index, form.column))
+	return err
+}
+
+// normalizePostgresCountries replaces country aliases of users with their ISO 3166-1 alpha-2 codes, e.g. `UK` with `GB`.
+// Users with invalid countries are logged and kept as they are.
+func normalizePostgresCountries(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT country, string_agg(id, ',' ORDER BY id) FROM users GROUP BY country")
+	if err != nil {
+		return err
+	}
+	aliases := map[string]string{}
+	for rows.Next() {
+		var value, userIds string
+		if err := rows.Scan(&value, &userIds); err != nil {
+			rows.Close()
+			return err
+		}
+		code, ok := country.Normalize(value)
+		if !ok {
+			slog.WarnContext(ctx, "users have an invalid country", slog.String("country", value),
+				slog.Any("userIds", strings.Split(userIds, ",")))
+		} else if code != value {
+			aliases[value] = code
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for value, code := range aliases {
+		res, err := tx.ExecContext(ctx, "UPDATE users SET country = $2 WHERE country = $1", value, code)
+		if err != nil {
+			return err
+		}
+		updated, _ := res.RowsAffected()
+		slog.InfoContext(ctx, "country of users is normalized", slog.String("from", value), slog.String("to", code),
+			slog.Int64("users", updated))
+	}
+	return nil
+}
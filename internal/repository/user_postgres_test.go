@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserFilterToSql(t *testing.T) {
+	tests := []struct {
+		name          string
+		filter        model.UserFilter
+		expectedWhere string
+		expectedArgs  []any
+	}{
+		{
+			name:          "empty filter defaults to active status",
+			filter:        model.UserFilter{},
+			expectedWhere: " WHERE status = $1",
+			expectedArgs:  []any{model.UserStatus_Active},
+		},
+		{
+			name:          "negative status disables status filter",
+			filter:        model.UserFilter{Status: -1},
+			expectedWhere: "",
+			expectedArgs:  []any{},
+		},
+		{
+			name:          "prefix and exact matches",
+			filter:        model.UserFilter{FirstName: "jo_n%", Email: "john@email.com", Status: model.UserStatus_Inactive},
+			expectedWhere: " WHERE first_name ILIKE $1 AND email = $2 AND status = $3",
+			expectedArgs:  []any{`jo\_n\%%`, "john@email.com", model.UserStatus_Inactive},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			where, args := userFilterToSql(tCase.filter)
+
+			require.Equal(tt, tCase.expectedWhere, where)
+			require.Equal(tt, tCase.expectedArgs, args)
+		})
+	}
+}
+
+func TestUniqueViolationField(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"email", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_email_key"}, model.UniqueFieldEmail},
+		{"canonical email", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_email_canonical_key"}, model.UniqueFieldEmail},
+		{"nickname skeleton", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_nick_name_skeleton_key"}, model.UniqueFieldNickName},
+		{"nickName", fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_nick_name_key"}), model.UniqueFieldNickName},
+		{"unknown constraint", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_pkey"}, ""},
+		{"not a postgres error", errors.New("unexpected"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, uniqueViolationField(tt.err))
+		})
+	}
+}
+
+// TestPostgresFuncMigrations checks that Go migrations run after SQL migrations which add their columns and their
+// versions don't take numbers of SQL migrations
+func TestPostgresFuncMigrations(t *testing.T) {
+	files, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	require.NoError(t, err)
+	numbers := map[string]string{}
+	for _, file := range files {
+		numbers[strings.SplitN(path.Base(file), "_", 2)[0]] = file
+	}
+
+	for _, mig := range postgresFuncMigrations() {
+		number := strings.SplitN(mig.Version, "_", 2)[0]
+		require.NotContains(t, numbers, number, "version of %s is taken by %s", mig.Version, numbers[number])
+		require.Greater(t, mig.Version, "0003_add_nick_name_skeleton.sql")
+		require.NotNil(t, mig.Up)
+	}
+}
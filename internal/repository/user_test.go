@@ -6,6 +6,7 @@ import (
 
 	"github.com/nsaltun/userapi/internal/model"
 	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
@@ -37,3 +38,31 @@ func TestMyRepositoryFunction(t *testing.T) {
 		assert.NoError(t, err, "Should have successfully run")
 	})
 }
+
+func TestCreateDuplicateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected errwrap.IError
+	}{
+		{"email", `E11000 duplicate key error collection: users.users index: email_1 dup key: { email: "john@email.com" }`, model.ErrUserEmailTaken},
+		{"canonical email", `E11000 duplicate key error collection: users.users index: emailCanonical_1 dup key: { emailCanonical: "john@email.com" }`, model.ErrUserEmailTaken},
+		{"nickName", `E11000 duplicate key error collection: users.users index: nickName_1 dup key: { nickName: "john" }`, model.ErrUserNicknameTaken},
+		{"nickname skeleton", `E11000 duplicate key error collection: users.users index: nickNameSkeleton_1 dup key: { nickNameSkeleton: "johndoe" }`, model.ErrUserNicknameTaken},
+		{"encrypted nickName", `E11000 duplicate key error collection: users.users index: nickNameHash_1 dup key: { nickNameHash: "abc" }`, model.ErrUserNicknameTaken},
+		{"unknown index", `E11000 duplicate key error collection: users.users index: _id_ dup key: { _id: "1" }`, model.ErrUserAlreadyExists},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: tt.message}))
+
+			repo, err := NewUserRepository(&mongohandler.MongoDBWrapper{Database: mt.DB})
+			require.NoError(mt, err)
+
+			err = repo.Create(context.Background(), &model.User{NickName: "john", Email: "john@email.com"})
+			require.Equal(mt, tt.expected, err)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userTokensCollection is the name of MongoDB collection of user tokens. Expired tokens are removed by its TTL index.
+const userTokensCollection = "user_tokens"
+
+// userTokenRepository is the MongoDB implementor of UserTokenRepository
+type userTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewUserTokenRepository returns UserTokenRepository on MongoDB. Indexes of the collection are created by MongoMigrations.
+func NewUserTokenRepository(db *mongohandler.MongoDBWrapper) UserTokenRepository {
+	return &userTokenRepository{db.Collection(userTokensCollection)}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	if _, err := r.collection.InsertOne(ctx, token); err != nil {
+		slog.ErrorContext(ctx, "mongo error while creating user token", slog.Any("error", err), slog.Any("userId", token.UserId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return nil
+}
+
+func (r *userTokenRepository) Get(ctx context.Context, hash string, purpose model.UserTokenPurpose) (*model.UserToken, error) {
+	var token model.UserToken
+	err := r.collection.FindOne(ctx, unexpiredTokenFilter(hash, purpose)).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, model.ErrUserTokenInvalid
+		}
+		slog.ErrorContext(ctx, "mongo error while getting user token", slog.Any("error", err))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return &token, nil
+}
+
+func (r *userTokenRepository) Consume(ctx context.Context, hash string, purpose model.UserTokenPurpose) error {
+	res, err := r.collection.DeleteOne(ctx, unexpiredTokenFilter(hash, purpose))
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while consuming user token", slog.Any("error", err))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if res.DeletedCount == 0 {
+		return model.ErrUserTokenInvalid
+	}
+	return nil
+}
+
+func (r *userTokenRepository) CountCreatedSince(ctx context.Context, userId string, purpose model.UserTokenPurpose, since time.Time) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"userId": userId, "purpose": purpose, "createdAt": bson.M{"$gte": since}})
+	if err != nil {
+		slog.ErrorContext(ctx, "mongo error while counting user tokens", slog.Any("error", err), slog.Any("userId", userId))
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return count, nil
+}
+
+func (r *userTokenRepository) DeleteByUser(ctx context.Context, userId string, purpose model.UserTokenPurpose) error {
+	if _, err := r.collection.DeleteMany(ctx, bson.M{"userId": userId, "purpose": purpose}); err != nil {
+		slog.ErrorContext(ctx, "mongo error while deleting user tokens", slog.Any("error", err), slog.Any("userId", userId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return nil
+}
+
+// unexpiredTokenFilter filters the token of the hash and purpose which isn't expired. TTL monitor removes expired
+// tokens periodically, they are filtered until then.
+func unexpiredTokenFilter(hash string, purpose model.UserTokenPurpose) bson.M {
+	return bson.M{"_id": hash, "purpose": purpose, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+}
+
+// createUserTokensIndexes creates TTL index which removes user tokens when they expire and the index of counting
+// tokens of users
+func createUserTokensIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(userTokensCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "purpose", Value: 1}, {Key: "createdAt", Value: 1}},
+		},
+	})
+	return err
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+)
+
+// inMemoryUserTokenRepository is an implementor of UserTokenRepository which keeps tokens in memory
+type inMemoryUserTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]model.UserToken // by hash
+}
+
+// NewInMemoryUserTokenRepository returns new instance of in-memory UserTokenRepository
+func NewInMemoryUserTokenRepository() UserTokenRepository {
+	return &inMemoryUserTokenRepository{tokens: map[string]model.UserToken{}}
+}
+
+// Create stores the token and removes expired tokens
+func (r *inMemoryUserTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, t := range r.tokens {
+		if !t.ExpiresAt.After(now) {
+			delete(r.tokens, hash)
+		}
+	}
+	r.tokens[token.Hash] = *token
+	return nil
+}
+
+func (r *inMemoryUserTokenRepository) Get(ctx context.Context, hash string, purpose model.UserTokenPurpose) (*model.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.unexpired(hash, purpose)
+	if !ok {
+		return nil, model.ErrUserTokenInvalid
+	}
+	return &token, nil
+}
+
+func (r *inMemoryUserTokenRepository) Consume(ctx context.Context, hash string, purpose model.UserTokenPurpose) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.unexpired(hash, purpose); !ok {
+		return model.ErrUserTokenInvalid
+	}
+	delete(r.tokens, hash)
+	return nil
+}
+
+func (r *inMemoryUserTokenRepository) CountCreatedSince(ctx context.Context, userId string, purpose model.UserTokenPurpose, since time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, t := range r.tokens {
+		if t.UserId == userId && t.Purpose == purpose && !t.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *inMemoryUserTokenRepository) DeleteByUser(ctx context.Context, userId string, purpose model.UserTokenPurpose) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, t := range r.tokens {
+		if t.UserId == userId && t.Purpose == purpose {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// unexpired returns the token of the hash and purpose when it isn't expired
+func (r *inMemoryUserTokenRepository) unexpired(hash string, purpose model.UserTokenPurpose) (model.UserToken, bool) {
+	token, ok := r.tokens[hash]
+	if !ok || token.Purpose != purpose || !token.ExpiresAt.After(time.Now()) {
+		return model.UserToken{}, false
+	}
+	return token, true
+}
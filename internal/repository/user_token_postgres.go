@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/db/pghandler"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// postgresUserTokenRepository is an implementor of UserTokenRepository on PostgreSQL
+type postgresUserTokenRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserTokenRepository returns UserTokenRepository backed by PostgreSQL. The table is created by SQL
+// migrations of NewPostgresUserRepository.
+func NewPostgresUserTokenRepository(pg *pghandler.PostgresWrapper) UserTokenRepository {
+	return &postgresUserTokenRepository{pg.DB}
+}
+
+// Create inserts the token and removes expired tokens of the user since there is no TTL index in PostgreSQL
+func (r *postgresUserTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	_, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM user_tokens WHERE user_id = $1 AND expires_at <= $2",
+		token.UserId, time.Now().UTC())
+	if err == nil {
+		_, err = r.conn(ctx).ExecContext(ctx,
+			"INSERT INTO user_tokens (hash, user_id, purpose, created_at, expires_at, email) VALUES ($1, $2, $3, $4, $5, $6)",
+			token.Hash, token.UserId, token.Purpose, token.CreatedAt, token.ExpiresAt, token.Email)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while creating user token", slog.Any("error", err), slog.Any("userId", token.UserId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return nil
+}
+
+func (r *postgresUserTokenRepository) Get(ctx context.Context, hash string, purpose model.UserTokenPurpose) (*model.UserToken, error) {
+	var token model.UserToken
+	err := r.conn(ctx).QueryRowContext(ctx,
+		"SELECT hash, user_id, purpose, created_at, expires_at, email FROM user_tokens WHERE hash = $1 AND purpose = $2 AND expires_at > $3",
+		hash, purpose, time.Now().UTC()).
+		Scan(&token.Hash, &token.UserId, &token.Purpose, &token.CreatedAt, &token.ExpiresAt, &token.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrUserTokenInvalid
+		}
+		slog.ErrorContext(ctx, "postgres error while getting user token", slog.Any("error", err))
+		return nil, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	token.CreatedAt, token.ExpiresAt = token.CreatedAt.UTC(), token.ExpiresAt.UTC()
+	return &token, nil
+}
+
+func (r *postgresUserTokenRepository) Consume(ctx context.Context, hash string, purpose model.UserTokenPurpose) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM user_tokens WHERE hash = $1 AND purpose = $2 AND expires_at > $3",
+		hash, purpose, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while consuming user token", slog.Any("error", err))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	if affected == 0 {
+		return model.ErrUserTokenInvalid
+	}
+	return nil
+}
+
+func (r *postgresUserTokenRepository) CountCreatedSince(ctx context.Context, userId string, purpose model.UserTokenPurpose, since time.Time) (int64, error) {
+	var count int64
+	err := r.conn(ctx).QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND created_at >= $3",
+		userId, purpose, since).Scan(&count)
+	if err != nil {
+		slog.ErrorContext(ctx, "postgres error while counting user tokens", slog.Any("error", err), slog.Any("userId", userId))
+		return 0, errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return count, nil
+}
+
+func (r *postgresUserTokenRepository) DeleteByUser(ctx context.Context, userId string, purpose model.UserTokenPurpose) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2", userId, purpose); err != nil {
+		slog.ErrorContext(ctx, "postgres error while deleting user tokens", slog.Any("error", err), slog.Any("userId", userId))
+		return errwrap.ErrInternal.SetMessage("internal error").SetOriginError(err)
+	}
+	return nil
+}
+
+// conn returns the transaction of the context if there is one so that queries join pghandler.WithTransaction
+func (r *postgresUserTokenRepository) conn(ctx context.Context) pghandler.Executor {
+	return pghandler.Conn(ctx, r.db)
+}
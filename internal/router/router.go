@@ -1,19 +1,179 @@
 package router
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/nsaltun/userapi/internal/handler"
+	"github.com/nsaltun/userapi/internal/handler/country"
+	"github.com/nsaltun/userapi/internal/handler/gql"
+	"github.com/nsaltun/userapi/internal/handler/scim"
 	"github.com/nsaltun/userapi/internal/handler/user"
 	"github.com/nsaltun/userapi/pkg/lib/health"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
 	"github.com/nsaltun/userapi/pkg/lib/middleware/fiber_middleware"
+	"github.com/nsaltun/userapi/pkg/lib/openapi"
 )
 
-func NewFiberRouter(app *fiber.App, userHandler user.UserHandler, health health.HealthCheck) {
-	// Use the response middleware
-	userApi := app.Group("/api/users")
-	userApi.Use(fiber_middleware.ResponseMiddleware())
-	userApi.Post("", handler.Serve(userHandler.CreateUser))
-	userApi.Put("/:id", handler.Serve(userHandler.UpdateUserById))
-	userApi.Post("/filter", handler.Serve(userHandler.ListUsers))
-	userApi.Delete("/:id", handler.Serve(userHandler.DeleteUserById))
+// apiInfo is the metadata of the generated OpenAPI document
+var apiInfo = openapi.Info{
+	Title:       "User API",
+	Version:     "1.0.0",
+	Description: "User management service",
+}
+
+// passwordChangePath is the path of password changes under /api/users
+const passwordChangePath = "/:id/password"
+
+// isPasswordChange reports whether the request is a password change, e.g. `/api/users/1/password`
+func isPasswordChange(c *fiber.Ctx) bool {
+	segments := strings.Split(strings.Trim(c.Path(), "/"), "/")
+	return len(segments) == 4 && segments[3] == "password"
+}
+
+// NewFiberRouter registers the routes to the app.
+//
+// Returns error when the idempotency middleware can't be initialized, e.g. `IDEMPOTENCY_SECRET` is not set.
+func NewFiberRouter(app *fiber.App, userHandler user.UserHandler, countryHandler country.CountryHandler, scimHandler scim.ScimHandler, graphQLHandler gql.GraphQLHandler, idempotencyStore idempotency.Store, health health.HealthCheck) error {
+	api := handler.NewAPI(app)
+
+	// Password changes are skipped since their requests with passwords shouldn't be stored for idempotency keys
+	idempotencyMiddleware, err := fiber_middleware.IdempotencyMiddleware(idempotencyStore, isPasswordChange)
+	if err != nil {
+		return err
+	}
+
+	// Use the response middleware, wrapped responses are replayed for repeated idempotency keys
+	userApi := api.Group("/api/users", idempotencyMiddleware, fiber_middleware.ResponseMiddleware())
+	handler.Route(userApi, fiber.MethodPost, "", userHandler.CreateUser, handler.RouteDoc{
+		Summary:       "Create a user",
+		Description:   "When email verification is required, the user is created pending verification and a verification token is sent to its email.",
+		Tags:          []string{"users"},
+		SuccessStatus: http.StatusCreated,
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError},
+		Idempotent:    true,
+	})
+	handler.Route(userApi, fiber.MethodPut, "/:id", userHandler.UpdateUserById, handler.RouteDoc{
+		Summary:       "Update a user by id",
+		Description:   "When email verification is required, a new email of an active user is applied after it is confirmed by the token sent to it.",
+		Tags:          []string{"users"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError},
+	})
+	handler.Route(userApi, fiber.MethodPost, "/filter", userHandler.ListUsers, handler.RouteDoc{
+		Summary:     "List active users by filter",
+		Description: "Items of the response are users. Filter values match exactly except names which match by case insensitive prefix.",
+		Tags:        []string{"users"},
+	})
+	handler.Route(userApi, fiber.MethodDelete, "/:id", userHandler.DeleteUserById, handler.RouteDoc{
+		Summary:       "Deactivate a user by id",
+		Tags:          []string{"users"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	})
+	handler.Route(userApi, fiber.MethodPost, passwordChangePath, userHandler.ChangePassword, handler.RouteDoc{
+		Summary:       "Change the password of a user",
+		Description:   "The current password is verified and the new password should follow the password policy. Version of the user is bumped and its sessions and tokens are revoked. Idempotency-Key isn't supported.",
+		Tags:          []string{"users"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError},
+	})
+
+	passwordApi := api.Group("/api/password", fiber_middleware.ResponseMiddleware())
+	handler.Route(passwordApi, fiber.MethodPost, "/forgot", userHandler.ForgotPassword, handler.RouteDoc{
+		Summary:       "Request a password reset",
+		Description:   "A single use and expiring reset token is sent to the active user of the email. The response is the same whether or not there is a user of the email, and requests of a user are throttled.",
+		Tags:          []string{"password"},
+		SuccessStatus: http.StatusAccepted,
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+	handler.Route(passwordApi, fiber.MethodPost, "/reset", userHandler.ResetPassword, handler.RouteDoc{
+		Summary:       "Reset the password by a reset token",
+		Description:   "The token is consumed and the new password should follow the password policy. Version of the user is bumped and its sessions and tokens are revoked.",
+		Tags:          []string{"password"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+
+	emailApi := api.Group("/api/email", fiber_middleware.ResponseMiddleware())
+	handler.Route(emailApi, fiber.MethodPost, "/verify", userHandler.ConfirmEmail, handler.RouteDoc{
+		Summary:       "Confirm an email by a verification or email change token",
+		Description:   "The token is consumed. The user of a verification token becomes active and the new email of an email change token replaces the email of the user.",
+		Tags:          []string{"email"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+	})
+	handler.Route(emailApi, fiber.MethodPost, "/resend", userHandler.ResendVerification, handler.RouteDoc{
+		Summary:       "Resend the verification token",
+		Description:   "A new verification token is sent to the user pending verification with the email. The response is the same whether or not there is such a user, and requests of a user are throttled.",
+		Tags:          []string{"email"},
+		SuccessStatus: http.StatusAccepted,
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	})
+
+	loginApi := api.Group("/api/login", fiber_middleware.ResponseMiddleware())
+	handler.Route(loginApi, fiber.MethodPost, "", userHandler.Login, handler.RouteDoc{
+		Summary:       "Verify the password of a user",
+		Description:   "The user is returned when MFA of the user isn't enabled, otherwise an MFA challenge token which is verified with a code. Sessions aren't issued. Unknown emails and wrong passwords return the same error.",
+		Tags:          []string{"login"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError},
+	})
+	handler.Route(loginApi, fiber.MethodPost, "/mfa", userHandler.VerifyMfaLogin, handler.RouteDoc{
+		Summary:       "Complete a login by an MFA code",
+		Description:   "The MFA challenge token is verified with a TOTP code or a recovery code. Codes and the token are used once, codes are locked out after too many invalid codes.",
+		Tags:          []string{"login"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	})
+
+	// MFA isn't under /api/users since responses with secrets and recovery codes shouldn't be stored for idempotency keys
+	mfaApi := api.Group("/api/mfa", fiber_middleware.ResponseMiddleware())
+	handler.Route(mfaApi, fiber.MethodPost, "/:id", userHandler.EnrollMfa, handler.RouteDoc{
+		Summary:       "Start an MFA enrollment of a user",
+		Description:   "The password is verified. The TOTP secret and its `otpauth://` provisioning URI are returned, MFA is enabled after the enrollment is confirmed with a code of the secret.",
+		Tags:          []string{"mfa"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	})
+	handler.Route(mfaApi, fiber.MethodPost, "/:id/confirm", userHandler.ConfirmMfa, handler.RouteDoc{
+		Summary:       "Confirm an MFA enrollment or reset",
+		Description:   "MFA is enabled with the new secret when the code of it is valid. Recovery codes are returned once.",
+		Tags:          []string{"mfa"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	})
+	handler.Route(mfaApi, fiber.MethodPost, "/:id/reset", userHandler.ResetMfa, handler.RouteDoc{
+		Summary:       "Reset MFA of a user",
+		Description:   "The password and a code of the current secret or a recovery code are verified. A new secret is returned, the current secret is used until the reset is confirmed.",
+		Tags:          []string{"mfa"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	})
+	handler.Route(mfaApi, fiber.MethodPost, "/:id/disable", userHandler.DisableMfa, handler.RouteDoc{
+		Summary:       "Disable MFA of a user",
+		Description:   "The password and a code of the secret or a recovery code are verified. The secret and recovery codes are deleted.",
+		Tags:          []string{"mfa"},
+		ErrorStatuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	})
+
+	countryApi := api.Group("/api/countries", fiber_middleware.ResponseMiddleware())
+	handler.Route(countryApi, fiber.MethodGet, "", countryHandler.ListCountries, handler.RouteDoc{
+		Summary:       "List ISO 3166-1 countries",
+		Description:   "Countries are ordered by alpha-2 code. `code` of a country is the value of `country` fields of users, aliases such as `UK` are normalized to it.",
+		Tags:          []string{"countries"},
+		ErrorStatuses: []int{http.StatusInternalServerError},
+	})
+
+	// SCIM 2.0 provisioning, responses are SCIM resources instead of APIResponse
+	scimApi := app.Group(scim.BasePath, scim.ErrorMiddleware(), scimHandler.Authenticate)
+	scimApi.Get("/Users", scimHandler.ListUsers)
+	scimApi.Post("/Users", scimHandler.CreateUser)
+	scimApi.Get("/Users/:id", scimHandler.GetUser)
+	scimApi.Put("/Users/:id", scimHandler.ReplaceUser)
+	scimApi.Patch("/Users/:id", scimHandler.PatchUser)
+	scimApi.Delete("/Users/:id", scimHandler.DeleteUser)
+	scimApi.Get("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+	scimApi.Get("/Schemas", scimHandler.Schemas)
+	scimApi.Get("/Schemas/:id", scimHandler.GetSchema)
+
+	// GraphQL responses are GraphQL results instead of APIResponse
+	app.Post(gql.Path, graphQLHandler.Query)
+
+	spec := api.OpenAPI(apiInfo)
+	app.Get("/openapi.json", func(c *fiber.Ctx) error {
+		return c.JSON(spec)
+	})
+	return nil
 }
@@ -0,0 +1,110 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/internal/handler/user"
+	mocks "github.com/nsaltun/userapi/internal/mocks/handler"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/health"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/nsaltun/userapi/pkg/lib/middleware/fiber_middleware"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// specPath is the committed OpenAPI document
+	specPath = "../../api/openapi.json"
+	// errorsPath is the committed error code catalog
+	errorsPath = "../../api/errors.md"
+)
+
+var update = flag.Bool("update", false, "update the committed OpenAPI document and error code catalog")
+
+// TestOpenAPISpec fails when the served OpenAPI document drifts from the committed one.
+//
+// Run `go test ./internal/router -update` to update it after changing routes or their types.
+func TestOpenAPISpec(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_SECRET", "test-secret")
+	app := fiber.New()
+	require.NoError(t, NewFiberRouter(app, &mocks.UserHandler{}, &mocks.CountryHandler{}, &mocks.ScimHandler{}, &mocks.GraphQLHandler{}, idempotency.NewMemoryStore(), health.NewHealthCheck(nil)))
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/openapi.json", nil))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var generated bytes.Buffer
+	require.NoError(t, json.Indent(&generated, body, "", "  "))
+	generated.WriteString("\n")
+
+	if *update {
+		require.NoError(t, os.WriteFile(specPath, generated.Bytes(), 0o644))
+	}
+
+	committed, err := os.ReadFile(specPath)
+	require.NoError(t, err)
+	require.Equal(t, string(committed), generated.String(), "OpenAPI document is outdated, run `go test ./internal/router -update`")
+}
+
+// TestErrorCatalog fails when the committed error code catalog drifts from the registered error codes.
+//
+// Run `go test ./internal/router -update` to update it after changing error codes.
+func TestErrorCatalog(t *testing.T) {
+	generated := errwrap.CatalogMarkdown()
+	if *update {
+		require.NoError(t, os.WriteFile(errorsPath, []byte(generated), 0o644))
+	}
+
+	committed, err := os.ReadFile(errorsPath)
+	require.NoError(t, err)
+	require.Equal(t, string(committed), generated, "error code catalog is outdated, run `go test ./internal/router -update`")
+}
+
+// recordingStore records idempotency keys which are locked
+type recordingStore struct {
+	idempotency.Store
+	keys []string
+}
+
+func (s *recordingStore) Lock(ctx context.Context, key, token, fingerprint string, expiresAt time.Time) (*idempotency.Record, error) {
+	s.keys = append(s.keys, key)
+	return s.Store.Lock(ctx, key, token, fingerprint, expiresAt)
+}
+
+func TestPasswordChangeIsNotIdempotent(t *testing.T) {
+	//test setup
+	t.Setenv("IDEMPOTENCY_SECRET", "test-secret")
+	app := fiber.New()
+	userHandler := &mocks.UserHandler{}
+	userHandler.On("ChangePassword", mock.Anything, mock.Anything).Return(&user.ChangePasswordResponse{}, fiber.StatusOK, nil).Twice()
+	store := &recordingStore{Store: idempotency.NewMemoryStore()}
+	require.NoError(t, NewFiberRouter(app, userHandler, &mocks.CountryHandler{}, &mocks.ScimHandler{}, &mocks.GraphQLHandler{}, store, health.NewHealthCheck(nil)))
+
+	//execution
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(fiber.MethodPost, "/api/users/1/password", strings.NewReader(`{"currentPassword":"Correct-Horse7","newPassword":"Battery-Staple9"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber_middleware.HeaderIdempotencyKey, "k1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		//assertion
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(fiber_middleware.HeaderIdempotentReplayed))
+	}
+	require.Empty(t, store.keys, "password changes shouldn't be stored for idempotency keys")
+	userHandler.AssertExpectations(t)
+}
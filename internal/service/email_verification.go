@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/email"
+	"github.com/nsaltun/userapi/pkg/lib/nickname"
+	"github.com/spf13/viper"
+)
+
+// emailVerificationConfig is the configuration of email verification and email change flows
+type emailVerificationConfig struct {
+	// required creates users pending verification and applies email changes after they are confirmed
+	required bool
+	// tokenTTL is how long verification and email change tokens are valid
+	tokenTTL time.Duration
+	// maxRequests is the max number of verification or email change tokens of a user in throttleWindow
+	maxRequests    int64
+	throttleWindow time.Duration
+	// unverifiedTTL is how long users can stay pending verification before they are deleted
+	unverifiedTTL time.Duration
+}
+
+// loadEmailVerificationConfig reads `EMAIL_VERIFICATION_REQUIRED`, `EMAIL_VERIFICATION_TOKEN_TTL`,
+// `EMAIL_VERIFICATION_MAX_REQUESTS`, `EMAIL_VERIFICATION_THROTTLE_WINDOW` and `UNVERIFIED_USER_TTL`
+func loadEmailVerificationConfig() emailVerificationConfig {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("EMAIL_VERIFICATION_REQUIRED", true)
+	vi.SetDefault("EMAIL_VERIFICATION_TOKEN_TTL", 24*time.Hour)
+	vi.SetDefault("EMAIL_VERIFICATION_MAX_REQUESTS", 3)
+	vi.SetDefault("EMAIL_VERIFICATION_THROTTLE_WINDOW", time.Hour)
+	vi.SetDefault("UNVERIFIED_USER_TTL", 7*24*time.Hour)
+
+	return emailVerificationConfig{
+		required:       vi.GetBool("EMAIL_VERIFICATION_REQUIRED"),
+		tokenTTL:       vi.GetDuration("EMAIL_VERIFICATION_TOKEN_TTL"),
+		maxRequests:    vi.GetInt64("EMAIL_VERIFICATION_MAX_REQUESTS"),
+		throttleWindow: vi.GetDuration("EMAIL_VERIFICATION_THROTTLE_WINDOW"),
+		unverifiedTTL:  vi.GetDuration("UNVERIFIED_USER_TTL"),
+	}
+}
+
+// emailTokenPurposes are purposes of tokens which ConfirmEmail accepts
+var emailTokenPurposes = []model.UserTokenPurpose{model.UserTokenPurpose_EmailVerification, model.UserTokenPurpose_EmailChange}
+
+// ConfirmEmail confirms the email of a verification or email change token and consumes the token.
+//
+// The user of a verification token becomes active. The new email of an email change token replaces the email of the
+// user and other email change tokens of the user are deleted.
+//
+// Error cases:
+//
+// - Returns ErrUserTokenInvalid when the token doesn't exist, is already used or expired, its user is deleted or the
+// email of the user is changed after the token is sent
+//
+// - Returns Conflict when the new email is taken by another user meanwhile
+func (u *userService) ConfirmEmail(ctx context.Context, token string) error {
+	hash := hashToken(token)
+	for _, purpose := range emailTokenPurposes {
+		userToken, err := u.tokens.Get(ctx, hash, purpose)
+		if errors.Is(err, model.ErrUserTokenInvalid) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return u.confirmEmail(ctx, userToken)
+	}
+	return model.ErrUserTokenInvalid
+}
+
+// confirmEmail applies the email token to its user.
+//
+// The token is consumed and the user is updated in a transaction so that concurrent confirmations apply the change
+// once and the token isn't lost when the update fails, e.g. the new email is taken meanwhile.
+func (u *userService) confirmEmail(ctx context.Context, userToken *model.UserToken) error {
+	err := u.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := u.tokens.Consume(ctx, userToken.Hash, userToken.Purpose); err != nil {
+			return err
+		}
+
+		user, err := u.userRepository.Get(ctx, userToken.UserId)
+		if err != nil {
+			if errors.Is(err, model.ErrUserNotFound) {
+				return model.ErrUserTokenInvalid
+			}
+			slog.Info("error from repository", slog.Any("error", err.Error()))
+			return err
+		}
+
+		switch userToken.Purpose {
+		case model.UserTokenPurpose_EmailVerification:
+			if user.Status != model.UserStatus_PendingVerification || !u.sameEmail(user.Email, userToken.Email) {
+				return model.ErrUserTokenInvalid
+			}
+			user.Status = model.UserStatus_Active
+		case model.UserTokenPurpose_EmailChange:
+			if user.Status == model.UserStatus_Inactive {
+				return model.ErrUserTokenInvalid
+			}
+			user.Email = userToken.Email
+		}
+
+		// unique forms of the stored user are blind indexes when PII is encrypted, they are computed from plaintext again
+		u.normalizeEmail(user)
+		user.NickNameSkeleton = nickname.Skeleton(user.NickName)
+		if _, err := u.userRepository.Update(ctx, user.Id, user); err != nil {
+			slog.Info("error from repository", slog.Any("error", err.Error()))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if userToken.Purpose == model.UserTokenPurpose_EmailChange {
+		if err := u.tokens.DeleteByUser(ctx, userToken.UserId, model.UserTokenPurpose_EmailChange); err != nil {
+			slog.WarnContext(ctx, "email change tokens can't be deleted", slog.Any("error", err), slog.Any("id", userToken.UserId))
+		}
+	}
+	return nil
+}
+
+// ResendVerification sends a new verification token to the user pending verification with the email.
+//
+// It returns nil whether or not there is such a user and when the user has requested too many tokens in the throttle
+// window, so that callers can't find out registered emails. Failures after the user is found are logged only.
+func (u *userService) ResendVerification(ctx context.Context, emailAddress string) error {
+	filter := model.UserFilter{Email: email.Normalize(emailAddress), Status: model.UserStatus_PendingVerification}
+	users, _, err := u.userRepository.ListByFilter(ctx, filter, 1, 0)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return err
+	}
+	if len(users) == 0 {
+		slog.InfoContext(ctx, "verification is requested for unknown or verified email")
+		return nil
+	}
+
+	user := &users[0]
+	if u.throttled(ctx, user.Id, model.UserTokenPurpose_EmailVerification, u.verification.maxRequests, u.verification.throttleWindow) {
+		return nil
+	}
+	u.sendVerification(ctx, user)
+	return nil
+}
+
+// ExpireUnverifiedUsers deletes users which are pending verification longer than the unverified user TTL so that
+// their email and nickName can be registered again. Returns the number of deleted users.
+func (u *userService) ExpireUnverifiedUsers(ctx context.Context) (int64, error) {
+	deleted, err := u.userRepository.DeleteUnverified(ctx, time.Now().Add(-u.verification.unverifiedTTL))
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "unverified users are expired", slog.Any("count", deleted))
+	}
+	return deleted, nil
+}
+
+// sendVerification issues a verification token for the email of the user and sends it by the notifier. Failures are
+// logged only since the user can request another token.
+func (u *userService) sendVerification(ctx context.Context, user *model.User) {
+	token, expiresAt, err := u.issueToken(ctx, user.Id, model.UserTokenPurpose_EmailVerification, user.Email, u.verification.tokenTTL)
+	if err != nil {
+		return
+	}
+	if err := u.notifier.NotifyEmailVerification(ctx, user, token, expiresAt); err != nil {
+		slog.ErrorContext(ctx, "email verification can't be notified", slog.Any("error", err), slog.Any("id", user.Id))
+	}
+}
+
+// sendEmailChange issues an email change token for the new email of the user and sends it to the new email by the
+// notifier. Failures are logged only since the change can be requested again.
+func (u *userService) sendEmailChange(ctx context.Context, user *model.User, newEmail string) {
+	token, expiresAt, err := u.issueToken(ctx, user.Id, model.UserTokenPurpose_EmailChange, newEmail, u.verification.tokenTTL)
+	if err != nil {
+		return
+	}
+	if err := u.notifier.NotifyEmailChange(ctx, user, newEmail, token, expiresAt); err != nil {
+		slog.ErrorContext(ctx, "email change can't be notified", slog.Any("error", err), slog.Any("id", user.Id))
+	}
+}
+
+// sameEmail reports whether the emails have the same canonical form, i.e. they are the same mailbox
+func (u *userService) sameEmail(a, b string) bool {
+	return u.emails.Canonical(a) == u.emails.Canonical(b)
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	repomocks "github.com/nsaltun/userapi/internal/mocks/repository"
+	servicemocks "github.com/nsaltun/userapi/internal/mocks/service"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// isToken matches stored tokens of the purpose sent to the email
+func isToken(purpose model.UserTokenPurpose, email string) interface{} {
+	return mock.MatchedBy(func(token *model.UserToken) bool {
+		return token.Purpose == purpose && token.Email == email && token.Hash != ""
+	})
+}
+
+// transactionKey is the context key of transactions of testTransactor
+type transactionKey struct{}
+
+// testTransactor runs fn with a context marked as in transaction
+type testTransactor struct{}
+
+func (testTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, transactionKey{}, true))
+}
+
+// inTransaction matches contexts of transactions of testTransactor
+var inTransaction = mock.MatchedBy(func(ctx context.Context) bool {
+	return ctx.Value(transactionKey{}) != nil
+})
+
+func TestCreateUserVerification(t *testing.T) {
+	tests := []struct {
+		name   string
+		status model.UserStatus
+		setup  func(*repomocks.UserRepository, *repomocks.UserTokenRepository, *servicemocks.Notifier)
+	}{
+		{
+			name: "user is pending verification and token is sent",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Status == model.UserStatus_PendingVerification
+				})).Return(nil).Once()
+				tr.On("Create", mock.Anything, isToken(model.UserTokenPurpose_EmailVerification, "john@email.com")).Return(nil).Once()
+				n.On("NotifyEmailVerification", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name:   "user with status is created without verification",
+			status: model.UserStatus_Active,
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Status == model.UserStatus_Active
+				})).Return(nil).Once()
+			},
+		},
+		{
+			name: "notification failure doesn't fail creation",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+				tr.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+				n.On("NotifyEmailVerification", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("smtp is down")).Once()
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockNotifier := new(servicemocks.Notifier)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, mockNotifier, new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens, mockNotifier)
+
+			//execution
+			_, err := svc.CreateUser(context.TODO(), &model.User{NickName: "johndoe", Email: "john@email.com", Password: "Correct-Horse7", Status: tCase.status})
+
+			//assertion
+			require.NoError(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+			assert.True(tt, mockNotifier.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestUpdateUserByIdEmail(t *testing.T) {
+	id := "1"
+	current := func(status model.UserStatus) *model.User {
+		return &model.User{Id: id, NickName: "johndoe", Email: "john@email.com", Status: status}
+	}
+
+	tests := []struct {
+		name      string
+		user      model.User
+		setup     func(*repomocks.UserRepository, *repomocks.UserTokenRepository, *servicemocks.Notifier)
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "new email of active user is applied after confirmation",
+			user: model.User{NickName: "johndoe", Email: "new@email.com", Status: model.UserStatus_Active},
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Get", mock.Anything, id).Return(current(model.UserStatus_Active), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, id, model.UserTokenPurpose_EmailChange, mock.Anything).Return(int64(0), nil).Once()
+				r.On("Update", mock.Anything, id, mock.MatchedBy(func(u *model.User) bool {
+					return u.Email == "john@email.com" && u.EmailCanonical == "john@email.com"
+				})).Return(current(model.UserStatus_Active), nil).Once()
+				tr.On("Create", mock.Anything, isToken(model.UserTokenPurpose_EmailChange, "new@email.com")).Return(nil).Once()
+				n.On("NotifyEmailChange", mock.Anything, mock.Anything, "new@email.com", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "email differing by case isn't a change",
+			user: model.User{NickName: "johndoe", Email: "John@Email.com", Status: model.UserStatus_Active},
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Get", mock.Anything, id).Return(current(model.UserStatus_Active), nil).Once()
+				r.On("Update", mock.Anything, id, mock.MatchedBy(func(u *model.User) bool {
+					return u.Email == "John@email.com"
+				})).Return(current(model.UserStatus_Active), nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "email changes are throttled",
+			user: model.User{NickName: "johndoe", Email: "new@email.com", Status: model.UserStatus_Active},
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Get", mock.Anything, id).Return(current(model.UserStatus_Active), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, id, model.UserTokenPurpose_EmailChange, mock.Anything).Return(int64(3), nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserEmailChangeThrottled, err)
+			},
+		},
+		{
+			name: "email of pending user is updated and verified again",
+			user: model.User{NickName: "johndoe", Email: "new@email.com"},
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Get", mock.Anything, id).Return(current(model.UserStatus_PendingVerification), nil).Once()
+				updated := current(model.UserStatus_PendingVerification)
+				updated.Email = "new@email.com"
+				r.On("Update", mock.Anything, id, mock.MatchedBy(func(u *model.User) bool {
+					return u.Email == "new@email.com" && u.Status == model.UserStatus_PendingVerification
+				})).Return(updated, nil).Once()
+				tr.On("Create", mock.Anything, isToken(model.UserTokenPurpose_EmailVerification, "new@email.com")).Return(nil).Once()
+				n.On("NotifyEmailVerification", mock.Anything, updated, mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "pending user can't be activated",
+			user: model.User{NickName: "johndoe", Email: "john@email.com", Status: model.UserStatus_Active},
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Get", mock.Anything, id).Return(current(model.UserStatus_PendingVerification), nil).Once()
+				r.On("Update", mock.Anything, id, mock.MatchedBy(func(u *model.User) bool {
+					return u.Status == model.UserStatus_PendingVerification
+				})).Return(current(model.UserStatus_PendingVerification), nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "user not found",
+			user: model.User{NickName: "johndoe", Email: "new@email.com"},
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("Get", mock.Anything, id).Return(nil, model.ErrUserNotFound).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserNotFound, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockNotifier := new(servicemocks.Notifier)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, mockNotifier, new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens, mockNotifier)
+
+			//execution
+			_, err := svc.UpdateUserById(context.TODO(), id, tCase.user)
+
+			//assertion
+			tCase.assertErr(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+			assert.True(tt, mockNotifier.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestConfirmEmail(t *testing.T) {
+	token := "email-token"
+	hash := hashToken(token)
+	verification := &model.UserToken{Hash: hash, UserId: "1", Purpose: model.UserTokenPurpose_EmailVerification, Email: "john@email.com"}
+	change := &model.UserToken{Hash: hash, UserId: "1", Purpose: model.UserTokenPurpose_EmailChange, Email: "new@email.com"}
+	user := func(status model.UserStatus) *model.User {
+		return &model.User{Id: "1", NickName: "johndoe", Email: "john@email.com", EmailCanonical: "blind-index", Status: status}
+	}
+
+	tests := []struct {
+		name      string
+		setup     func(*repomocks.UserRepository, *repomocks.UserTokenRepository)
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "verification token activates the user",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(verification, nil).Once()
+				tr.On("Consume", inTransaction, hash, model.UserTokenPurpose_EmailVerification).Return(nil).Once()
+				r.On("Get", inTransaction, "1").Return(user(model.UserStatus_PendingVerification), nil).Once()
+				r.On("Update", inTransaction, "1", mock.MatchedBy(func(u *model.User) bool {
+					return u.Status == model.UserStatus_Active && u.EmailCanonical == "john@email.com"
+				})).Return(user(model.UserStatus_Active), nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "email change token applies the new email",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(nil, model.ErrUserTokenInvalid).Once()
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailChange).Return(change, nil).Once()
+				tr.On("Consume", inTransaction, hash, model.UserTokenPurpose_EmailChange).Return(nil).Once()
+				r.On("Get", inTransaction, "1").Return(user(model.UserStatus_Active), nil).Once()
+				r.On("Update", inTransaction, "1", mock.MatchedBy(func(u *model.User) bool {
+					return u.Email == "new@email.com" && u.EmailCanonical == "new@email.com" && u.Status == model.UserStatus_Active
+				})).Return(user(model.UserStatus_Active), nil).Once()
+				tr.On("DeleteByUser", mock.Anything, "1", model.UserTokenPurpose_EmailChange).Return(nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "token is invalid or expired",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(nil, model.ErrUserTokenInvalid).Once()
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailChange).Return(nil, model.ErrUserTokenInvalid).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+		{
+			name: "verification token of a changed email is invalid",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(verification, nil).Once()
+				tr.On("Consume", inTransaction, hash, model.UserTokenPurpose_EmailVerification).Return(nil).Once()
+				changed := user(model.UserStatus_PendingVerification)
+				changed.Email = "other@email.com"
+				r.On("Get", mock.Anything, "1").Return(changed, nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+		{
+			name: "user of the token is expired",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(verification, nil).Once()
+				tr.On("Consume", inTransaction, hash, model.UserTokenPurpose_EmailVerification).Return(nil).Once()
+				r.On("Get", inTransaction, "1").Return(nil, model.ErrUserNotFound).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+		{
+			name: "new email is taken meanwhile",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(nil, model.ErrUserTokenInvalid).Once()
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailChange).Return(change, nil).Once()
+				// the consumed token is restored by rolling back the transaction when the update fails
+				tr.On("Consume", inTransaction, hash, model.UserTokenPurpose_EmailChange).Return(nil).Once()
+				r.On("Get", inTransaction, "1").Return(user(model.UserStatus_Active), nil).Once()
+				r.On("Update", inTransaction, "1", mock.Anything).Return(nil, model.ErrUserEmailTaken).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserEmailTaken, err)
+			},
+		},
+		{
+			name: "token is consumed by a concurrent confirmation",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_EmailVerification).Return(verification, nil).Once()
+				// the user isn't updated again
+				tr.On("Consume", inTransaction, hash, model.UserTokenPurpose_EmailVerification).Return(model.ErrUserTokenInvalid).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			svc := NewUserService(mockRepo, testTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens)
+
+			//execution
+			err := svc.ConfirmEmail(context.TODO(), token)
+
+			//assertion
+			tCase.assertErr(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	pending := model.User{Id: "1", Email: "john@email.com", Status: model.UserStatus_PendingVerification}
+	byEmail := model.UserFilter{Email: "john@email.com", Status: model.UserStatus_PendingVerification}
+
+	tests := []struct {
+		name  string
+		setup func(*repomocks.UserRepository, *repomocks.UserTokenRepository, *servicemocks.Notifier)
+	}{
+		{
+			name: "token is sent to pending user",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{pending}, int64(1), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, "1", model.UserTokenPurpose_EmailVerification, mock.Anything).Return(int64(1), nil).Once()
+				tr.On("Create", mock.Anything, isToken(model.UserTokenPurpose_EmailVerification, "john@email.com")).Return(nil).Once()
+				n.On("NotifyEmailVerification", mock.Anything, &pending, mock.Anything, mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name: "unknown or verified email isn't revealed",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{}, int64(0), nil).Once()
+			},
+		},
+		{
+			name: "throttled requests aren't revealed",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{pending}, int64(1), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, "1", model.UserTokenPurpose_EmailVerification, mock.Anything).Return(int64(3), nil).Once()
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockNotifier := new(servicemocks.Notifier)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, mockNotifier, new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens, mockNotifier)
+
+			//execution
+			err := svc.ResendVerification(context.TODO(), " john@Email.com")
+
+			//assertion
+			require.NoError(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+			assert.True(tt, mockNotifier.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestExpireUnverifiedUsers(t *testing.T) {
+	mockRepo := new(repomocks.UserRepository)
+	svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+	mockRepo.On("DeleteUnverified", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return time.Until(before.Add(7*24*time.Hour)).Abs() < time.Minute
+	})).Return(int64(2), nil).Once()
+
+	deleted, err := svc.ExpireUnverifiedUsers(context.TODO())
+
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/email"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/totp"
+	"github.com/spf13/viper"
+)
+
+const (
+	// recoveryCodeCount is the number of recovery codes generated when an enrollment is confirmed
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of random bytes of recovery codes, 16 base32 characters
+	recoveryCodeLength = 10
+)
+
+// mfaConfig is the configuration of multi-factor authentication
+type mfaConfig struct {
+	// issuer is the name of the service shown by authenticator apps
+	issuer string
+	// challengeTTL is how long the login has to be verified by a code after the password
+	challengeTTL time.Duration
+	// maxAttempts is the number of invalid codes after which codes of the user are rejected for lockout
+	maxAttempts int
+	lockout     time.Duration
+}
+
+// loadMfaConfig reads `MFA_ISSUER`, `MFA_CHALLENGE_TTL`, `MFA_MAX_ATTEMPTS` and `MFA_LOCKOUT`
+func loadMfaConfig() mfaConfig {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("MFA_ISSUER", "User API")
+	vi.SetDefault("MFA_CHALLENGE_TTL", 5*time.Minute)
+	vi.SetDefault("MFA_MAX_ATTEMPTS", 5)
+	vi.SetDefault("MFA_LOCKOUT", 15*time.Minute)
+
+	return mfaConfig{
+		issuer:       vi.GetString("MFA_ISSUER"),
+		challengeTTL: vi.GetDuration("MFA_CHALLENGE_TTL"),
+		maxAttempts:  vi.GetInt("MFA_MAX_ATTEMPTS"),
+		lockout:      vi.GetDuration("MFA_LOCKOUT"),
+	}
+}
+
+// Login verifies the password of the active user of the email. The user is returned without password when MFA of the
+// user isn't enabled, otherwise an MFA challenge token is returned which should be verified by VerifyMfaLogin with a
+// code of the second factor. Sessions aren't issued, they are up to the authentication system in front of the service.
+//
+// Error cases:
+//
+// - Returns Forbidden when there is no active user of the email, the password doesn't match or the user has no
+// password, so that callers can't find out registered emails. A dummy hash is verified when there is no hash of the
+// user so that the response time doesn't reveal them either
+//
+// - Returns internal error when the challenge token can't be stored
+func (u *userService) Login(ctx context.Context, emailAddress string, password string) (*model.LoginResult, error) {
+	users, _, err := u.userRepository.ListByFilter(ctx, model.UserFilter{Email: email.Normalize(emailAddress)}, 1, 0)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	if len(users) == 0 {
+		u.verifyDummyPassword(ctx, password)
+		return nil, model.ErrUserInvalidPassword
+	}
+	// passwords aren't listed, the user is read again with its password
+	user, err := u.userRepository.Get(ctx, users[0].Id)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			u.verifyDummyPassword(ctx, password)
+			return nil, model.ErrUserInvalidPassword
+		}
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	needsRehash, err := u.verifyPassword(ctx, user, password)
+	if err != nil {
+		return nil, err
+	}
+	if needsRehash {
+		u.rehashPassword(ctx, user.Id, user.Password, password)
+	}
+	user.Password = ""
+
+	mfa, err := u.mfa.Get(ctx, user.Id)
+	if err != nil && !errors.Is(err, model.ErrUserMfaNotEnabled) {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return &model.LoginResult{User: user}, nil
+	}
+
+	token, expiresAt, err := u.issueToken(ctx, user.Id, model.UserTokenPurpose_MfaChallenge, "", u.mfaConf.challengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &model.LoginResult{MfaRequired: true, MfaToken: token, ExpiresAt: &expiresAt}, nil
+}
+
+// VerifyMfaLogin completes the login of the MFA challenge token by a TOTP code or a recovery code and consumes the
+// token. The user is returned without password.
+//
+// Error cases:
+//
+// - Returns ErrUserTokenInvalid when the token doesn't exist, is already used, expired, its user isn't active or MFA
+// of its user is disabled
+//
+// - Returns ErrUserMfaCodeInvalid when the code is invalid or already used, ErrUserMfaLocked after too many invalid
+// codes
+func (u *userService) VerifyMfaLogin(ctx context.Context, mfaToken string, code string) (*model.User, error) {
+	hash := hashToken(mfaToken)
+	userToken, err := u.tokens.Get(ctx, hash, model.UserTokenPurpose_MfaChallenge)
+	if err != nil {
+		return nil, err
+	}
+	user, err := u.userRepository.Get(ctx, userToken.UserId)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil, model.ErrUserTokenInvalid
+		}
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	if user.Status != model.UserStatus_Active {
+		return nil, model.ErrUserTokenInvalid
+	}
+	mfa, err := u.enabledMfa(ctx, user.Id)
+	if err != nil {
+		if errors.Is(err, model.ErrUserMfaNotEnabled) {
+			return nil, model.ErrUserTokenInvalid
+		}
+		return nil, err
+	}
+
+	if err := u.verifyMfaCode(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+	if err := u.tokens.Consume(ctx, hash, model.UserTokenPurpose_MfaChallenge); err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// EnrollMfa starts an MFA enrollment of the user when its password is verified. The returned secret is used after the
+// enrollment is confirmed by ConfirmMfa. A started enrollment is replaced.
+//
+// Error cases:
+//
+// - Returns NotFound error if record not found
+//
+// - Returns Forbidden when the password doesn't match or the user has no password
+//
+// - Returns Conflict when MFA is already enabled, it should be reset instead
+//
+// - Returns ServiceUnavailable when secrets can't be encrypted
+func (u *userService) EnrollMfa(ctx context.Context, id string, password string) (*model.MfaEnrollment, error) {
+	if u.mfaSecrets == nil {
+		return nil, model.ErrUserMfaUnavailable
+	}
+	user, err := u.userRepository.Get(ctx, id)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	if _, err := u.verifyPassword(ctx, user, password); err != nil {
+		return nil, err
+	}
+
+	mfa, err := u.mfa.Get(ctx, id)
+	if err != nil && !errors.Is(err, model.ErrUserMfaNotEnabled) {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, model.ErrUserMfaAlreadyEnabled
+	}
+	return u.startMfaEnrollment(ctx, user, mfa)
+}
+
+// ConfirmMfa confirms the started enrollment or reset of the user by a code of its new secret. MFA is enabled with
+// the new secret and new recovery codes are returned. Recovery codes are stored hashed, they can't be read again.
+//
+// Error cases:
+//
+// - Returns Conflict when there is no started enrollment or reset
+//
+// - Returns ErrUserMfaCodeInvalid when the code is invalid, ErrUserMfaLocked after too many invalid codes
+//
+// - Returns ServiceUnavailable when secrets can't be decrypted
+func (u *userService) ConfirmMfa(ctx context.Context, id string, code string) ([]string, error) {
+	mfa, err := u.mfa.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, model.ErrUserMfaNotEnabled) {
+			return nil, model.ErrUserMfaNotPending
+		}
+		return nil, err
+	}
+	if mfa.PendingSecret == "" {
+		return nil, model.ErrUserMfaNotPending
+	}
+	now := time.Now().UTC()
+	attempts, err := u.recordMfaAttempt(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := u.decryptMfaSecret(ctx, mfa.PendingSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok, err := totp.Default.Validate(secret, normalizeMfaCode(code), now)
+	if err != nil {
+		slog.ErrorContext(ctx, "mfa code can't be validated", slog.Any("error", err), slog.Any("id", id))
+		return nil, errwrap.ErrInternal.SetOriginError(err)
+	}
+	if !ok {
+		u.warnMfaLocked(ctx, id, attempts)
+		return nil, model.ErrUserMfaCodeInvalid
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		slog.ErrorContext(ctx, "recovery codes can't be generated", slog.Any("error", err), slog.Any("id", id))
+		return nil, errwrap.ErrInternal.SetOriginError(err)
+	}
+	mfa.Enabled = true
+	mfa.Secret, mfa.PendingSecret = mfa.PendingSecret, ""
+	mfa.RecoveryCodes = hashes
+	mfa.LastUsedStep = step
+	mfa.FailedAttempts, mfa.LastFailureAt = 0, time.Time{}
+	mfa.UpdatedAt = now
+	if err := u.mfa.Save(ctx, mfa); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetMfa starts a new enrollment of the user when its password and a code of its current secret or a recovery code
+// are verified. The current secret and recovery codes are used until the new enrollment is confirmed by ConfirmMfa.
+//
+// Error cases:
+//
+// - Returns NotFound error if record not found
+//
+// - Returns Forbidden when the password doesn't match or the user has no password
+//
+// - Returns Conflict when MFA isn't enabled
+//
+// - Returns ErrUserMfaCodeInvalid when the code is invalid, ErrUserMfaLocked after too many invalid codes
+//
+// - Returns ServiceUnavailable when secrets can't be encrypted
+func (u *userService) ResetMfa(ctx context.Context, id string, password string, code string) (*model.MfaEnrollment, error) {
+	if u.mfaSecrets == nil {
+		return nil, model.ErrUserMfaUnavailable
+	}
+	user, mfa, err := u.reauthenticate(ctx, id, password, code)
+	if err != nil {
+		return nil, err
+	}
+	return u.startMfaEnrollment(ctx, user, mfa)
+}
+
+// DisableMfa disables MFA of the user when its password and a code of its secret or a recovery code are verified.
+// The secret and recovery codes are deleted.
+//
+// Error cases are the same as ResetMfa.
+func (u *userService) DisableMfa(ctx context.Context, id string, password string, code string) error {
+	if _, _, err := u.reauthenticate(ctx, id, password, code); err != nil {
+		return err
+	}
+	return u.mfa.Delete(ctx, id)
+}
+
+// reauthenticate verifies the password and a code of the enabled MFA of the user before MFA is changed. MFA is read
+// again after the code is used, so that saving it keeps the used code and the reset failed attempts.
+func (u *userService) reauthenticate(ctx context.Context, id string, password string, code string) (*model.User, *model.UserMfa, error) {
+	user, err := u.userRepository.Get(ctx, id)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return nil, nil, err
+	}
+	if _, err := u.verifyPassword(ctx, user, password); err != nil {
+		return nil, nil, err
+	}
+	mfa, err := u.enabledMfa(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := u.verifyMfaCode(ctx, mfa, code); err != nil {
+		return nil, nil, err
+	}
+	mfa, err = u.mfa.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, mfa, nil
+}
+
+// startMfaEnrollment stores a new encrypted secret of the user as pending, mfa is nil when the user has no MFA
+func (u *userService) startMfaEnrollment(ctx context.Context, user *model.User, mfa *model.UserMfa) (*model.MfaEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		slog.ErrorContext(ctx, "mfa secret can't be generated", slog.Any("error", err), slog.Any("id", user.Id))
+		return nil, errwrap.ErrInternal.SetOriginError(err)
+	}
+	encrypted, err := u.mfaSecrets.Encrypt(secret)
+	if err != nil {
+		slog.ErrorContext(ctx, "mfa secret can't be encrypted", slog.Any("error", err), slog.Any("id", user.Id))
+		return nil, errwrap.ErrInternal.SetOriginError(err)
+	}
+
+	now := time.Now().UTC()
+	if mfa == nil {
+		mfa = &model.UserMfa{UserId: user.Id, CreatedAt: now}
+	}
+	mfa.PendingSecret = encrypted
+	mfa.UpdatedAt = now
+	if err := u.mfa.Save(ctx, mfa); err != nil {
+		return nil, err
+	}
+	return &model.MfaEnrollment{
+		Secret:          secret,
+		ProvisioningUri: totp.Default.ProvisioningURI(secret, u.mfaConf.issuer, user.Email),
+	}, nil
+}
+
+// enabledMfa returns MFA of the user, ErrUserMfaNotEnabled when it has no confirmed enrollment
+func (u *userService) enabledMfa(ctx context.Context, id string) (*model.UserMfa, error) {
+	mfa, err := u.mfa.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled {
+		return nil, model.ErrUserMfaNotEnabled
+	}
+	return mfa, nil
+}
+
+// verifyMfaCode verifies a TOTP code of the secret or a recovery code of the enabled MFA. Codes are used once, TOTP
+// codes of the last used step or earlier are rejected. Codes are counted for lockout, valid codes reset the count.
+func (u *userService) verifyMfaCode(ctx context.Context, mfa *model.UserMfa, code string) error {
+	now := time.Now().UTC()
+	attempts, err := u.recordMfaAttempt(ctx, mfa.UserId, now)
+	if err != nil {
+		return err
+	}
+
+	code = normalizeMfaCode(code)
+	if isTotpCode(code) {
+		err = u.useTotpCode(ctx, mfa, code, now)
+	} else {
+		err = u.mfa.UseRecoveryCode(ctx, mfa.UserId, hashRecoveryCode(code))
+	}
+	if errors.Is(err, model.ErrUserMfaCodeInvalid) {
+		u.warnMfaLocked(ctx, mfa.UserId, attempts)
+	}
+	return err
+}
+
+// useTotpCode validates the code by the secret and records its step as used
+func (u *userService) useTotpCode(ctx context.Context, mfa *model.UserMfa, code string, now time.Time) error {
+	secret, err := u.decryptMfaSecret(ctx, mfa.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok, err := totp.Default.Validate(secret, code, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "mfa code can't be validated", slog.Any("error", err), slog.Any("id", mfa.UserId))
+		return errwrap.ErrInternal.SetOriginError(err)
+	}
+	if !ok {
+		return model.ErrUserMfaCodeInvalid
+	}
+	return u.mfa.UseStep(ctx, mfa.UserId, step)
+}
+
+// recordMfaAttempt counts the code attempt of the user before it is verified, so that concurrent requests can't try
+// more than max attempts. Attempts older than the lockout are forgotten, a valid code resets them.
+//
+// Returns ErrUserMfaLocked when the user has max attempts in the lockout.
+func (u *userService) recordMfaAttempt(ctx context.Context, id string, at time.Time) (int, error) {
+	attempts, err := u.mfa.RecordAttempt(ctx, id, at, u.mfaConf.maxAttempts, u.mfaConf.lockout)
+	if err != nil {
+		if errors.Is(err, model.ErrUserMfaNotEnabled) {
+			// mfa is deleted concurrently
+			return 0, model.ErrUserMfaCodeInvalid
+		}
+		return 0, err
+	}
+	return attempts, nil
+}
+
+// warnMfaLocked logs the user which is locked by the invalid code
+func (u *userService) warnMfaLocked(ctx context.Context, id string, attempts int) {
+	if attempts >= u.mfaConf.maxAttempts {
+		slog.WarnContext(ctx, "mfa of the user is locked", slog.Any("id", id), slog.Any("failedAttempts", attempts))
+	}
+}
+
+// decryptMfaSecret decrypts the stored secret. Secrets are always stored encrypted, plaintext values are rejected.
+func (u *userService) decryptMfaSecret(ctx context.Context, value string) (string, error) {
+	if u.mfaSecrets == nil {
+		return "", model.ErrUserMfaUnavailable
+	}
+	if !crypt.IsEncrypted(value) {
+		slog.ErrorContext(ctx, "mfa secret is not encrypted")
+		return "", errwrap.ErrInternal.SetMessage("mfa secret is not encrypted")
+	}
+	secret, err := u.mfaSecrets.Decrypt(value)
+	if err != nil {
+		slog.ErrorContext(ctx, "mfa secret can't be decrypted", slog.Any("error", err))
+		return "", errwrap.ErrInternal.SetOriginError(err)
+	}
+	return secret, nil
+}
+
+// newRecoveryCodes returns recovery codes formatted as `xxxx-xxxx-xxxx-xxxx` and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	b := make([]byte, recoveryCodeLength)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode returns the hash of the normalized recovery code. Recovery codes are random, so like tokens a fast
+// hash is enough.
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ReplaceAll(code, "-", ""))
+}
+
+// normalizeMfaCode lowercases the code and removes spaces so that codes can be typed as they are shown
+func normalizeMfaCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// isTotpCode reports whether the code looks like a TOTP code rather than a recovery code
+func isTotpCode(code string) bool {
+	if len(code) != totp.Default.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
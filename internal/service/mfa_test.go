@@ -0,0 +1,444 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	repomocks "github.com/nsaltun/userapi/internal/mocks/repository"
+	servicemocks "github.com/nsaltun/userapi/internal/mocks/service"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/totp"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testKeys is a KeyProvider with a single static key
+type testKeys struct{}
+
+func (testKeys) CurrentKeyId() string          { return "test" }
+func (testKeys) Key(id string) ([]byte, error) { return bytes.Repeat([]byte{7}, crypt.KeySize), nil }
+func (testKeys) BlindIndexKey() []byte         { return bytes.Repeat([]byte{9}, crypt.KeySize) }
+
+var testMfaSecrets = crypt.NewEnvelopeEncryptor(testKeys{})
+
+// mfaTestUser returns the active user with the password `test_password_123`
+func mfaTestUser(t *testing.T) *model.User {
+	hash, err := testPasswordHashing.Hash("test_password_123")
+	require.NoError(t, err)
+	return &model.User{Id: "1", FirstName: "John", Email: "john@email.com", Password: hash, Status: model.UserStatus_Active}
+}
+
+// saveEnabledMfa stores enabled MFA of the user with the secret and returns its recovery codes
+func saveEnabledMfa(t *testing.T, repo repository.UserMfaRepository, userId string, secret string) []string {
+	encrypted, err := testMfaSecrets.Encrypt(secret)
+	require.NoError(t, err)
+	codes, hashes, err := newRecoveryCodes()
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(context.Background(), &model.UserMfa{UserId: userId, Enabled: true, Secret: encrypted, RecoveryCodes: hashes}))
+	return codes
+}
+
+// countingHasher counts verifications of the hasher
+type countingHasher struct {
+	crypt.PasswordHasher
+	verifies int
+}
+
+func (h *countingHasher) Verify(password, encoded string) (bool, error) {
+	h.verifies++
+	return h.PasswordHasher.Verify(password, encoded)
+}
+
+// currentCode returns the TOTP code of the secret at now
+func currentCode(t *testing.T, secret string) string {
+	code, err := totp.Default.Code(secret, totp.Default.Step(time.Now()))
+	require.NoError(t, err)
+	return code
+}
+
+func TestLogin(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	byEmail := model.UserFilter{Email: "john@email.com"}
+
+	tests := []struct {
+		name       string
+		password   string
+		mfa        bool
+		setup      func(*repomocks.UserRepository, *repomocks.UserTokenRepository)
+		assertResp func(*testing.T, *model.LoginResult)
+		expected   error
+	}{
+		{
+			name:     "user is returned without mfa",
+			password: "test_password_123",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{{Id: "1"}}, int64(1), nil).Once()
+				r.On("Get", mock.Anything, "1").Return(mfaTestUser(t), nil).Once()
+			},
+			assertResp: func(t *testing.T, res *model.LoginResult) {
+				require.False(t, res.MfaRequired)
+				require.Equal(t, "1", res.User.Id)
+				require.Empty(t, res.User.Password)
+				require.Empty(t, res.MfaToken)
+			},
+		},
+		{
+			name:     "challenge token is returned with mfa",
+			password: "test_password_123",
+			mfa:      true,
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{{Id: "1"}}, int64(1), nil).Once()
+				r.On("Get", mock.Anything, "1").Return(mfaTestUser(t), nil).Once()
+				tr.On("Create", mock.Anything, mock.MatchedBy(func(token *model.UserToken) bool {
+					return token.UserId == "1" && token.Purpose == model.UserTokenPurpose_MfaChallenge &&
+						token.ExpiresAt.Sub(token.CreatedAt) == 5*time.Minute
+				})).Return(nil).Once()
+			},
+			assertResp: func(t *testing.T, res *model.LoginResult) {
+				require.True(t, res.MfaRequired)
+				require.Nil(t, res.User)
+				require.NotEmpty(t, res.MfaToken)
+				require.NotNil(t, res.ExpiresAt)
+			},
+		},
+		{
+			name:     "wrong password",
+			password: "wrong_password",
+			mfa:      true,
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{{Id: "1"}}, int64(1), nil).Once()
+				r.On("Get", mock.Anything, "1").Return(mfaTestUser(t), nil).Once()
+			},
+			expected: model.ErrUserInvalidPassword,
+		},
+		{
+			name:     "unknown email is the same as wrong password",
+			password: "test_password_123",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{}, int64(0), nil).Once()
+			},
+			expected: model.ErrUserInvalidPassword,
+		},
+		{
+			name:     "expired user is the same as wrong password",
+			password: "test_password_123",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{{Id: "1"}}, int64(1), nil).Once()
+				r.On("Get", mock.Anything, "1").Return(nil, model.ErrUserNotFound).Once()
+			},
+			expected: model.ErrUserInvalidPassword,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mfaRepo := repository.NewInMemoryUserMfaRepository()
+			if tCase.mfa {
+				saveEnabledMfa(tt, mfaRepo, "1", secret)
+			}
+			// hashes of mfaTestUser are bcrypt hashes of testPasswordHashing
+			hasher := &countingHasher{PasswordHasher: &crypt.BcryptHasher{Cost: bcrypt.MinCost}}
+			hashing := crypt.NewPasswordHashingWith(hasher)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), hashing, new(servicemocks.SessionRevoker), mockTokens, new(servicemocks.Notifier), mfaRepo, testMfaSecrets)
+			tCase.setup(mockRepo, mockTokens)
+
+			//execution
+			res, err := svc.Login(context.TODO(), " john@Email.com ", tCase.password)
+
+			//assertion
+			if tCase.expected != nil {
+				require.Equal(tt, tCase.expected, err)
+			} else {
+				require.NoError(tt, err)
+				tCase.assertResp(tt, res)
+			}
+			// a hash is verified whether or not there is a user of the email
+			require.Equal(tt, 1, hasher.verifies)
+			mockRepo.AssertExpectations(tt)
+			mockTokens.AssertExpectations(tt)
+		})
+	}
+}
+
+func TestVerifyMfaLogin(t *testing.T) {
+	token := "mfa-token"
+	hash := hashToken(token)
+	userToken := &model.UserToken{Hash: hash, UserId: "1", Purpose: model.UserTokenPurpose_MfaChallenge}
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		code     func(t *testing.T, repo repository.UserMfaRepository, recoveryCodes []string) string
+		mfa      bool
+		consumed bool
+		expected error
+	}{
+		{
+			name:     "totp code",
+			mfa:      true,
+			code:     func(t *testing.T, _ repository.UserMfaRepository, _ []string) string { return currentCode(t, secret) },
+			consumed: true,
+		},
+		{
+			name: "replayed totp code",
+			mfa:  true,
+			code: func(t *testing.T, repo repository.UserMfaRepository, _ []string) string {
+				require.NoError(t, repo.UseStep(context.Background(), "1", totp.Default.Step(time.Now())+1))
+				return currentCode(t, secret)
+			},
+			expected: model.ErrUserMfaCodeInvalid,
+		},
+		{
+			name: "recovery code typed in upper case",
+			mfa:  true,
+			code: func(_ *testing.T, _ repository.UserMfaRepository, codes []string) string {
+				return strings.ToUpper(codes[0])
+			},
+			consumed: true,
+		},
+		{
+			name: "used recovery code",
+			mfa:  true,
+			code: func(t *testing.T, repo repository.UserMfaRepository, codes []string) string {
+				require.NoError(t, repo.UseRecoveryCode(context.Background(), "1", hashRecoveryCode(codes[0])))
+				return codes[0]
+			},
+			expected: model.ErrUserMfaCodeInvalid,
+		},
+		{
+			name: "locked after too many invalid codes",
+			mfa:  true,
+			code: func(t *testing.T, repo repository.UserMfaRepository, _ []string) string {
+				for i := 0; i < 5; i++ {
+					_, err := repo.RecordAttempt(context.Background(), "1", time.Now(), 5, time.Minute)
+					require.NoError(t, err)
+				}
+				return currentCode(t, secret)
+			},
+			expected: model.ErrUserMfaLocked,
+		},
+		{
+			name:     "mfa is disabled after login",
+			code:     func(t *testing.T, _ repository.UserMfaRepository, _ []string) string { return currentCode(t, secret) },
+			expected: model.ErrUserTokenInvalid,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mfaRepo := repository.NewInMemoryUserMfaRepository()
+			var codes []string
+			if tCase.mfa {
+				codes = saveEnabledMfa(tt, mfaRepo, "1", secret)
+			}
+			mockTokens.On("Get", mock.Anything, hash, model.UserTokenPurpose_MfaChallenge).Return(userToken, nil).Once()
+			mockRepo.On("Get", mock.Anything, "1").Return(mfaTestUser(tt), nil).Once()
+			if tCase.consumed {
+				mockTokens.On("Consume", mock.Anything, hash, model.UserTokenPurpose_MfaChallenge).Return(nil).Once()
+			}
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, new(servicemocks.Notifier), mfaRepo, testMfaSecrets)
+			code := tCase.code(tt, mfaRepo, codes)
+
+			//execution
+			user, err := svc.VerifyMfaLogin(context.TODO(), token, code)
+
+			//assertion
+			if tCase.expected != nil {
+				require.Equal(tt, tCase.expected, err)
+			} else {
+				require.NoError(tt, err)
+				require.Equal(tt, "1", user.Id)
+				require.Empty(tt, user.Password)
+			}
+			mockRepo.AssertExpectations(tt)
+			mockTokens.AssertExpectations(tt)
+		})
+	}
+}
+
+func TestMfaLockout(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	invalidCode := "aaaa-bbbb-cccc-dddd"
+
+	t.Run("concurrent invalid codes don't exceed max attempts", func(tt *testing.T) {
+		//test setup
+		mfaRepo := repository.NewInMemoryUserMfaRepository()
+		saveEnabledMfa(tt, mfaRepo, "1", secret)
+		svc := NewUserService(new(repomocks.UserRepository), repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), mfaRepo, testMfaSecrets).(*userService)
+		mfa, err := mfaRepo.Get(context.Background(), "1")
+		require.NoError(tt, err)
+
+		//execution
+		const requests = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, requests)
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// every request verifies the same snapshot of mfa
+				errs <- svc.verifyMfaCode(context.Background(), mfa, invalidCode)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		//assertion
+		checked := 0
+		for err := range errs {
+			if errors.Is(err, model.ErrUserMfaCodeInvalid) {
+				checked++
+				continue
+			}
+			require.Equal(tt, model.ErrUserMfaLocked, err)
+		}
+		require.Equal(tt, 5, checked)
+		require.Equal(tt, model.ErrUserMfaLocked, svc.verifyMfaCode(context.Background(), mfa, currentCode(tt, secret)))
+	})
+
+	t.Run("an invalid code after the lockout doesn't lock again", func(tt *testing.T) {
+		//test setup
+		mfaRepo := repository.NewInMemoryUserMfaRepository()
+		saveEnabledMfa(tt, mfaRepo, "1", secret)
+		mfa, err := mfaRepo.Get(context.Background(), "1")
+		require.NoError(tt, err)
+		mfa.FailedAttempts, mfa.LastFailureAt = 5, time.Now().Add(-16*time.Minute)
+		require.NoError(tt, mfaRepo.Save(context.Background(), mfa))
+		svc := NewUserService(new(repomocks.UserRepository), repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), mfaRepo, testMfaSecrets).(*userService)
+
+		//execution
+		err = svc.verifyMfaCode(context.Background(), mfa, invalidCode)
+
+		//assertion
+		require.Equal(tt, model.ErrUserMfaCodeInvalid, err)
+		require.NoError(tt, svc.verifyMfaCode(context.Background(), mfa, currentCode(tt, secret)))
+		stored, err := mfaRepo.Get(context.Background(), "1")
+		require.NoError(tt, err)
+		require.Equal(tt, 0, stored.FailedAttempts)
+	})
+}
+
+func TestEnrollMfa(t *testing.T) {
+	//test setup
+	ctx := context.TODO()
+	mockRepo := new(repomocks.UserRepository)
+	mockRepo.On("Get", mock.Anything, "1").Return(mfaTestUser(t), nil)
+	mfaRepo := repository.NewInMemoryUserMfaRepository()
+	svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), mfaRepo, testMfaSecrets)
+
+	//execution
+	_, err := svc.EnrollMfa(ctx, "1", "wrong_password")
+	require.Equal(t, model.ErrUserInvalidPassword, err)
+
+	enrollment, err := svc.EnrollMfa(ctx, "1", "test_password_123")
+	require.NoError(t, err)
+
+	//assertion
+	uri, err := url.Parse(enrollment.ProvisioningUri)
+	require.NoError(t, err)
+	require.Equal(t, "/User API:john@email.com", uri.Path)
+	require.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+
+	stored, err := mfaRepo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.False(t, stored.Enabled)
+	require.True(t, crypt.IsEncrypted(stored.PendingSecret))
+	require.NotContains(t, stored.PendingSecret, enrollment.Secret)
+
+	_, err = svc.ConfirmMfa(ctx, "1", "000000")
+	require.Equal(t, model.ErrUserMfaCodeInvalid, err)
+
+	codes, err := svc.ConfirmMfa(ctx, "1", currentCode(t, enrollment.Secret))
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.Regexp(t, "^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$", codes[0])
+
+	stored, err = mfaRepo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.True(t, stored.Enabled)
+	require.Empty(t, stored.PendingSecret)
+	require.Equal(t, 0, stored.FailedAttempts)
+	require.Len(t, stored.RecoveryCodes, recoveryCodeCount)
+	require.NotContains(t, stored.RecoveryCodes, codes[0])
+
+	_, err = svc.ConfirmMfa(ctx, "1", currentCode(t, enrollment.Secret))
+	require.Equal(t, model.ErrUserMfaNotPending, err)
+	_, err = svc.EnrollMfa(ctx, "1", "test_password_123")
+	require.Equal(t, model.ErrUserMfaAlreadyEnabled, err)
+}
+
+func TestEnrollMfaUnavailable(t *testing.T) {
+	svc := NewUserService(new(repomocks.UserRepository), repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), repository.NewInMemoryUserMfaRepository(), nil)
+
+	_, err := svc.EnrollMfa(context.TODO(), "1", "test_password_123")
+
+	require.Equal(t, model.ErrUserMfaUnavailable, err)
+}
+
+func TestResetAndDisableMfa(t *testing.T) {
+	//test setup
+	ctx := context.TODO()
+	mockRepo := new(repomocks.UserRepository)
+	mockRepo.On("Get", mock.Anything, "1").Return(mfaTestUser(t), nil)
+	mfaRepo := repository.NewInMemoryUserMfaRepository()
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	recoveryCodes := saveEnabledMfa(t, mfaRepo, "1", secret)
+	svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), mfaRepo, testMfaSecrets)
+
+	//execution and assertion
+	_, err = svc.ResetMfa(ctx, "1", "test_password_123", "000000")
+	require.Equal(t, model.ErrUserMfaCodeInvalid, err)
+	_, err = svc.ResetMfa(ctx, "1", "wrong_password", currentCode(t, secret))
+	require.Equal(t, model.ErrUserInvalidPassword, err)
+
+	// the device is lost, a recovery code resets mfa
+	enrollment, err := svc.ResetMfa(ctx, "1", "test_password_123", recoveryCodes[0])
+	require.NoError(t, err)
+	require.NotEqual(t, secret, enrollment.Secret)
+
+	// the current secret is used until the reset is confirmed
+	stored, err := mfaRepo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.True(t, stored.Enabled)
+	current, err := testMfaSecrets.Decrypt(stored.Secret)
+	require.NoError(t, err)
+	require.Equal(t, secret, current)
+	require.Equal(t, 0, stored.FailedAttempts)
+
+	_, err = svc.ConfirmMfa(ctx, "1", currentCode(t, enrollment.Secret))
+	require.NoError(t, err)
+
+	err = svc.DisableMfa(ctx, "1", "test_password_123", recoveryCodes[1])
+	require.Equal(t, model.ErrUserMfaCodeInvalid, err, "recovery codes are replaced")
+	err = svc.DisableMfa(ctx, "1", "test_password_123", currentCode(t, enrollment.Secret))
+	require.Equal(t, model.ErrUserMfaCodeInvalid, err, "the code is used by the confirmation")
+
+	// the next code can be used
+	stored, err = mfaRepo.Get(ctx, "1")
+	require.NoError(t, err)
+	stored.LastUsedStep = 0
+	require.NoError(t, mfaRepo.Save(ctx, stored))
+
+	require.NoError(t, svc.DisableMfa(ctx, "1", "test_password_123", currentCode(t, enrollment.Secret)))
+	_, err = mfaRepo.Get(ctx, "1")
+	require.Equal(t, model.ErrUserMfaNotEnabled, err)
+	err = svc.DisableMfa(ctx, "1", "test_password_123", currentCode(t, enrollment.Secret))
+	require.Equal(t, model.ErrUserMfaNotEnabled, err)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+)
+
+// Notifier sends messages of account flows to users, e.g. password reset links by email
+type Notifier interface {
+	NotifyPasswordReset(ctx context.Context, user *model.User, token string, expiresAt time.Time) error
+	// NotifyEmailVerification sends the verification token to the email of the user
+	NotifyEmailVerification(ctx context.Context, user *model.User, token string, expiresAt time.Time) error
+	// NotifyEmailChange sends the email change token to the new email of the user
+	NotifyEmailChange(ctx context.Context, user *model.User, newEmail string, token string, expiresAt time.Time) error
+}
+
+// Names of notification templates
+const (
+	templatePasswordReset     = "password_reset"
+	templateEmailVerification = "email_verification"
+	templateEmailChange       = "email_change"
+)
+
+// defaultLocale is the locale of users whose country has no locale in countryLocales
+const defaultLocale = "en"
+
+// countryLocales are locales of notifications by the country of the user
+var countryLocales = map[string]string{
+	"TR": "tr",
+}
+
+//go:embed templates
+var notificationTemplates embed.FS
+
+// NewNotificationTemplates returns templates of notifications of account flows
+func NewNotificationTemplates() (*notification.Templates, error) {
+	templates, err := fs.Sub(notificationTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return notification.NewTemplates(templates, defaultLocale)
+}
+
+// notificationData is the data of notification templates
+type notificationData struct {
+	FirstName string
+	NickName  string
+	// Email is the address the notification is sent to
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// queueNotifier is the Notifier which renders messages in the locale of users and enqueues them
+type queueNotifier struct {
+	templates *notification.Templates
+	queue     notification.Queue
+}
+
+// NewQueueNotifier returns Notifier which enqueues messages to be delivered in the background by a
+// notification.Dispatcher. Messages contain tokens, which are stored in the queue until they are delivered.
+func NewQueueNotifier(templates *notification.Templates, queue notification.Queue) Notifier {
+	return &queueNotifier{templates: templates, queue: queue}
+}
+
+func (n *queueNotifier) NotifyPasswordReset(ctx context.Context, user *model.User, token string, expiresAt time.Time) error {
+	return n.notify(ctx, templatePasswordReset, user, user.Email, token, expiresAt)
+}
+
+func (n *queueNotifier) NotifyEmailVerification(ctx context.Context, user *model.User, token string, expiresAt time.Time) error {
+	return n.notify(ctx, templateEmailVerification, user, user.Email, token, expiresAt)
+}
+
+func (n *queueNotifier) NotifyEmailChange(ctx context.Context, user *model.User, newEmail string, token string, expiresAt time.Time) error {
+	return n.notify(ctx, templateEmailChange, user, newEmail, token, expiresAt)
+}
+
+// notify renders the template for the user and enqueues it to the address
+func (n *queueNotifier) notify(ctx context.Context, template string, user *model.User, to string, token string, expiresAt time.Time) error {
+	msg, err := n.templates.Render(template, userLocale(user), notificationData{
+		FirstName: user.FirstName,
+		NickName:  user.NickName,
+		Email:     to,
+		Token:     token,
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return n.queue.Enqueue(ctx, msg)
+}
+
+// userLocale returns the locale of notifications of the user
+func userLocale(user *model.User) string {
+	if locale, ok := countryLocales[user.Country]; ok {
+		return locale
+	}
+	return defaultLocale
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueNotifier(t *testing.T) {
+	expiresAt := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC)
+	john := &model.User{Id: "1", FirstName: "John", NickName: "johndoe", Email: "john@email.com", Country: "US"}
+	ahmet := &model.User{Id: "2", FirstName: "Ahmet", NickName: "ahmet", Email: "ahmet@email.com", Country: "TR"}
+
+	tests := []struct {
+		name    string
+		notify  func(Notifier) error
+		to      string
+		subject string
+		texts   []string
+	}{
+		{
+			name:    "password reset",
+			notify:  func(n Notifier) error { return n.NotifyPasswordReset(context.TODO(), john, "reset-token", expiresAt) },
+			to:      "john@email.com",
+			subject: "Reset your password",
+			texts:   []string{"Hi John,", "reset-token", "2025-01-02 15:04 UTC"},
+		},
+		{
+			name: "email verification",
+			notify: func(n Notifier) error {
+				return n.NotifyEmailVerification(context.TODO(), john, "verify-token", expiresAt)
+			},
+			to:      "john@email.com",
+			subject: "Verify your email",
+			texts:   []string{"verify-token", "john@email.com"},
+		},
+		{
+			name: "email change is sent to the new email",
+			notify: func(n Notifier) error {
+				return n.NotifyEmailChange(context.TODO(), john, "new@email.com", "change-token", expiresAt)
+			},
+			to:      "new@email.com",
+			subject: "Confirm your new email",
+			texts:   []string{"change-token", "new@email.com"},
+		},
+		{
+			name:    "notification is in the locale of the country",
+			notify:  func(n Notifier) error { return n.NotifyPasswordReset(context.TODO(), ahmet, "reset-token", expiresAt) },
+			to:      "ahmet@email.com",
+			subject: "Şifrenizi sıfırlayın",
+			texts:   []string{"Merhaba Ahmet,", "reset-token"},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			templates, err := NewNotificationTemplates()
+			require.NoError(tt, err)
+			queue := notification.NewMemoryQueue()
+			notifier := NewQueueNotifier(templates, queue)
+
+			//execution
+			err = tCase.notify(notifier)
+
+			//assertion
+			require.NoError(tt, err)
+			jobs, err := queue.Claim(context.TODO(), 10, time.Now().Add(time.Minute))
+			require.NoError(tt, err)
+			require.Len(tt, jobs, 1)
+			msg := jobs[0].Message
+			require.Equal(tt, tCase.to, msg.To)
+			require.Equal(tt, tCase.subject, msg.Subject)
+			for _, text := range tCase.texts {
+				require.True(tt, strings.Contains(msg.Text, text), "text should contain %q", text)
+				require.True(tt, strings.Contains(msg.HTML, text), "HTML should contain %q", text)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/pkg/lib/email"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/spf13/viper"
+)
+
+// passwordResetConfig is the configuration of the password reset flow
+type passwordResetConfig struct {
+	// tokenTTL is how long reset tokens are valid
+	tokenTTL time.Duration
+	// maxRequests is the max number of reset tokens of a user in throttleWindow
+	maxRequests    int64
+	throttleWindow time.Duration
+	// maxPending is the max number of reset requests handled in background at a time
+	maxPending int
+}
+
+// loadPasswordResetConfig reads `PASSWORD_RESET_TOKEN_TTL`, `PASSWORD_RESET_MAX_REQUESTS`,
+// `PASSWORD_RESET_THROTTLE_WINDOW` and `PASSWORD_RESET_MAX_PENDING`
+func loadPasswordResetConfig() passwordResetConfig {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("PASSWORD_RESET_TOKEN_TTL", time.Hour)
+	vi.SetDefault("PASSWORD_RESET_MAX_REQUESTS", 3)
+	vi.SetDefault("PASSWORD_RESET_THROTTLE_WINDOW", time.Hour)
+	vi.SetDefault("PASSWORD_RESET_MAX_PENDING", 100)
+
+	return passwordResetConfig{
+		tokenTTL:       vi.GetDuration("PASSWORD_RESET_TOKEN_TTL"),
+		maxRequests:    vi.GetInt64("PASSWORD_RESET_MAX_REQUESTS"),
+		throttleWindow: vi.GetDuration("PASSWORD_RESET_THROTTLE_WINDOW"),
+		maxPending:     vi.GetInt("PASSWORD_RESET_MAX_PENDING"),
+	}
+}
+
+// ForgotPassword issues a password reset token for the active user of the email and sends it by the notifier.
+//
+// The request is handed to a background worker and nil is returned before the email is looked up, so that
+// neither the response nor its timing reveal whether there is a user of the email. Requests are dropped when
+// `PASSWORD_RESET_MAX_PENDING` requests are in progress. Failures are logged only for the same reason.
+func (u *userService) ForgotPassword(ctx context.Context, emailAddress string) error {
+	select {
+	case u.resetRequests <- struct{}{}:
+	default:
+		slog.WarnContext(ctx, "password reset request is dropped, too many requests are in progress")
+		return nil
+	}
+	// the request context is canceled when the response is sent
+	ctx = context.WithoutCancel(ctx)
+	u.background.Add(1)
+	go func() {
+		defer u.background.Done()
+		defer func() { <-u.resetRequests }()
+		u.forgotPassword(ctx, emailAddress)
+	}()
+	return nil
+}
+
+// forgotPassword issues and notifies the reset token of ForgotPassword
+func (u *userService) forgotPassword(ctx context.Context, emailAddress string) {
+	users, _, err := u.userRepository.ListByFilter(ctx, model.UserFilter{Email: email.Normalize(emailAddress)}, 1, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "user of password reset can't be found", slog.Any("error", err))
+		return
+	}
+	if len(users) == 0 {
+		slog.InfoContext(ctx, "password reset is requested for unknown email")
+		return
+	}
+	user := &users[0]
+
+	if u.throttled(ctx, user.Id, model.UserTokenPurpose_PasswordReset, u.reset.maxRequests, u.reset.throttleWindow) {
+		return
+	}
+	token, expiresAt, err := u.issueToken(ctx, user.Id, model.UserTokenPurpose_PasswordReset, "", u.reset.tokenTTL)
+	if err != nil {
+		return
+	}
+	if err := u.notifier.NotifyPasswordReset(ctx, user, token, expiresAt); err != nil {
+		slog.ErrorContext(ctx, "password reset can't be notified", slog.Any("error", err), slog.Any("id", user.Id))
+	}
+}
+
+// ResetPassword sets the new password of the user of the reset token and consumes the token. Other reset tokens of
+// the user are deleted, version of the user is bumped and its sessions and tokens are revoked.
+//
+// Error cases:
+//
+// - Returns ErrUserTokenInvalid when the token doesn't exist, is already used, expired or its user is deleted
+//
+// - Returns BadRequest when the new password is too long or breaks the password policy
+//
+// - Returns internal error when hash is faulty or sessions can't be revoked
+func (u *userService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	hash := hashToken(token)
+	userToken, err := u.tokens.Get(ctx, hash, model.UserTokenPurpose_PasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := u.userRepository.Get(ctx, userToken.UserId)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return model.ErrUserTokenInvalid
+		}
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return err
+	}
+	if user.Status != model.UserStatus_Active {
+		return model.ErrUserTokenInvalid
+	}
+
+	user.Password = newPassword
+	if err := u.validatePassword(user); err != nil {
+		return err
+	}
+	hashedPwd, err := u.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// the token is consumed first so that concurrent resets with the same token set the password once
+	if err := u.tokens.Consume(ctx, hash, model.UserTokenPurpose_PasswordReset); err != nil {
+		return err
+	}
+	if err := u.userRepository.SetPassword(ctx, user.Id, hashedPwd); err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return err
+	}
+	if err := u.tokens.DeleteByUser(ctx, user.Id, model.UserTokenPurpose_PasswordReset); err != nil {
+		slog.WarnContext(ctx, "password reset tokens can't be deleted", slog.Any("error", err), slog.Any("id", user.Id))
+	}
+	if err := u.sessions.RevokeUserSessions(ctx, user.Id); err != nil {
+		slog.ErrorContext(ctx, "sessions can't be revoked after password reset", slog.Any("error", err), slog.Any("id", user.Id))
+		return errwrap.ErrInternal.SetOriginError(err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	repomocks "github.com/nsaltun/userapi/internal/mocks/repository"
+	servicemocks "github.com/nsaltun/userapi/internal/mocks/service"
+	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForgotPassword(t *testing.T) {
+	user := model.User{Id: "1", FirstName: "John", Email: "john@email.com", Status: model.UserStatus_Active}
+	byEmail := model.UserFilter{Email: "john@email.com"}
+
+	tests := []struct {
+		name  string
+		email string
+		setup func(*repomocks.UserRepository, *repomocks.UserTokenRepository, *servicemocks.Notifier)
+	}{
+		{
+			name:  "token is stored by its hash and notified",
+			email: " john@Email.com ",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{user}, int64(1), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, "1", model.UserTokenPurpose_PasswordReset, mock.Anything).Return(int64(2), nil).Once()
+				var stored *model.UserToken
+				tr.On("Create", mock.Anything, mock.MatchedBy(func(token *model.UserToken) bool {
+					stored = token
+					return token.UserId == "1" && token.Purpose == model.UserTokenPurpose_PasswordReset &&
+						token.ExpiresAt.Sub(token.CreatedAt) == time.Hour
+				})).Return(nil).Once()
+				n.On("NotifyPasswordReset", mock.Anything, &user, mock.MatchedBy(func(token string) bool {
+					return stored != nil && stored.Hash == hashToken(token) && stored.Hash != token
+				}), mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name:  "unknown email isn't revealed",
+			email: "jane@email.com",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, model.UserFilter{Email: "jane@email.com"}, 1, 0).Return([]model.User{}, int64(0), nil).Once()
+			},
+		},
+		{
+			name:  "throttled requests aren't revealed",
+			email: "john@email.com",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{user}, int64(1), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, "1", model.UserTokenPurpose_PasswordReset, mock.Anything).Return(int64(3), nil).Once()
+			},
+		},
+		{
+			name:  "notification failure isn't revealed",
+			email: "john@email.com",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, n *servicemocks.Notifier) {
+				r.On("ListByFilter", mock.Anything, byEmail, 1, 0).Return([]model.User{user}, int64(1), nil).Once()
+				tr.On("CountCreatedSince", mock.Anything, "1", model.UserTokenPurpose_PasswordReset, mock.Anything).Return(int64(0), nil).Once()
+				tr.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+				n.On("NotifyPasswordReset", mock.Anything, &user, mock.Anything, mock.Anything).Return(errors.New("smtp is down")).Once()
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockNotifier := new(servicemocks.Notifier)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, mockNotifier, new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens, mockNotifier)
+
+			//execution
+			err := svc.ForgotPassword(context.TODO(), tCase.email)
+			svc.(*userService).background.Wait()
+
+			//assertion
+			require.NoError(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+			assert.True(tt, mockNotifier.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestForgotPasswordReturnsBeforeLookup(t *testing.T) {
+	for _, users := range [][]model.User{{{Id: "1", Email: "john@email.com", Status: model.UserStatus_Active}}, {}} {
+		//test setup
+		lookup := make(chan struct{})
+		mockRepo := new(repomocks.UserRepository)
+		mockRepo.On("ListByFilter", mock.Anything, model.UserFilter{Email: "john@email.com"}, 1, 0).
+			Run(func(mock.Arguments) { <-lookup }).Return(users, int64(len(users)), nil).Once()
+		mockTokens := new(repomocks.UserTokenRepository)
+		mockTokens.On("CountCreatedSince", mock.Anything, "1", model.UserTokenPurpose_PasswordReset, mock.Anything).Return(int64(3), nil).Maybe()
+		svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+
+		//execution
+		ctx, cancel := context.WithCancel(context.Background())
+		err := svc.ForgotPassword(ctx, "john@email.com")
+		cancel()
+
+		//assertion
+		// known and unknown emails return before the lookup of the background worker is done
+		require.NoError(t, err)
+		close(lookup)
+		svc.(*userService).background.Wait()
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	token := "reset-token"
+	hash := hashToken(token)
+	userToken := &model.UserToken{Hash: hash, UserId: "1", Purpose: model.UserTokenPurpose_PasswordReset}
+	user := func() *model.User {
+		return &model.User{Id: "1", FirstName: "John", NickName: "johndoe", Email: "john@email.com", Status: model.UserStatus_Active}
+	}
+	isNewHash := mock.MatchedBy(func(hash string) bool {
+		match, _, err := testPasswordHashing.Verify("Battery-Staple9", hash)
+		return err == nil && match
+	})
+
+	tests := []struct {
+		name        string
+		newPassword string
+		setup       func(*repomocks.UserRepository, *repomocks.UserTokenRepository, *servicemocks.SessionRevoker)
+		assertErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:        "password is reset and tokens and sessions are revoked",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(userToken, nil).Once()
+				r.On("Get", mock.Anything, "1").Return(user(), nil).Once()
+				tr.On("Consume", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(nil).Once()
+				r.On("SetPassword", mock.Anything, "1", isNewHash).Return(nil).Once()
+				tr.On("DeleteByUser", mock.Anything, "1", model.UserTokenPurpose_PasswordReset).Return(nil).Once()
+				s.On("RevokeUserSessions", mock.Anything, "1").Return(nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name:        "token is invalid or expired",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(nil, model.ErrUserTokenInvalid).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+		{
+			name:        "user is deactivated",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(userToken, nil).Once()
+				inactive := user()
+				inactive.Status = model.UserStatus_Inactive
+				r.On("Get", mock.Anything, "1").Return(inactive, nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+		{
+			name:        "new password breaks the password policy",
+			newPassword: "Johndoe-2024",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(userToken, nil).Once()
+				r.On("Get", mock.Anything, "1").Return(user(), nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var iErr errwrap.IError
+				require.ErrorAs(t, err, &iErr)
+				require.Equal(t, []errwrap.FieldError{{Field: "password", Code: password.CodePersonalInfo, Message: "password can't contain your name, nickname or email"}}, iErr.FieldErrors())
+			},
+		},
+		{
+			name:        "token is consumed concurrently",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				tr.On("Get", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(userToken, nil).Once()
+				r.On("Get", mock.Anything, "1").Return(user(), nil).Once()
+				tr.On("Consume", mock.Anything, hash, model.UserTokenPurpose_PasswordReset).Return(model.ErrUserTokenInvalid).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserTokenInvalid, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockSessions := new(servicemocks.SessionRevoker)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, mockSessions, mockTokens, new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens, mockSessions)
+
+			//execution
+			err := svc.ResetPassword(context.TODO(), token, tCase.newPassword)
+
+			//assertion
+			tCase.assertErr(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+			assert.True(tt, mockSessions.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestResetTokenIsInvalidAfterPasswordChange(t *testing.T) {
+	//test setup
+	ctx := context.Background()
+	users := repository.NewInMemoryUserRepository()
+	hash, err := testPasswordHashing.Hash("Correct-Horse7")
+	require.NoError(t, err)
+	user := &model.User{FirstName: "John", NickName: "johndoe", Email: "john@email.com", Password: hash}
+	require.NoError(t, users.Create(ctx, user))
+	mockSessions := new(servicemocks.SessionRevoker)
+	mockSessions.On("RevokeUserSessions", mock.Anything, user.Id).Return(nil).Once()
+	svc := NewUserService(users, repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, mockSessions, repository.NewInMemoryUserTokenRepository(), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+	resetToken, _, err := svc.(*userService).issueToken(ctx, user.Id, model.UserTokenPurpose_PasswordReset, "", time.Hour)
+	require.NoError(t, err)
+	mfaToken, _, err := svc.(*userService).issueToken(ctx, user.Id, model.UserTokenPurpose_MfaChallenge, "", time.Hour)
+	require.NoError(t, err)
+
+	//execution
+	require.NoError(t, svc.ChangePassword(ctx, user.Id, "Correct-Horse7", "Battery-Staple9"))
+
+	//assertion
+	require.Equal(t, model.ErrUserTokenInvalid, svc.ResetPassword(ctx, resetToken, "Staple-Battery9"))
+	_, err = svc.VerifyMfaLogin(ctx, mfaToken, "123456")
+	require.Equal(t, model.ErrUserTokenInvalid, err)
+	mockSessions.AssertExpectations(t)
+}
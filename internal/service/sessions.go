@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SessionRevoker revokes sessions and tokens of users, e.g. after their password is changed. The service doesn't
+// issue sessions or tokens, the authentication system in front of it implements SessionRevoker.
+type SessionRevoker interface {
+	RevokeUserSessions(ctx context.Context, userId string) error
+}
+
+// noopSessionRevoker is the SessionRevoker when there is no session store
+type noopSessionRevoker struct{}
+
+// NewNoopSessionRevoker returns SessionRevoker which only logs revocations. Tokens can still be revoked by rejecting
+// tokens issued for an older `version` of the user, which is bumped when the password changes.
+func NewNoopSessionRevoker() SessionRevoker {
+	return noopSessionRevoker{}
+}
+
+func (noopSessionRevoker) RevokeUserSessions(ctx context.Context, userId string) error {
+	slog.InfoContext(ctx, "sessions of the user are revoked", slog.Any("id", userId))
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/internal/model"
+)
+
+// tokenLength is the number of random bytes of user tokens
+const tokenLength = 32
+
+// issueToken creates a token of the user for the purpose and stores its hash. Email is the address which the token
+// is sent to when it is for an email flow. Failures are logged.
+func (u *userService) issueToken(ctx context.Context, userId string, purpose model.UserTokenPurpose, email string, ttl time.Duration) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		slog.ErrorContext(ctx, "user token can't be generated", slog.Any("error", err), slog.Any("purpose", purpose))
+		return "", time.Time{}, err
+	}
+	now := time.Now().UTC()
+	userToken := &model.UserToken{
+		Hash:      hashToken(token),
+		UserId:    userId,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Email:     email,
+	}
+	if err := u.tokens.Create(ctx, userToken); err != nil {
+		slog.ErrorContext(ctx, "user token can't be stored", slog.Any("error", err), slog.Any("id", userId), slog.Any("purpose", purpose))
+		return "", time.Time{}, err
+	}
+	return token, userToken.ExpiresAt, nil
+}
+
+// throttled reports whether the user has max tokens of the purpose created in the window. Tokens aren't throttled
+// when they can't be counted, failures are logged.
+func (u *userService) throttled(ctx context.Context, userId string, purpose model.UserTokenPurpose, max int64, window time.Duration) bool {
+	count, err := u.tokens.CountCreatedSince(ctx, userId, purpose, time.Now().Add(-window))
+	if err != nil {
+		slog.ErrorContext(ctx, "user tokens can't be counted", slog.Any("error", err), slog.Any("id", userId), slog.Any("purpose", purpose))
+		return false
+	}
+	if count >= max {
+		slog.WarnContext(ctx, "user token request is throttled", slog.Any("id", userId), slog.Any("purpose", purpose), slog.Any("count", count))
+		return true
+	}
+	return false
+}
+
+// newToken returns a random url safe token
+func newToken() (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash of the token which is stored instead of the token. Tokens are random and long enough
+// that a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
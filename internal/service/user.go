@@ -2,33 +2,93 @@ package service
 
 import (
 	"context"
+	"errors"
 	"log/slog"
-	"net/http"
-	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/nsaltun/userapi/internal/model"
 	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/country"
 	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/email"
 	"github.com/nsaltun/userapi/pkg/lib/errwrap"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/nsaltun/userapi/pkg/lib/nickname"
+	"github.com/nsaltun/userapi/pkg/lib/password"
 )
 
 // UserService interface
 type UserService interface {
 	CreateUser(ctx context.Context, user *model.User) (*model.User, error)
+	ProvisionUser(ctx context.Context, user *model.User) (*model.User, error)
+	GetUserById(ctx context.Context, id string) (*model.User, error)
 	UpdateUserById(ctx context.Context, id string, user model.User) (*model.User, error)
 	DeleteUserById(ctx context.Context, id string) error
 	ListUsers(ctx context.Context, userFilter model.UserFilter, limit int, offset int) (*model.Pagination, error)
+	VerifyPassword(ctx context.Context, id string, password string) error
+	ChangePassword(ctx context.Context, id string, currentPassword string, newPassword string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	ConfirmEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ExpireUnverifiedUsers(ctx context.Context) (int64, error)
+	Login(ctx context.Context, email string, password string) (*model.LoginResult, error)
+	VerifyMfaLogin(ctx context.Context, mfaToken string, code string) (*model.User, error)
+	EnrollMfa(ctx context.Context, id string, password string) (*model.MfaEnrollment, error)
+	ConfirmMfa(ctx context.Context, id string, code string) ([]string, error)
+	ResetMfa(ctx context.Context, id string, password string, code string) (*model.MfaEnrollment, error)
+	DisableMfa(ctx context.Context, id string, password string, code string) error
 }
 
 // userService implementor
 type userService struct {
 	userRepository repository.UserRepository
+	transactor     repository.Transactor
+	emails         *email.Canonicalizer
+	nicknames      *nickname.Policy
+	passwords      *password.Policy
+	hashing        *crypt.PasswordHashing
+	sessions       SessionRevoker
+	tokens         repository.UserTokenRepository
+	notifier       Notifier
+	reset          passwordResetConfig
+	verification   emailVerificationConfig
+	mfa            repository.UserMfaRepository
+	// mfaSecrets encrypts TOTP secrets, MFA is unavailable when it is nil
+	mfaSecrets *crypt.EnvelopeEncryptor
+	mfaConf    mfaConfig
+	// resetRequests limits password reset requests handled in background, see ForgotPassword
+	resetRequests chan struct{}
+	background    sync.WaitGroup
+	// dummyHash is a hash of the current hasher verified when there is no hash to verify, see verifyDummyPassword
+	dummyHash func() (string, error)
 }
 
-// NewUserService returns new instance of UserService to use it's methods
-func NewUserService(userRepository repository.UserRepository) UserService {
-	return &userService{userRepository}
+// NewUserService returns new instance of UserService to use it's methods. MFA is unavailable when mfaSecrets is nil.
+//
+// Writes which should be atomic across repositories run in transactions of the transactor, so repositories should be
+// on its storage.
+func NewUserService(userRepository repository.UserRepository, transactor repository.Transactor, passwords *password.Policy, hashing *crypt.PasswordHashing,
+	sessions SessionRevoker, tokens repository.UserTokenRepository, notifier Notifier, mfa repository.UserMfaRepository, mfaSecrets *crypt.EnvelopeEncryptor) UserService {
+	reset := loadPasswordResetConfig()
+	return &userService{
+		userRepository: userRepository,
+		transactor:     transactor,
+		emails:         email.NewCanonicalizer(),
+		nicknames:      nickname.NewPolicy(),
+		passwords:      passwords,
+		hashing:        hashing,
+		sessions:       sessions,
+		tokens:         tokens,
+		notifier:       notifier,
+		reset:          reset,
+		verification:   loadEmailVerificationConfig(),
+		mfa:            mfa,
+		mfaSecrets:     mfaSecrets,
+		mfaConf:        loadMfaConfig(),
+		resetRequests:  make(chan struct{}, reset.maxPending),
+		dummyHash:      sync.OnceValues(func() (string, error) { return hashing.Hash("dummy-password") }),
+	}
 }
 
 // CreateUser calling relevant repository method to create user.
@@ -37,30 +97,75 @@ func NewUserService(userRepository repository.UserRepository) UserService {
 //
 // - Returns internal error when hash is faulty or error from repository other than conflict
 //
-// - Returns BadRequest when password is too long.
+// - Returns BadRequest when password is too long, password breaks the password policy or nickName breaks the
+// nickname policy.
+//
+// - Returns Conflict error when unique index constraint violated or nickName looks like nickName of another user.
+//
+// Users without status are created pending verification and a verification token is sent to their email when email
+// verification is required, e.g. users provisioned by SCIM are created active.
 //
-// - Returns Conflict error when unique index constraint violated.
+// - Returns BadRequest when password is empty.
 //
 // Returns created user with ID,CreatedAt,UpdatedAt,Status when operation is successful.
 func (u *userService) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
-	hashedPwd, err := crypt.HashPassword(user.Password)
-	if err != nil {
-		code := http.StatusInternalServerError
-		message := "unexpected error"
-		if err == bcrypt.ErrPasswordTooLong {
-			code = http.StatusBadRequest
-			message = "password is too long"
+	if user.Password == "" {
+		return nil, errwrap.NewValidationError(errwrap.FieldError{Field: "password", Code: errwrap.FieldCodeRequired, Message: "password can't be empty"})
+	}
+	return u.createUser(ctx, user)
+}
+
+// ProvisionUser creates the user as CreateUser except that password is optional, e.g. users provisioned by SCIM
+// authenticate with their identity provider. A given password should follow the password policy.
+func (u *userService) ProvisionUser(ctx context.Context, user *model.User) (*model.User, error) {
+	return u.createUser(ctx, user)
+}
+
+// createUser normalizes, validates and stores the new user. Empty password is allowed, callers decide whether it is
+// required.
+func (u *userService) createUser(ctx context.Context, user *model.User) (*model.User, error) {
+	if err := u.normalizeNickName(user); err != nil {
+		return nil, err
+	}
+	if err := u.validatePassword(user); err != nil {
+		return nil, err
+	}
+	if user.Password != "" {
+		hashedPwd, err := u.hashPassword(user.Password)
+		if err != nil {
+			return nil, err
 		}
-		return nil, errwrap.NewError(message, strconv.Itoa(code)).SetHttpCode(code)
+		user.Password = hashedPwd
 	}
 
-	user.Password = hashedPwd
-	err = u.userRepository.Create(ctx, user)
+	u.normalizeEmail(user)
+	user.Country = normalizeCountry(user.Country)
+	if user.Status == 0 && u.verification.required {
+		user.Status = model.UserStatus_PendingVerification
+	}
+	err := u.userRepository.Create(ctx, user)
 	if err != nil {
 		slog.Info("error while creating user.", slog.Any("error", err.Error()))
 		return nil, err
 	}
 
+	if user.Status == model.UserStatus_PendingVerification {
+		u.sendVerification(ctx, user)
+	}
+	return user, nil
+}
+
+// GetUserById returns the user regardless of its status. Password is not returned.
+//
+// Returns NotFound error if record not found
+func (u *userService) GetUserById(ctx context.Context, id string) (*model.User, error) {
+	user, err := u.userRepository.Get(ctx, id)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	user.Password = ""
 	return user, nil
 }
 
@@ -70,19 +175,60 @@ func (u *userService) CreateUser(ctx context.Context, user *model.User) (*model.
 //
 // - Returns internal error when hash is faulty.
 //
-// - Returns BadRequest when password is too long.
+// - Returns BadRequest when password is too long or nickName breaks the nickname policy.
+//
+// - Returns Conflict error when unique index constraint violated or nickName looks like nickName of another user.
+//
+// - Returns TooManyRequests when email is changed and the user has requested too many email changes recently.
 //
-// - Returns Conflict error when unique index constraint violated.
+// When email verification is required, a new email of an active user isn't updated. It is applied after it is
+// confirmed by the email change token sent to it. Email of a user pending verification is updated and a new
+// verification token is sent. Users pending verification can't be activated by updates.
 //
 // Returns created user with ID,CreatedAt,UpdatedAt,Status when operation is successful.
 func (u *userService) UpdateUserById(ctx context.Context, id string, user model.User) (*model.User, error) {
 	user.Id = ""
+	if err := u.normalizeNickName(&user); err != nil {
+		return nil, err
+	}
+	user.Email = email.Normalize(user.Email)
+	var newEmail string
+	var verifyEmail bool
+	if u.verification.required {
+		current, err := u.userRepository.Get(ctx, id)
+		if err != nil {
+			slog.Info("error from repository", slog.Any("error", err.Error()))
+			return nil, err
+		}
+		pending := current.Status == model.UserStatus_PendingVerification
+		if pending && (user.Status == 0 || user.Status == model.UserStatus_Active) {
+			user.Status = model.UserStatus_PendingVerification
+		}
+		if !u.sameEmail(user.Email, current.Email) {
+			if pending {
+				verifyEmail = true
+			} else {
+				if u.throttled(ctx, id, model.UserTokenPurpose_EmailChange, u.verification.maxRequests, u.verification.throttleWindow) {
+					return nil, model.ErrUserEmailChangeThrottled
+				}
+				newEmail, user.Email = user.Email, current.Email
+			}
+		}
+	}
+	u.normalizeEmail(&user)
+	user.Country = normalizeCountry(user.Country)
 	updatedUser, err := u.userRepository.Update(ctx, id, &user)
 	if err != nil {
 		slog.Info("error from repository", slog.Any("error", err.Error()))
 		return nil, err
 	}
 
+	if newEmail != "" {
+		u.sendEmailChange(ctx, updatedUser, newEmail)
+	}
+	if verifyEmail && updatedUser.Status == model.UserStatus_PendingVerification {
+		u.sendVerification(ctx, updatedUser)
+	}
 	return updatedUser, nil
 }
 
@@ -95,7 +241,10 @@ func (u *userService) DeleteUserById(ctx context.Context, id string) error {
 
 // ListUsers lists users with filter and pagination
 func (u *userService) ListUsers(ctx context.Context, userFilter model.UserFilter, limit int, offset int) (*model.Pagination, error) {
-	users, totalCount, err := u.userRepository.ListByFilter(ctx, userFilter.ToBson(), limit, offset)
+	userFilter.Email = email.Normalize(userFilter.Email)
+	userFilter.NickName = nickname.Normalize(userFilter.NickName)
+	userFilter.Country = normalizeCountry(userFilter.Country)
+	users, totalCount, err := u.userRepository.ListByFilter(ctx, userFilter, limit, offset)
 	if err != nil {
 		slog.Info("error from DB while getting list of users", slog.Any("error", err.Error()))
 		return nil, err
@@ -119,3 +268,188 @@ func (u *userService) ListUsers(ctx context.Context, userFilter model.UserFilter
 
 	return pagination, nil
 }
+
+// VerifyPassword checks the password of the user regardless of its status. When the hash of the password has another
+// algorithm or outdated parameters than the current hasher, it is replaced with a new hash so that hashing can be
+// strengthened without resetting passwords.
+//
+// Error cases:
+//
+// - Returns NotFound error if record not found
+//
+// - Returns Forbidden when the password doesn't match or the user has no password
+func (u *userService) VerifyPassword(ctx context.Context, id string, password string) error {
+	user, err := u.userRepository.Get(ctx, id)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return err
+	}
+
+	needsRehash, err := u.verifyPassword(ctx, user, password)
+	if err != nil {
+		return err
+	}
+	if needsRehash {
+		u.rehashPassword(ctx, id, user.Password, password)
+	}
+	return nil
+}
+
+// ChangePassword changes the password of the user when the current password is verified. Version of the user is
+// bumped and its sessions and tokens are revoked. Pending password reset and MFA challenge tokens of the user are
+// deleted so that they can't be used with the old password anymore.
+//
+// Error cases:
+//
+// - Returns NotFound error if record not found
+//
+// - Returns Forbidden when the current password doesn't match or the user has no password
+//
+// - Returns BadRequest when the new password is too long or breaks the password policy
+//
+// - Returns internal error when hash is faulty or sessions can't be revoked
+func (u *userService) ChangePassword(ctx context.Context, id string, currentPassword string, newPassword string) error {
+	user, err := u.userRepository.Get(ctx, id)
+	if err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return err
+	}
+	if _, err := u.verifyPassword(ctx, user, currentPassword); err != nil {
+		return err
+	}
+
+	user.Password = newPassword
+	if err := u.validatePassword(user); err != nil {
+		return err
+	}
+	hashedPwd, err := u.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := u.userRepository.SetPassword(ctx, id, hashedPwd); err != nil {
+		slog.Info("error from repository", slog.Any("error", err.Error()))
+		return err
+	}
+	for _, purpose := range []model.UserTokenPurpose{model.UserTokenPurpose_PasswordReset, model.UserTokenPurpose_MfaChallenge} {
+		if err := u.tokens.DeleteByUser(ctx, id, purpose); err != nil {
+			slog.WarnContext(ctx, "user tokens can't be deleted after password change", slog.Any("error", err), slog.Any("id", id), slog.Any("purpose", purpose))
+		}
+	}
+	if err := u.sessions.RevokeUserSessions(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "sessions can't be revoked after password change", slog.Any("error", err), slog.Any("id", id))
+		return errwrap.ErrInternal.SetOriginError(err)
+	}
+	return nil
+}
+
+// hashPassword returns the hash of the password by the current hasher.
+//
+// Returns BadRequest when the password is too long for the hasher.
+func (u *userService) hashPassword(password string) (string, error) {
+	hashedPwd, err := u.hashing.Hash(password)
+	if err != nil {
+		if errors.Is(err, crypt.ErrPasswordTooLong) {
+			return "", model.ErrUserPasswordTooLong
+		}
+		return "", errwrap.ErrInternal.SetOriginError(err)
+	}
+	return hashedPwd, nil
+}
+
+// verifyPassword checks the password against the hash of the user and reports whether the hash needs rehash.
+//
+// Returns ErrUserInvalidPassword when the password doesn't match or the user has no password.
+func (u *userService) verifyPassword(ctx context.Context, user *model.User, password string) (bool, error) {
+	if user.Password == "" || password == "" {
+		u.verifyDummyPassword(ctx, password)
+		return false, model.ErrUserInvalidPassword
+	}
+
+	match, needsRehash, err := u.hashing.Verify(password, user.Password)
+	if err != nil {
+		slog.ErrorContext(ctx, "password hash can't be verified", slog.Any("error", err), slog.Any("id", user.Id))
+		return false, errwrap.ErrInternal.SetOriginError(err)
+	}
+	if !match {
+		return false, model.ErrUserInvalidPassword
+	}
+	return needsRehash, nil
+}
+
+// verifyDummyPassword verifies the password against a dummy hash of the current hasher and ignores the result. It is
+// called when there is no hash to verify, e.g. the email is unknown, so that responses take as long as a wrong password.
+func (u *userService) verifyDummyPassword(ctx context.Context, password string) {
+	hash, err := u.dummyHash()
+	if err != nil {
+		slog.ErrorContext(ctx, "dummy password hash can't be generated", slog.Any("error", err))
+		return
+	}
+	_, _, _ = u.hashing.Verify(password, hash)
+}
+
+// rehashPassword replaces the verified hash of the user with a hash of the current hasher. Failures are logged only
+// since the password is verified and the hash is replaced on the next verification.
+func (u *userService) rehashPassword(ctx context.Context, id string, current string, password string) {
+	hash, err := u.hashing.Hash(password)
+	if err != nil {
+		slog.WarnContext(ctx, "password can't be rehashed", slog.Any("error", err), slog.Any("id", id))
+		return
+	}
+	// not found means the password is changed concurrently, the new password has a current hash already
+	if err := u.userRepository.ReplacePasswordHash(ctx, id, current, hash); err != nil && !errors.Is(err, model.ErrUserNotFound) {
+		slog.WarnContext(ctx, "password hash can't be replaced", slog.Any("error", err), slog.Any("id", id))
+	}
+}
+
+// normalizeEmail normalizes email of the user and sets its canonical form which is unique among users
+func (u *userService) normalizeEmail(user *model.User) {
+	user.Email = email.Normalize(user.Email)
+	user.EmailCanonical = u.emails.Canonical(user.Email)
+}
+
+// normalizeNickName normalizes nickName of the user and sets its skeleton which is unique among users.
+//
+// Returns validation error when nickName breaks the nickname policy. Empty nickName of partial updates is skipped.
+func (u *userService) normalizeNickName(user *model.User) error {
+	if user.NickName == "" {
+		return nil
+	}
+	user.NickName = nickname.Normalize(user.NickName)
+	if err := u.nicknames.Validate(user.NickName); err != nil {
+		return errwrap.NewValidationError(errwrap.FieldError{
+			Field:   model.UniqueFieldNickName,
+			Code:    errwrap.FieldCodeInvalid,
+			Message: err.Error(),
+		})
+	}
+	user.NickNameSkeleton = nickname.Skeleton(user.NickName)
+	return nil
+}
+
+// validatePassword returns validation error with a field error per rule of the password policy which the password
+// of the user breaks. Empty password of users provisioned without password is skipped.
+func (u *userService) validatePassword(user *model.User) error {
+	if user.Password == "" {
+		return nil
+	}
+	localPart, _, _ := strings.Cut(user.Email, "@")
+	violations := u.passwords.Validate(user.Password, user.FirstName, user.LastName, user.NickName, user.Email, localPart)
+	if len(violations) == 0 {
+		return nil
+	}
+	fieldErrors := make([]errwrap.FieldError, 0, len(violations))
+	for _, v := range violations {
+		fieldErrors = append(fieldErrors, errwrap.FieldError{Field: "password", Code: v.Code, Message: v.Message})
+	}
+	return errwrap.NewValidationError(fieldErrors...)
+}
+
+// normalizeCountry returns the ISO 3166-1 alpha-2 code of the country code or its alias, e.g. `GB` for `UK`.
+// Unknown values are returned as they are.
+func normalizeCountry(value string) string {
+	if code, ok := country.Normalize(value); ok {
+		return code
+	}
+	return value
+}
@@ -2,16 +2,34 @@ package service
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	repomocks "github.com/nsaltun/userapi/internal/mocks/repository"
+	servicemocks "github.com/nsaltun/userapi/internal/mocks/service"
 	"github.com/nsaltun/userapi/internal/model"
+	"github.com/nsaltun/userapi/internal/repository"
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
 	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/password"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testPasswordPolicy(t *testing.T) *password.Policy {
+	policy, err := password.NewPolicyWith(password.DefaultConfig())
+	require.NoError(t, err)
+	return policy
+}
+
+// testPasswordHashing hashes with bcrypt and verifies argon2id hashes too, with cheap parameters to keep tests fast
+var testPasswordHashing = crypt.NewPasswordHashingWith(
+	&crypt.BcryptHasher{Cost: bcrypt.MinCost},
+	&crypt.Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
 )
 
 func TestCreate(t *testing.T) {
@@ -32,13 +50,103 @@ func TestCreate(t *testing.T) {
 			assertResp: require.NotNil,
 			assertErr:  require.NoError,
 		},
+		{
+			name:        "password is hashed",
+			userRequest: &model.User{Password: "test_password_123"},
+			setup: func(r *repomocks.UserRepository, u *model.User) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					match, needsRehash, err := testPasswordHashing.Verify("test_password_123", u.Password)
+					return err == nil && match && !needsRehash
+				})).Return(nil).Once()
+			},
+			assertResp: require.NotNil,
+			assertErr:  require.NoError,
+		},
+		{
+			name:        "empty password is required",
+			userRequest: &model.User{},
+			setup:       func(r *repomocks.UserRepository, u *model.User) {},
+			assertResp:  require.Nil,
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, errwrap.NewValidationError(errwrap.FieldError{Field: "password", Code: errwrap.FieldCodeRequired, Message: "password can't be empty"}), err)
+			},
+		},
+		{
+			name:        "email is normalized with its canonical form",
+			userRequest: &model.User{Password: "test_password_123", Email: " John.Doe@Email.COM "},
+			setup: func(r *repomocks.UserRepository, u *model.User) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Email == "John.Doe@email.com" && u.EmailCanonical == "john.doe@email.com"
+				})).Return(nil).Once()
+			},
+			assertResp: require.NotNil,
+			assertErr:  require.NoError,
+		},
+		{
+			name:        "nickName is normalized with its skeleton",
+			userRequest: &model.User{Password: "test_password_123", NickName: " ｊｏｈｎ.Doe "},
+			setup: func(r *repomocks.UserRepository, u *model.User) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.NickName == "john.Doe" && u.NickNameSkeleton == "johndoe"
+				})).Return(nil).Once()
+			},
+			assertResp: require.NotNil,
+			assertErr:  require.NoError,
+		},
+		{
+			name:        "country alias is normalized",
+			userRequest: &model.User{Password: "test_password_123", Country: "uk"},
+			setup: func(r *repomocks.UserRepository, u *model.User) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Country == "GB"
+				})).Return(nil).Once()
+			},
+			assertResp: require.NotNil,
+			assertErr:  require.NoError,
+		},
+		{
+			name:        "nickName breaks the nickname policy",
+			userRequest: &model.User{Password: "test_password_123", NickName: "Adm1n"},
+			setup:       noSetup,
+			assertResp:  require.Nil,
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var iErr errwrap.IError
+				require.ErrorAs(t, err, &iErr)
+				require.Equal(t, []errwrap.FieldError{{Field: "nickName", Code: errwrap.FieldCodeInvalid, Message: "nickname is reserved"}}, iErr.FieldErrors())
+			},
+		},
+		{
+			name:        "password breaks the password policy",
+			userRequest: &model.User{Password: "johnsmith1", FirstName: "John", Email: "jsmith@email.com"},
+			setup:       noSetup,
+			assertResp:  require.Nil,
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var iErr errwrap.IError
+				require.ErrorAs(t, err, &iErr)
+				require.Equal(t, []errwrap.FieldError{
+					{Field: "password", Code: password.CodeCharacterClasses, Message: "password must have at least 3 of lowercase letters, uppercase letters, digits and symbols"},
+					{Field: "password", Code: password.CodePersonalInfo, Message: "password can't contain your name, nickname or email"},
+				}, iErr.FieldErrors())
+			},
+		},
+		{
+			name:        "password is breached",
+			userRequest: &model.User{Password: "P@ssw0rd123"},
+			setup:       noSetup,
+			assertResp:  require.Nil,
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var iErr errwrap.IError
+				require.ErrorAs(t, err, &iErr)
+				require.Equal(t, []errwrap.FieldError{{Field: "password", Code: password.CodeBreached, Message: "password is known to be breached, choose another one"}}, iErr.FieldErrors())
+			},
+		},
 		{
 			name:        "password is too long",
-			userRequest: &model.User{Password: strings.Repeat("a", 73)},
+			userRequest: &model.User{Password: strings.Repeat("Aa1_", 19)},
 			setup:       noSetup,
 			assertResp:  require.Nil,
 			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
-				require.Equal(t, err, errwrap.ErrBadRequest.SetMessage("password is too long"))
+				require.Equal(t, err, model.ErrUserPasswordTooLong)
 			},
 		},
 		{
@@ -58,7 +166,12 @@ func TestCreate(t *testing.T) {
 			//test setup
 			ctx := context.TODO()
 			mockRepo := new(repomocks.UserRepository)
-			svc := NewUserService(mockRepo)
+			// verification of created users is tested in TestCreateUserVerification
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockTokens.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+			mockNotifier := new(servicemocks.Notifier)
+			mockNotifier.On("NotifyEmailVerification", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, mockNotifier, new(repomocks.UserMfaRepository), nil)
 			tCase.setup(mockRepo, tCase.userRequest)
 
 			//execution
@@ -74,6 +187,55 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestProvisionUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		userRequest *model.User
+		setup       func(*repomocks.UserRepository)
+		assertResp  require.ValueAssertionFunc
+		assertErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:        "empty password is not hashed",
+			userRequest: &model.User{},
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Password == ""
+				})).Return(nil).Once()
+			},
+			assertResp: require.NotNil,
+			assertErr:  require.NoError,
+		},
+		{
+			name:        "given password follows the policy",
+			userRequest: &model.User{Password: "short"},
+			setup:       func(r *repomocks.UserRepository) {},
+			assertResp:  require.Nil,
+			assertErr:   require.Error,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockTokens.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+			mockNotifier := new(servicemocks.Notifier)
+			mockNotifier.On("NotifyEmailVerification", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), mockTokens, mockNotifier, new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo)
+
+			//execution
+			res, err := svc.ProvisionUser(context.TODO(), tCase.userRequest)
+
+			//assertion
+			tCase.assertErr(tt, err)
+			tCase.assertResp(tt, res)
+			mockRepo.AssertExpectations(tt)
+		})
+	}
+}
+
 func TestUpdateUserById(t *testing.T) {
 	type request struct {
 		id   string
@@ -90,6 +252,7 @@ func TestUpdateUserById(t *testing.T) {
 			name: "repository returns success",
 			req:  &request{id: uuid.NewString(), user: &model.User{FirstName: "test_firstName"}},
 			setup: func(r *repomocks.UserRepository, u *request) {
+				r.On("Get", mock.Anything, u.id).Return(&model.User{Status: model.UserStatus_Active}, nil).Once()
 				r.On("Update", mock.Anything, u.id, u.user).Return(&model.User{FirstName: "test_firstName"}, nil).Once()
 			},
 			assertResp: func(t require.TestingT, actual interface{}, _ ...interface{}) {
@@ -102,6 +265,7 @@ func TestUpdateUserById(t *testing.T) {
 			name: "repository returns error",
 			req:  &request{id: uuid.NewString(), user: &model.User{FirstName: "test_firstName"}},
 			setup: func(r *repomocks.UserRepository, u *request) {
+				r.On("Get", mock.Anything, u.id).Return(&model.User{Status: model.UserStatus_Active}, nil).Once()
 				r.On("Update", mock.Anything, u.id, u.user).Return(nil, errwrap.ErrConflict.SetMessage("test update error")).Once()
 			},
 			assertResp: require.Nil,
@@ -109,13 +273,36 @@ func TestUpdateUserById(t *testing.T) {
 				require.Equal(t, errwrap.ErrConflict.SetMessage("test update error"), err)
 			},
 		},
+		{
+			name: "nickName is normalized with its skeleton",
+			req:  &request{id: uuid.NewString(), user: &model.User{NickName: " ｊｏｈｎ.Doe "}},
+			setup: func(r *repomocks.UserRepository, u *request) {
+				r.On("Get", mock.Anything, u.id).Return(&model.User{Status: model.UserStatus_Active}, nil).Once()
+				r.On("Update", mock.Anything, u.id, mock.MatchedBy(func(u *model.User) bool {
+					return u.NickName == "john.Doe" && u.NickNameSkeleton == "johndoe"
+				})).Return(&model.User{NickName: "john.Doe"}, nil).Once()
+			},
+			assertResp: require.NotNil,
+			assertErr:  require.NoError,
+		},
+		{
+			name:       "nickName breaks the nickname policy",
+			req:        &request{id: uuid.NewString(), user: &model.User{NickName: "Adm1n"}},
+			setup:      func(r *repomocks.UserRepository, u *request) {},
+			assertResp: require.Nil,
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var iErr errwrap.IError
+				require.ErrorAs(t, err, &iErr)
+				require.Equal(t, []errwrap.FieldError{{Field: "nickName", Code: errwrap.FieldCodeInvalid, Message: "nickname is reserved"}}, iErr.FieldErrors())
+			},
+		},
 	}
 	for _, tCase := range tests {
 		t.Run(tCase.name, func(tt *testing.T) {
 			//test setup
 			ctx := context.TODO()
 			mockRepo := new(repomocks.UserRepository)
-			svc := NewUserService(mockRepo)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
 			tCase.setup(mockRepo, tCase.req)
 
 			//execution
@@ -131,6 +318,74 @@ func TestUpdateUserById(t *testing.T) {
 	}
 }
 
+func TestUpdateUserByIdNickNameLookAlike(t *testing.T) {
+	//test setup
+	ctx := context.Background()
+	users := repository.NewInMemoryUserRepository()
+	svc := NewUserService(users, repository.NoopTransactor{}, testPasswordPolicy(t), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+	john, err := svc.CreateUser(ctx, &model.User{NickName: "john.doe", Email: "john@email.com", Password: "test_password_123", Status: model.UserStatus_Active})
+	require.NoError(t, err)
+	jane, err := svc.CreateUser(ctx, &model.User{NickName: "jane", Email: "jane@email.com", Password: "test_password_123", Status: model.UserStatus_Active})
+	require.NoError(t, err)
+
+	//execution
+	_, renameErr := svc.UpdateUserById(ctx, jane.Id, model.User{NickName: "J0hn_Doe", Email: "jane@email.com"})
+	_, updateErr := svc.UpdateUserById(ctx, john.Id, model.User{FirstName: "John", NickName: "john.doe", Email: "john@email.com"})
+	_, lookAlikeErr := svc.CreateUser(ctx, &model.User{NickName: "JOHN-D0E", Email: "other@email.com", Password: "test_password_123", Status: model.UserStatus_Active})
+
+	//assertion
+	require.Equal(t, model.ErrUserNicknameTaken, renameErr)
+	require.NoError(t, updateErr)
+	require.Equal(t, model.ErrUserNicknameTaken, lookAlikeErr, "updated user should keep its skeleton")
+}
+
+func TestGetUserById(t *testing.T) {
+	id := uuid.NewString()
+	tests := []struct {
+		name      string
+		setup     func(*repomocks.UserRepository)
+		expected  *model.User
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "repository returns user without password",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(&model.User{Id: id, FirstName: "test", Password: "hash"}, nil).Once()
+			},
+			expected:  &model.User{Id: id, FirstName: "test"},
+			assertErr: require.NoError,
+		},
+		{
+			name: "repository returns error",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(nil, errwrap.ErrNotFound).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, errwrap.ErrNotFound, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			ctx := context.TODO()
+			mockRepo := new(repomocks.UserRepository)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo)
+
+			//execution
+			user, err := svc.GetUserById(ctx, id)
+
+			//assertion
+			tCase.assertErr(tt, err)
+			require.Equal(tt, tCase.expected, user)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+		})
+	}
+}
+
 func TestDeleteUserById(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -162,7 +417,7 @@ func TestDeleteUserById(t *testing.T) {
 			//test setup
 			ctx := context.TODO()
 			mockRepo := new(repomocks.UserRepository)
-			svc := NewUserService(mockRepo)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
 			tCase.setup(mockRepo, tCase.req)
 
 			//execution
@@ -206,7 +461,7 @@ func TestListUsers(t *testing.T) {
 			name: "repository returns success",
 			req:  &request{limit: 10, offset: 0, filter: model.UserFilter{FirstName: "test_firstName"}},
 			setup: func(r *repomocks.UserRepository, req *request) {
-				r.On("ListByFilter", mock.Anything, req.filter.ToBson(), req.limit, req.offset).Return(users, int64(2), nil).Once()
+				r.On("ListByFilter", mock.Anything, req.filter, req.limit, req.offset).Return(users, int64(2), nil).Once()
 			},
 			assertResp: func(t require.TestingT, actual interface{}, _ ...interface{}) {
 				expected := &model.Pagination{
@@ -225,7 +480,7 @@ func TestListUsers(t *testing.T) {
 			name: "hasNext and hasPrevious true",
 			req:  &request{limit: 10, offset: 2, filter: model.UserFilter{FirstName: "test_firstName"}},
 			setup: func(r *repomocks.UserRepository, req *request) {
-				r.On("ListByFilter", mock.Anything, req.filter.ToBson(), req.limit, req.offset).Return(users, int64(100), nil).Once()
+				r.On("ListByFilter", mock.Anything, req.filter, req.limit, req.offset).Return(users, int64(100), nil).Once()
 			},
 			assertResp: func(t require.TestingT, actual interface{}, _ ...interface{}) {
 				expected := &model.Pagination{
@@ -244,7 +499,7 @@ func TestListUsers(t *testing.T) {
 			name: "repository returns error",
 			req:  &request{limit: 10, offset: 0, filter: model.UserFilter{FirstName: "test_firstName"}},
 			setup: func(r *repomocks.UserRepository, req *request) {
-				r.On("ListByFilter", mock.Anything, req.filter.ToBson(), req.limit, req.offset).Return(nil, int64(0), errwrap.ErrInternal.SetMessage("test list error")).Once()
+				r.On("ListByFilter", mock.Anything, req.filter, req.limit, req.offset).Return(nil, int64(0), errwrap.ErrInternal.SetMessage("test list error")).Once()
 			},
 			assertResp: require.Nil,
 			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
@@ -257,7 +512,7 @@ func TestListUsers(t *testing.T) {
 			//test setup
 			ctx := context.TODO()
 			mockRepo := new(repomocks.UserRepository)
-			svc := NewUserService(mockRepo)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
 			tCase.setup(mockRepo, tCase.req)
 
 			//execution
@@ -272,3 +527,202 @@ func TestListUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyPassword(t *testing.T) {
+	id := uuid.NewString()
+	currentHash, err := testPasswordHashing.Hash("Correct-Horse7")
+	require.NoError(t, err)
+	argon2idHash, err := (&crypt.Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("Correct-Horse7")
+	require.NoError(t, err)
+	isCurrentHash := mock.MatchedBy(func(hash string) bool {
+		match, needsRehash, err := testPasswordHashing.Verify("Correct-Horse7", hash)
+		return err == nil && match && !needsRehash
+	})
+
+	tests := []struct {
+		name      string
+		password  string
+		setup     func(*repomocks.UserRepository)
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name:     "password matches",
+			password: "Correct-Horse7",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(&model.User{Id: id, Password: currentHash}, nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name:     "outdated hash is replaced",
+			password: "Correct-Horse7",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(&model.User{Id: id, Password: argon2idHash}, nil).Once()
+				r.On("ReplacePasswordHash", mock.Anything, id, argon2idHash, isCurrentHash).Return(nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name:     "failure of replacing outdated hash is ignored",
+			password: "Correct-Horse7",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(&model.User{Id: id, Password: argon2idHash}, nil).Once()
+				r.On("ReplacePasswordHash", mock.Anything, id, argon2idHash, isCurrentHash).Return(errwrap.ErrInternal).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name:     "password doesn't match",
+			password: "Correct-Horse8",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(&model.User{Id: id, Password: argon2idHash}, nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserInvalidPassword, err)
+			},
+		},
+		{
+			name:     "user has no password",
+			password: "",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(&model.User{Id: id}, nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserInvalidPassword, err)
+			},
+		},
+		{
+			name:     "user not found",
+			password: "Correct-Horse7",
+			setup: func(r *repomocks.UserRepository) {
+				r.On("Get", mock.Anything, id).Return(nil, model.ErrUserNotFound).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserNotFound, err)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, new(servicemocks.SessionRevoker), new(repomocks.UserTokenRepository), new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo)
+
+			//execution
+			err := svc.VerifyPassword(context.TODO(), id, tCase.password)
+
+			//assertion
+			tCase.assertErr(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	id := uuid.NewString()
+	currentHash, err := testPasswordHashing.Hash("Correct-Horse7")
+	require.NoError(t, err)
+	isNewHash := mock.MatchedBy(func(hash string) bool {
+		match, _, err := testPasswordHashing.Verify("Battery-Staple9", hash)
+		return err == nil && match
+	})
+	user := func() *model.User {
+		return &model.User{Id: id, FirstName: "John", NickName: "johndoe", Email: "john@email.com", Password: currentHash}
+	}
+
+	tests := []struct {
+		name        string
+		current     string
+		newPassword string
+		setup       func(*repomocks.UserRepository, *repomocks.UserTokenRepository, *servicemocks.SessionRevoker)
+		assertErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:        "password is changed and sessions are revoked",
+			current:     "Correct-Horse7",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				r.On("Get", mock.Anything, id).Return(user(), nil).Once()
+				r.On("SetPassword", mock.Anything, id, isNewHash).Return(nil).Once()
+				tr.On("DeleteByUser", mock.Anything, id, model.UserTokenPurpose_PasswordReset).Return(nil).Once()
+				tr.On("DeleteByUser", mock.Anything, id, model.UserTokenPurpose_MfaChallenge).Return(nil).Once()
+				s.On("RevokeUserSessions", mock.Anything, id).Return(nil).Once()
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name:        "current password doesn't match",
+			current:     "Correct-Horse8",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				r.On("Get", mock.Anything, id).Return(user(), nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserInvalidPassword, err)
+			},
+		},
+		{
+			name:        "new password breaks the password policy",
+			current:     "Correct-Horse7",
+			newPassword: "Johndoe-2024",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				r.On("Get", mock.Anything, id).Return(user(), nil).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var iErr errwrap.IError
+				require.ErrorAs(t, err, &iErr)
+				require.Equal(t, []errwrap.FieldError{{Field: "password", Code: password.CodePersonalInfo, Message: "password can't contain your name, nickname or email"}}, iErr.FieldErrors())
+			},
+		},
+		{
+			name:        "user not found",
+			current:     "Correct-Horse7",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				r.On("Get", mock.Anything, id).Return(nil, model.ErrUserNotFound).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.Equal(t, model.ErrUserNotFound, err)
+			},
+		},
+		{
+			name:        "sessions can't be revoked",
+			current:     "Correct-Horse7",
+			newPassword: "Battery-Staple9",
+			setup: func(r *repomocks.UserRepository, tr *repomocks.UserTokenRepository, s *servicemocks.SessionRevoker) {
+				r.On("Get", mock.Anything, id).Return(user(), nil).Once()
+				r.On("SetPassword", mock.Anything, id, isNewHash).Return(nil).Once()
+				tr.On("DeleteByUser", mock.Anything, id, model.UserTokenPurpose_PasswordReset).Return(nil).Once()
+				tr.On("DeleteByUser", mock.Anything, id, model.UserTokenPurpose_MfaChallenge).Return(nil).Once()
+				s.On("RevokeUserSessions", mock.Anything, id).Return(errors.New("session store is down")).Once()
+			},
+			assertErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorIs(t, err, errwrap.ErrInternal)
+			},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			mockRepo := new(repomocks.UserRepository)
+			mockTokens := new(repomocks.UserTokenRepository)
+			mockSessions := new(servicemocks.SessionRevoker)
+			svc := NewUserService(mockRepo, repository.NoopTransactor{}, testPasswordPolicy(tt), testPasswordHashing, mockSessions, mockTokens, new(servicemocks.Notifier), new(repomocks.UserMfaRepository), nil)
+			tCase.setup(mockRepo, mockTokens, mockSessions)
+
+			//execution
+			err := svc.ChangePassword(context.TODO(), id, tCase.current, tCase.newPassword)
+
+			//assertion
+			tCase.assertErr(tt, err)
+
+			//assert mocking calls
+			assert.True(tt, mockRepo.AssertExpectations(tt))
+			assert.True(tt, mockTokens.AssertExpectations(tt))
+			assert.True(tt, mockSessions.AssertExpectations(tt))
+		})
+	}
+}
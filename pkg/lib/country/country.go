@@ -0,0 +1,91 @@
+// Package country has ISO 3166-1 countries and normalizes country codes.
+package country
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"strings"
+)
+
+// Country is an ISO 3166-1 country
+type Country struct {
+	// Code is the alpha-2 code, e.g. TR
+	Code string `json:"code"`
+	// Alpha3 is the alpha-3 code, e.g. TUR
+	Alpha3 string `json:"alpha3"`
+	// Numeric is the numeric code with leading zeros, e.g. 792
+	Numeric string `json:"numeric"`
+	// Name is the English short name
+	Name string `json:"name"`
+}
+
+//go:embed countries.csv
+var countriesCSV string
+
+// countries are officially assigned ISO 3166-1 countries ordered by alpha-2 code
+var countries = func() []Country {
+	records, err := csv.NewReader(strings.NewReader(countriesCSV)).ReadAll()
+	if err != nil {
+		panic("country: invalid countries.csv: " + err.Error())
+	}
+	list := make([]Country, 0, len(records)-1)
+	for _, r := range records[1:] {
+		list = append(list, Country{Code: r[0], Alpha3: r[1], Numeric: r[2], Name: r[3]})
+	}
+	return list
+}()
+
+// byCode are countries by alpha-2 code
+var byCode = func() map[string]Country {
+	m := make(map[string]Country, len(countries))
+	for _, c := range countries {
+		m[c.Code] = c
+	}
+	return m
+}()
+
+// aliases maps codes which are commonly used instead of alpha-2 codes to alpha-2 codes. Alpha-3 codes are aliases too.
+var aliases = func() map[string]string {
+	m := map[string]string{
+		"UK": "GB", // exceptionally reserved for United Kingdom
+		"EL": "GR", // used by the European Union for Greece
+		"FX": "FR", // exceptionally reserved for Metropolitan France
+		"TP": "TL", // transitionally reserved, former code of Timor-Leste
+		"ZR": "CD", // transitionally reserved, former code of Zaire
+		"BU": "MM", // transitionally reserved, former code of Burma
+	}
+	for _, c := range countries {
+		m[c.Alpha3] = c.Code
+	}
+	return m
+}()
+
+// All returns all countries ordered by alpha-2 code
+func All() []Country {
+	return append([]Country(nil), countries...)
+}
+
+// Lookup returns the country of the alpha-2 code
+func Lookup(code string) (Country, bool) {
+	c, ok := byCode[code]
+	return c, ok
+}
+
+// IsValid reports whether the code is an alpha-2 code. Aliases and lowercase codes are not valid, see Normalize.
+func IsValid(code string) bool {
+	_, ok := byCode[code]
+	return ok
+}
+
+// Normalize returns the alpha-2 code of the case insensitive alpha-2 code, alpha-3 code or alias, e.g. `uk` and `GBR`
+// are `GB`. Returns false when the value isn't a country.
+func Normalize(value string) (string, bool) {
+	code := strings.ToUpper(strings.TrimSpace(value))
+	if IsValid(code) {
+		return code, true
+	}
+	if alias, ok := aliases[code]; ok {
+		return alias, true
+	}
+	return "", false
+}
@@ -0,0 +1,54 @@
+package country
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"TR", "TR", true},
+		{" tr ", "TR", true},
+		{"TUR", "TR", true},
+		{"gbr", "GB", true},
+		{"UK", "GB", true},
+		{"EL", "GR", true},
+		{"XX", "", false},
+		{"Turkey", "", false},
+		{"792", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			code, ok := Normalize(tt.value)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, code)
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	countries := All()
+	require.Len(t, countries, 249)
+	for i, c := range countries {
+		require.Len(t, c.Code, 2)
+		require.Len(t, c.Alpha3, 3)
+		require.Len(t, c.Numeric, 3)
+		require.NotEmpty(t, c.Name)
+		if i > 0 {
+			require.Less(t, countries[i-1].Code, c.Code, "countries should be ordered by code")
+		}
+	}
+
+	countries[0].Name = "changed"
+	tr, ok := Lookup("TR")
+	require.True(t, ok)
+	require.Equal(t, Country{Code: "TR", Alpha3: "TUR", Numeric: "792", Name: "Türkiye"}, tr)
+	require.Equal(t, "Andorra", All()[0].Name, "All should return a copy")
+	require.False(t, IsValid("UK"))
+}
@@ -0,0 +1,116 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// DefaultArgon2idHasher has the parameters recommended by RFC 9106 for memory constrained environments
+var DefaultArgon2idHasher = Argon2idHasher{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idHasher is a PasswordHasher with argon2id. Hashes are in the PHC string format with base64 encoded salt and
+// key without padding, e.g. `$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>`.
+type Argon2idHasher struct {
+	// Memory is the memory in KiB
+	Memory uint32
+	// Iterations is the number of passes over the memory
+	Iterations uint32
+	// Parallelism is the number of threads
+	Parallelism uint8
+	// SaltLength is the length of random salts in bytes
+	SaltLength uint32
+	// KeyLength is the length of hashes in bytes
+	KeyLength uint32
+}
+
+// argon2idPrefix is the prefix of argon2id hashes in the PHC string format
+const argon2idPrefix = "$argon2id$"
+
+// Hash returns the argon2id hash of the password
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return h.encode(salt, key), nil
+}
+
+// Verify reports whether the password matches the argon2id hash with parameters of the hash
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// Supports reports whether the encoded hash is an argon2id hash
+func (h *Argon2idHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// NeedsRehash reports whether the argon2id hash has other parameters, salt length or key length
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	return err != nil || params != *h
+}
+
+// encode returns the hash in the PHC string format
+func (h *Argon2idHasher) encode(salt, key []byte) string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.Memory, h.Iterations,
+		h.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// validate returns error when parameters are out of ranges of argon2id
+func (h *Argon2idHasher) validate() error {
+	if h.Iterations < 1 || h.Parallelism < 1 {
+		return fmt.Errorf("argon2id iterations and parallelism should be at least 1")
+	}
+	if h.Memory < 8*uint32(h.Parallelism) {
+		return fmt.Errorf("argon2id memory should be at least 8 KiB per thread")
+	}
+	if h.SaltLength < 8 || h.KeyLength < 16 {
+		return fmt.Errorf("argon2id salt should be at least 8 bytes and key at least 16 bytes")
+	}
+	return nil
+}
+
+// decodeArgon2id returns parameters, salt and key of the argon2id hash in the PHC string format
+func decodeArgon2id(encoded string) (params Argon2idHasher, salt []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: unsupported argon2 version", ErrInvalidHash)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	if err := params.validate(); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return params, salt, key, nil
+}
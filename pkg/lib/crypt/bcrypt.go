@@ -0,0 +1,60 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher is a PasswordHasher with bcrypt. Hashes are in the modular crypt format, e.g. `$2a$10$...`.
+//
+// NOTE: bcrypt hashes only the first 72 bytes so longer passwords are rejected with ErrPasswordTooLong.
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns the bcrypt hash of the password
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return "", ErrPasswordTooLong
+		}
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Verify reports whether the password matches the bcrypt hash
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword), errors.Is(err, bcrypt.ErrPasswordTooLong):
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+}
+
+// Supports reports whether the encoded hash is a bcrypt hash
+func (h *BcryptHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// NeedsRehash reports whether the bcrypt hash has another cost
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// validate returns error when the cost is out of the range of bcrypt
+func (h *BcryptHasher) validate() error {
+	if h.Cost < bcrypt.MinCost || h.Cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost should be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefixes of encrypted values. Values without them are treated as plaintext.
+//
+// Values of v2 are bound to their associated data, v1 values are written before associated data and they are still
+// decrypted so that they can be re-encrypted.
+const (
+	envelopePrefix   = "enc:v2:"
+	envelopePrefixV1 = "enc:v1:"
+)
+
+var (
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// EnvelopeEncryptor encrypts values with envelope encryption.
+//
+// Every value is encrypted with a random data key(AES-256-GCM) and the data key is encrypted with the current key
+// of KeyProvider. Encrypted value format is `enc:v2:<keyId>:<encrypted data key>:<encrypted value>`.
+type EnvelopeEncryptor struct {
+	keys KeyProvider
+}
+
+// NewEnvelopeEncryptor returns an encryptor with keys of the given provider
+func NewEnvelopeEncryptor(keys KeyProvider) *EnvelopeEncryptor {
+	return &EnvelopeEncryptor{keys}
+}
+
+// Encrypt encrypts the plaintext with a new data key wrapped by the current key
+func (e *EnvelopeEncryptor) Encrypt(plaintext string) (string, error) {
+	return e.EncryptWith(plaintext, "")
+}
+
+// EncryptWith encrypts the plaintext as Encrypt and binds it to the associated data, e.g. the field and id of the
+// record it is stored in. Associated data isn't stored, the value can only be decrypted with the same associated
+// data so that it can't be moved to another field or record without being detected.
+func (e *EnvelopeEncryptor) EncryptWith(plaintext, associatedData string) (string, error) {
+	keyId := e.keys.CurrentKeyId()
+	kek, err := e.keys.Key(keyId)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(kek, dataKey, []byte(keyId))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), valueAdditionalData(wrappedKey, associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	return envelopePrefix + keyId + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt. Values which are not encrypted are returned as they are
+// so that data written before enabling encryption can still be read.
+func (e *EnvelopeEncryptor) Decrypt(value string) (string, error) {
+	return e.DecryptWith(value, "")
+}
+
+// DecryptWith decrypts a value encrypted by EncryptWith with the same associated data. Values which are not
+// encrypted are returned as they are and v1 values, which aren't bound to associated data, are decrypted without it.
+func (e *EnvelopeEncryptor) DecryptWith(value, associatedData string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	v1 := strings.HasPrefix(value, envelopePrefixV1)
+	prefix := envelopePrefix
+	if v1 {
+		prefix = envelopePrefixV1
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrInvalidCiphertext
+	}
+	keyId := parts[0]
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	kek, err := e.keys.Key(keyId)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(kek, wrappedKey, []byte(keyId))
+	if err != nil {
+		return "", err
+	}
+	additionalData := wrappedKey
+	if !v1 {
+		additionalData = valueAdditionalData(wrappedKey, associatedData)
+	}
+	plaintext, err := open(dataKey, ciphertext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// valueAdditionalData returns the AES-GCM additional data of the encrypted value which binds it to its wrapped data
+// key and its associated data
+func valueAdditionalData(wrappedKey []byte, associatedData string) []byte {
+	data := append([]byte(nil), wrappedKey...)
+	data = append(data, 0)
+	return append(data, associatedData...)
+}
+
+// NeedsReencryption reports whether the value is plaintext, a v1 value or encrypted with a key other than the current
+// key
+func (e *EnvelopeEncryptor) NeedsReencryption(value string) bool {
+	return !strings.HasPrefix(value, envelopePrefix+e.keys.CurrentKeyId()+":")
+}
+
+// BlindIndex returns a deterministic keyed hash(HMAC-SHA256) of the value of the field.
+// It is used for exact match lookups and unique indexes on encrypted values.
+//
+// Every field has its own HMAC key derived from the blind index key so that the same value has different blind
+// indexes in different fields.
+func (e *EnvelopeEncryptor) BlindIndex(field, value string) string {
+	fieldKey := hmac.New(sha256.New, e.keys.BlindIndexKey())
+	fieldKey.Write([]byte("blind-index:" + field))
+	mac := hmac.New(sha256.New, fieldKey.Sum(nil))
+	mac.Write([]byte(value))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether the value is encrypted by EnvelopeEncryptor
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix) || strings.HasPrefix(value, envelopePrefixV1)
+}
+
+// seal encrypts plaintext with AES-GCM and returns nonce and ciphertext together
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts output of seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
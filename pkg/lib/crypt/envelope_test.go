@@ -0,0 +1,171 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// staticKeys is a KeyProvider for tests
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (s staticKeys) CurrentKeyId() string { return s.current }
+func (s staticKeys) Key(id string) ([]byte, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+func (s staticKeys) BlindIndexKey() []byte { return bytes.Repeat([]byte{9}, KeySize) }
+
+func TestEnvelopeEncryptor(t *testing.T) {
+	k1, k2 := bytes.Repeat([]byte{1}, KeySize), bytes.Repeat([]byte{2}, KeySize)
+	oldEncryptor := NewEnvelopeEncryptor(staticKeys{"k1", map[string][]byte{"k1": k1}})
+	encryptor := NewEnvelopeEncryptor(staticKeys{"k2", map[string][]byte{"k1": k1, "k2": k2}})
+
+	t.Run("encrypts and decrypts", func(tt *testing.T) {
+		encrypted, err := encryptor.Encrypt("john@email.com")
+		require.NoError(tt, err)
+		require.True(tt, IsEncrypted(encrypted))
+		require.NotContains(tt, encrypted, "john")
+
+		again, err := encryptor.Encrypt("john@email.com")
+		require.NoError(tt, err)
+		require.NotEqual(tt, encrypted, again, "encryption should be randomized")
+
+		decrypted, err := encryptor.Decrypt(encrypted)
+		require.NoError(tt, err)
+		require.Equal(tt, "john@email.com", decrypted)
+		require.False(tt, encryptor.NeedsReencryption(encrypted))
+	})
+
+	t.Run("returns plaintext as it is", func(tt *testing.T) {
+		decrypted, err := encryptor.Decrypt("john@email.com")
+		require.NoError(tt, err)
+		require.Equal(tt, "john@email.com", decrypted)
+		require.True(tt, encryptor.NeedsReencryption("john@email.com"))
+	})
+
+	t.Run("decrypts values of rotated keys", func(tt *testing.T) {
+		encrypted, err := oldEncryptor.Encrypt("john")
+		require.NoError(tt, err)
+		require.True(tt, encryptor.NeedsReencryption(encrypted))
+
+		decrypted, err := encryptor.Decrypt(encrypted)
+		require.NoError(tt, err)
+		require.Equal(tt, "john", decrypted)
+	})
+
+	t.Run("fails for unknown key", func(tt *testing.T) {
+		encrypted, err := encryptor.Encrypt("john")
+		require.NoError(tt, err)
+
+		_, err = oldEncryptor.Decrypt(encrypted)
+		require.ErrorIs(tt, err, ErrKeyNotFound)
+	})
+
+	t.Run("fails for tampered value", func(tt *testing.T) {
+		encrypted, err := encryptor.Encrypt("john")
+		require.NoError(tt, err)
+		tampered := encrypted[:len(encrypted)-2] + "AA"
+		if tampered == encrypted {
+			tampered = encrypted[:len(encrypted)-2] + "BB"
+		}
+
+		_, err = encryptor.Decrypt(tampered)
+		require.ErrorIs(tt, err, ErrInvalidCiphertext)
+	})
+
+	t.Run("binds values to associated data", func(tt *testing.T) {
+		encrypted, err := encryptor.EncryptWith("john", "users/1/firstName")
+		require.NoError(tt, err)
+
+		decrypted, err := encryptor.DecryptWith(encrypted, "users/1/firstName")
+		require.NoError(tt, err)
+		require.Equal(tt, "john", decrypted)
+		for _, other := range []string{"users/1/lastName", "users/2/firstName", ""} {
+			_, err = encryptor.DecryptWith(encrypted, other)
+			require.ErrorIs(tt, err, ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("decrypts v1 values without associated data", func(tt *testing.T) {
+		dataKey := bytes.Repeat([]byte{3}, KeySize)
+		wrappedKey, err := seal(k1, dataKey, []byte("k1"))
+		require.NoError(tt, err)
+		ciphertext, err := seal(dataKey, []byte("john"), wrappedKey)
+		require.NoError(tt, err)
+		v1 := "enc:v1:k1:" + base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" + base64.RawStdEncoding.EncodeToString(ciphertext)
+
+		decrypted, err := encryptor.DecryptWith(v1, "users/1/firstName")
+		require.NoError(tt, err)
+		require.Equal(tt, "john", decrypted)
+		require.True(tt, IsEncrypted(v1))
+		require.True(tt, encryptor.NeedsReencryption(v1))
+	})
+
+	t.Run("blind index is deterministic", func(tt *testing.T) {
+		require.Equal(tt, encryptor.BlindIndex("email", "john"), oldEncryptor.BlindIndex("email", "john"))
+		require.NotEqual(tt, encryptor.BlindIndex("email", "john"), encryptor.BlindIndex("email", "John"))
+		require.NotEqual(tt, encryptor.BlindIndex("email", "john"), encryptor.BlindIndex("nickName", "john"), "fields should have their own keys")
+	})
+}
+
+func TestNewFileKeyProvider(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))
+	tests := []struct {
+		name      string
+		file      keyFile
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name:      "valid file",
+			file:      keyFile{CurrentKeyId: "k1", Keys: map[string]string{"k1": key}, BlindIndexKey: key},
+			assertErr: require.NoError,
+		},
+		{
+			name:      "current key is missing",
+			file:      keyFile{CurrentKeyId: "k2", Keys: map[string]string{"k1": key}, BlindIndexKey: key},
+			assertErr: require.Error,
+		},
+		{
+			name:      "key is too short",
+			file:      keyFile{CurrentKeyId: "k1", Keys: map[string]string{"k1": "c2hvcnQ="}, BlindIndexKey: key},
+			assertErr: require.Error,
+		},
+		{
+			name:      "key id has separator",
+			file:      keyFile{CurrentKeyId: "k:1", Keys: map[string]string{"k:1": key}, BlindIndexKey: key},
+			assertErr: require.Error,
+		},
+		{
+			name:      "blind index key is missing",
+			file:      keyFile{CurrentKeyId: "k1", Keys: map[string]string{"k1": key}},
+			assertErr: require.Error,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			content, err := json.Marshal(tCase.file)
+			require.NoError(tt, err)
+			path := filepath.Join(tt.TempDir(), "keys.json")
+			require.NoError(tt, os.WriteFile(path, content, 0600))
+
+			provider, err := NewFileKeyProvider(path)
+
+			tCase.assertErr(tt, err)
+			if err == nil {
+				require.Equal(tt, tCase.file.CurrentKeyId, provider.CurrentKeyId())
+			}
+		})
+	}
+}
@@ -1,12 +0,0 @@
-package crypt
-
-import "golang.org/x/crypto/bcrypt"
-
-// HashPassword generates a bcrypt hash of the password
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedPassword), nil
-}
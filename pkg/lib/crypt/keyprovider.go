@@ -0,0 +1,117 @@
+package crypt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of AES-256 keys in bytes
+const KeySize = 32
+
+var (
+	ErrKeyNotFound = errors.New("encryption key not found")
+)
+
+// KeyProvider provides key encryption keys(KEK) by id and the key of blind indexes.
+//
+// Old keys should be kept in the provider after rotation to be able to decrypt existing data.
+type KeyProvider interface {
+	// CurrentKeyId returns id of the key which is used for new encryptions
+	CurrentKeyId() string
+	// Key returns the key with the given id
+	Key(id string) ([]byte, error)
+	// BlindIndexKey returns the HMAC key of blind indexes. Changing it needs all blind indexes to be recomputed.
+	BlindIndexKey() []byte
+}
+
+// keyFile is the format of local key file. Keys are base64 encoded 32 bytes.
+//
+//	{
+//	  "currentKeyId": "2024-12",
+//	  "keys": {"2024-11": "...", "2024-12": "..."},
+//	  "blindIndexKey": "..."
+//	}
+type keyFile struct {
+	CurrentKeyId  string            `json:"currentKeyId"`
+	Keys          map[string]string `json:"keys"`
+	BlindIndexKey string            `json:"blindIndexKey"`
+}
+
+// fileKeyProvider is a KeyProvider reading keys from a local json file. It is meant for development.
+type fileKeyProvider struct {
+	currentKeyId  string
+	keys          map[string][]byte
+	blindIndexKey []byte
+}
+
+// NewFileKeyProvider reads keys from the json file in the given path.
+//
+// Returns error when file is not valid, current key doesn't exist or a key is not 32 bytes.
+func NewFileKeyProvider(path string) (KeyProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	provider := &fileKeyProvider{
+		currentKeyId: file.CurrentKeyId,
+		keys:         make(map[string][]byte, len(file.Keys)),
+	}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		provider.keys[id] = key
+	}
+	if _, ok := provider.keys[file.CurrentKeyId]; !ok {
+		return nil, fmt.Errorf("current key %q doesn't exist in keys", file.CurrentKeyId)
+	}
+
+	provider.blindIndexKey, err = decodeKey(file.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blind index key: %w", err)
+	}
+
+	return provider, nil
+}
+
+func (p *fileKeyProvider) CurrentKeyId() string {
+	return p.currentKeyId
+}
+
+func (p *fileKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+func (p *fileKeyProvider) BlindIndexKey() []byte {
+	return p.blindIndexKey
+}
+
+// decodeKey decodes a base64 encoded 32 bytes key
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key should be %d bytes", KeySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,110 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms of password hashes
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrPasswordTooLong = errors.New("password is too long")
+	ErrInvalidHash     = errors.New("invalid password hash")
+	ErrUnsupportedHash = errors.New("unsupported password hash")
+)
+
+// PasswordHasher hashes passwords with an algorithm and its parameters
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password with a random salt
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the encoded hash
+	Verify(password, encoded string) (bool, error)
+	// Supports reports whether the encoded hash is of the algorithm of the hasher
+	Supports(encoded string) bool
+	// NeedsRehash reports whether the encoded hash has other parameters than the hasher
+	NeedsRehash(encoded string) bool
+}
+
+// PasswordHashing hashes new passwords with the current hasher and verifies hashes of all hashers so that the
+// algorithm or its parameters can be changed without resetting existing passwords.
+type PasswordHashing struct {
+	current PasswordHasher
+	hashers []PasswordHasher
+}
+
+// NewPasswordHashing returns PasswordHashing of the algorithm of `PASSWORD_HASH_ALGORITHM`(default `argon2id`) which
+// verifies both argon2id and bcrypt hashes.
+//
+// Parameters are read from env variables:
+// `PASSWORD_ARGON2_MEMORY`(KiB, default `65536`), `PASSWORD_ARGON2_ITERATIONS`(default `3`),
+// `PASSWORD_ARGON2_PARALLELISM`(default `2`) and `PASSWORD_BCRYPT_COST`(default `10`).
+func NewPasswordHashing() (*PasswordHashing, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("PASSWORD_HASH_ALGORITHM", AlgorithmArgon2id)
+	vi.SetDefault("PASSWORD_ARGON2_MEMORY", DefaultArgon2idHasher.Memory)
+	vi.SetDefault("PASSWORD_ARGON2_ITERATIONS", DefaultArgon2idHasher.Iterations)
+	vi.SetDefault("PASSWORD_ARGON2_PARALLELISM", DefaultArgon2idHasher.Parallelism)
+	vi.SetDefault("PASSWORD_BCRYPT_COST", bcrypt.DefaultCost)
+
+	argon2id := &Argon2idHasher{
+		Memory:      vi.GetUint32("PASSWORD_ARGON2_MEMORY"),
+		Iterations:  vi.GetUint32("PASSWORD_ARGON2_ITERATIONS"),
+		Parallelism: uint8(vi.GetUint("PASSWORD_ARGON2_PARALLELISM")),
+		SaltLength:  DefaultArgon2idHasher.SaltLength,
+		KeyLength:   DefaultArgon2idHasher.KeyLength,
+	}
+	if err := argon2id.validate(); err != nil {
+		return nil, err
+	}
+	bcryptHasher := &BcryptHasher{Cost: vi.GetInt("PASSWORD_BCRYPT_COST")}
+	if err := bcryptHasher.validate(); err != nil {
+		return nil, err
+	}
+
+	switch algorithm := vi.GetString("PASSWORD_HASH_ALGORITHM"); algorithm {
+	case AlgorithmArgon2id:
+		return NewPasswordHashingWith(argon2id, bcryptHasher), nil
+	case AlgorithmBcrypt:
+		return NewPasswordHashingWith(bcryptHasher, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+}
+
+// NewPasswordHashingWith returns PasswordHashing which hashes with the current hasher and verifies hashes of the
+// current and the other hashers
+func NewPasswordHashingWith(current PasswordHasher, others ...PasswordHasher) *PasswordHashing {
+	return &PasswordHashing{current, append([]PasswordHasher{current}, others...)}
+}
+
+// Hash returns the encoded hash of the password by the current hasher
+func (h *PasswordHashing) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify reports whether the password matches the encoded hash. When it matches, needsRehash reports whether the
+// hash should be replaced with a new hash of the password since it has another algorithm or parameters than the
+// current hasher.
+//
+// Returns ErrUnsupportedHash when no hasher supports the encoded hash.
+func (h *PasswordHashing) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	for _, hasher := range h.hashers {
+		if !hasher.Supports(encoded) {
+			continue
+		}
+		match, err := hasher.Verify(password, encoded)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, hasher != h.current || hasher.NeedsRehash(encoded), nil
+	}
+	return false, false, ErrUnsupportedHash
+}
@@ -0,0 +1,152 @@
+package crypt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idHasher has cheap parameters to keep tests fast
+var testArgon2idHasher = &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		AlgorithmArgon2id: testArgon2idHasher,
+		AlgorithmBcrypt:   &BcryptHasher{Cost: bcrypt.MinCost},
+	}
+	for name, hasher := range hashers {
+		t.Run(name, func(tt *testing.T) {
+			encoded, err := hasher.Hash("Correct-Horse7")
+			require.NoError(tt, err)
+			require.True(tt, hasher.Supports(encoded))
+			require.False(tt, hasher.NeedsRehash(encoded))
+
+			again, err := hasher.Hash("Correct-Horse7")
+			require.NoError(tt, err)
+			require.NotEqual(tt, encoded, again, "hashes should be salted")
+
+			match, err := hasher.Verify("Correct-Horse7", encoded)
+			require.NoError(tt, err)
+			require.True(tt, match)
+
+			match, err = hasher.Verify("Correct-Horse8", encoded)
+			require.NoError(tt, err)
+			require.False(tt, match)
+		})
+	}
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	t.Run("PHC string format", func(tt *testing.T) {
+		encoded, err := testArgon2idHasher.Hash("Correct-Horse7")
+		require.NoError(tt, err)
+		require.Regexp(tt, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, encoded)
+	})
+
+	t.Run("verifies with parameters of the hash", func(tt *testing.T) {
+		encoded, err := testArgon2idHasher.Hash("Correct-Horse7")
+		require.NoError(tt, err)
+
+		stronger := &Argon2idHasher{Memory: 128, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+		match, err := stronger.Verify("Correct-Horse7", encoded)
+		require.NoError(tt, err)
+		require.True(tt, match)
+		require.True(tt, stronger.NeedsRehash(encoded))
+	})
+
+	t.Run("rejects invalid hashes", func(tt *testing.T) {
+		invalid := []string{
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+			"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5a2V5a2V5",
+			"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5a2V5a2V5",
+			"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5a2V5a2V5a2V5a2V5",
+		}
+		for _, encoded := range invalid {
+			_, err := testArgon2idHasher.Verify("Correct-Horse7", encoded)
+			require.ErrorIs(tt, err, ErrInvalidHash, encoded)
+			require.True(tt, testArgon2idHasher.NeedsRehash(encoded))
+		}
+	})
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := &BcryptHasher{Cost: bcrypt.MinCost}
+
+	_, err := hasher.Hash(strings.Repeat("a", 73))
+	require.ErrorIs(t, err, ErrPasswordTooLong)
+
+	encoded, err := (&BcryptHasher{Cost: bcrypt.MinCost + 1}).Hash("Correct-Horse7")
+	require.NoError(t, err)
+	require.True(t, hasher.NeedsRehash(encoded))
+}
+
+func TestPasswordHashing(t *testing.T) {
+	bcryptHasher := &BcryptHasher{Cost: bcrypt.MinCost}
+	hashing := NewPasswordHashingWith(testArgon2idHasher, bcryptHasher)
+	bcryptHash, err := bcryptHasher.Hash("Correct-Horse7")
+	require.NoError(t, err)
+	outdatedHash, err := (&Argon2idHasher{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("Correct-Horse7")
+	require.NoError(t, err)
+	currentHash, err := hashing.Hash("Correct-Horse7")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		password    string
+		encoded     string
+		match       bool
+		needsRehash bool
+		err         error
+	}{
+		{"current hash", "Correct-Horse7", currentHash, true, false, nil},
+		{"hash of another algorithm", "Correct-Horse7", bcryptHash, true, true, nil},
+		{"hash with outdated parameters", "Correct-Horse7", outdatedHash, true, true, nil},
+		{"wrong password", "Correct-Horse8", bcryptHash, false, false, nil},
+		{"unsupported hash", "Correct-Horse7", "$1$salt$hash", false, false, ErrUnsupportedHash},
+		{"empty hash", "", "", false, false, ErrUnsupportedHash},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			match, needsRehash, err := hashing.Verify(tCase.password, tCase.encoded)
+			require.ErrorIs(tt, err, tCase.err)
+			require.Equal(tt, tCase.match, match)
+			require.Equal(tt, tCase.needsRehash, needsRehash)
+		})
+	}
+}
+
+func TestNewPasswordHashing(t *testing.T) {
+	t.Run("argon2id by default", func(tt *testing.T) {
+		hashing, err := NewPasswordHashing()
+		require.NoError(tt, err)
+		require.Equal(tt, &DefaultArgon2idHasher, hashing.current)
+	})
+
+	t.Run("bcrypt with cost", func(tt *testing.T) {
+		tt.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+		tt.Setenv("PASSWORD_BCRYPT_COST", "12")
+
+		hashing, err := NewPasswordHashing()
+		require.NoError(tt, err)
+		require.Equal(tt, &BcryptHasher{Cost: 12}, hashing.current)
+	})
+
+	invalid := map[string]map[string]string{
+		"unknown algorithm":  {"PASSWORD_HASH_ALGORITHM": "md5"},
+		"bcrypt cost":        {"PASSWORD_BCRYPT_COST": "40"},
+		"argon2 iterations":  {"PASSWORD_ARGON2_ITERATIONS": "0"},
+		"argon2 memory":      {"PASSWORD_ARGON2_MEMORY": "8", "PASSWORD_ARGON2_PARALLELISM": "2"},
+		"argon2 parallelism": {"PASSWORD_ARGON2_PARALLELISM": "0"},
+	}
+	for name, envs := range invalid {
+		t.Run("invalid "+name, func(tt *testing.T) {
+			for key, value := range envs {
+				tt.Setenv(key, value)
+			}
+			_, err := NewPasswordHashing()
+			require.Error(tt, err)
+		})
+	}
+}
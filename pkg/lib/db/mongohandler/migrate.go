@@ -0,0 +1,256 @@
+package mongohandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MigrationsCollection keeps applied migrations by version
+	MigrationsCollection = "schema_migrations"
+	// MigrationsLockCollection keeps the lock document to prevent concurrent migrations from different replicas
+	MigrationsLockCollection = "schema_migrations_lock"
+
+	migrationsLockId = "lock"
+)
+
+var (
+	// ErrMigrationLocked is returned when the lock couldn't be acquired before context is done
+	ErrMigrationLocked = errors.New("migrations are locked by another process")
+)
+
+// Migration is a versioned schema or data change. Up should be idempotent as it may be retried after a failure.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationStatus is the state of a migration in the database
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// appliedMigration is the document stored in schema_migrations collection
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// Migrator applies migrations in order of their versions
+type Migrator struct {
+	db                *mongo.Database
+	migrations        []Migration
+	owner             string
+	LockTTL           time.Duration // lock is refreshed while migrating and expires after LockTTL if the owner dies
+	LockRetryInterval time.Duration
+}
+
+// NewMigrator returns a migrator for the given migrations.
+//
+// Returns error when versions are not positive or not unique.
+func NewMigrator(db *mongo.Database, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, mig := range sorted {
+		if mig.Version <= 0 {
+			return nil, fmt.Errorf("migration version should be positive: %d", mig.Version)
+		}
+		if i > 0 && sorted[i-1].Version == mig.Version {
+			return nil, fmt.Errorf("duplicated migration version: %d", mig.Version)
+		}
+		if mig.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up function", mig.Version)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:                db,
+		migrations:        sorted,
+		owner:             fmt.Sprintf("%s/%s", hostname, uuid.NewString()),
+		LockTTL:           time.Minute,
+		LockRetryInterval: time.Second,
+	}, nil
+}
+
+// Status returns all migrations with their applied state
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Description: mig.Description}
+		if a, ok := applied[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns migrations which are not applied yet without applying them(dry-run)
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations in order while holding the migration lock.
+//
+// Stops at the first failing migration. Returns applied migrations.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	release, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// pending migrations are read after acquiring the lock since another replica might have applied them
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, mig := range pending {
+		slog.InfoContext(ctx, "applying mongo migration", slog.Int("version", mig.Version), slog.String("description", mig.Description))
+		if err := mig.Up(ctx, m.db); err != nil {
+			return done, fmt.Errorf("migration %d(%s) failed: %w", mig.Version, mig.Description, err)
+		}
+
+		_, err := m.db.Collection(MigrationsCollection).InsertOne(ctx, appliedMigration{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// applied returns applied migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	cursor, err := m.db.Collection(MigrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []appliedMigration
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode applied migrations: %w", err)
+	}
+
+	applied := make(map[int]appliedMigration, len(docs))
+	for _, doc := range docs {
+		applied[doc.Version] = doc
+	}
+	return applied, nil
+}
+
+// lock acquires the migration lock waiting until it is released or expired, or context is done.
+//
+// The lock is refreshed in background until the returned release function is called.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	for {
+		acquired, err := m.tryLock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+
+		slog.InfoContext(ctx, "waiting for migration lock")
+		select {
+		case <-ctx.Done():
+			return nil, ErrMigrationLocked
+		case <-time.After(m.LockRetryInterval):
+		}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(m.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if ok, err := m.tryLock(context.Background()); err != nil || !ok {
+					slog.Warn("failed to refresh migration lock", slog.Any("error", err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := m.db.Collection(MigrationsLockCollection).DeleteOne(ctx, bson.M{"_id": migrationsLockId, "owner": m.owner})
+		if err != nil {
+			slog.Warn("failed to release migration lock", slog.Any("error", err))
+		}
+	}, nil
+}
+
+// tryLock takes or refreshes the lock if it is free, expired or already owned by this migrator.
+func (m *Migrator) tryLock(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": migrationsLockId,
+		"$or": []bson.M{
+			{"owner": m.owner},
+			{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": m.owner, "expiresAt": now.Add(m.LockTTL)}}
+
+	_, err := m.db.Collection(MigrationsLockCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// lock document exists and it is held by another owner
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	return true, nil
+}
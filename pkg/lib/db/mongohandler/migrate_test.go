@@ -0,0 +1,109 @@
+package mongohandler
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func noopMigration(context.Context, *mongo.Database) error { return nil }
+
+func TestNewMigrator(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []Migration
+		assertErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:       "valid migrations",
+			migrations: []Migration{{Version: 2, Up: noopMigration}, {Version: 1, Up: noopMigration}},
+			assertErr:  require.NoError,
+		},
+		{
+			name:       "duplicated version",
+			migrations: []Migration{{Version: 1, Up: noopMigration}, {Version: 1, Up: noopMigration}},
+			assertErr:  require.Error,
+		},
+		{
+			name:       "non positive version",
+			migrations: []Migration{{Version: 0, Up: noopMigration}},
+			assertErr:  require.Error,
+		},
+		{
+			name:       "missing up function",
+			migrations: []Migration{{Version: 1}},
+			assertErr:  require.Error,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			_, err := NewMigrator(nil, tCase.migrations)
+			tCase.assertErr(tt, err)
+		})
+	}
+}
+
+// TestMigratorUp runs against a real MongoDB when `MONGODB_TEST_URI` is set.
+func TestMigratorUp(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	ctx := context.Background()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+	db := client.Database("migrations_test_" + strings.ReplaceAll(uuid.NewString(), "-", ""))
+	defer db.Drop(ctx)
+
+	var mu sync.Mutex
+	runs := []int{}
+	migration := func(version int) Migration {
+		return Migration{Version: version, Description: "test", Up: func(context.Context, *mongo.Database) error {
+			mu.Lock()
+			defer mu.Unlock()
+			runs = append(runs, version)
+			return nil
+		}}
+	}
+	migrations := []Migration{migration(2), migration(1)}
+
+	// concurrent migrators apply each migration once and in order
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			migrator, err := NewMigrator(db, migrations)
+			require.NoError(t, err)
+			migrator.LockRetryInterval = 10 * time.Millisecond
+			_, err = migrator.Up(ctx)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, []int{1, 2}, runs)
+
+	migrator, err := NewMigrator(db, append(migrations, migration(3)))
+	require.NoError(t, err)
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, 3, pending[0].Version)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	require.True(t, statuses[0].Applied)
+	require.False(t, statuses[2].Applied)
+}
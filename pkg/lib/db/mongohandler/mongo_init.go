@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
@@ -28,6 +29,9 @@ type MongoDBWrapper struct {
 	Database *mongo.Database
 	conf     config
 	client   *mongo.Client
+
+	txCheck     sync.Once
+	txSupported bool
 }
 
 func New() *MongoDBWrapper {
@@ -0,0 +1,57 @@
+package mongohandler
+
+import (
+	"context"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// WithTransaction runs fn in a multi-document transaction. fn should use the given context for all operations
+// to be part of the transaction. Transaction is aborted when fn returns error and retried on transient errors
+// so fn may run more than once.
+//
+// Transactions need a replica set or sharded cluster. On a standalone MongoDB fn runs without a transaction.
+//
+// Calling WithTransaction inside a transaction joins the outer transaction.
+func (m *MongoDBWrapper) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil || !m.supportsTransactions() {
+		return fn(ctx)
+	}
+
+	session, err := m.Database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// supportsTransactions checks once whether connected MongoDB is a replica set member or a mongos
+func (m *MongoDBWrapper) supportsTransactions() bool {
+	m.txCheck.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), ConnectionTimeoutInSecond)
+		defer cancel()
+
+		var hello struct {
+			SetName string `bson:"setName"`
+			Msg     string `bson:"msg"`
+		}
+		err := m.Database.Client().Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+		if err != nil {
+			slog.Warn("failed to check MongoDB topology. Transactions are disabled.", slog.Any("error", err))
+			return
+		}
+
+		m.txSupported = hello.SetName != "" || hello.Msg == "isdbgrid"
+		if !m.txSupported {
+			slog.Warn("MongoDB is not a replica set. Transactions are disabled.")
+		}
+	})
+	return m.txSupported
+}
@@ -0,0 +1,67 @@
+package mongohandler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestWithTransaction runs against a real MongoDB when `MONGODB_TEST_URI` is set.
+//
+// Rollback is only asserted when MongoDB is a replica set, otherwise fn runs without a transaction.
+func TestWithTransaction(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	ctx := context.Background()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+	db := client.Database("transaction_test_" + strings.ReplaceAll(uuid.NewString(), "-", ""))
+	defer db.Drop(ctx)
+	// collections can't be created implicitly in a transaction before MongoDB 4.4
+	require.NoError(t, db.CreateCollection(ctx, "docs"))
+
+	wrapper := &MongoDBWrapper{Database: db}
+	docs := db.Collection("docs")
+
+	err = wrapper.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := docs.InsertOne(ctx, bson.M{"_id": "committed"})
+		return err
+	})
+	require.NoError(t, err)
+
+	fnErr := errors.New("abort")
+	err = wrapper.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := docs.InsertOne(ctx, bson.M{"_id": "aborted"}); err != nil {
+			return err
+		}
+		// nested call joins the outer transaction
+		return wrapper.WithTransaction(ctx, func(ctx context.Context) error {
+			return fnErr
+		})
+	})
+	require.ErrorIs(t, err, fnErr)
+
+	committed, err := docs.CountDocuments(ctx, bson.M{"_id": "committed"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), committed)
+
+	aborted, err := docs.CountDocuments(ctx, bson.M{"_id": "aborted"})
+	require.NoError(t, err)
+	if wrapper.supportsTransactions() {
+		require.Equal(t, int64(0), aborted)
+	} else {
+		require.Equal(t, int64(1), aborted)
+	}
+}
@@ -0,0 +1,157 @@
+package pghandler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+)
+
+// migrationLockKey is the key of postgres advisory lock to prevent concurrent migrations from different replicas
+const migrationLockKey = 7245104201
+
+// FuncMigration is a migration written in Go, e.g. a backfill which needs Go functions to compute values.
+// Version is recorded in `schema_migrations` like file names of SQL migrations.
+type FuncMigration struct {
+	Version string
+	// Up applies the migration in the transaction of the migration
+	Up func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migration is a SQL or Go migration to be applied
+type migration struct {
+	version string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Migrate applies `*.sql` files in the given file system and Go migrations of funcs in lexical order of their versions.
+// Version of a SQL migration is its file name.
+//
+// Applied migrations are recorded in `schema_migrations` table by version so each migration runs only once.
+// The whole run holds a session level advisory lock, so that replicas starting together create the table, read applied
+// versions and apply migrations one at a time. Every migration runs in its own transaction.
+func (p *PostgresWrapper) Migrate(ctx context.Context, migrations fs.FS, funcs ...FuncMigration) error {
+	sorted, err := sortedMigrations(migrations, funcs)
+	if err != nil {
+		return err
+	}
+
+	// session level locks belong to a connection, so the run uses a single connection of the pool
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to lock migrations: %v", err)
+	}
+	defer func() {
+		// the lock is released with the session anyway if unlocking fails
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			slog.WarnContext(ctx, "postgres migration lock can't be released", slog.Any("error", err))
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+
+	for _, mig := range sorted {
+		if applied[mig.version] {
+			continue
+		}
+		if err := applyMigration(ctx, conn, mig); err != nil {
+			return fmt.Errorf("migration %s failed: %v", mig.version, err)
+		}
+	}
+	return nil
+}
+
+// sortedMigrations returns SQL migrations of the file system and Go migrations in lexical order of their versions.
+// Returns error when versions are duplicated or a Go migration has no Up function.
+func sortedMigrations(migrations fs.FS, funcs []FuncMigration) ([]migration, error) {
+	files, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := []migration{}
+	for _, file := range files {
+		script, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			return nil, err
+		}
+		sorted = append(sorted, migration{path.Base(file), func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, string(script))
+			return err
+		}})
+	}
+	for _, f := range funcs {
+		if f.Up == nil {
+			return nil, fmt.Errorf("migration %s has no Up function", f.Version)
+		}
+		sorted = append(sorted, migration{f.Version, f.Up})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].version < sorted[j].version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].version == sorted[i].version {
+			return nil, fmt.Errorf("duplicated migration version: %s", sorted[i].version)
+		}
+	}
+	return sorted, nil
+}
+
+// appliedMigrations returns versions recorded in `schema_migrations`
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs a single migration and records it in one transaction.
+func applyMigration(ctx context.Context, conn *sql.Conn, mig migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := mig.up(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", mig.version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "postgres migration applied", slog.String("version", mig.version))
+	return nil
+}
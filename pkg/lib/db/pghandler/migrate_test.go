@@ -0,0 +1,66 @@
+package pghandler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func noopMigration(context.Context, *sql.Tx) error { return nil }
+
+func TestSortedMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"0001_create.sql": {Data: []byte("CREATE TABLE t (id TEXT)")},
+		"0003_alter.sql":  {Data: []byte("ALTER TABLE t ADD COLUMN c TEXT")},
+		"README.md":       {Data: []byte("not a migration")},
+	}
+
+	tests := []struct {
+		name      string
+		funcs     []FuncMigration
+		versions  []string
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name:      "sql migrations only",
+			versions:  []string{"0001_create.sql", "0003_alter.sql"},
+			assertErr: require.NoError,
+		},
+		{
+			name:      "go migrations are ordered with sql migrations",
+			funcs:     []FuncMigration{{Version: "0004_backfill", Up: noopMigration}, {Version: "0002_backfill", Up: noopMigration}},
+			versions:  []string{"0001_create.sql", "0002_backfill", "0003_alter.sql", "0004_backfill"},
+			assertErr: require.NoError,
+		},
+		{
+			name:      "duplicated version",
+			funcs:     []FuncMigration{{Version: "0003_alter.sql", Up: noopMigration}},
+			assertErr: require.Error,
+		},
+		{
+			name:      "missing up function",
+			funcs:     []FuncMigration{{Version: "0002_backfill"}},
+			assertErr: require.Error,
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//execution
+			sorted, err := sortedMigrations(files, tCase.funcs)
+
+			//assertion
+			tCase.assertErr(tt, err)
+			if err != nil {
+				return
+			}
+			versions := []string{}
+			for _, mig := range sorted {
+				versions = append(versions, mig.version)
+			}
+			require.Equal(tt, tCase.versions, versions)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package pghandler
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Executor is the common interface of *sql.DB and *sql.Tx
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// WithTransaction runs fn in a transaction. fn should use Conn with the given context for all queries
+// to be part of the transaction. Transaction is rolled back when fn returns error.
+//
+// Calling WithTransaction inside a transaction joins the outer transaction.
+func (p *PostgresWrapper) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Conn returns the transaction in the context if there is, otherwise db itself.
+func Conn(ctx context.Context, db *sql.DB) Executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
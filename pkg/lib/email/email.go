@@ -0,0 +1,172 @@
+// Package email validates and canonicalizes email addresses.
+package email
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxLength is the max length of an address in a forward-path of SMTP(RFC 5321)
+	maxLength = 254
+	// maxLocalLength is the max length of the local part(RFC 5321)
+	maxLocalLength = 64
+	// maxLabelLength is the max length of a label of the domain(RFC 1035)
+	maxLabelLength = 63
+)
+
+// Errors of invalid addresses
+var (
+	ErrTooLong       = errors.New("address is too long")
+	ErrMissingAt     = errors.New("address should have local part and domain separated by @")
+	ErrInvalidLocal  = errors.New("local part is invalid")
+	ErrInvalidDomain = errors.New("domain is invalid")
+)
+
+// Validate returns an error when the address isn't valid.
+//
+// It is a subset of RFC 5322 addr-spec: local part is a dot-atom of ASCII characters and domain is a host name
+// with at least two labels. Quoted local parts, comments, display names and IP literal domains are not accepted.
+func Validate(addr string) error {
+	if len(addr) > maxLength {
+		return ErrTooLong
+	}
+	at := strings.LastIndexByte(addr, '@')
+	if at <= 0 || at == len(addr)-1 {
+		return ErrMissingAt
+	}
+	if !isDotAtom(addr[:at]) {
+		return ErrInvalidLocal
+	}
+	if !isHostName(addr[at+1:]) {
+		return ErrInvalidDomain
+	}
+	return nil
+}
+
+// isDotAtom reports whether the local part is a dot-atom: atext characters separated by single dots
+func isDotAtom(local string) bool {
+	if len(local) > maxLocalLength {
+		return false
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return false
+		}
+		for i := 0; i < len(atom); i++ {
+			if !isAtext(atom[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isAtext reports whether the character is atext of RFC 5322
+func isAtext(c byte) bool {
+	return isAlphaNumeric(c) || strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// isHostName reports whether the domain is a host name having at least two labels and a non-numeric top level label
+func isHostName(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			if !isAlphaNumeric(label[i]) && label[i] != '-' {
+				return false
+			}
+		}
+	}
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+// isAlphaNumeric reports whether the character is an ASCII letter or digit
+func isAlphaNumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Normalize returns the address without surrounding spaces and with lowercase domain.
+//
+// Local part is kept as it is since it is case sensitive by RFC 5321, it is only ignored in the canonical form.
+func Normalize(addr string) string {
+	addr = strings.TrimSpace(addr)
+	at := strings.LastIndexByte(addr, '@')
+	if at < 0 {
+		return addr
+	}
+	return addr[:at+1] + strings.ToLower(addr[at+1:])
+}
+
+// providerRule is how a mail provider delivers different addresses to the same mailbox
+type providerRule struct {
+	// domain is the main domain of the provider, e.g. gmail.com for googlemail.com
+	domain string
+	// ignoreDots is true when dots of the local part are ignored
+	ignoreDots bool
+	// subaddressing is true when everything after `+` in the local part is ignored
+	subaddressing bool
+}
+
+// providerRules are rules of well known providers by domain
+var providerRules = map[string]providerRule{
+	"gmail.com":      {domain: "gmail.com", ignoreDots: true, subaddressing: true},
+	"googlemail.com": {domain: "gmail.com", ignoreDots: true, subaddressing: true},
+	"outlook.com":    {domain: "outlook.com", subaddressing: true},
+	"hotmail.com":    {domain: "hotmail.com", subaddressing: true},
+	"live.com":       {domain: "live.com", subaddressing: true},
+	"icloud.com":     {domain: "icloud.com", subaddressing: true},
+	"me.com":         {domain: "icloud.com", subaddressing: true},
+	"mac.com":        {domain: "icloud.com", subaddressing: true},
+	"fastmail.com":   {domain: "fastmail.com", subaddressing: true},
+	"proton.me":      {domain: "proton.me", subaddressing: true},
+	"protonmail.com": {domain: "proton.me", subaddressing: true},
+}
+
+// Canonicalizer returns the canonical form of addresses which is used for uniqueness of addresses
+type Canonicalizer struct {
+	providerRules bool
+}
+
+// NewCanonicalizer returns Canonicalizer. Provider rules are applied when `EMAIL_CANONICAL_PROVIDER_RULES` is true.
+func NewCanonicalizer() *Canonicalizer {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("EMAIL_CANONICAL_PROVIDER_RULES", false)
+
+	return &Canonicalizer{providerRules: vi.GetBool("EMAIL_CANONICAL_PROVIDER_RULES")}
+}
+
+// Canonical returns the lowercase address. When provider rules are enabled, dots and `+` tags which are ignored by
+// the provider are removed and domain aliases are replaced, e.g. `J.Doe+news@googlemail.com` is `jdoe@gmail.com`.
+//
+// Returns empty string for an empty address.
+func (c *Canonicalizer) Canonical(addr string) string {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	at := strings.LastIndexByte(addr, '@')
+	if at < 0 || !c.providerRules {
+		return addr
+	}
+
+	local, domain := addr[:at], addr[at+1:]
+	rule, ok := providerRules[domain]
+	if !ok {
+		return addr
+	}
+	if rule.subaddressing {
+		if plus := strings.IndexByte(local, '+'); plus > 0 {
+			local = local[:plus]
+		}
+	}
+	if rule.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + rule.domain
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		addr string
+		err  error
+	}{
+		{"john@doe.com", nil},
+		{"John.Doe+news@Mail.Example.co.uk", nil},
+		{"o'brien!#$%&*/=?^_`{|}~-@x-y.io", nil},
+		{strings.Repeat("a", 64) + "@doe.com", nil},
+		{strings.Repeat("a", 65) + "@doe.com", ErrInvalidLocal},
+		{"a@" + strings.Repeat("b", 250) + ".com", ErrTooLong},
+		{"john", ErrMissingAt},
+		{"@doe.com", ErrMissingAt},
+		{"john@", ErrMissingAt},
+		{"John <john@doe.com>", ErrInvalidLocal},
+		{"john..doe@doe.com", ErrInvalidLocal},
+		{".john@doe.com", ErrInvalidLocal},
+		{`"john doe"@doe.com`, ErrInvalidLocal},
+		{"jöhn@doe.com", ErrInvalidLocal},
+		{"john@localhost", ErrInvalidDomain},
+		{"john@doe..com", ErrInvalidDomain},
+		{"john@-doe.com", ErrInvalidDomain},
+		{"john@doe_x.com", ErrInvalidDomain},
+		{"john@127.0.0.1", ErrInvalidDomain},
+		{"john@[127.0.0.1]", ErrInvalidDomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			require.Equal(t, tt.err, Validate(tt.addr))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	require.Equal(t, "John.Doe@email.com", Normalize(" John.Doe@Email.COM "))
+	require.Equal(t, "john", Normalize("john"))
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name          string
+		addr          string
+		providerRules bool
+		want          string
+	}{
+		{"lowercase", "John@Email.com", false, "john@email.com"},
+		{"provider rules are disabled", "J.Doe+news@gmail.com", false, "j.doe+news@gmail.com"},
+		{"gmail dots and tag", "J.Doe+news@Gmail.com", true, "jdoe@gmail.com"},
+		{"googlemail alias", "j.doe@googlemail.com", true, "jdoe@gmail.com"},
+		{"outlook keeps dots", "j.doe+news@outlook.com", true, "j.doe@outlook.com"},
+		{"icloud alias", "jdoe+a@me.com", true, "jdoe@icloud.com"},
+		{"unknown provider", "j.doe+news@doe.com", true, "j.doe+news@doe.com"},
+		{"leading plus is kept", "+news@gmail.com", true, "+news@gmail.com"},
+		{"empty", "", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Canonicalizer{providerRules: tt.providerRules}
+			require.Equal(t, tt.want, c.Canonical(tt.addr))
+		})
+	}
+}
+
+func TestNewCanonicalizer(t *testing.T) {
+	t.Setenv("EMAIL_CANONICAL_PROVIDER_RULES", "true")
+	require.Equal(t, "jdoe@gmail.com", NewCanonicalizer().Canonical("j.doe@gmail.com"))
+}
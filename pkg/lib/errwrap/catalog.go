@@ -0,0 +1,77 @@
+package errwrap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Definition is a registered error code
+type Definition struct {
+	// Code is the stable, namespaced code of the error, e.g. USER_NOT_FOUND
+	Code string
+	// HttpCode is the http status code the error is responded with
+	HttpCode int
+	// Message is the default message of the error
+	Message string
+	// Description documents when the error occurs
+	Description string
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = map[string]Definition{}
+)
+
+// Register adds the definition to the catalog and returns an error of its code with its default message and http code.
+//
+// Errors are registered in package level variables, it panics when the code is registered twice.
+func Register(def Definition) IError {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	if def.Code == "" || def.HttpCode == 0 {
+		panic("errwrap: code and http code of an error definition can't be empty")
+	}
+	if _, ok := catalog[def.Code]; ok {
+		panic(fmt.Sprintf("errwrap: error code %s is already registered", def.Code))
+	}
+	catalog[def.Code] = def
+	return NewError(def.Message, def.Code).SetHttpCode(def.HttpCode)
+}
+
+// Lookup returns the definition of the code
+func Lookup(code string) (Definition, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	def, ok := catalog[code]
+	return def, ok
+}
+
+// Definitions returns registered definitions ordered by code
+func Definitions() []Definition {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	defs := make([]Definition, 0, len(catalog))
+	for _, def := range catalog {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code < defs[j].Code })
+	return defs
+}
+
+// CatalogMarkdown returns the documentation of registered errors as a markdown table
+func CatalogMarkdown() string {
+	var b strings.Builder
+	b.WriteString("# Error codes\n\n")
+	b.WriteString("Errors have a stable `code` in addition to their http status. Clients should rely on codes instead of messages.\n\n")
+	b.WriteString("| Code | HTTP status | Default message | Description |\n")
+	b.WriteString("|------|-------------|-----------------|-------------|\n")
+	for _, def := range Definitions() {
+		fmt.Fprintf(&b, "| `%s` | %d | %s | %s |\n", def.Code, def.HttpCode, def.Message, def.Description)
+	}
+	return b.String()
+}
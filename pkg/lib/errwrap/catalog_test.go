@@ -0,0 +1,29 @@
+package errwrap
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	def := Definition{Code: "TEST_TEAPOT", HttpCode: http.StatusTeapot, Message: "i'm a teapot", Description: "Test error."}
+	err := Register(def)
+	t.Cleanup(func() {
+		catalogMu.Lock()
+		delete(catalog, def.Code)
+		catalogMu.Unlock()
+	})
+
+	require.Equal(t, http.StatusTeapot, err.HttpCode())
+	require.Equal(t, ErrorResponse{Code: "TEST_TEAPOT", Message: "i'm a teapot"}, err.ErrorResp())
+
+	found, ok := Lookup("TEST_TEAPOT")
+	require.True(t, ok)
+	require.Equal(t, def, found)
+	require.Contains(t, Definitions(), def)
+
+	require.Panics(t, func() { Register(def) }, "codes can't be registered twice")
+	require.Panics(t, func() { Register(Definition{Code: "TEST_NO_STATUS"}) }, "http code is required")
+}
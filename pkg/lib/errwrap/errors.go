@@ -3,6 +3,7 @@ package errwrap
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 )
 
 type IError interface {
@@ -10,27 +11,46 @@ type IError interface {
 	SetMessage(msg string) IError
 	SetHttpCode(code int) IError
 	SetOriginError(err error) IError
+	SetFieldErrors(errs ...FieldError) IError
 	HttpCode() int
 	ErrorResp() ErrorResponse
 	OriginErr() error
+	FieldErrors() []FieldError
 }
 
 type errorWrapper struct {
-	message   string
-	code      string
-	httpCode  int
-	originErr error
+	message     string
+	code        string
+	httpCode    int
+	originErr   error
+	fieldErrors []FieldError
+	stack       stack
 }
 
 type ErrorResponse struct {
 	Message string
 	Code    string
+	Errors  []FieldError `json:",omitempty"`
+}
+
+// Codes of field errors
+const (
+	FieldCodeRequired = "required"
+	FieldCodeInvalid  = "invalid"
+)
+
+// FieldError is the error of a single field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func NewError(msg string, code string) IError {
 	return &errorWrapper{
 		message: msg,
 		code:    code,
+		stack:   callers(),
 	}
 }
 
@@ -42,6 +62,7 @@ func NewFromError(err error) IError {
 
 	return &errorWrapper{
 		originErr: err,
+		stack:     callers(),
 	}
 }
 
@@ -57,9 +78,21 @@ func (e *errorWrapper) SetHttpCode(code int) IError {
 	return newErr
 }
 
+// SetOriginError returns a copy of the error caused by err. Stack is captured here when it is enabled since internal
+// errors are constructed from predefined ones with their causes.
 func (e *errorWrapper) SetOriginError(err error) IError {
 	newErr := e.clone()
 	newErr.originErr = err
+	if s := callers(); s != nil {
+		newErr.stack = s
+	}
+	return newErr
+}
+
+// SetFieldErrors returns a copy of the error with errs as its field errors
+func (e *errorWrapper) SetFieldErrors(errs ...FieldError) IError {
+	newErr := e.clone()
+	newErr.fieldErrors = append([]FieldError(nil), errs...)
 	return newErr
 }
 
@@ -71,10 +104,17 @@ func (e *errorWrapper) ErrorResp() ErrorResponse {
 	return ErrorResponse{
 		Code:    e.code,
 		Message: e.message,
+		Errors:  e.FieldErrors(),
 	}
 }
 
+// Error returns message and code of the error together with its cause.
+//
+// NOTE: It may contain internals of the cause, use ErrorResp for responses.
 func (e *errorWrapper) Error() string {
+	if e.originErr != nil {
+		return fmt.Sprintf("%s code:%s: %v", e.message, e.code, e.originErr)
+	}
 	return fmt.Sprintf("%s code:%s", e.message, e.code)
 }
 
@@ -82,14 +122,65 @@ func (e *errorWrapper) OriginErr() error {
 	return e.originErr
 }
 
+// Unwrap returns the cause of the error
+func (e *errorWrapper) Unwrap() error {
+	return e.originErr
+}
+
+// Is reports whether the target is an errwrap error with the same code so that copies of predefined errors match them,
+// e.g. `errors.Is(ErrNotFound.SetMessage("user not found"), ErrNotFound)` is true.
+func (e *errorWrapper) Is(target error) bool {
+	t, ok := target.(*errorWrapper)
+	if !ok || t == nil {
+		return false
+	}
+	if e.code == "" || t.code == "" {
+		return e == t
+	}
+	return e.code == t.code
+}
+
+// LogValue implements slog.LogValuer. Cause chain and stack of the error are logged, they are never responded.
+func (e *errorWrapper) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("message", e.message),
+		slog.String("code", e.code),
+		slog.Int("httpCode", e.httpCode),
+	}
+	if causes := causeChain(e.originErr); len(causes) > 0 {
+		attrs = append(attrs, slog.Any("causes", causes))
+	}
+	if frames := e.stack.frames(); len(frames) > 0 {
+		attrs = append(attrs, slog.Any("stack", frames))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// causeChain returns messages of err and the errors it wraps, outermost first
+func causeChain(err error) []string {
+	var causes []string
+	for err != nil {
+		causes = append(causes, err.Error())
+		err = errors.Unwrap(err)
+	}
+	return causes
+}
+
+// FieldErrors returns errors of request fields which caused the error
+func (e *errorWrapper) FieldErrors() []FieldError {
+	return e.fieldErrors
+}
+
 func (e *errorWrapper) clone() *errorWrapper {
 	if e == nil {
 		return nil
 	}
 	return &errorWrapper{
-		code:      e.code,
-		httpCode:  e.httpCode,
-		message:   e.message,
-		originErr: e.originErr,
+		code:        e.code,
+		httpCode:    e.httpCode,
+		message:     e.message,
+		originErr:   e.originErr,
+		fieldErrors: e.fieldErrors,
+		stack:       e.stack,
 	}
 }
@@ -0,0 +1,103 @@
+package errwrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIs(t *testing.T) {
+	cause := errors.New("connection refused")
+	tests := []struct {
+		name     string
+		err      error
+		target   error
+		expected bool
+	}{
+		{"same error", ErrNotFound, ErrNotFound, true},
+		{"copy with another message", ErrNotFound.SetMessage("user not found"), ErrNotFound, true},
+		{"wrapped by fmt", fmt.Errorf("get user: %w", ErrConflict.SetMessage("taken")), ErrConflict, true},
+		{"another code", ErrNotFound, ErrConflict, false},
+		{"cause", ErrInternal.SetOriginError(cause), cause, true},
+		{"not an errwrap error", errors.New("not found"), ErrNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, errors.Is(tt.err, tt.target))
+		})
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := ErrInternal.SetOriginError(fmt.Errorf("count users: %w", cause))
+
+	require.ErrorIs(t, err, cause)
+	require.Equal(t, "internal server error code:INTERNAL_ERROR: count users: connection refused", err.Error())
+
+	var iErr IError
+	require.ErrorAs(t, fmt.Errorf("wrapped: %w", err), &iErr)
+	require.Equal(t, "INTERNAL_ERROR", iErr.ErrorResp().Code)
+}
+
+func TestLogValue(t *testing.T) {
+	SetStackCapture(true)
+	t.Cleanup(func() { SetStackCapture(false) })
+
+	err := ErrInternal.SetMessage("user decode error").SetOriginError(fmt.Errorf("decode: %w", errors.New("invalid bson")))
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", slog.Any("error", err))
+
+	var logged struct {
+		Error struct {
+			Message  string   `json:"message"`
+			Code     string   `json:"code"`
+			HttpCode int      `json:"httpCode"`
+			Causes   []string `json:"causes"`
+			Stack    []string `json:"stack"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &logged))
+	require.Equal(t, "user decode error", logged.Error.Message)
+	require.Equal(t, "INTERNAL_ERROR", logged.Error.Code)
+	require.Equal(t, http.StatusInternalServerError, logged.Error.HttpCode)
+	require.Equal(t, []string{"decode: invalid bson", "invalid bson"}, logged.Error.Causes)
+	require.NotEmpty(t, logged.Error.Stack)
+	require.True(t, strings.HasPrefix(logged.Error.Stack[0], "github.com/nsaltun/userapi/pkg/lib/errwrap.TestLogValue"), logged.Error.Stack[0])
+}
+
+func TestStackCaptureDisabled(t *testing.T) {
+	err := ErrInternal.SetOriginError(errors.New("boom")).(*errorWrapper)
+	require.Nil(t, err.stack)
+}
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected IError
+	}{
+		{"client error", ErrNotFound.SetMessage("user not found"), ErrNotFound.SetMessage("user not found")},
+		{"internal error message", ErrInternal.SetMessage("user decrypt error").SetOriginError(errors.New("bad key")), ErrInternal},
+		{"unregistered internal code", NewError("db down", "DB_DOWN").SetHttpCode(http.StatusServiceUnavailable), ErrInternal.SetHttpCode(http.StatusServiceUnavailable)},
+		{"no http code", NewFromError(errors.New("boom")), ErrInternal},
+		{"not an errwrap error", errors.New("boom"), ErrInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			public := Public(tt.err)
+			require.Equal(t, tt.expected.HttpCode(), public.HttpCode())
+			require.Equal(t, tt.expected.ErrorResp(), public.ErrorResp())
+		})
+	}
+}
@@ -1,10 +1,69 @@
 package errwrap
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
 
 var (
-	ErrBadRequest = NewError("invalid argument", "400").SetHttpCode(http.StatusBadRequest)
-	ErrNotFound   = NewError("resource not found", "404").SetHttpCode(http.StatusNotFound)
-	ErrConflict   = NewError("already exists", "409").SetHttpCode(http.StatusConflict)
-	ErrInternal   = NewError("internal server error", "500").SetHttpCode(http.StatusInternalServerError)
+	ErrBadRequest = Register(Definition{
+		Code:        "BAD_REQUEST",
+		HttpCode:    http.StatusBadRequest,
+		Message:     "invalid argument",
+		Description: "The request is invalid.",
+	})
+	ErrValidation = Register(Definition{
+		Code:        "VALIDATION_FAILED",
+		HttpCode:    http.StatusBadRequest,
+		Message:     "request validation failed",
+		Description: "Fields of the request are invalid. Invalid fields are listed in field errors.",
+	})
+	ErrNotFound = Register(Definition{
+		Code:        "NOT_FOUND",
+		HttpCode:    http.StatusNotFound,
+		Message:     "resource not found",
+		Description: "The requested resource doesn't exist.",
+	})
+	ErrConflict = Register(Definition{
+		Code:        "CONFLICT",
+		HttpCode:    http.StatusConflict,
+		Message:     "already exists",
+		Description: "The request conflicts with an existing resource.",
+	})
+	ErrInternal = Register(Definition{
+		Code:        "INTERNAL_ERROR",
+		HttpCode:    http.StatusInternalServerError,
+		Message:     "internal server error",
+		Description: "An unexpected error occurred. Details are not exposed.",
+	})
 )
+
+// NewValidationError returns ErrValidation with the field errors. Its message is the messages of field errors joined.
+func NewValidationError(errs ...FieldError) IError {
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+	return ErrValidation.SetMessage(strings.Join(messages, "; ")).SetFieldErrors(errs...)
+}
+
+// Public returns the error which is safe to respond for err.
+//
+// Client errors are returned as they are. Internal errors, i.e. errors with 5xx http code and errors which are not
+// IError, are replaced with the default message of their code so that their internals only go to logs.
+func Public(err error) IError {
+	var iErr IError
+	if !errors.As(err, &iErr) || iErr.HttpCode() == 0 {
+		return ErrInternal
+	}
+	if iErr.HttpCode() < http.StatusInternalServerError {
+		return iErr
+	}
+
+	def, ok := Lookup(iErr.ErrorResp().Code)
+	if !ok {
+		return ErrInternal.SetHttpCode(iErr.HttpCode())
+	}
+	return NewError(def.Message, def.Code).SetHttpCode(iErr.HttpCode())
+}
@@ -0,0 +1,51 @@
+package errwrap
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// maxStackDepth is the maximum number of frames captured
+const maxStackDepth = 32
+
+var stackCapture atomic.Bool
+
+// SetStackCapture enables or disables capturing stack traces of errors. It is disabled by default.
+//
+// Stacks are captured where errors are constructed with NewError, NewFromError and SetOriginError. They are only
+// logged, never responded.
+func SetStackCapture(enabled bool) {
+	stackCapture.Store(enabled)
+}
+
+// stack is program counters of a captured stack trace
+type stack []uintptr
+
+// callers captures the stack of the caller of the function calling it. Returns nil when capture is disabled.
+func callers() stack {
+	if !stackCapture.Load() {
+		return nil
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	// skip runtime.Callers, callers and the errwrap function calling it
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
+
+// frames returns frames of the stack as `function file:line`
+func (s stack) frames() []string {
+	if len(s) == 0 {
+		return nil
+	}
+	frames := runtime.CallersFrames(s)
+	lines := make([]string, 0, len(s))
+	for {
+		frame, more := frames.Next()
+		lines = append(lines, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+	return lines
+}
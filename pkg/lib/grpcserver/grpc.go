@@ -0,0 +1,55 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+)
+
+type GrpcServer struct {
+	Server  *grpc.Server
+	Address string
+}
+
+// NewGrpcServer returns a gRPC server listening on `GRPC_HOST_ADDRESS`:`GRPC_PORT` when started. Default port is 9090.
+func NewGrpcServer(opts ...grpc.ServerOption) *GrpcServer {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("GRPC_HOST_ADDRESS", "0.0.0.0")
+	vi.SetDefault("GRPC_PORT", "9090")
+	return &GrpcServer{
+		Server:  grpc.NewServer(opts...),
+		Address: fmt.Sprintf("%s:%s", vi.Get("GRPC_HOST_ADDRESS"), vi.Get("GRPC_PORT")),
+	}
+}
+
+// Start listens on the address and serves until Shutdown is called
+func (s *GrpcServer) Start() error {
+	lis, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen gRPC address: %w", err)
+	}
+	slog.Info(fmt.Sprintf("gRPC server is running on %s", s.Address))
+	return s.Server.Serve(lis)
+}
+
+// Shutdown stops the server gracefully. Remaining calls are cancelled when ctx is done.
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.Server.Stop()
+		return nil
+	}
+}
@@ -1,11 +1,12 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"sync"
 
-	"github.com/nsaltun/userapi/pkg/lib/db/mongohandler"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -19,12 +20,13 @@ type HealthCheckResponse struct {
 }
 
 type healthCheck struct {
-	mongoHealthCheck mongohandler.HealthFn
+	checks map[string]func(context.Context) error
 }
 
-func NewHealthCheck(mongoHealthCheck mongohandler.HealthFn) HealthCheck {
+// NewHealthCheck returns a health checker for the given dependencies. Map key is the name of the dependency in the response details.
+func NewHealthCheck(checks map[string]func(context.Context) error) HealthCheck {
 	return &healthCheck{
-		mongoHealthCheck,
+		checks,
 	}
 }
 
@@ -36,16 +38,23 @@ func (h *healthCheck) HealthCheckHandler() http.HandlerFunc {
 
 		// Perform health checks in parallel
 		g, ctx := errgroup.WithContext(ctx)
+		var mu sync.Mutex
 
-		g.Go(func() error {
-			if err := h.mongoHealthCheck(ctx); err != nil {
-				health.Status = "unhealthy"
-				health.Details["MongoDB"] = "DOWN"
-			} else {
-				health.Details["MongoDB"] = "UP"
-			}
-			return nil
-		})
+		for name, check := range h.checks {
+			g.Go(func() error {
+				err := check(ctx)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					health.Status = "unhealthy"
+					health.Details[name] = "DOWN"
+				} else {
+					health.Details[name] = "UP"
+				}
+				return nil
+			})
+		}
 
 		if err := g.Wait(); err != nil {
 			health.Status = "unhealthy"
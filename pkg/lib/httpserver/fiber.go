@@ -3,14 +3,11 @@ package httpserver
 import (
 	"context"
 	"fmt"
-	"log"
 	"log/slog"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/server"
 	"github.com/spf13/viper"
 )
 
@@ -38,32 +35,18 @@ func NewFiberServer() *FiberServer {
 	}
 }
 
-func (s *FiberServer) Listen() {
-	// Channel to listen for termination signals
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	// Run the server in a goroutine
-	go func() {
-		if err := s.App.Listen(s.Address); err != nil {
-			log.Fatalf("Failed to start server: %v\n", err)
-		}
-	}()
+// Listen serves until a termination signal is received and shuts down gracefully. See server.Run for errors.
+func (s *FiberServer) Listen() error {
+	return server.Run(s)
+}
 
+// Start listens on the address and serves until Shutdown is called
+func (s *FiberServer) Start() error {
 	slog.Info(fmt.Sprintf("Server is running on %s", s.Address))
+	return s.App.Listen(s.Address)
+}
 
-	// Block until a termination signal is received
-	<-quit
-	slog.Info("Server is shutting down...")
-
-	// Create a timeout context for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Attempt to gracefully shutdown the server
-	if err := s.App.ShutdownWithContext(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
-	}
-
-	slog.Info("Server stopped gracefully")
+// Shutdown stops the server gracefully waiting in-flight requests until ctx is done
+func (s *FiberServer) Shutdown(ctx context.Context) error {
+	return s.App.ShutdownWithContext(ctx)
 }
@@ -0,0 +1,46 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+)
+
+// encryptedStore encrypts bodies of responses before they are stored by the underlying Store. Bodies contain PII of
+// users such as emails and names which shouldn't be readable from the storage.
+type encryptedStore struct {
+	Store
+	encryptor *crypt.EnvelopeEncryptor
+}
+
+// NewEncryptedStore returns Store storing keys in the store with bodies of responses encrypted by the encryptor.
+// Responses which were stored in plaintext are still replayed.
+func NewEncryptedStore(store Store, encryptor *crypt.EnvelopeEncryptor) Store {
+	return &encryptedStore{Store: store, encryptor: encryptor}
+}
+
+// Lock decrypts the body of the response of the returned record
+func (s *encryptedStore) Lock(ctx context.Context, key, token, fingerprint string, expiresAt time.Time) (*Record, error) {
+	record, err := s.Store.Lock(ctx, key, token, fingerprint, expiresAt)
+	if err != nil || record == nil || len(record.Response.Body) == 0 {
+		return record, err
+	}
+	body, err := s.encryptor.Decrypt(string(record.Response.Body))
+	if err != nil {
+		return nil, err
+	}
+	record.Response.Body = []byte(body)
+	return record, nil
+}
+
+func (s *encryptedStore) Save(ctx context.Context, key, token string, resp Response, expiresAt time.Time) error {
+	if len(resp.Body) > 0 {
+		body, err := s.encryptor.Encrypt(string(resp.Body))
+		if err != nil {
+			return err
+		}
+		resp.Body = []byte(body)
+	}
+	return s.Store.Save(ctx, key, token, resp, expiresAt)
+}
@@ -0,0 +1,71 @@
+package idempotency_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency/idempotencytest"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys is a crypt.KeyProvider for tests, keys other than the current one are unknown
+type testKeys struct{}
+
+func (testKeys) CurrentKeyId() string { return "test" }
+func (testKeys) Key(id string) ([]byte, error) {
+	if id != "test" {
+		return nil, errors.New("unknown key")
+	}
+	return bytes.Repeat([]byte{1}, crypt.KeySize), nil
+}
+func (testKeys) BlindIndexKey() []byte { return bytes.Repeat([]byte{2}, crypt.KeySize) }
+
+func TestEncryptedStoreSuite(t *testing.T) {
+	idempotencytest.RunStoreSuite(t, func(t *testing.T) idempotency.Store {
+		return idempotency.NewEncryptedStore(idempotency.NewMemoryStore(), crypt.NewEnvelopeEncryptor(testKeys{}))
+	})
+}
+
+func TestEncryptedStore(t *testing.T) {
+	//test setup
+	ctx := context.Background()
+	resp := idempotency.Response{Status: 201, ContentType: "application/json", Body: []byte(`{"email":"john@email.com"}`)}
+
+	t.Run("bodies are stored encrypted", func(tt *testing.T) {
+		stored := idempotency.NewMemoryStore()
+		store := idempotency.NewEncryptedStore(stored, crypt.NewEnvelopeEncryptor(testKeys{}))
+		_, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+		require.NoError(tt, err)
+
+		//execution
+		require.NoError(tt, store.Save(ctx, "key", "token", resp, time.Now().Add(time.Hour)))
+
+		//assertion
+		record, err := stored.Lock(ctx, "key", "other-token", "fp", time.Now().Add(time.Minute))
+		require.NoError(tt, err)
+		require.True(tt, crypt.IsEncrypted(string(record.Response.Body)))
+		replayed, err := store.Lock(ctx, "key", "other-token", "fp", time.Now().Add(time.Minute))
+		require.NoError(tt, err)
+		require.Equal(tt, resp, replayed.Response)
+	})
+
+	t.Run("plaintext bodies are replayed", func(tt *testing.T) {
+		stored := idempotency.NewMemoryStore()
+		store := idempotency.NewEncryptedStore(stored, crypt.NewEnvelopeEncryptor(testKeys{}))
+		_, err := stored.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+		require.NoError(tt, err)
+		require.NoError(tt, stored.Save(ctx, "key", "token", resp, time.Now().Add(time.Hour)))
+
+		//execution
+		record, err := store.Lock(ctx, "key", "other-token", "fp", time.Now().Add(time.Minute))
+
+		//assertion
+		require.NoError(tt, err)
+		require.Equal(tt, resp, record.Response)
+	})
+}
@@ -0,0 +1,52 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Record is the state of an idempotency key
+type Record struct {
+	Key string
+	// Fingerprint is the hash of the request which used the key first
+	Fingerprint string
+	// Completed is false while the first request is in progress
+	Completed bool
+	// Response of the first request, set when it is completed
+	Response Response
+	// ExpiresAt is the time the key can be used again. Locks of requests which didn't complete expire earlier.
+	ExpiresAt time.Time
+}
+
+// Response is the stored response which is replayed for repeated requests
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// ErrNotLocked is returned by Save when the key isn't locked with the token, e.g. the lock expired and another
+// request locked the key.
+var ErrNotLocked = errors.New("idempotency key is not locked with the token")
+
+// Store stores idempotency keys and responses of their first requests.
+//
+// A lock is owned by the request with its token, so that a request whose lock expired and was taken over by another
+// request can't complete or release the key of the other request.
+type Store interface {
+	// Lock locks the key with the token for the request with the fingerprint until expiresAt.
+	//
+	// Returns nil when the key is locked for the request. Returns the record of the key when it is already used and
+	// not expired.
+	Lock(ctx context.Context, key, token, fingerprint string, expiresAt time.Time) (*Record, error)
+
+	// Save completes the key locked with the token with the response and keeps it until expiresAt.
+	//
+	// Returns ErrNotLocked when the key isn't locked with the token.
+	Save(ctx context.Context, key, token string, resp Response, expiresAt time.Time) error
+
+	// Unlock removes the lock of the key when it is locked with the token and its request is not completed so that
+	// it can be retried
+	Unlock(ctx context.Context, key, token string) error
+}
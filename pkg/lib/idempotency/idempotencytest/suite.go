@@ -0,0 +1,167 @@
+// Package idempotencytest provides a conformance test suite for idempotency.Store implementations.
+//
+//	func TestMyStore(t *testing.T) {
+//		idempotencytest.RunStoreSuite(t, func(t *testing.T) idempotency.Store {
+//			return newEmptyStore(t)
+//		})
+//	}
+package idempotencytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/stretchr/testify/require"
+)
+
+// StoreFactory returns an empty store for each test case. Cleanup can be registered with t.Cleanup.
+type StoreFactory func(t *testing.T) idempotency.Store
+
+// RunStoreSuite runs the Store contract tests against stores created by the factory.
+func RunStoreSuite(t *testing.T, newStore StoreFactory) {
+	tests := []struct {
+		name string
+		run  func(*testing.T, idempotency.Store)
+	}{
+		{"lock returns pending record", testLockPending},
+		{"save completes the record", testSave},
+		{"unlock releases pending key", testUnlock},
+		{"unlock keeps completed key", testUnlockCompleted},
+		{"expired key is locked again", testLockExpired},
+		{"taken over key is owned by the new token", testLockTakenOver},
+		{"concurrent locks acquire once", testConcurrentLock},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			tCase.run(tt, newStore(tt))
+		})
+	}
+}
+
+func testLockPending(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute)
+
+	record, err := store.Lock(ctx, "key", "token", "fp", expiresAt)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	record, err = store.Lock(ctx, "key", "other-token", "other", expiresAt)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, "fp", record.Fingerprint)
+	require.False(t, record.Completed)
+	require.WithinDuration(t, expiresAt, record.ExpiresAt, time.Millisecond)
+}
+
+func testSave(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+	resp := idempotency.Response{Status: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+
+	_, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, "key", "token", resp, time.Now().Add(time.Hour)))
+
+	record, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.True(t, record.Completed)
+	require.Equal(t, resp, record.Response)
+}
+
+func testUnlock(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+
+	_, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, store.Unlock(ctx, "key", "token"))
+
+	record, err := store.Lock(ctx, "key", "other-token", "other", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Nil(t, record)
+}
+
+func testUnlockCompleted(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+
+	_, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, "key", "token", idempotency.Response{Status: 200}, time.Now().Add(time.Hour)))
+	require.NoError(t, store.Unlock(ctx, "key", "token"))
+
+	record, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.True(t, record.Completed)
+}
+
+func testLockExpired(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+
+	_, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+
+	record, err := store.Lock(ctx, "key", "other-token", "other", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	record, err = store.Lock(ctx, "key", "token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, "other", record.Fingerprint)
+}
+
+func testLockTakenOver(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+
+	_, err := store.Lock(ctx, "key", "token", "fp", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	record, err := store.Lock(ctx, "key", "other-token", "other", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	// the request of the expired lock can't complete or release the key
+	require.ErrorIs(t, store.Save(ctx, "key", "token", idempotency.Response{Status: 200}, time.Now().Add(time.Hour)), idempotency.ErrNotLocked)
+	require.NoError(t, store.Unlock(ctx, "key", "token"))
+
+	record, err = store.Lock(ctx, "key", "third-token", "fp", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, "other", record.Fingerprint)
+	require.False(t, record.Completed)
+}
+
+func testConcurrentLock(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+	const workers = 10
+
+	type result struct {
+		record *idempotency.Record
+		err    error
+	}
+	var wg sync.WaitGroup
+	results := make(chan result, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record, err := store.Lock(ctx, "key", uuid.NewString(), "fp", time.Now().Add(time.Minute))
+			results <- result{record, err}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	acquired := 0
+	for r := range results {
+		require.NoError(t, r.err)
+		if r.record == nil {
+			acquired++
+		}
+	}
+	require.Equal(t, 1, acquired)
+}
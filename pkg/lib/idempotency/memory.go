@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryRecord is the record of a key with the token it is locked with
+type memoryRecord struct {
+	Record
+	token string
+}
+
+// memoryStore is an in-memory implementor of Store
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryRecord
+	now     func() time.Time
+}
+
+// NewMemoryStore returns Store keeping keys in memory. Keys are not shared between instances of the service.
+func NewMemoryStore() Store {
+	return &memoryStore{records: map[string]*memoryRecord{}, now: time.Now}
+}
+
+func (s *memoryStore) Lock(ctx context.Context, key, token, fingerprint string, expiresAt time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpired()
+	if record, ok := s.records[key]; ok {
+		found := record.Record
+		return &found, nil
+	}
+	s.records[key] = &memoryRecord{Record: Record{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt}, token: token}
+	return nil, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, key, token string, resp Response, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok || record.token != token {
+		return ErrNotLocked
+	}
+	record.Completed = true
+	record.Response = resp
+	record.ExpiresAt = expiresAt
+	return nil
+}
+
+func (s *memoryStore) Unlock(ctx context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.token == token && !record.Completed {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// removeExpired removes expired keys like the TTL index of MongoDB
+func (s *memoryStore) removeExpired() {
+	now := s.now()
+	for key, record := range s.records {
+		if !record.ExpiresAt.After(now) {
+			delete(s.records, key)
+		}
+	}
+}
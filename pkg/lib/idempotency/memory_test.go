@@ -0,0 +1,14 @@
+package idempotency_test
+
+import (
+	"testing"
+
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency/idempotencytest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	idempotencytest.RunStoreSuite(t, func(t *testing.T) idempotency.Store {
+		return idempotency.NewMemoryStore()
+	})
+}
@@ -0,0 +1,69 @@
+// Package job runs background jobs together with servers, see server.Run.
+package job
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Periodic runs a function at start and then every interval. It implements server.Server so that it is stopped
+// together with servers.
+type Periodic struct {
+	name     string
+	interval time.Duration
+	run      func(context.Context) error
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewPeriodic returns the Periodic job of the function. Name is used in logs.
+func NewPeriodic(name string, interval time.Duration, run func(context.Context) error) *Periodic {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Periodic{
+		name:     name,
+		interval: interval,
+		run:      run,
+		ctx:      ctx,
+		cancel:   cancel,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the function until Shutdown is called. Errors of runs are logged, they don't stop the job.
+func (p *Periodic) Start() error {
+	defer close(p.done)
+	slog.Info("Job is started", slog.String("job", p.name), slog.Duration("interval", p.interval))
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.run(p.ctx); err != nil {
+			slog.Error("Job failed", slog.String("job", p.name), slog.Any("error", err))
+		}
+		select {
+		case <-p.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops the job and waits for the running function until ctx is done. Then context of the function is
+// canceled.
+func (p *Periodic) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
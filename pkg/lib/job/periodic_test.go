@@ -0,0 +1,51 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodicRunsUntilShutdown(t *testing.T) {
+	var runs atomic.Int32
+	p := NewPeriodic("test", time.Millisecond, func(context.Context) error {
+		runs.Add(1)
+		return errors.New("failed runs don't stop the job")
+	})
+
+	started := make(chan error)
+	go func() { started <- p.Start() }()
+	require.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.NoError(t, <-started)
+	stopped := runs.Load()
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, stopped, runs.Load(), "job should not run after shutdown")
+}
+
+func TestPeriodicShutdownCancelsRunAfterTimeout(t *testing.T) {
+	running := make(chan struct{})
+	canceled := make(chan struct{})
+	p := NewPeriodic("test", time.Hour, func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+	go p.Start()
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("running function should be canceled")
+	}
+}
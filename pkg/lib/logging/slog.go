@@ -5,6 +5,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
 	"github.com/spf13/viper"
 )
 
@@ -21,6 +22,10 @@ func InitSlog() {
 	vi.SetDefault("LOG_LEVEL", level)
 	level = vi.GetString("LOG_LEVEL")
 
+	// stack traces of errors are logged when it is enabled
+	vi.SetDefault("ERROR_STACK_TRACE", false)
+	errwrap.SetStackCapture(vi.GetBool("ERROR_STACK_TRACE"))
+
 	// Set up a JSON handler for logging
 	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: levelMap[level],
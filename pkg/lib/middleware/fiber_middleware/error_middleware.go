@@ -16,7 +16,9 @@ type APIResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-// ResponseMiddleware is a Fiber middleware for mapping and logging responses
+// ResponseMiddleware is a Fiber middleware for mapping and logging responses.
+//
+// Successful responses are wrapped by APIResponse. Errors are responded as RFC 7807 problem details, see Problem.
 func ResponseMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		start := time.Now() // Record the start time
@@ -27,7 +29,16 @@ func ResponseMiddleware() fiber.Handler {
 		// Calculate response time
 		duration := time.Since(start)
 		statusCode := c.Response().StatusCode()
-		level := slog.LevelInfo
+
+		// Handle errors
+		if err != nil {
+			problem, level := newProblem(c, err)
+			logRequest(c, level, "error", problem.Status, duration, slog.Any("error", err))
+
+			// Send problem details instead of the response
+			c.Response().Reset()
+			return c.Status(problem.Status).JSON(problem, MIMEApplicationProblemJSON)
+		}
 
 		// Initialize a response wrapper
 		response := APIResponse{
@@ -37,40 +48,18 @@ func ResponseMiddleware() fiber.Handler {
 			Data:    nil,
 		}
 
-		// Handle errors
-		if err != nil {
-			response.Status = "error"
-			if fiberErr, ok := err.(*fiber.Error); ok {
-				// Fiber error
-				response.Code = fiberErr.Code
-				response.Message = fiberErr.Message
-				level = slog.LevelWarn
-			} else {
-				// Generic error
-				response.Code = fiber.StatusInternalServerError
-				response.Message = err.Error()
-				level = slog.LevelError
+		// Parse the original response body
+		if len(c.Response().Body()) > 0 {
+			if err := json.Unmarshal(c.Response().Body(), &response.Data); err != nil {
+				slog.Warn("Failed to parse response body", "error", err)
+				response.Data = string(c.Response().Body())
 			}
 		} else {
-			// Parse the original response body
-			if len(c.Response().Body()) > 0 {
-				if err := json.Unmarshal(c.Response().Body(), &response.Data); err != nil {
-					slog.Warn("Failed to parse response body", "error", err)
-					response.Data = string(c.Response().Body())
-				}
-			} else {
-				response.Data = fiber.Map{"message": "Request completed successfully"}
-			}
+			response.Data = fiber.Map{"message": "Request completed successfully"}
 		}
 
 		// Log the request and response
-		slog.Log(c.Context(), level, "request completed",
-			"method", c.Method(),
-			"path", c.Path(),
-			"status", response.Status,
-			"statusCode", response.Code,
-			"duration", duration,
-		)
+		logRequest(c, slog.LevelInfo, response.Status, response.Code, duration)
 
 		// Send the final wrapped response
 		c.Response().Reset()
@@ -80,3 +69,15 @@ func ResponseMiddleware() fiber.Handler {
 		return c.Status(statusCode).JSON(response)
 	}
 }
+
+// logRequest logs the completed request with the extra attributes
+func logRequest(c *fiber.Ctx, level slog.Level, status string, statusCode int, duration time.Duration, attrs ...any) {
+	args := []any{
+		"method", c.Method(),
+		"path", c.Path(),
+		"status", status,
+		"statusCode", statusCode,
+		"duration", duration,
+	}
+	slog.Log(c.Context(), level, "request completed", append(args, attrs...)...)
+}
@@ -0,0 +1,93 @@
+package fiber_middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     fiber.Handler
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name: "success",
+			handler: func(c *fiber.Ctx) error {
+				return c.Status(http.StatusCreated).JSON(fiber.Map{"id": "1"})
+			},
+			status:      http.StatusCreated,
+			contentType: fiber.MIMEApplicationJSON,
+			body:        `{"status":"success","code":201,"data":{"id":"1"}}`,
+		},
+		{
+			name: "field errors",
+			handler: func(c *fiber.Ctx) error {
+				return errwrap.NewValidationError(
+					errwrap.FieldError{Field: "firstName", Code: errwrap.FieldCodeRequired, Message: "firstName can't be empty"},
+					errwrap.FieldError{Field: "email", Code: errwrap.FieldCodeRequired, Message: "email can't be empty"},
+				)
+			},
+			status:      http.StatusBadRequest,
+			contentType: MIMEApplicationProblemJSON,
+			body: `{"type":"about:blank","title":"Bad Request","status":400,"instance":"/users","code":"VALIDATION_FAILED",
+				"detail":"firstName can't be empty; email can't be empty",
+				"errors":[{"field":"firstName","code":"required","message":"firstName can't be empty"},
+					{"field":"email","code":"required","message":"email can't be empty"}]}`,
+		},
+		{
+			name: "IError",
+			handler: func(c *fiber.Ctx) error {
+				return errwrap.ErrNotFound.SetMessage("user not found")
+			},
+			status:      http.StatusNotFound,
+			contentType: MIMEApplicationProblemJSON,
+			body:        `{"type":"about:blank","title":"Not Found","status":404,"instance":"/users","code":"NOT_FOUND","detail":"user not found"}`,
+		},
+		{
+			name: "fiber error",
+			handler: func(c *fiber.Ctx) error {
+				return fiber.NewError(http.StatusUnprocessableEntity, "Unprocessable Entity")
+			},
+			status:      http.StatusUnprocessableEntity,
+			contentType: MIMEApplicationProblemJSON,
+			body:        `{"type":"about:blank","title":"Unprocessable Entity","status":422,"instance":"/users","detail":"Unprocessable Entity"}`,
+		},
+		{
+			name: "generic error",
+			handler: func(c *fiber.Ctx) error {
+				return errors.New("unexpected")
+			},
+			status:      http.StatusInternalServerError,
+			contentType: MIMEApplicationProblemJSON,
+			body:        `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/users","code":"INTERNAL_ERROR","detail":"internal server error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/users", ResponseMiddleware(), tt.handler)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/users", nil))
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.Equal(t, tt.contentType, resp.Header.Get(fiber.HeaderContentType))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.True(t, json.Valid(body))
+			require.JSONEq(t, tt.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package fiber_middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/spf13/viper"
+)
+
+const (
+	// HeaderIdempotencyKey is the request header of the idempotency key
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set to true on responses which are replayed for a repeated idempotency key
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength is the maximum length of idempotency keys
+	maxIdempotencyKeyLength = 255
+)
+
+// ErrIdempotencySecretMissing is returned by IdempotencyMiddleware when `IDEMPOTENCY_SECRET` is not set
+var ErrIdempotencySecretMissing = errors.New("IDEMPOTENCY_SECRET is not set")
+
+// Errors of idempotency keys
+var (
+	ErrIdempotencyKeyInvalid = errwrap.Register(errwrap.Definition{
+		Code:        "IDEMPOTENCY_KEY_INVALID",
+		HttpCode:    http.StatusBadRequest,
+		Message:     "Idempotency-Key must be 1 to 255 characters",
+		Description: "Idempotency-Key header is empty or too long.",
+	})
+	ErrIdempotencyKeyReused = errwrap.Register(errwrap.Definition{
+		Code:        "IDEMPOTENCY_KEY_REUSED",
+		HttpCode:    http.StatusUnprocessableEntity,
+		Message:     "Idempotency-Key is already used for a different request",
+		Description: "Idempotency-Key was used before with another method, path or body.",
+	})
+	ErrIdempotencyRequestInProgress = errwrap.Register(errwrap.Definition{
+		Code:        "IDEMPOTENCY_REQUEST_IN_PROGRESS",
+		HttpCode:    http.StatusConflict,
+		Message:     "a request with the same Idempotency-Key is in progress",
+		Description: "The first request of the Idempotency-Key didn't complete yet. It can be retried after Retry-After seconds.",
+	})
+)
+
+// IdempotencyMiddleware is a Fiber middleware replaying responses of POST requests with the same `Idempotency-Key` header.
+//
+// The first response of a key is stored and replayed for repeated requests until `IDEMPOTENCY_TTL`(default 24h).
+// A repeated key with another method, path or body is rejected. While the first request is in progress the key is
+// locked for `IDEMPOTENCY_LOCK_TIMEOUT`(default 1m) and repeated requests are rejected. Server errors are not stored,
+// the key can be retried after them.
+//
+// Requests are compared by their HMAC with `IDEMPOTENCY_SECRET` so that stored fingerprints don't reveal bodies
+// such as passwords. The secret is required and it should be shared by instances sharing the store, so that keys
+// can be retried on any of them.
+//
+// Requests for which skip returns true are passed to the next handler without their keys, e.g. requests with passwords
+// which shouldn't be stored. skip can be nil.
+//
+// It should be used before ResponseMiddleware so that the wrapped response is stored.
+//
+// Returns ErrIdempotencySecretMissing when `IDEMPOTENCY_SECRET` is not set.
+func IdempotencyMiddleware(store idempotency.Store, skip func(c *fiber.Ctx) bool) (fiber.Handler, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("IDEMPOTENCY_TTL", 24*time.Hour)
+	vi.SetDefault("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute)
+	vi.SetDefault("IDEMPOTENCY_SECRET", "")
+
+	ttl := vi.GetDuration("IDEMPOTENCY_TTL")
+	lockTimeout := vi.GetDuration("IDEMPOTENCY_LOCK_TIMEOUT")
+	secret := []byte(vi.GetString("IDEMPOTENCY_SECRET"))
+	if len(secret) == 0 {
+		return nil, ErrIdempotencySecretMissing
+	}
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if c.Method() != fiber.MethodPost || key == "" || (skip != nil && skip(c)) {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return sendProblem(c, ErrIdempotencyKeyInvalid)
+		}
+
+		ctx := c.UserContext()
+		fingerprint := requestFingerprint(c, secret)
+		// the token owns the lock so that the key isn't saved or released by this request after it is taken over
+		token := uuid.NewString()
+		record, err := store.Lock(ctx, key, token, fingerprint, time.Now().Add(lockTimeout))
+		if err != nil {
+			return sendProblem(c, errwrap.ErrInternal.SetOriginError(err))
+		}
+		if record != nil {
+			switch {
+			case record.Fingerprint != fingerprint:
+				return sendProblem(c, ErrIdempotencyKeyReused)
+			case !record.Completed:
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter(record.ExpiresAt)))
+				return sendProblem(c, ErrIdempotencyRequestInProgress)
+			}
+			c.Set(HeaderIdempotentReplayed, "true")
+			c.Set(fiber.HeaderContentType, record.Response.ContentType)
+			return c.Status(record.Response.Status).Send(record.Response.Body)
+		}
+
+		completed := false
+		defer func() {
+			// release the key when the request failed or panicked so that it can be retried
+			if !completed {
+				if err := store.Unlock(ctx, key, token); err != nil {
+					slog.Error("Failed to unlock idempotency key", "error", err)
+				}
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= http.StatusInternalServerError {
+			return nil
+		}
+		resp := idempotency.Response{
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if err := store.Save(ctx, key, token, resp, time.Now().Add(ttl)); err != nil {
+			// the response is still sent, the key is released to be retried
+			slog.Error("Failed to save idempotent response", "error", err)
+			return nil
+		}
+		completed = true
+		return nil
+	}, nil
+}
+
+// requestFingerprint returns the HMAC of method, url and body of the request with the secret
+func requestFingerprint(c *fiber.Ctx, secret []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.OriginalURL()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// retryAfter returns seconds until the lock expires, at least 1
+func retryAfter(expiresAt time.Time) int {
+	seconds := int(time.Until(expiresAt).Seconds())
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// sendProblem responds the error as problem details and logs the request
+func sendProblem(c *fiber.Ctx, err error) error {
+	problem, level := newProblem(c, err)
+	logRequest(c, level, "error", problem.Status, 0, slog.Any("error", err))
+	return c.Status(problem.Status).JSON(problem, MIMEApplicationProblemJSON)
+}
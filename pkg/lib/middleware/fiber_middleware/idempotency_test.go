@@ -0,0 +1,264 @@
+package fiber_middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/nsaltun/userapi/pkg/lib/idempotency"
+	"github.com/stretchr/testify/require"
+)
+
+// idempotencyResponse is a response of a request to the idempotency test app
+type idempotencyResponse struct {
+	status   int
+	replayed bool
+	code     string
+	body     string
+	// retryAfter is Retry-After header of the response
+	retryAfter string
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	type request struct {
+		key  string
+		body string
+	}
+	tests := []struct {
+		name     string
+		statuses []int
+		requests []request
+		want     []idempotencyResponse
+		calls    int
+	}{
+		{
+			name:     "replays the first response",
+			requests: []request{{"k1", `{"a":1}`}, {"k1", `{"a":1}`}},
+			want: []idempotencyResponse{
+				{status: http.StatusCreated, body: `{"status":"success","code":201,"data":{"call":1}}`},
+				{status: http.StatusCreated, replayed: true, body: `{"status":"success","code":201,"data":{"call":1}}`},
+			},
+			calls: 1,
+		},
+		{
+			name:     "client errors are replayed",
+			statuses: []int{http.StatusConflict},
+			requests: []request{{"k1", `{}`}, {"k1", `{}`}},
+			want: []idempotencyResponse{
+				{status: http.StatusConflict, code: "CONFLICT"},
+				{status: http.StatusConflict, replayed: true, code: "CONFLICT"},
+			},
+			calls: 1,
+		},
+		{
+			name:     "rejects reused key with another payload",
+			requests: []request{{"k1", `{"a":1}`}, {"k1", `{"a":2}`}},
+			want: []idempotencyResponse{
+				{status: http.StatusCreated},
+				{status: http.StatusUnprocessableEntity, code: "IDEMPOTENCY_KEY_REUSED"},
+			},
+			calls: 1,
+		},
+		{
+			name:     "server errors release the key",
+			statuses: []int{http.StatusInternalServerError},
+			requests: []request{{"k1", `{}`}, {"k1", `{}`}},
+			want: []idempotencyResponse{
+				{status: http.StatusInternalServerError, code: "INTERNAL_ERROR"},
+				{status: http.StatusCreated, body: `{"status":"success","code":201,"data":{"call":2}}`},
+			},
+			calls: 2,
+		},
+		{
+			name:     "requests without key are not stored",
+			requests: []request{{"", `{}`}, {"", `{}`}},
+			want:     []idempotencyResponse{{status: http.StatusCreated}, {status: http.StatusCreated}},
+			calls:    2,
+		},
+		{
+			name:     "too long key",
+			requests: []request{{strings.Repeat("k", 256), `{}`}},
+			want:     []idempotencyResponse{{status: http.StatusBadRequest, code: "IDEMPOTENCY_KEY_INVALID"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := idempotency.NewMemoryStore()
+			calls := 0
+			app := fiber.New()
+			app.Post("/users", newIdempotencyMiddleware(t, store, nil), ResponseMiddleware(), func(c *fiber.Ctx) error {
+				status := http.StatusCreated
+				if calls < len(tt.statuses) {
+					status = tt.statuses[calls]
+				}
+				calls++
+				switch status {
+				case http.StatusConflict:
+					return errwrap.ErrConflict
+				case http.StatusInternalServerError:
+					return io.ErrUnexpectedEOF
+				}
+				return c.Status(status).JSON(fiber.Map{"call": calls})
+			})
+
+			for i, r := range tt.requests {
+				got := doIdempotent(t, app, r.key, r.body)
+				require.Equal(t, tt.want[i].status, got.status, "request %d", i)
+				require.Equal(t, tt.want[i].replayed, got.replayed, "request %d", i)
+				require.Equal(t, tt.want[i].code, got.code, "request %d", i)
+				if tt.want[i].body != "" {
+					require.JSONEq(t, tt.want[i].body, got.body, "request %d", i)
+				}
+			}
+			require.Equal(t, tt.calls, calls)
+		})
+	}
+}
+
+func TestIdempotencyMiddlewareConcurrentRequest(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	app := fiber.New()
+	app.Post("/users", newIdempotencyMiddleware(t, idempotency.NewMemoryStore(), nil), ResponseMiddleware(), func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.Status(http.StatusCreated).JSON(fiber.Map{"id": "1"})
+	})
+
+	first := make(chan idempotencyResponse)
+	go func() { first <- doIdempotent(t, app, "k1", `{}`) }()
+	<-started
+
+	got := doIdempotent(t, app, "k1", `{}`)
+	require.Equal(t, http.StatusConflict, got.status)
+	require.Equal(t, "IDEMPOTENCY_REQUEST_IN_PROGRESS", got.code)
+	require.NotEmpty(t, got.retryAfter)
+
+	close(release)
+	require.Equal(t, http.StatusCreated, (<-first).status)
+	got = doIdempotent(t, app, "k1", `{}`)
+	require.Equal(t, http.StatusCreated, got.status)
+	require.True(t, got.replayed)
+}
+
+func TestIdempotencyMiddlewareSkip(t *testing.T) {
+	//test setup
+	calls := 0
+	app := fiber.New()
+	skip := func(c *fiber.Ctx) bool { return c.Get("X-Skip") == "true" }
+	app.Post("/users", newIdempotencyMiddleware(t, idempotency.NewMemoryStore(), skip), ResponseMiddleware(), func(c *fiber.Ctx) error {
+		calls++
+		return c.Status(http.StatusCreated).JSON(fiber.Map{"call": calls})
+	})
+	send := func(skipped bool) idempotencyResponse {
+		req := httptest.NewRequest(fiber.MethodPost, "/users", strings.NewReader(`{}`))
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		if skipped {
+			req.Header.Set("X-Skip", "true")
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return idempotencyResponse{status: resp.StatusCode, replayed: resp.Header.Get(HeaderIdempotentReplayed) == "true"}
+	}
+
+	//execution
+	skipped := []idempotencyResponse{send(true), send(true)}
+	stored := send(false)
+
+	//assertion
+	for _, got := range skipped {
+		require.Equal(t, http.StatusCreated, got.status)
+		require.False(t, got.replayed)
+	}
+	require.False(t, stored.replayed, "skipped requests shouldn't store the key")
+	require.Equal(t, 3, calls)
+}
+
+// fingerprintStore records fingerprints of locked keys
+type fingerprintStore struct {
+	idempotency.Store
+	fingerprints []string
+}
+
+func (s *fingerprintStore) Lock(ctx context.Context, key, token, fingerprint string, expiresAt time.Time) (*idempotency.Record, error) {
+	s.fingerprints = append(s.fingerprints, fingerprint)
+	return s.Store.Lock(ctx, key, token, fingerprint, expiresAt)
+}
+
+func TestIdempotencyFingerprintIsKeyed(t *testing.T) {
+	//test setup
+	body := `{"password":"Correct-Horse7"}`
+	plain := sha256.Sum256([]byte(fiber.MethodPost + "\x00/users\x00" + body))
+	fingerprint := func(secret string) string {
+		t.Setenv("IDEMPOTENCY_SECRET", secret)
+		store := &fingerprintStore{Store: idempotency.NewMemoryStore()}
+		app := fiber.New()
+		app.Post("/users", newIdempotencyMiddleware(t, store, nil), ResponseMiddleware(), func(c *fiber.Ctx) error {
+			return c.Status(http.StatusCreated).JSON(fiber.Map{"id": "1"})
+		})
+		doIdempotent(t, app, "k1", body)
+		require.Len(t, store.fingerprints, 1)
+		return store.fingerprints[0]
+	}
+
+	//execution
+	first, second, other := fingerprint("secret"), fingerprint("secret"), fingerprint("other-secret")
+
+	//assertion
+	require.Equal(t, first, second)
+	require.NotEqual(t, first, other)
+	require.NotEqual(t, hex.EncodeToString(plain[:]), first)
+}
+
+func TestIdempotencySecretIsRequired(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_SECRET", "")
+
+	_, err := IdempotencyMiddleware(idempotency.NewMemoryStore(), nil)
+
+	require.ErrorIs(t, err, ErrIdempotencySecretMissing)
+}
+
+// newIdempotencyMiddleware returns the middleware with a test secret unless the secret is set by the test
+func newIdempotencyMiddleware(t *testing.T, store idempotency.Store, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	if os.Getenv("IDEMPOTENCY_SECRET") == "" {
+		t.Setenv("IDEMPOTENCY_SECRET", "test-secret")
+	}
+	middleware, err := IdempotencyMiddleware(store, skip)
+	require.NoError(t, err)
+	return middleware
+}
+
+// doIdempotent sends the body to the app with the idempotency key
+func doIdempotent(t *testing.T, app *fiber.App, key, body string) idempotencyResponse {
+	req := httptest.NewRequest(fiber.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var problem Problem
+	if resp.Header.Get(fiber.HeaderContentType) == MIMEApplicationProblemJSON {
+		require.NoError(t, json.Unmarshal(raw, &problem))
+	}
+	return idempotencyResponse{
+		status:     resp.StatusCode,
+		replayed:   resp.Header.Get(HeaderIdempotentReplayed) == "true",
+		code:       problem.Code,
+		body:       string(raw),
+		retryAfter: resp.Header.Get(fiber.HeaderRetryAfter),
+	}
+}
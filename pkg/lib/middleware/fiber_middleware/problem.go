@@ -0,0 +1,58 @@
+package fiber_middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+const (
+	// MIMEApplicationProblemJSON is the content type of error responses
+	MIMEApplicationProblemJSON = "application/problem+json"
+	// ProblemTypeDefault is the type of problems which don't have more semantics than their status code
+	ProblemTypeDefault = "about:blank"
+)
+
+// Problem is RFC 7807 problem details of error responses. Code is the error code of errwrap catalog.
+type Problem struct {
+	Type     string               `json:"type"`
+	Title    string               `json:"title"`
+	Status   int                  `json:"status"`
+	Detail   string               `json:"detail,omitempty"`
+	Instance string               `json:"instance,omitempty"`
+	Code     string               `json:"code,omitempty"`
+	Errors   []errwrap.FieldError `json:"errors,omitempty"`
+}
+
+// newProblem maps the error to problem details of the request and returns log level of the error.
+//
+// Status and detail of fiber.Error are kept. Other errors are mapped with errwrap.Public so that internals of errors
+// are not responded, code and field errors of errwrap.IError are kept.
+func newProblem(c *fiber.Ctx, err error) (Problem, slog.Level) {
+	problem := Problem{
+		Type:     ProblemTypeDefault,
+		Instance: c.Path(),
+	}
+
+	var iErr errwrap.IError
+	var fiberErr *fiber.Error
+	if !errors.As(err, &iErr) && errors.As(err, &fiberErr) {
+		problem.Status = fiberErr.Code
+		problem.Detail = fiberErr.Message
+	} else {
+		public := errwrap.Public(err)
+		problem.Status = public.HttpCode()
+		problem.Detail = public.ErrorResp().Message
+		problem.Code = public.ErrorResp().Code
+		problem.Errors = public.FieldErrors()
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	if problem.Status >= http.StatusInternalServerError {
+		return problem, slog.LevelError
+	}
+	return problem, slog.LevelWarn
+}
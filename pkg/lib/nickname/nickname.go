@@ -0,0 +1,207 @@
+// Package nickname validates nicknames by a configurable policy and computes their confusable skeletons.
+package nickname
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Errors of nicknames which break the policy
+var (
+	ErrInvalidLength     = errors.New("nickname length is invalid")
+	ErrInvalidCharacters = errors.New("nickname can only have letters, digits and '.', '_', '-' between them")
+	ErrMixedScripts      = errors.New("nickname can't mix letters of different scripts")
+	ErrReserved          = errors.New("nickname is reserved")
+	ErrProfane           = errors.New("nickname isn't allowed")
+)
+
+// defaultReserved are names which can be mistaken for the service or its staff
+var defaultReserved = []string{
+	"admin", "administrator", "root", "system", "sysadmin", "superuser", "support", "help", "helpdesk", "staff",
+	"moderator", "mod", "security", "official", "api", "www", "mail", "postmaster", "webmaster", "hostmaster",
+	"abuse", "noreply", "info", "billing", "team", "owner", "null", "undefined", "anonymous", "everyone",
+}
+
+// defaultProfanity are words which nicknames can't contain. Words which are parts of common words, e.g. of place
+// names, are left out.
+var defaultProfanity = []string{
+	"fuck", "shit", "bitch", "asshole", "bastard", "whore", "slut", "nigger", "faggot",
+}
+
+// Policy validates nicknames
+type Policy struct {
+	minLength int
+	maxLength int
+	// reserved are skeletons of reserved names
+	reserved map[string]bool
+	// profanity are skeletons of profane words
+	profanity []string
+}
+
+// NewPolicy returns Policy configured by:
+//
+// - `NICKNAME_MIN_LENGTH`(default 3) and `NICKNAME_MAX_LENGTH`(default 32) characters
+//
+// - `NICKNAME_RESERVED`: comma separated names which are reserved in addition to the built-in list
+//
+// - `NICKNAME_PROFANITY`: comma separated words which nicknames can't contain in addition to the built-in list
+func NewPolicy() *Policy {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("NICKNAME_MIN_LENGTH", 3)
+	vi.SetDefault("NICKNAME_MAX_LENGTH", 32)
+	vi.SetDefault("NICKNAME_RESERVED", "")
+	vi.SetDefault("NICKNAME_PROFANITY", "")
+
+	return NewPolicyWith(vi.GetInt("NICKNAME_MIN_LENGTH"), vi.GetInt("NICKNAME_MAX_LENGTH"),
+		append(defaultReserved, splitList(vi.GetString("NICKNAME_RESERVED"))...),
+		append(defaultProfanity, splitList(vi.GetString("NICKNAME_PROFANITY"))...))
+}
+
+// NewPolicyWith returns Policy with the given limits and lists. Built-in lists are not included.
+func NewPolicyWith(minLength, maxLength int, reserved, profanity []string) *Policy {
+	p := &Policy{minLength: minLength, maxLength: maxLength, reserved: map[string]bool{}}
+	for _, name := range reserved {
+		if s := Skeleton(name); s != "" {
+			p.reserved[s] = true
+		}
+	}
+	for _, word := range profanity {
+		if s := Skeleton(word); s != "" {
+			p.profanity = append(p.profanity, s)
+		}
+	}
+	return p
+}
+
+// splitList splits the comma separated list
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Normalize returns the NFKC normalized nickname without surrounding spaces so that nicknames which differ only by
+// Unicode composition or compatibility characters, e.g. `ｊｏｈｎ` and `john`, are stored the same.
+func Normalize(nick string) string {
+	return strings.TrimSpace(norm.NFKC.String(nick))
+}
+
+// Validate returns an error when the normalized nickname breaks the policy.
+//
+// A nickname has letters and digits of a single script, optionally separated by single '.', '_' or '-'. Reserved names
+// and profane words are matched by their skeletons so that look-alikes, e.g. `Adm1n` or `a.d.m.i.n`, are rejected too.
+func (p *Policy) Validate(nick string) error {
+	if n := utf8.RuneCountInString(nick); n < p.minLength || n > p.maxLength {
+		return fmt.Errorf("%w: it must be %d to %d characters", ErrInvalidLength, p.minLength, p.maxLength)
+	}
+	if !hasAllowedCharacters(nick) {
+		return ErrInvalidCharacters
+	}
+	if mixesScripts(nick) {
+		return ErrMixedScripts
+	}
+
+	skeleton := Skeleton(nick)
+	if p.reserved[skeleton] {
+		return ErrReserved
+	}
+	for _, word := range p.profanity {
+		if strings.Contains(skeleton, word) {
+			return ErrProfane
+		}
+	}
+	return nil
+}
+
+// hasAllowedCharacters reports whether the nickname has letters, digits and single separators between them
+func hasAllowedCharacters(nick string) bool {
+	previousSeparator := true // nickname can't start with a separator
+	for _, r := range nick {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			previousSeparator = false
+		case isSeparator(r) && !previousSeparator:
+			previousSeparator = true
+		default:
+			return false
+		}
+	}
+	return !previousSeparator
+}
+
+// isSeparator reports whether the character can separate words of nicknames
+func isSeparator(r rune) bool {
+	return r == '.' || r == '_' || r == '-'
+}
+
+// scriptGroups are scripts of letters, scripts which are used together in a language are in the same group
+var scriptGroups = []struct {
+	name    string
+	scripts []*unicode.RangeTable
+}{
+	{"Latin", []*unicode.RangeTable{unicode.Latin}},
+	{"Greek", []*unicode.RangeTable{unicode.Greek}},
+	{"Cyrillic", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"Armenian", []*unicode.RangeTable{unicode.Armenian}},
+	{"Georgian", []*unicode.RangeTable{unicode.Georgian}},
+	{"Hebrew", []*unicode.RangeTable{unicode.Hebrew}},
+	{"Arabic", []*unicode.RangeTable{unicode.Arabic}},
+	{"Devanagari", []*unicode.RangeTable{unicode.Devanagari}},
+	{"Thai", []*unicode.RangeTable{unicode.Thai}},
+	{"Japanese", []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana}},
+	{"Korean", []*unicode.RangeTable{unicode.Han, unicode.Hangul}},
+}
+
+// mixesScripts reports whether letters of the nickname aren't all in one of script groups. Letters of other scripts
+// can't be mixed with any other script.
+func mixesScripts(nick string) bool {
+	var common uint // bits of script groups having all letters so far
+	hasGroup := false
+	other := ""
+	for _, r := range nick {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		var groups uint
+		for i, group := range scriptGroups {
+			if unicode.In(r, group.scripts...) {
+				groups |= 1 << i
+			}
+		}
+		if groups == 0 {
+			script := scriptOf(r)
+			if other != "" && other != script {
+				return true
+			}
+			other = script
+			continue
+		}
+		if !hasGroup {
+			common, hasGroup = groups, true
+		} else if common &= groups; common == 0 {
+			return true
+		}
+	}
+	return hasGroup && other != ""
+}
+
+// scriptOf returns the name of the script of the letter
+func scriptOf(r rune) string {
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
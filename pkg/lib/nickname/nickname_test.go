@@ -0,0 +1,104 @@
+package nickname
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		nick     string
+		expected string
+	}{
+		{" john ", "john"},
+		{"ｊｏｈｎ", "john"},   // fullwidth
+		{"josé", "josé"},  // decomposed é is composed
+		{"ﬁlip", "filip"},  // ligature
+		{"john①", "john1"}, // circled digit
+		{"Jöhn_Doe", "Jöhn_Doe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nick, func(t *testing.T) {
+			require.Equal(t, tt.expected, Normalize(tt.nick))
+		})
+	}
+}
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct {
+		nick     string
+		expected string
+	}{
+		{"john.doe", "johndoe"},
+		{"J0hn_Doe", "johndoe"},
+		{"jоhndое", "johndoe"}, // Cyrillic о and е
+		{"Jöhn-Dóe", "johndoe"},
+		{"АDMIN", "admln"}, // Cyrillic А
+		{"adrn1n", "admln"},
+		{"vvill", "wlll"},
+		{"Ian", "lan"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nick, func(t *testing.T) {
+			require.Equal(t, tt.expected, Skeleton(tt.nick))
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	policy := NewPolicyWith(3, 10, []string{"admin", "support"}, []string{"badword"})
+	tests := []struct {
+		nick string
+		err  error
+	}{
+		{"john", nil},
+		{"john.doe_1", nil},
+		{"Jöhn-Doe", nil},
+		{"иван", nil},
+		{"山田たろう", nil},
+		{"김철수", nil},
+		{"jo", ErrInvalidLength},
+		{"johnjohndoe", ErrInvalidLength},
+		{"john doe", ErrInvalidCharacters},
+		{"john@doe", ErrInvalidCharacters},
+		{"_john", ErrInvalidCharacters},
+		{"john.", ErrInvalidCharacters},
+		{"john..doe", ErrInvalidCharacters},
+		{"jоhn", ErrMixedScripts}, // Cyrillic о
+		{"johnиван", ErrMixedScripts},
+		{"admin", ErrReserved},
+		{"Adm1n", ErrReserved},
+		{"a.d.m.i.n", ErrReserved},
+		{"аdmin", ErrMixedScripts}, // Cyrillic а
+		{"supp0rt", ErrReserved},
+		{"my_badword", ErrProfane},
+		{"BadW0rd1", ErrProfane},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nick, func(t *testing.T) {
+			err := policy.Validate(tt.nick)
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	t.Setenv("NICKNAME_MAX_LENGTH", "5")
+	t.Setenv("NICKNAME_RESERVED", "acme, ceo")
+
+	policy := NewPolicy()
+
+	require.ErrorIs(t, policy.Validate("johndoe"), ErrInvalidLength)
+	require.ErrorIs(t, policy.Validate("Acme"), ErrReserved)
+	require.ErrorIs(t, policy.Validate("root"), ErrReserved)
+	require.ErrorIs(t, policy.Validate("sh1t"), ErrProfane)
+	require.NoError(t, policy.Validate("john"))
+	require.Contains(t, policy.Validate(strings.Repeat("a", 6)).Error(), "3 to 5 characters")
+}
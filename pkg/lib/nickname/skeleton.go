@@ -0,0 +1,45 @@
+package nickname
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps lowercase characters to the Latin letters or digits they look like. It is a subset of confusables
+// of Unicode Technical Standard #39 for letters and digits of Latin, Greek and Cyrillic scripts. Since skeletons are
+// case insensitive, `i`, `I`, `l` and `1` are all mapped to `l`.
+var confusables = map[rune]rune{
+	// Latin and digits
+	'0': 'o', '1': 'l', 'i': 'l', 'ı': 'l', 'ɩ': 'l', 'ſ': 'f', 'ɑ': 'a', 'ɡ': 'g', 'ʏ': 'y',
+	// Greek
+	'α': 'a', 'β': 'b', 'γ': 'y', 'ε': 'e', 'ζ': 'z', 'η': 'n', 'ι': 'l', 'κ': 'k', 'μ': 'u', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'σ': 'o', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ϲ': 'c', 'ϳ': 'j',
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'з': '3', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't',
+	'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'l', 'ј': 'j', 'ԁ': 'd', 'һ': 'h', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l',
+}
+
+// sequences are character sequences which look like a single letter
+var sequences = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// Skeleton returns the form of the nickname which is the same for nicknames that look alike, e.g. `john.doe`,
+// `J0hn_Doe` and `jоhndое` with Cyrillic `о` and `е` have the same skeleton `johndoe`.
+//
+// The nickname is lowercased, confusable characters are replaced with the Latin letters they look like and
+// diacritics and separators are removed. Skeletons are for detecting look-alikes and shouldn't be displayed.
+func Skeleton(nick string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(nick) {
+		if unicode.Is(unicode.Mn, r) || isSeparator(r) || unicode.IsSpace(r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		b.WriteRune(r)
+	}
+	return sequences.Replace(b.String())
+}
@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Dispatcher delivers jobs of the queue by the sender and retries failed deliveries with exponential backoff
+type Dispatcher struct {
+	queue  Queue
+	sender Sender
+	// batchSize is the max number of jobs claimed at once
+	batchSize int
+	// lease is how long claimed jobs are reserved for the dispatcher
+	lease time.Duration
+	// maxAttempts is the number of failed deliveries after which a job is failed
+	maxAttempts int
+	// retryBackoff is the delay after the first failed delivery, it is doubled after every failure up to maxRetryBackoff
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+	// failedRetention is how long failed jobs are kept for inspection before Purge removes them
+	failedRetention time.Duration
+	now             func() time.Time
+}
+
+// NewDispatcher returns Dispatcher of the queue and the sender.
+//
+// Parameters are read from env variables: `NOTIFICATION_BATCH_SIZE`(default `50`), `NOTIFICATION_LEASE`(default
+// `5m`), `NOTIFICATION_MAX_ATTEMPTS`(default `5`), `NOTIFICATION_RETRY_BACKOFF`(default `30s`),
+// `NOTIFICATION_MAX_RETRY_BACKOFF`(default `1h`) and `NOTIFICATION_FAILED_RETENTION`(default `168h`).
+func NewDispatcher(queue Queue, sender Sender) *Dispatcher {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("NOTIFICATION_BATCH_SIZE", 50)
+	vi.SetDefault("NOTIFICATION_LEASE", 5*time.Minute)
+	vi.SetDefault("NOTIFICATION_MAX_ATTEMPTS", 5)
+	vi.SetDefault("NOTIFICATION_RETRY_BACKOFF", 30*time.Second)
+	vi.SetDefault("NOTIFICATION_MAX_RETRY_BACKOFF", time.Hour)
+	vi.SetDefault("NOTIFICATION_FAILED_RETENTION", 7*24*time.Hour)
+
+	return &Dispatcher{
+		queue:           queue,
+		sender:          sender,
+		batchSize:       max(vi.GetInt("NOTIFICATION_BATCH_SIZE"), 1),
+		lease:           vi.GetDuration("NOTIFICATION_LEASE"),
+		maxAttempts:     max(vi.GetInt("NOTIFICATION_MAX_ATTEMPTS"), 1),
+		retryBackoff:    vi.GetDuration("NOTIFICATION_RETRY_BACKOFF"),
+		maxRetryBackoff: vi.GetDuration("NOTIFICATION_MAX_RETRY_BACKOFF"),
+		failedRetention: vi.GetDuration("NOTIFICATION_FAILED_RETENTION"),
+		now:             time.Now,
+	}
+}
+
+// Dispatch delivers due jobs until there is none left. Failed deliveries are retried later, they don't fail
+// Dispatch. Returns error when the queue fails.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	for {
+		jobs, err := d.queue.Claim(ctx, d.batchSize, d.now().Add(d.lease))
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if err := d.deliver(ctx, job); err != nil {
+				return err
+			}
+		}
+		if len(jobs) < d.batchSize || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Purge removes failed jobs older than the failed retention, delivered jobs are removed when they are completed.
+// Failed jobs keep their messages with tokens, so they shouldn't be kept longer than needed.
+func (d *Dispatcher) Purge(ctx context.Context) error {
+	purged, err := d.queue.Purge(ctx, d.now().Add(-d.failedRetention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "failed notifications are purged", slog.Int64("count", purged))
+	}
+	return nil
+}
+
+// deliver sends the job and completes, retries or fails it by the result
+func (d *Dispatcher) deliver(ctx context.Context, job Job) error {
+	sendErr := d.sender.Send(ctx, job.Message)
+	if sendErr == nil {
+		return d.queue.Complete(ctx, job.Id)
+	}
+
+	attempts := job.Attempts + 1
+	if attempts >= d.maxAttempts {
+		slog.ErrorContext(ctx, "notification delivery failed", slog.String("id", job.Id), slog.Int("attempts", attempts), slog.Any("error", sendErr))
+		return d.queue.Fail(ctx, job.Id, sendErr.Error())
+	}
+	nextAttemptAt := d.now().Add(d.backoff(attempts))
+	slog.WarnContext(ctx, "notification delivery will be retried", slog.String("id", job.Id), slog.Int("attempts", attempts),
+		slog.Time("nextAttemptAt", nextAttemptAt), slog.Any("error", sendErr))
+	return d.queue.Retry(ctx, job.Id, nextAttemptAt, sendErr.Error())
+}
+
+// backoff returns the delay after the number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryBackoff
+	for i := 1; i < attempts && delay < d.maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxRetryBackoff)
+}
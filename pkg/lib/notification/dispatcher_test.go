@@ -0,0 +1,121 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender records sent messages and fails the recipients of errs
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []Message
+	errs map[string]error
+}
+
+func (s *fakeSender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.errs[msg.To]; err != nil {
+		return err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func testDispatcher(queue Queue, sender Sender, now func() time.Time) *Dispatcher {
+	return &Dispatcher{
+		queue:           queue,
+		sender:          sender,
+		batchSize:       2,
+		lease:           time.Minute,
+		maxAttempts:     3,
+		retryBackoff:    time.Minute,
+		maxRetryBackoff: 3 * time.Minute,
+		failedRetention: time.Hour,
+		now:             now,
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	//test setup
+	ctx := context.Background()
+	queue := NewMemoryQueue().(*memoryQueue)
+	now := time.Now()
+	queue.now = func() time.Time { return now }
+	sender := &fakeSender{errs: map[string]error{"jane@email.com": errors.New("mailbox is full")}}
+	dispatcher := testDispatcher(queue, sender, func() time.Time { return now })
+	for _, to := range []string{"john@email.com", "jane@email.com", "joe@email.com"} {
+		require.NoError(t, queue.Enqueue(ctx, Message{To: to, Subject: "Hello", Text: "Hello"}))
+	}
+
+	//execution
+	require.NoError(t, dispatcher.Dispatch(ctx))
+
+	//assertion
+	require.Len(t, sender.sent, 2)
+	require.Len(t, queue.jobs, 1)
+	var failed *memoryJob
+	for _, job := range queue.jobs {
+		failed = job
+	}
+	require.Equal(t, "jane@email.com", failed.Message.To)
+	require.Equal(t, 1, failed.Attempts)
+	require.Equal(t, "mailbox is full", failed.LastError)
+	require.Equal(t, now.Add(time.Minute), failed.nextAttemptAt)
+
+	// the job is retried with backoff and failed after max attempts
+	now = now.Add(time.Minute)
+	require.NoError(t, dispatcher.Dispatch(ctx))
+	require.Equal(t, 2, failed.Attempts)
+	require.Equal(t, now.Add(2*time.Minute), failed.nextAttemptAt)
+
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, dispatcher.Dispatch(ctx))
+	require.Equal(t, 3, failed.Attempts)
+	require.Equal(t, JobStatusFailed, failed.status)
+
+	// the sender recovers but failed jobs aren't delivered
+	delete(sender.errs, "jane@email.com")
+	now = now.Add(time.Hour)
+	require.NoError(t, dispatcher.Dispatch(ctx))
+	require.Len(t, sender.sent, 2)
+
+	// failed jobs are purged after the retention
+	require.NoError(t, dispatcher.Purge(ctx))
+	require.Empty(t, queue.jobs)
+}
+
+func TestBackoff(t *testing.T) {
+	dispatcher := testDispatcher(nil, nil, time.Now)
+	for attempts, expected := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 3 * time.Minute, 10: 3 * time.Minute} {
+		require.Equal(t, expected, dispatcher.backoff(attempts), "attempts %d", attempts)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	//test setup
+	dir := filepath.Join(t.TempDir(), "notifications")
+	sender, err := NewFileSender(dir, "no-reply@userapi.com")
+	require.NoError(t, err)
+
+	//execution
+	err = sender.Send(context.Background(), Message{To: "john@email.com", Subject: "Hello", Text: "Hello John"})
+
+	//assertion
+	require.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(content), "Subject: Hello\r\n"))
+	require.True(t, strings.Contains(string(content), "\r\n\r\nHello John"))
+}
@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+)
+
+// encryptedQueue encrypts recipients and bodies of messages before they are stored by the underlying Queue. Bodies
+// contain tokens of users such as password reset tokens which shouldn't be readable from the storage.
+type encryptedQueue struct {
+	Queue
+	encryptor *crypt.EnvelopeEncryptor
+}
+
+// NewEncryptedQueue returns Queue storing messages in the queue with `To`, `Text` and `HTML` encrypted by the
+// encryptor. Messages which were stored in plaintext are still delivered.
+func NewEncryptedQueue(queue Queue, encryptor *crypt.EnvelopeEncryptor) Queue {
+	return &encryptedQueue{Queue: queue, encryptor: encryptor}
+}
+
+func (q *encryptedQueue) Enqueue(ctx context.Context, msg Message) error {
+	for _, field := range []*string{&msg.To, &msg.Text, &msg.HTML} {
+		if *field == "" {
+			continue
+		}
+		encrypted, err := q.encryptor.Encrypt(*field)
+		if err != nil {
+			return err
+		}
+		*field = encrypted
+	}
+	return q.Queue.Enqueue(ctx, msg)
+}
+
+// Claim decrypts messages of claimed jobs. Jobs which can't be decrypted, e.g. their key is removed, are failed
+// since they can't be delivered by retrying.
+func (q *encryptedQueue) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]Job, error) {
+	jobs, err := q.Queue.Claim(ctx, limit, leaseUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		if err := q.decrypt(&job.Message); err != nil {
+			slog.ErrorContext(ctx, "notification can't be decrypted", slog.String("id", job.Id), slog.Any("error", err))
+			if err := q.Queue.Fail(ctx, job.Id, "message can't be decrypted"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		decrypted = append(decrypted, job)
+	}
+	return decrypted, nil
+}
+
+// decrypt decrypts the fields of the message encrypted by Enqueue
+func (q *encryptedQueue) decrypt(msg *Message) error {
+	for _, field := range []*string{&msg.To, &msg.Text, &msg.HTML} {
+		plaintext, err := q.encryptor.Decrypt(*field)
+		if err != nil {
+			return err
+		}
+		*field = plaintext
+	}
+	return nil
+}
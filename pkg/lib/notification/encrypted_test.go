@@ -0,0 +1,81 @@
+package notification_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/pkg/lib/crypt"
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+	"github.com/nsaltun/userapi/pkg/lib/notification/notificationtest"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys is a crypt.KeyProvider for tests, keys other than the current one are unknown
+type testKeys struct{}
+
+func (testKeys) CurrentKeyId() string { return "test" }
+func (testKeys) Key(id string) ([]byte, error) {
+	if id != "test" {
+		return nil, errors.New("unknown key")
+	}
+	return bytes.Repeat([]byte{1}, crypt.KeySize), nil
+}
+func (testKeys) BlindIndexKey() []byte { return bytes.Repeat([]byte{2}, crypt.KeySize) }
+
+func TestEncryptedQueueSuite(t *testing.T) {
+	notificationtest.RunQueueSuite(t, func(t *testing.T) notification.Queue {
+		return notification.NewEncryptedQueue(notification.NewMemoryQueue(), crypt.NewEnvelopeEncryptor(testKeys{}))
+	})
+}
+
+func TestEncryptedQueue(t *testing.T) {
+	//test setup
+	ctx := context.Background()
+	msg := notification.Message{To: "john@email.com", Subject: "Reset your password", Text: "token: abc", HTML: "<p>token: abc</p>"}
+	plaintext := notification.Message{To: "jane@email.com", Subject: "Welcome", Text: "Hello Jane"}
+
+	t.Run("messages are stored encrypted", func(tt *testing.T) {
+		stored := notification.NewMemoryQueue()
+		queue := notification.NewEncryptedQueue(stored, crypt.NewEnvelopeEncryptor(testKeys{}))
+
+		//execution
+		require.NoError(tt, queue.Enqueue(ctx, msg))
+
+		//assertion
+		jobs, err := stored.Claim(ctx, 10, time.Now().Add(time.Minute))
+		require.NoError(tt, err)
+		require.Len(tt, jobs, 1)
+		require.Equal(tt, msg.Subject, jobs[0].Message.Subject)
+		for _, field := range []string{jobs[0].Message.To, jobs[0].Message.Text, jobs[0].Message.HTML} {
+			require.True(tt, crypt.IsEncrypted(field))
+		}
+	})
+
+	t.Run("plaintext messages are delivered and undecryptable messages are failed", func(tt *testing.T) {
+		stored := notification.NewMemoryQueue()
+		queue := notification.NewEncryptedQueue(stored, crypt.NewEnvelopeEncryptor(testKeys{}))
+		require.NoError(tt, queue.Enqueue(ctx, msg))
+		require.NoError(tt, stored.Enqueue(ctx, plaintext))
+		require.NoError(tt, stored.Enqueue(ctx, notification.Message{To: "enc:v1:old:a:b", Subject: "Welcome", Text: "Hello Joe"}))
+
+		//execution
+		jobs, err := queue.Claim(ctx, 10, time.Now().Add(100*time.Millisecond))
+
+		//assertion
+		require.NoError(tt, err)
+		delivered := []notification.Message{}
+		for _, job := range jobs {
+			delivered = append(delivered, job.Message)
+		}
+		require.ElementsMatch(tt, []notification.Message{msg, plaintext}, delivered)
+
+		// the failed message isn't claimed again after the lease
+		time.Sleep(200 * time.Millisecond)
+		again, err := stored.Claim(ctx, 10, time.Now().Add(time.Minute))
+		require.NoError(tt, err)
+		require.Len(tt, again, 2)
+	})
+}
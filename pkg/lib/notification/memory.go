@@ -0,0 +1,109 @@
+package notification
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryJob is a job with its state in memoryQueue
+type memoryJob struct {
+	Job
+	status        string
+	nextAttemptAt time.Time
+}
+
+// memoryQueue is an in-memory implementor of Queue
+type memoryQueue struct {
+	mu   sync.Mutex
+	jobs map[string]*memoryJob
+	now  func() time.Time
+}
+
+// NewMemoryQueue returns Queue keeping messages in memory. Messages which aren't delivered are lost on shutdown.
+func NewMemoryQueue() Queue {
+	return &memoryQueue{jobs: map[string]*memoryJob{}, now: time.Now}
+}
+
+func (q *memoryQueue) Enqueue(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	id := uuid.NewString()
+	q.jobs[id] = &memoryJob{Job: Job{Id: id, Message: msg, CreatedAt: now}, status: JobStatusPending, nextAttemptAt: now}
+	return nil
+}
+
+func (q *memoryQueue) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	due := []*memoryJob{}
+	for _, job := range q.jobs {
+		if job.status == JobStatusPending && !job.nextAttemptAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].nextAttemptAt.Before(due[j].nextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]Job, 0, len(due))
+	for _, job := range due {
+		job.nextAttemptAt = leaseUntil
+		claimed = append(claimed, job.Job)
+	}
+	return claimed, nil
+}
+
+func (q *memoryQueue) Complete(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.jobs, id)
+	return nil
+}
+
+func (q *memoryQueue) Retry(ctx context.Context, id string, nextAttemptAt time.Time, lastError string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job, ok := q.jobs[id]; ok {
+		job.Attempts++
+		job.LastError = lastError
+		job.nextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
+
+func (q *memoryQueue) Fail(ctx context.Context, id string, lastError string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job, ok := q.jobs[id]; ok {
+		job.Attempts++
+		job.LastError = lastError
+		job.status = JobStatusFailed
+	}
+	return nil
+}
+
+func (q *memoryQueue) Purge(ctx context.Context, before time.Time) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var purged int64
+	for id, job := range q.jobs {
+		if job.status == JobStatusFailed && job.CreatedAt.Before(before) {
+			delete(q.jobs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
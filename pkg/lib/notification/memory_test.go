@@ -0,0 +1,14 @@
+package notification_test
+
+import (
+	"testing"
+
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+	"github.com/nsaltun/userapi/pkg/lib/notification/notificationtest"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	notificationtest.RunQueueSuite(t, func(t *testing.T) notification.Queue {
+		return notification.NewMemoryQueue()
+	})
+}
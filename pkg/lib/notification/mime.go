@@ -0,0 +1,102 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// envelope is the message encoded to be sent from an address
+type envelope struct {
+	from *mail.Address
+	to   *mail.Address
+	data []byte
+}
+
+// encode parses addresses and encodes the message in RFC 5322 format. Text and HTML are alternative MIME parts.
+func encode(from string, msg Message, date time.Time) (*envelope, error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	toAddr, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	var buf bytes.Buffer
+	// header values other than the subject are generated or parsed, so they can't inject headers
+	writeHeader(&buf, "From", fromAddr.String())
+	writeHeader(&buf, "To", toAddr.String())
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageId(fromAddr.Address))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		writeHeader(&buf, "Content-Type", "text/plain; charset=utf-8")
+		writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return &envelope{from: fromAddr, to: toAddr, data: buf.Bytes()}, nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	// parts are in increasing order of preference
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return &envelope{from: fromAddr, to: toAddr, data: buf.Bytes()}, nil
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key + ": " + value + "\r\n")
+}
+
+// writeQuotedPrintable writes the body with CRLF line endings in quoted-printable encoding
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageId returns a unique Message-ID on the domain of the sender address
+func messageId(from string) string {
+	_, domain, _ := strings.Cut(from, "@")
+	if domain == "" {
+		domain = "localhost"
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,66 @@
+// Package notification renders messages from localized templates and delivers them by a Sender.
+//
+// Messages are enqueued to a persistent Queue and delivered by a Dispatcher in the background, so that callers don't
+// wait for or fail by the delivery channel:
+//
+//	msg, err := templates.Render("password_reset", "tr", data)
+//	msg.To = user.Email
+//	err = queue.Enqueue(ctx, msg)
+//
+//	dispatcher := notification.NewDispatcher(queue, sender)
+//	err = dispatcher.Dispatch(ctx) // periodically, see job.Periodic
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/viper"
+)
+
+// Supported values of `NOTIFICATION_SENDER` config
+const (
+	SenderLog  = "log"
+	SenderFile = "file"
+	SenderSMTP = "smtp"
+)
+
+// Message is an email message. HTML is optional, Text is the alternative of clients which don't display HTML.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers messages by a channel
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewSender returns the Sender of `NOTIFICATION_SENDER`(default `log`).
+//
+// Messages are sent from `NOTIFICATION_FROM`(default `no-reply@localhost`). The file sender writes messages to
+// `NOTIFICATION_FILE_DIR`(default `notifications`), the SMTP sender is configured as in NewSMTPSender.
+func NewSender() (Sender, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("NOTIFICATION_SENDER", SenderLog)
+	vi.SetDefault("NOTIFICATION_FROM", "no-reply@localhost")
+	vi.SetDefault("NOTIFICATION_FILE_DIR", "notifications")
+
+	from := vi.GetString("NOTIFICATION_FROM")
+	switch sender := vi.GetString("NOTIFICATION_SENDER"); sender {
+	case SenderLog:
+		slog.Warn("Notifications are logged together with their tokens, don't use it in production.")
+		return NewLogSender(), nil
+	case SenderFile:
+		slog.Warn("Notifications are written to files together with their tokens, don't use it in production.")
+		return NewFileSender(vi.GetString("NOTIFICATION_FILE_DIR"), from)
+	case SenderSMTP:
+		return NewSMTPSender(from)
+	default:
+		return nil, fmt.Errorf("unknown notification sender %q", sender)
+	}
+}
@@ -0,0 +1,212 @@
+// Package notificationtest provides a conformance test suite for notification.Queue implementations.
+//
+//	func TestMyQueue(t *testing.T) {
+//		notificationtest.RunQueueSuite(t, func(t *testing.T) notification.Queue {
+//			return newEmptyQueue(t)
+//		})
+//	}
+package notificationtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsaltun/userapi/pkg/lib/notification"
+	"github.com/stretchr/testify/require"
+)
+
+// QueueFactory returns an empty queue for each test case. Cleanup can be registered with t.Cleanup.
+type QueueFactory func(t *testing.T) notification.Queue
+
+// RunQueueSuite runs the Queue contract tests against queues created by the factory.
+func RunQueueSuite(t *testing.T, newQueue QueueFactory) {
+	tests := []struct {
+		name string
+		run  func(*testing.T, notification.Queue)
+	}{
+		{"claim returns enqueued message", testClaim},
+		{"claim is limited", testClaimLimit},
+		{"claimed job is claimed again after lease", testClaimLease},
+		{"complete removes the job", testComplete},
+		{"retry delays the job", testRetry},
+		{"failed job isn't claimed", testFail},
+		{"purge removes failed jobs", testPurge},
+		{"concurrent claims claim once", testConcurrentClaim},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			tCase.run(tt, newQueue(tt))
+		})
+	}
+}
+
+func testClaim(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	msg := newMessage(1)
+	require.NoError(t, queue.Enqueue(ctx, msg))
+
+	jobs, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.NotEmpty(t, jobs[0].Id)
+	require.Equal(t, msg, jobs[0].Message)
+	require.Zero(t, jobs[0].Attempts)
+	require.WithinDuration(t, time.Now(), jobs[0].CreatedAt, time.Minute)
+
+	jobs, err = queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+}
+
+func testClaimLimit(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, queue.Enqueue(ctx, newMessage(i)))
+	}
+
+	jobs, err := queue.Claim(ctx, 2, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+
+	jobs, err = queue.Claim(ctx, 2, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+}
+
+func testClaimLease(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	require.NoError(t, queue.Enqueue(ctx, newMessage(1)))
+
+	jobs, err := queue.Claim(ctx, 10, time.Now().Add(100*time.Millisecond))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	time.Sleep(200 * time.Millisecond)
+	again, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	require.Equal(t, jobs[0].Id, again[0].Id)
+}
+
+func testComplete(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	require.NoError(t, queue.Enqueue(ctx, newMessage(1)))
+	jobs, err := queue.Claim(ctx, 10, time.Now().Add(100*time.Millisecond))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	require.NoError(t, queue.Complete(ctx, jobs[0].Id))
+
+	time.Sleep(200 * time.Millisecond)
+	jobs, err = queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+}
+
+func testRetry(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	require.NoError(t, queue.Enqueue(ctx, newMessage(1)))
+	jobs, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	require.NoError(t, queue.Retry(ctx, jobs[0].Id, time.Now().Add(time.Hour), "mailbox is full"))
+	delayed, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, delayed)
+
+	require.NoError(t, queue.Retry(ctx, jobs[0].Id, time.Now().Add(-time.Second), "mailbox is full again"))
+	retried, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	require.Equal(t, jobs[0].Id, retried[0].Id)
+	require.Equal(t, 2, retried[0].Attempts)
+	require.Equal(t, "mailbox is full again", retried[0].LastError)
+}
+
+func testFail(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	require.NoError(t, queue.Enqueue(ctx, newMessage(1)))
+	jobs, err := queue.Claim(ctx, 10, time.Now().Add(100*time.Millisecond))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	require.NoError(t, queue.Fail(ctx, jobs[0].Id, "no such user"))
+
+	time.Sleep(200 * time.Millisecond)
+	jobs, err = queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+}
+
+func testPurge(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	require.NoError(t, queue.Enqueue(ctx, newMessage(1)))
+	require.NoError(t, queue.Enqueue(ctx, newMessage(2)))
+	jobs, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	require.NoError(t, queue.Fail(ctx, jobs[0].Id, "no such user"))
+	require.NoError(t, queue.Retry(ctx, jobs[1].Id, time.Now().Add(-time.Second), "mailbox is full"))
+
+	purged, err := queue.Purge(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, purged)
+	purged, err = queue.Purge(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+
+	// pending jobs aren't purged
+	pending, err := queue.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, jobs[1].Id, pending[0].Id)
+}
+
+func testConcurrentClaim(t *testing.T, queue notification.Queue) {
+	ctx := context.Background()
+	const messages = 20
+	for i := 0; i < messages; i++ {
+		require.NoError(t, queue.Enqueue(ctx, newMessage(i)))
+	}
+
+	const workers = 5
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := map[string]int{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobs, err := queue.Claim(ctx, messages, time.Now().Add(time.Minute))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, job := range jobs {
+				claimed[job.Id]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, claimed, messages)
+	for id, count := range claimed {
+		require.Equal(t, 1, count, "job %s is claimed more than once", id)
+	}
+}
+
+// newMessage returns a message with both text and HTML bodies
+func newMessage(i int) notification.Message {
+	return notification.Message{
+		To:      fmt.Sprintf("john%d@email.com", i),
+		Subject: "Welcome",
+		Text:    "Hello John",
+		HTML:    "<p>Hello John</p>",
+	}
+}
@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"context"
+	"time"
+)
+
+// Statuses of jobs in Queue
+const (
+	JobStatusPending = "pending"
+	JobStatusFailed  = "failed"
+)
+
+// Job is a message in the queue
+type Job struct {
+	Id      string
+	Message Message
+	// Attempts is the number of failed deliveries
+	Attempts int
+	// LastError is the error of the last failed delivery
+	LastError string
+	CreatedAt time.Time
+}
+
+// Queue stores messages until they are delivered. Jobs are delivered at least once, a job can be delivered again
+// when its dispatcher stops or its lease expires before the job is completed.
+type Queue interface {
+	// Enqueue adds the message to be delivered as soon as possible
+	Enqueue(ctx context.Context, msg Message) error
+
+	// Claim leases at most limit pending jobs which are due, oldest first, until leaseUntil. Leased jobs aren't
+	// claimed again until the lease expires, so that they are retried if their dispatcher stops.
+	Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]Job, error)
+
+	// Complete removes the delivered job
+	Complete(ctx context.Context, id string) error
+
+	// Retry records the failed attempt and makes the job due again at nextAttemptAt
+	Retry(ctx context.Context, id string, nextAttemptAt time.Time, lastError string) error
+
+	// Fail records the failed attempt and keeps the job as failed, it isn't claimed again
+	Fail(ctx context.Context, id string, lastError string) error
+
+	// Purge removes failed jobs created before the time and returns the number of removed jobs
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// logSender is the Sender when there is no delivery channel
+type logSender struct{}
+
+// NewLogSender returns Sender which logs messages. It is meant for development only since anyone reading logs can
+// read the messages, including their tokens.
+func NewLogSender() Sender {
+	return logSender{}
+}
+
+func (logSender) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "notification is sent", slog.String("to", msg.To), slog.String("subject", msg.Subject), slog.String("text", msg.Text))
+	return nil
+}
+
+// fileSender writes messages to files
+type fileSender struct {
+	dir  string
+	from string
+	now  func() time.Time
+}
+
+// NewFileSender returns Sender which writes every message to an `.eml` file in the directory, which email clients
+// can open. The directory is created if it doesn't exist. It is meant for development only.
+func NewFileSender(dir string, from string) (Sender, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileSender{dir: dir, from: from, now: time.Now}, nil
+}
+
+func (s *fileSender) Send(ctx context.Context, msg Message) error {
+	now := s.now()
+	env, err := encode(s.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.dir, fmt.Sprintf("%s-*.eml", now.UTC().Format("20060102T150405")))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(env.data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "notification is written", slog.String("file", filepath.Base(f.Name())))
+	return nil
+}
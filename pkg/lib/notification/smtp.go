@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// smtpSender sends messages to an SMTP server
+type smtpSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+	// timeout limits a delivery when ctx has no deadline
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// NewSMTPSender returns Sender which sends messages from the address to the SMTP server of `SMTP_HOST` and
+// `SMTP_PORT`(default `587`).
+//
+// Connections are upgraded by STARTTLS when the server supports it. `SMTP_USERNAME` and `SMTP_PASSWORD` are used for
+// PLAIN authentication when they are set, which is allowed over TLS or to localhost only. Deliveries time out after
+// `SMTP_TIMEOUT`(default `30s`).
+func NewSMTPSender(from string) (Sender, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	vi.SetDefault("SMTP_PORT", 587)
+	vi.SetDefault("SMTP_TIMEOUT", 30*time.Second)
+
+	host := vi.GetString("SMTP_HOST")
+	if host == "" {
+		return nil, errors.New("SMTP_HOST is not set")
+	}
+	s := &smtpSender{
+		addr:    net.JoinHostPort(host, strconv.Itoa(vi.GetInt("SMTP_PORT"))),
+		host:    host,
+		from:    from,
+		timeout: vi.GetDuration("SMTP_TIMEOUT"),
+		now:     time.Now,
+	}
+	if username := vi.GetString("SMTP_USERNAME"); username != "" {
+		s.auth = smtp.PlainAuth("", username, vi.GetString("SMTP_PASSWORD"), host)
+	}
+	return s, nil
+}
+
+// Send delivers the message in a new connection. ctx limits the whole delivery.
+func (s *smtpSender) Send(ctx context.Context, msg Message) error {
+	env, err := encode(s.from, msg, s.now())
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return s.deliver(c, env)
+}
+
+// deliver runs the SMTP transaction of the envelope on the client
+func (s *smtpSender) deliver(c *smtp.Client, env *envelope) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(env.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(env.to.Address); err != nil {
+		return fmt.Errorf("recipient is rejected: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(env.data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,212 @@
+package notification
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStandIn is a local SMTP server which accepts messages without TLS and authentication
+type smtpStandIn struct {
+	listener net.Listener
+	// rejectRcpt rejects recipients when it is set
+	rejectRcpt bool
+
+	mu       sync.Mutex
+	from     string
+	to       []string
+	messages [][]byte
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(textproto.NewConn(conn))
+	}
+}
+
+func (s *smtpStandIn) handle(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250-localhost")
+			c.PrintfLine("250 HELP")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rejectRcpt {
+				c.PrintfLine("550 no such user")
+				continue
+			}
+			s.mu.Lock()
+			s.to = append(s.to, arg)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *smtpStandIn) sender(t *testing.T) Sender {
+	t.Setenv("SMTP_HOST", "127.0.0.1")
+	t.Setenv("SMTP_PORT", strings.TrimPrefix(s.listener.Addr().String(), "127.0.0.1:"))
+	sender, err := NewSMTPSender("User API <no-reply@userapi.com>")
+	require.NoError(t, err)
+	return sender
+}
+
+func TestSMTPSender(t *testing.T) {
+	//test setup
+	server := newSMTPStandIn(t)
+	sender := server.sender(t)
+	msg := Message{To: "John Doe <john@email.com>", Subject: "Şifre sıfırlama", Text: "Hello John\nBye", HTML: "<p>Hello John</p>"}
+
+	//execution
+	err := sender.Send(context.Background(), msg)
+
+	//assertion
+	require.NoError(t, err)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	require.Equal(t, "FROM:<no-reply@userapi.com>", server.from)
+	require.Equal(t, []string{"TO:<john@email.com>"}, server.to)
+	require.Len(t, server.messages, 1)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(server.messages[0])))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "Şifre sıfırlama", subject)
+	require.Equal(t, `"John Doe" <john@email.com>`, parsed.Header.Get("To"))
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, body string }{
+		// line endings of the data are normalized by the stand-in
+		{"text/plain; charset=utf-8", "Hello John\nBye"},
+		{"text/html; charset=utf-8", "<p>Hello John</p>"},
+	} {
+		part, err := parts.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+		// quoted-printable parts are decoded by the reader
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, expected.body, string(body))
+	}
+}
+
+func TestSMTPSenderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    Message
+		reject bool
+	}{
+		{
+			name:   "recipient is rejected",
+			msg:    Message{To: "john@email.com", Subject: "Hello", Text: "Hello"},
+			reject: true,
+		},
+		{
+			name: "recipient can't inject headers",
+			msg:  Message{To: "john@email.com\r\nBcc: jane@email.com", Subject: "Hello", Text: "Hello"},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			server := newSMTPStandIn(tt)
+			server.rejectRcpt = tCase.reject
+			sender := server.sender(tt)
+
+			//execution
+			err := sender.Send(context.Background(), tCase.msg)
+
+			//assertion
+			require.Error(tt, err)
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			require.Empty(tt, server.messages)
+		})
+	}
+}
+
+func TestSMTPSenderTimeout(t *testing.T) {
+	//test setup
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	// the server accepts connections and never greets
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	sender := (&smtpStandIn{listener: listener}).sender(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	//execution
+	err = sender.Send(ctx, Message{To: "john@email.com", Subject: "Hello", Text: "Hello"})
+
+	//assertion
+	require.Error(t, err)
+}
+
+func TestNewSMTPSenderWithoutHost(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	_, err := NewSMTPSender("no-reply@userapi.com")
+	require.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Suffixes of template files
+const (
+	subjectSuffix = ".subject.tmpl"
+	textSuffix    = ".txt.tmpl"
+	htmlSuffix    = ".html.tmpl"
+)
+
+// Templates renders messages from templates in directories of locales:
+//
+//	en/password_reset.subject.tmpl
+//	en/password_reset.txt.tmpl
+//	en/password_reset.html.tmpl
+//	tr/password_reset.subject.tmpl
+//	...
+//
+// Subject and text templates are required, the HTML template is optional. Templates which a locale doesn't have are
+// rendered in the default locale.
+type Templates struct {
+	defaultLocale string
+	locales       map[string]*localeTemplates
+}
+
+// localeTemplates are templates of a locale
+type localeTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// NewTemplates parses templates of the file system. Returns error when a template can't be parsed or there is no
+// template of the default locale.
+func NewTemplates(fsys fs.FS, defaultLocale string) (*Templates, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{defaultLocale: defaultLocale, locales: map[string]*localeTemplates{}}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale := entry.Name()
+		templates, err := parseLocale(fsys, locale)
+		if err != nil {
+			return nil, fmt.Errorf("parsing templates of locale %q: %w", locale, err)
+		}
+		t.locales[strings.ToLower(locale)] = templates
+	}
+	if _, ok := t.locales[strings.ToLower(defaultLocale)]; !ok {
+		return nil, fmt.Errorf("no templates of default locale %q", defaultLocale)
+	}
+	return t, nil
+}
+
+// parseLocale parses templates in the directory of the locale
+func parseLocale(fsys fs.FS, locale string) (*localeTemplates, error) {
+	templates := &localeTemplates{text: texttemplate.New(locale), html: htmltemplate.New(locale)}
+	files, err := fs.ReadDir(fsys, locale)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".tmpl") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(locale, name))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(name, htmlSuffix) {
+			_, err = templates.html.New(name).Parse(string(content))
+		} else {
+			_, err = templates.text.New(name).Parse(string(content))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// Render renders the message of the template in the locale with the data. Locales are matched case-insensitively
+// and by their language when there is no template of the region, e.g. `pt-BR` is rendered in `pt`.
+//
+// To of the message is left empty.
+func (t *Templates) Render(name, locale string, data any) (Message, error) {
+	templates := t.lookup(name, locale)
+	if templates == nil {
+		return Message{}, fmt.Errorf("no template %q", name)
+	}
+
+	var msg Message
+	subject, err := executeText(templates.text, name+subjectSuffix, data)
+	if err != nil {
+		return Message{}, err
+	}
+	// subject is a single line header
+	msg.Subject = strings.Join(strings.Fields(subject), " ")
+	if msg.Text, err = executeText(templates.text, name+textSuffix, data); err != nil {
+		return Message{}, err
+	}
+	if html := templates.html.Lookup(name + htmlSuffix); html != nil {
+		var buf bytes.Buffer
+		if err := html.Execute(&buf, data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+// lookup returns templates of the locale, its language or the default locale which have the template
+func (t *Templates) lookup(name, locale string) *localeTemplates {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	language, _, _ := strings.Cut(locale, "-")
+	for _, candidate := range []string{locale, language, strings.ToLower(t.defaultLocale)} {
+		if templates, ok := t.locales[candidate]; ok && templates.text.Lookup(name+subjectSuffix) != nil {
+			return templates
+		}
+	}
+	return nil
+}
+
+// executeText executes the text template of the name, which should exist
+func executeText(templates *texttemplate.Template, name string, data any) (string, error) {
+	tmpl := templates.Lookup(name)
+	if tmpl == nil {
+		return "", fmt.Errorf("no template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,93 @@
+package notification
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testTemplates(t *testing.T) *Templates {
+	templates, err := NewTemplates(fstest.MapFS{
+		"en/welcome.subject.tmpl": {Data: []byte("Welcome\n{{.Name}}\n")},
+		"en/welcome.txt.tmpl":     {Data: []byte("Hello {{.Name}}")},
+		"en/welcome.html.tmpl":    {Data: []byte("<p>Hello {{.Name}}</p>")},
+		"en/plain.subject.tmpl":   {Data: []byte("Plain")},
+		"en/plain.txt.tmpl":       {Data: []byte("Plain text")},
+		"tr/welcome.subject.tmpl": {Data: []byte("Hoş geldin {{.Name}}")},
+		"tr/welcome.txt.tmpl":     {Data: []byte("Merhaba {{.Name}}")},
+	}, "en")
+	require.NoError(t, err)
+	return templates
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		locale   string
+		data     any
+		expected Message
+	}{
+		{
+			name:     "text and html of the locale",
+			template: "welcome",
+			locale:   "en",
+			data:     map[string]string{"Name": "John"},
+			expected: Message{Subject: "Welcome John", Text: "Hello John", HTML: "<p>Hello John</p>"},
+		},
+		{
+			name:     "html is escaped",
+			template: "welcome",
+			locale:   "en",
+			data:     map[string]string{"Name": "<b>John</b>"},
+			expected: Message{Subject: "Welcome <b>John</b>", Text: "Hello <b>John</b>", HTML: "<p>Hello &lt;b&gt;John&lt;/b&gt;</p>"},
+		},
+		{
+			name:     "locale is matched by its language",
+			template: "welcome",
+			locale:   "TR_tr",
+			data:     map[string]string{"Name": "Ahmet"},
+			expected: Message{Subject: "Hoş geldin Ahmet", Text: "Merhaba Ahmet"},
+		},
+		{
+			name:     "unknown locale is rendered in default locale",
+			template: "welcome",
+			locale:   "de",
+			data:     map[string]string{"Name": "John"},
+			expected: Message{Subject: "Welcome John", Text: "Hello John", HTML: "<p>Hello John</p>"},
+		},
+		{
+			name:     "template missing in the locale is rendered in default locale",
+			template: "plain",
+			locale:   "tr",
+			expected: Message{Subject: "Plain", Text: "Plain text"},
+		},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//test setup
+			templates := testTemplates(tt)
+
+			//execution
+			msg, err := templates.Render(tCase.template, tCase.locale, tCase.data)
+
+			//assertion
+			require.NoError(tt, err)
+			require.Equal(tt, tCase.expected, msg)
+		})
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	_, err := testTemplates(t).Render("unknown", "en", nil)
+	require.Error(t, err)
+}
+
+func TestNewTemplatesErrors(t *testing.T) {
+	_, err := NewTemplates(fstest.MapFS{"tr/welcome.subject.tmpl": {Data: []byte("Hoş geldin")}}, "en")
+	require.ErrorContains(t, err, "default locale")
+
+	_, err = NewTemplates(fstest.MapFS{"en/welcome.subject.tmpl": {Data: []byte("{{.Name")}}, "en")
+	require.ErrorContains(t, err, `locale "en"`)
+}
@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// refPrefix is the prefix of references to component schemas
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generator generates JSON schemas of Go types by reflection with respect to `json` struct tags.
+//
+// Named struct types are added to components and referenced by `$ref`. Embedded structs without json name
+// are flattened into the parent like encoding/json does.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// NewGenerator returns a generator with empty components
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas: map[string]*Schema{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+// Components returns schemas of named structs generated so far
+func (g *Generator) Components() map[string]*Schema {
+	return g.schemas
+}
+
+// Schema returns the schema of the type. It is a reference for named structs.
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return &Schema{Ref: refPrefix + g.component(t)}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(t.Elem())}
+	case reflect.Struct:
+		return g.Object(t, nil)
+	}
+	// interfaces accept any value
+	return &Schema{}
+}
+
+// Object returns an inline object schema of the struct type. Fields for which skip returns true are not included.
+func (g *Generator) Object(t reflect.Type, skip func(Field) bool) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range Fields(t) {
+		if skip != nil && skip(f) {
+			continue
+		}
+		schema.Properties[f.Name] = g.Schema(f.Type)
+	}
+	return schema
+}
+
+// component adds the named struct to components if it isn't added yet and returns its component name.
+//
+// Types having the same name in different packages are prefixed with the package name.
+func (g *Generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + name
+	}
+	g.names[t] = name
+	// placeholder for recursive types
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.Object(t, nil)
+	return name
+}
+
+// Field is a json field of a struct
+type Field struct {
+	// Name is the json name of the field
+	Name string
+	Type reflect.Type
+	Tag  reflect.StructTag
+}
+
+// Fields returns json fields of the struct type including fields of embedded structs
+func Fields(t reflect.Type) []Field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, Fields(ft)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, Field{Name: name, Type: sf.Type, Tag: sf.Tag})
+	}
+	return fields
+}
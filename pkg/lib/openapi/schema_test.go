@@ -0,0 +1,62 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type embedded struct {
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type node struct {
+	Name     string            `json:"name"`
+	Secret   string            `json:"-"`
+	Children []*node           `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Raw      []byte            `json:"raw"`
+	Any      interface{}       `json:"any"`
+	Count    int64
+	hidden   string
+	embedded
+}
+
+func TestGeneratorSchema(t *testing.T) {
+	gen := NewGenerator()
+	require.Equal(t, &Schema{Ref: "#/components/schemas/node"}, gen.Schema(reflect.TypeOf(&node{})))
+
+	tests := []struct {
+		name     string
+		typ      reflect.Type
+		expected *Schema
+	}{
+		{"string", reflect.TypeOf(""), &Schema{Type: "string"}},
+		{"int32", reflect.TypeOf(int32(0)), &Schema{Type: "integer", Format: "int32"}},
+		{"pointer", reflect.TypeOf(new(bool)), &Schema{Type: "boolean"}},
+		{"time", reflect.TypeOf(time.Time{}), &Schema{Type: "string", Format: "date-time"}},
+		{"slice", reflect.TypeOf([]float64{}), &Schema{Type: "array", Items: &Schema{Type: "number", Format: "double"}}},
+		{"anonymous struct", reflect.TypeOf(struct {
+			A string `json:"a"`
+		}{}), &Schema{Type: "object", Properties: map[string]*Schema{"a": {Type: "string"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, gen.Schema(tt.typ))
+		})
+	}
+
+	require.Equal(t, map[string]*Schema{
+		"node": {Type: "object", Properties: map[string]*Schema{
+			"name":      {Type: "string"},
+			"children":  {Type: "array", Items: &Schema{Ref: "#/components/schemas/node"}},
+			"labels":    {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"raw":       {Type: "string", Format: "byte"},
+			"any":       {},
+			"Count":     {Type: "integer", Format: "int64"},
+			"createdAt": {Type: "string", Format: "date-time"},
+		}},
+	}, gen.Components())
+}
@@ -0,0 +1,72 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed breached.txt
+var bundledBreached string
+
+// BreachedList is a set of SHA-1 hashes of breached passwords
+type BreachedList struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// NewBreachedList returns the bundled list of common breached passwords with hashes in the files added.
+//
+// Files are in the format of Have I Been Pwned downloads: a SHA-1 hash in hex per line, optionally followed by
+// `:count`. Empty lines and lines starting with `#` are skipped.
+func NewBreachedList(files ...string) (*BreachedList, error) {
+	l := &BreachedList{hashes: map[[sha1.Size]byte]struct{}{}}
+	if err := l.read(strings.NewReader(bundledBreached)); err != nil {
+		return nil, fmt.Errorf("invalid bundled breached passwords: %w", err)
+	}
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached passwords: %w", err)
+		}
+		err = l.read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid breached passwords in %s: %w", path, err)
+		}
+	}
+	return l, nil
+}
+
+// read adds hashes of the reader
+func (l *BreachedList) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		decoded, err := hex.DecodeString(hash)
+		if err != nil || len(decoded) != sha1.Size {
+			return fmt.Errorf("line %d is not a SHA-1 hash", line)
+		}
+		l.hashes[[sha1.Size]byte(decoded)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Contains reports whether the password is in the list
+func (l *BreachedList) Contains(password string) bool {
+	_, ok := l.hashes[sha1.Sum([]byte(password))]
+	return ok
+}
+
+// Len returns the number of hashes in the list
+func (l *BreachedList) Len() int {
+	return len(l.hashes)
+}
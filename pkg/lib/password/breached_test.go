@@ -0,0 +1,43 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBreachedList(t *testing.T) {
+	bundled, err := NewBreachedList()
+	require.NoError(t, err)
+	require.True(t, bundled.Contains("123456"))
+	require.True(t, bundled.Contains("password"))
+	require.False(t, bundled.Contains("Correct-Horse7"))
+
+	// hashes in the format of Have I Been Pwned
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# extra\n\n5B2A1E8C2E1A3F0C4B9D6E7F8A9B0C1D2E3F4A5B:3\n" + sha1Hex("Correct-Horse7") + ":12\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	list, err := NewBreachedList(path)
+	require.NoError(t, err)
+	require.True(t, list.Contains("Correct-Horse7"))
+	require.Equal(t, bundled.Len()+2, list.Len())
+}
+
+func TestNewBreachedListInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not-a-hash\n"), 0o600))
+
+	_, err := NewBreachedList(path)
+	require.ErrorContains(t, err, "line 1 is not a SHA-1 hash")
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
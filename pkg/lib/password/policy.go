@@ -0,0 +1,253 @@
+// Package password validates passwords by a configurable policy.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// Character classes of passwords
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// classNames are names of character classes in messages
+var classNames = map[string]string{
+	ClassLower:  "a lowercase letter",
+	ClassUpper:  "an uppercase letter",
+	ClassDigit:  "a digit",
+	ClassSymbol: "a symbol",
+}
+
+// Codes of policy violations. They are codes of field errors of passwords.
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeCharacterClasses = "character_classes"
+	CodePersonalInfo     = "personal_info"
+	CodeBreached         = "breached"
+)
+
+// minPersonalLength is the min length of personal values which passwords can't contain, shorter values are common
+// in passwords by chance
+const minPersonalLength = 3
+
+// Violation is a rule of the policy which a password breaks
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Config is the configuration of Policy
+type Config struct {
+	// MinLength is the min number of characters
+	MinLength int
+	// MaxLength is the max number of characters
+	MaxLength int
+	// MinClasses is the min number of character classes among lowercase letters, uppercase letters, digits and symbols
+	MinClasses int
+	// RequiredClasses are character classes which passwords must have, e.g. `upper`
+	RequiredClasses []string
+	// AllowPersonalInfo allows passwords containing names, nickname or email of the user
+	AllowPersonalInfo bool
+	// CheckBreached rejects passwords in the breached passwords list
+	CheckBreached bool
+	// BreachedFiles are files of breached password hashes added to the bundled list, see NewBreachedList
+	BreachedFiles []string
+}
+
+// configEnvs are env variables of config keys
+var configEnvs = map[string]string{
+	"minLength":         "PASSWORD_MIN_LENGTH",
+	"maxLength":         "PASSWORD_MAX_LENGTH",
+	"minClasses":        "PASSWORD_MIN_CLASSES",
+	"requiredClasses":   "PASSWORD_REQUIRED_CLASSES",
+	"allowPersonalInfo": "PASSWORD_ALLOW_PERSONAL_INFO",
+	"checkBreached":     "PASSWORD_CHECK_BREACHED",
+	"breachedFiles":     "PASSWORD_BREACHED_FILES",
+}
+
+// DefaultConfig returns the default configuration of Policy
+func DefaultConfig() Config {
+	return Config{
+		MinLength:     10,
+		MaxLength:     128,
+		MinClasses:    3,
+		CheckBreached: true,
+	}
+}
+
+// LoadConfig returns the configuration of Policy.
+//
+// It is read from the json or yaml file of `PASSWORD_POLICY_FILE` when it is set, with keys of Config fields, e.g.
+// `{"minLength": 12, "requiredClasses": ["upper", "digit"]}`. Env variables override the file:
+// `PASSWORD_MIN_LENGTH`, `PASSWORD_MAX_LENGTH`, `PASSWORD_MIN_CLASSES`, `PASSWORD_REQUIRED_CLASSES`(comma separated),
+// `PASSWORD_ALLOW_PERSONAL_INFO`, `PASSWORD_CHECK_BREACHED` and `PASSWORD_BREACHED_FILES`(comma separated).
+func LoadConfig() (Config, error) {
+	vi := viper.New()
+	vi.AutomaticEnv()
+	def := DefaultConfig()
+	vi.SetDefault("minLength", def.MinLength)
+	vi.SetDefault("maxLength", def.MaxLength)
+	vi.SetDefault("minClasses", def.MinClasses)
+	vi.SetDefault("requiredClasses", def.RequiredClasses)
+	vi.SetDefault("allowPersonalInfo", def.AllowPersonalInfo)
+	vi.SetDefault("checkBreached", def.CheckBreached)
+	vi.SetDefault("breachedFiles", def.BreachedFiles)
+	for key, env := range configEnvs {
+		if err := vi.BindEnv(key, env); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if path := vi.GetString("PASSWORD_POLICY_FILE"); path != "" {
+		vi.SetConfigFile(path)
+		if err := vi.ReadInConfig(); err != nil {
+			return Config{}, fmt.Errorf("failed to read password policy file: %w", err)
+		}
+	}
+
+	return Config{
+		MinLength:         vi.GetInt("minLength"),
+		MaxLength:         vi.GetInt("maxLength"),
+		MinClasses:        vi.GetInt("minClasses"),
+		RequiredClasses:   getList(vi, "requiredClasses"),
+		AllowPersonalInfo: vi.GetBool("allowPersonalInfo"),
+		CheckBreached:     vi.GetBool("checkBreached"),
+		BreachedFiles:     getList(vi, "breachedFiles"),
+	}, nil
+}
+
+// getList returns the list of the key. Lists of env variables are comma separated.
+func getList(vi *viper.Viper, key string) []string {
+	if list, ok := vi.Get(key).(string); ok {
+		return splitList(list)
+	}
+	return vi.GetStringSlice(key)
+}
+
+// splitList splits the comma separated list
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Policy validates passwords
+type Policy struct {
+	config   Config
+	breached *BreachedList
+}
+
+// NewPolicy returns Policy with the configuration of LoadConfig
+func NewPolicy() (*Policy, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewPolicyWith(config)
+}
+
+// NewPolicyWith returns Policy with the configuration. Breached password files are read when CheckBreached is true.
+func NewPolicyWith(config Config) (*Policy, error) {
+	if config.MinLength < 1 || config.MaxLength < config.MinLength {
+		return nil, fmt.Errorf("invalid password policy: length should be between 1 and max length")
+	}
+	if config.MinClasses < 0 || config.MinClasses > len(classNames) {
+		return nil, fmt.Errorf("invalid password policy: min classes should be between 0 and %d", len(classNames))
+	}
+	for _, class := range config.RequiredClasses {
+		if _, ok := classNames[class]; !ok {
+			return nil, fmt.Errorf("invalid password policy: unknown character class %q", class)
+		}
+	}
+
+	p := &Policy{config: config}
+	if config.CheckBreached {
+		breached, err := NewBreachedList(config.BreachedFiles...)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = breached
+	}
+	return p, nil
+}
+
+// Validate returns rules of the policy which the password breaks. Returns nil when the password is valid.
+//
+// Personal values are names, nickname and email of the user which the password can't contain case insensitively.
+// Values shorter than 3 characters are skipped.
+func (p *Policy) Validate(password string, personal ...string) []Violation {
+	violations := []Violation{}
+	length := utf8.RuneCountInString(password)
+	if length < p.config.MinLength {
+		violations = append(violations, Violation{CodeTooShort, fmt.Sprintf("password must be at least %d characters", p.config.MinLength)})
+	}
+	if length > p.config.MaxLength {
+		violations = append(violations, Violation{CodeTooLong, fmt.Sprintf("password must be at most %d characters", p.config.MaxLength)})
+	}
+
+	classes := characterClasses(password)
+	for _, class := range p.config.RequiredClasses {
+		if !classes[class] {
+			violations = append(violations, Violation{CodeCharacterClasses, "password must have " + classNames[class]})
+		}
+	}
+	if len(classes) < p.config.MinClasses {
+		violations = append(violations, Violation{CodeCharacterClasses, fmt.Sprintf(
+			"password must have at least %d of lowercase letters, uppercase letters, digits and symbols", p.config.MinClasses)})
+	}
+
+	if !p.config.AllowPersonalInfo && containsPersonal(password, personal) {
+		violations = append(violations, Violation{CodePersonalInfo, "password can't contain your name, nickname or email"})
+	}
+	if p.breached != nil && p.breached.Contains(password) {
+		violations = append(violations, Violation{CodeBreached, "password is known to be breached, choose another one"})
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+// characterClasses returns character classes which the password has
+func characterClasses(password string) map[string]bool {
+	classes := map[string]bool{}
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			classes[ClassLower] = true
+		case unicode.IsUpper(r):
+			classes[ClassUpper] = true
+		case unicode.IsDigit(r):
+			classes[ClassDigit] = true
+		case !unicode.IsSpace(r):
+			classes[ClassSymbol] = true
+		}
+	}
+	return classes
+}
+
+// containsPersonal reports whether the password contains one of personal values case insensitively
+func containsPersonal(password string, personal []string) bool {
+	password = strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if utf8.RuneCountInString(value) >= minPersonalLength && strings.Contains(password, value) {
+			return true
+		}
+	}
+	return false
+}
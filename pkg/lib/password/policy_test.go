@@ -0,0 +1,117 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	config := DefaultConfig()
+	config.RequiredClasses = []string{ClassSymbol}
+	policy, err := NewPolicyWith(config)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		codes    []string
+	}{
+		{"valid", "Correct-Horse7", nil, nil},
+		{"unicode letters", "Şifre-Güçlü-9", nil, nil},
+		{"too short", "Ab1-", nil, []string{CodeTooShort}},
+		{"too long", strings.Repeat("Ab1-", 33), nil, []string{CodeTooLong}},
+		{"missing required class", "CorrectHorse7", nil, []string{CodeCharacterClasses}},
+		{"too few classes", "correct-horse", nil, []string{CodeCharacterClasses}},
+		{"contains name", "Johnson-2024!", []string{"John", "Doe"}, []string{CodePersonalInfo}},
+		{"short personal values are skipped", "Correct-Jo7", []string{"Jo"}, nil},
+		{"contains email local part", "x-JDoe-1987!", []string{"jdoe@email.com", "jdoe"}, []string{CodePersonalInfo}},
+		{"breached", "P@ssw0rd123", nil, []string{CodeBreached}},
+		{"all rules", "password", []string{"pass"}, []string{CodeTooShort, CodeCharacterClasses, CodeCharacterClasses, CodePersonalInfo, CodeBreached}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := policy.Validate(tt.password, tt.personal...)
+			var codes []string
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+			}
+			require.Equal(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestPolicyOptionalRules(t *testing.T) {
+	policy, err := NewPolicyWith(Config{MinLength: 8, MaxLength: 64, AllowPersonalInfo: true})
+	require.NoError(t, err)
+
+	require.Nil(t, policy.Validate("password", "pass"))
+}
+
+func TestNewPolicyWithInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"zero min length", Config{MinLength: 0, MaxLength: 10}},
+		{"max less than min", Config{MinLength: 10, MaxLength: 5}},
+		{"too many classes", Config{MinLength: 10, MaxLength: 20, MinClasses: 5}},
+		{"unknown class", Config{MinLength: 10, MaxLength: 20, RequiredClasses: []string{"emoji"}}},
+		{"missing breached file", Config{MinLength: 10, MaxLength: 20, CheckBreached: true, BreachedFiles: []string{"missing.txt"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPolicyWith(tt.config)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, err := LoadConfig()
+		require.NoError(t, err)
+		require.Equal(t, DefaultConfig().MinLength, config.MinLength)
+		require.Equal(t, DefaultConfig().MaxLength, config.MaxLength)
+		require.Equal(t, DefaultConfig().MinClasses, config.MinClasses)
+		require.True(t, config.CheckBreached)
+		require.Empty(t, config.RequiredClasses)
+	})
+
+	t.Run("file with env overrides", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"minLength": 12, "minClasses": 2, "requiredClasses": ["upper", "digit"], "checkBreached": false}`), 0o600))
+		t.Setenv("PASSWORD_POLICY_FILE", path)
+		t.Setenv("PASSWORD_MIN_LENGTH", "14")
+		t.Setenv("PASSWORD_BREACHED_FILES", "a.txt, b.txt")
+
+		config, err := LoadConfig()
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			MinLength:       14,
+			MaxLength:       128,
+			MinClasses:      2,
+			RequiredClasses: []string{"upper", "digit"},
+			BreachedFiles:   []string{"a.txt", "b.txt"},
+		}, config)
+	})
+
+	t.Run("required classes from env", func(t *testing.T) {
+		t.Setenv("PASSWORD_REQUIRED_CLASSES", "upper,symbol")
+
+		config, err := LoadConfig()
+		require.NoError(t, err)
+		require.Equal(t, []string{"upper", "symbol"}, config.RequiredClasses)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("PASSWORD_POLICY_FILE", filepath.Join(t.TempDir(), "missing.json"))
+
+		_, err := LoadConfig()
+		require.Error(t, err)
+	})
+}
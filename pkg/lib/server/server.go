@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ShutdownTimeout is the time given to servers to finish in-flight requests on shutdown
+const ShutdownTimeout = 5 * time.Second
+
+// Server is a network server which can be shut down gracefully
+type Server interface {
+	// Start serves until Shutdown is called. It returns nil after Shutdown.
+	Start() error
+	// Shutdown stops accepting new requests and waits for in-flight requests until ctx is done
+	Shutdown(ctx context.Context) error
+}
+
+// Run starts the servers and blocks until a termination signal is received or a server fails.
+// Then all servers are shut down together within ShutdownTimeout.
+//
+// Returns the error of the failed server and errors of servers which can't be shut down gracefully, so that callers
+// exit after their cleanup.
+func Run(servers ...Server) error {
+	// Channel to listen for termination signals
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	failed := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s Server) {
+			if err := s.Start(); err != nil {
+				failed <- err
+			}
+		}(s)
+	}
+
+	// Block until a termination signal is received or a server fails
+	var errs []error
+	select {
+	case <-quit:
+		slog.Info("Server is shutting down...")
+	case err := <-failed:
+		slog.Error("Server failed, shutting down...", slog.Any("error", err))
+		errs = append(errs, err)
+	}
+
+	// Create a timeout context for graceful shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s Server) {
+			defer wg.Done()
+			if err := s.Shutdown(ctx); err != nil {
+				slog.Error("Server forced to shutdown", slog.Any("error", err))
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("server forced to shutdown: %w", err))
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	slog.Info("Server stopped gracefully")
+	return nil
+}
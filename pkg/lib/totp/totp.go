@@ -0,0 +1,130 @@
+// Package totp generates and validates time-based one-time passwords of RFC 6238 with HMAC-SHA1, which
+// authenticator apps support.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secretSize is the size of generated secrets in bytes, the length of HMAC-SHA1 keys recommended by RFC 4226
+const secretSize = 20
+
+var (
+	ErrInvalidSecret = errors.New("invalid totp secret")
+)
+
+// encoding is the base32 encoding of secrets in provisioning URIs, without padding
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP is the configuration of codes
+type TOTP struct {
+	// Digits is the length of codes
+	Digits int
+	// Period is the duration of a time step, a code is valid in its step
+	Period time.Duration
+	// Skew is the number of steps before and after the current step whose codes are accepted, for clock drift
+	Skew int
+}
+
+// Default is the configuration which authenticator apps use by default: 6 digits codes of 30 seconds accepted one
+// step before and after
+var Default = TOTP{Digits: 6, Period: 30 * time.Second, Skew: 1}
+
+// GenerateSecret returns a random secret in base32 encoding
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Step returns the time step of the time
+func (t TOTP) Step(at time.Time) int64 {
+	return at.Unix() / int64(t.Period/time.Second)
+}
+
+// Code returns the code of the base32 secret in the time step
+func (t TOTP) Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.code(key, step), nil
+}
+
+// Validate checks the code against codes of the steps around the time and returns the matched step. Callers should
+// reject codes of steps which are already used so that a code can't be replayed.
+func (t TOTP) Validate(secret string, code string, at time.Time) (int64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(code) != t.Digits {
+		return 0, false, nil
+	}
+
+	current := t.Step(at)
+	for step := current - int64(t.Skew); step <= current+int64(t.Skew); step++ {
+		if subtle.ConstantTimeCompare([]byte(t.code(key, step)), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// ProvisioningURI returns the `otpauth://` URI of the secret which authenticator apps read from QR codes. Issuer is
+// the name of the service and account is the name of the user in the app.
+func (t TOTP) ProvisioningURI(secret, issuer, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(t.Digits))
+	query.Set("period", strconv.Itoa(int(t.Period/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// code computes the code of the key in the step by HOTP of RFC 4226
+func (t TOTP) code(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, value%mod)
+}
+
+// decodeSecret decodes the base32 secret, case-insensitively and with or without padding
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.TrimRight(strings.ToUpper(secret), "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
@@ -0,0 +1,102 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed of test vectors of RFC 6238, "12345678901234567890" in base32
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	rfc := TOTP{Digits: 8, Period: 30 * time.Second}
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tCase := range tests {
+		code, err := rfc.Code(rfcSecret, rfc.Step(time.Unix(tCase.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, tCase.expected, code, "time %d", tCase.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := Default.Step(at)
+	code := func(step int64) string {
+		c, err := Default.Code(rfcSecret, step)
+		require.NoError(t, err)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		ok       bool
+		expected int64
+	}{
+		{name: "code of the current step", code: code(step), ok: true, expected: step},
+		{name: "code of the previous step", code: code(step - 1), ok: true, expected: step - 1},
+		{name: "code of the next step", code: code(step + 1), ok: true, expected: step + 1},
+		{name: "code out of skew", code: code(step - 2)},
+		{name: "code of another length", code: "1234"},
+	}
+	for _, tCase := range tests {
+		t.Run(tCase.name, func(tt *testing.T) {
+			//execution
+			matched, ok, err := Default.Validate(rfcSecret, tCase.code, at)
+
+			//assertion
+			require.NoError(tt, err)
+			require.Equal(tt, tCase.ok, ok)
+			require.Equal(tt, tCase.expected, matched)
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	_, _, err := Default.Validate("not base32!", "123456", time.Now())
+	require.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+	require.NotEqual(t, secret, other)
+	require.Len(t, secret, 32)
+
+	code, err := Default.Code(secret, Default.Step(time.Now()))
+	require.NoError(t, err)
+	_, ok, err := Default.Validate(secret, code, time.Now())
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := Default.ProvisioningURI("JBSWY3DPEHPK3PXP", "User API", "john@email.com")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/User API:john@email.com", parsed.Path)
+	query := parsed.Query()
+	require.Equal(t, "JBSWY3DPEHPK3PXP", query.Get("secret"))
+	require.Equal(t, "User API", query.Get("issuer"))
+	require.Equal(t, "6", query.Get("digits"))
+	require.Equal(t, "30", query.Get("period"))
+}
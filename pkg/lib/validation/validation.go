@@ -0,0 +1,355 @@
+// Package validation validates structs by their `validate` tags and applies `default` tags.
+//
+// Rules of a field are separated by comma:
+//
+//	type Request struct {
+//		Email  string `json:"email" validate:"required,email"`
+//		Limit  int    `query:"limit" default:"20" validate:"min=1,max=100"`
+//		Status string `json:"status" validate:"oneof=active inactive"`
+//		Code   string `json:"code" validate:"regex=^[a-z]+$"`
+//	}
+//
+// Supported rules:
+//   - required: the value can't be zero
+//   - email: a valid email address, see email.Validate
+//   - iso3166: an ISO 3166-1 alpha-2 or alpha-3 country code or an alias of it, e.g. TR, tur or UK, see country.Normalize
+//   - min=n, max=n: limits of numbers, or of the length of strings(in characters), slices and maps
+//   - oneof=a b c: one of the space separated values
+//   - regex=pattern: matches the pattern. It should be the last rule since the pattern can contain commas.
+//
+// Rules other than required are skipped for zero values. Fields of embedded and nested structs are validated too and
+// named by their json tags, nested fields as `parent.child`. `validate:"partial"` on a struct field skips required rules
+// of its fields, e.g. for partial updates.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nsaltun/userapi/pkg/lib/country"
+	"github.com/nsaltun/userapi/pkg/lib/email"
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+)
+
+// Rules of `validate` tag
+const (
+	RuleRequired = "required"
+	RulePartial  = "partial"
+	RuleEmail    = "email"
+	RuleISO3166  = "iso3166"
+	RuleMin      = "min"
+	RuleMax      = "max"
+	RuleOneOf    = "oneof"
+	RuleRegex    = "regex"
+)
+
+// rule is a parsed rule of `validate` tag
+type rule struct {
+	name  string
+	param string
+	limit float64
+	re    *regexp.Regexp
+	oneOf []string
+}
+
+// field is a parsed field of a struct
+type field struct {
+	index    int
+	name     string
+	embedded bool
+	rules    []rule
+	partial  bool
+	def      string
+	hasDef   bool
+}
+
+// required reports whether the field has required rule
+func (f field) required() bool {
+	for _, r := range f.rules {
+		if r.name == RuleRequired {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsCache caches parsed fields by struct type
+var fieldsCache sync.Map
+
+// Struct applies defaults to zero fields of the struct which v points to and validates it.
+//
+// Returns errwrap validation error with field errors of invalid fields. Other errors are returned for invalid tags.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("validation: %T is not a pointer to struct", v)
+	}
+
+	fieldErrs := []errwrap.FieldError{}
+	if err := validateStruct(rv.Elem(), "", false, &fieldErrs); err != nil {
+		return err
+	}
+	if len(fieldErrs) > 0 {
+		return errwrap.NewValidationError(fieldErrs...)
+	}
+	return nil
+}
+
+// validateStruct validates fields of the struct and appends their errors
+func validateStruct(v reflect.Value, prefix string, partial bool, fieldErrs *[]errwrap.FieldError) error {
+	fields, err := fieldsOf(v.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		name := prefix + f.name
+		if f.hasDef && fv.IsZero() {
+			if err := setDefault(fv, f.def); err != nil {
+				return fmt.Errorf("validation: default of %s: %w", name, err)
+			}
+		}
+
+		if isStruct(fv.Type()) {
+			if fv.IsZero() && (fv.Kind() == reflect.Pointer || f.required()) {
+				if f.required() && !partial {
+					*fieldErrs = append(*fieldErrs, requiredError(name))
+				}
+				continue
+			}
+			nestedPrefix := prefix
+			if !f.embedded {
+				nestedPrefix = name + "."
+			}
+			if err := validateStruct(reflect.Indirect(fv), nestedPrefix, partial || f.partial, fieldErrs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if fv.IsZero() {
+			if f.required() && !partial {
+				*fieldErrs = append(*fieldErrs, requiredError(name))
+			}
+			continue
+		}
+		fv = reflect.Indirect(fv)
+		for _, r := range f.rules {
+			if msg := r.check(fv, name); msg != "" {
+				*fieldErrs = append(*fieldErrs, errwrap.FieldError{Field: name, Code: errwrap.FieldCodeInvalid, Message: msg})
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// check returns the error message when the non-zero value breaks the rule
+func (r rule) check(v reflect.Value, name string) string {
+	switch r.name {
+	case RuleEmail:
+		if email.Validate(v.String()) != nil {
+			return name + " must be a valid email address"
+		}
+	case RuleISO3166:
+		if _, ok := country.Normalize(v.String()); !ok {
+			return name + " must be an ISO 3166-1 country code"
+		}
+	case RuleMin, RuleMax:
+		value, unit := measure(v)
+		if r.name == RuleMin && value < r.limit {
+			return fmt.Sprintf("%s must be at least %s%s", name, r.param, unit)
+		}
+		if r.name == RuleMax && value > r.limit {
+			return fmt.Sprintf("%s must be at most %s%s", name, r.param, unit)
+		}
+	case RuleOneOf:
+		value := fmt.Sprint(v.Interface())
+		for _, allowed := range r.oneOf {
+			if value == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s must be one of %s", name, strings.Join(r.oneOf, ", "))
+	case RuleRegex:
+		if !r.re.MatchString(v.String()) {
+			return name + " has an invalid format"
+		}
+	}
+	return ""
+}
+
+// measure returns the number which min and max rules compare and its unit in messages
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	default:
+		return v.Float(), ""
+	}
+}
+
+// requiredError returns the field error of an empty required field
+func requiredError(name string) errwrap.FieldError {
+	return errwrap.FieldError{Field: name, Code: errwrap.FieldCodeRequired, Message: name + " can't be empty"}
+}
+
+// fieldsOf returns parsed fields of the struct type
+func fieldsOf(t reflect.Type) ([]field, error) {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field), nil
+	}
+
+	fields := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		f := field{index: i, name: fieldName(sf), embedded: sf.Anonymous}
+		f.def, f.hasDef = sf.Tag.Lookup("default")
+
+		rules, err := parseRules(sf.Tag.Get("validate"), sf.Type)
+		if err != nil {
+			return nil, fmt.Errorf("validation: field %s of %s: %w", sf.Name, t, err)
+		}
+		for _, r := range rules {
+			if r.name == RulePartial {
+				f.partial = true
+				continue
+			}
+			f.rules = append(f.rules, r)
+		}
+		fields = append(fields, f)
+	}
+
+	fieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+// parseRules parses `validate` tag of a field of the type
+func parseRules(tag string, t reflect.Type) ([]rule, error) {
+	rules := []rule{}
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, RuleRegex+"=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		r := rule{name: name, param: param}
+
+		switch name {
+		case RuleRequired, RulePartial:
+		case RuleEmail, RuleISO3166, RuleRegex:
+			if elemKind(t) != reflect.String {
+				return nil, fmt.Errorf("rule %s needs a string", name)
+			}
+			if name == RuleRegex {
+				re, err := regexp.Compile(param)
+				if err != nil {
+					return nil, err
+				}
+				r.re = re
+			}
+		case RuleMin, RuleMax:
+			switch elemKind(t) {
+			case reflect.Bool, reflect.Struct, reflect.Interface, reflect.Pointer, reflect.Func, reflect.Chan, reflect.Complex64, reflect.Complex128:
+				return nil, fmt.Errorf("rule %s needs a number, string, slice or map", name)
+			}
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid limit of rule %s: %w", name, err)
+			}
+			r.limit = limit
+		case RuleOneOf:
+			r.oneOf = strings.Fields(param)
+			if len(r.oneOf) == 0 {
+				return nil, fmt.Errorf("rule %s needs values", name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// fieldName returns json name of the field, or the field name starting with a lowercase letter
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	name := sf.Name
+	if sf.Anonymous {
+		t := sf.Type
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		name = t.Name()
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// elemKind returns kind of the type or the type it points to
+func elemKind(t reflect.Type) reflect.Kind {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem().Kind()
+	}
+	return t.Kind()
+}
+
+// isStruct reports whether the type is a struct or a pointer to struct
+func isStruct(t reflect.Type) bool {
+	return elemKind(t) == reflect.Struct
+}
+
+// setDefault sets the value parsed from the default tag
+func setDefault(v reflect.Value, def string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(def, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(def, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(def, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("%s can't have a default", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nsaltun/userapi/pkg/lib/errwrap"
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	Country string `json:"country" validate:"required,iso3166"`
+	City    string `json:"city" validate:"max=5"`
+}
+
+type Base struct {
+	Id string `json:"id" validate:"required"`
+}
+
+type request struct {
+	*Base    `validate:"required"`
+	Email    string   `json:"email,omitempty" validate:"required,email"`
+	Name     string   `validate:"min=2,max=4"`
+	Age      int      `json:"age" validate:"min=18"`
+	Limit    int      `query:"limit" default:"20" validate:"min=1,max=100"`
+	Status   string   `json:"status" default:"active" validate:"oneof=active inactive"`
+	Code     *string  `json:"code" validate:"regex=^[a-z]{2,3}$"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Address  *address `json:"address"`
+	Previous address  `json:"previous" validate:"partial"`
+}
+
+func TestStruct(t *testing.T) {
+	code := func(s string) *string { return &s }
+	valid := func() *request {
+		return &request{Base: &Base{Id: "1"}, Email: "john@doe.com"}
+	}
+	tests := []struct {
+		name   string
+		modify func(*request)
+		errs   []errwrap.FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(r *request) {},
+		},
+		{
+			name: "valid with optional fields",
+			modify: func(r *request) {
+				r.Name, r.Age, r.Code, r.Tags = "john", 18, code("abc"), []string{"a"}
+				r.Address = &address{Country: "TR", City: "Izmir"}
+			},
+		},
+		{
+			name:   "required",
+			modify: func(r *request) { r.Base, r.Email = nil, "" },
+			errs: []errwrap.FieldError{
+				{Field: "base", Code: errwrap.FieldCodeRequired, Message: "base can't be empty"},
+				{Field: "email", Code: errwrap.FieldCodeRequired, Message: "email can't be empty"},
+			},
+		},
+		{
+			name:   "required of embedded struct",
+			modify: func(r *request) { r.Base.Id = "" },
+			errs:   []errwrap.FieldError{{Field: "id", Code: errwrap.FieldCodeRequired, Message: "id can't be empty"}},
+		},
+		{
+			name: "invalid values",
+			modify: func(r *request) {
+				r.Email, r.Name, r.Age, r.Limit = "John <john@doe.com>", "j", 17, 101
+				r.Status, r.Code, r.Tags = "deleted", code("a,b"), []string{"a", "b", "c"}
+			},
+			errs: []errwrap.FieldError{
+				{Field: "email", Code: errwrap.FieldCodeInvalid, Message: "email must be a valid email address"},
+				{Field: "name", Code: errwrap.FieldCodeInvalid, Message: "name must be at least 2 characters"},
+				{Field: "age", Code: errwrap.FieldCodeInvalid, Message: "age must be at least 18"},
+				{Field: "limit", Code: errwrap.FieldCodeInvalid, Message: "limit must be at most 100"},
+				{Field: "status", Code: errwrap.FieldCodeInvalid, Message: "status must be one of active, inactive"},
+				{Field: "code", Code: errwrap.FieldCodeInvalid, Message: "code has an invalid format"},
+				{Field: "tags", Code: errwrap.FieldCodeInvalid, Message: "tags must be at most 2 items"},
+			},
+		},
+		{
+			name:   "nested struct",
+			modify: func(r *request) { r.Address = &address{Country: "XX", City: "London"} },
+			errs: []errwrap.FieldError{
+				{Field: "address.country", Code: errwrap.FieldCodeInvalid, Message: "address.country must be an ISO 3166-1 country code"},
+				{Field: "address.city", Code: errwrap.FieldCodeInvalid, Message: "address.city must be at most 5 characters"},
+			},
+		},
+		{
+			name:   "required of nested struct",
+			modify: func(r *request) { r.Address = &address{} },
+			errs:   []errwrap.FieldError{{Field: "address.country", Code: errwrap.FieldCodeRequired, Message: "address.country can't be empty"}},
+		},
+		{
+			name:   "partial struct validates only provided fields",
+			modify: func(r *request) { r.Previous = address{City: "Istanbul"} },
+			errs:   []errwrap.FieldError{{Field: "previous.city", Code: errwrap.FieldCodeInvalid, Message: "previous.city must be at most 5 characters"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(req)
+
+			err := Struct(req)
+			if len(tt.errs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			iErr, ok := err.(errwrap.IError)
+			require.True(t, ok, "expected IError, got %v", err)
+			require.Equal(t, http.StatusBadRequest, iErr.HttpCode())
+			require.Equal(t, tt.errs, iErr.FieldErrors())
+		})
+	}
+}
+
+func TestStructDefaults(t *testing.T) {
+	req := &request{Base: &Base{Id: "1"}, Email: "john@doe.com", Limit: 5}
+	require.NoError(t, Struct(req))
+	require.Equal(t, 5, req.Limit)
+	require.Equal(t, "active", req.Status)
+
+	req.Limit = 0
+	require.NoError(t, Struct(req))
+	require.Equal(t, 20, req.Limit)
+}
+
+func TestStructInvalidUsage(t *testing.T) {
+	type unknownRule struct {
+		Name string `validate:"unique"`
+	}
+	type regexOnInt struct {
+		Age int `validate:"regex=^1"`
+	}
+	type invalidDefault struct {
+		Limit int `default:"ten"`
+	}
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{name: "not a pointer", v: request{}},
+		{name: "not a struct", v: new(string)},
+		{name: "unknown rule", v: &unknownRule{Name: "a"}},
+		{name: "string rule on int", v: &regexOnInt{}},
+		{name: "invalid default", v: &invalidDefault{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			require.Error(t, err)
+			_, ok := err.(errwrap.IError)
+			require.False(t, ok)
+		})
+	}
+}
@@ -48,6 +48,26 @@ func (_m *IError) ErrorResp() errwrap.ErrorResponse {
 	return r0
 }
 
+// FieldErrors provides a mock function with given fields:
+func (_m *IError) FieldErrors() []errwrap.FieldError {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for FieldErrors")
+	}
+
+	var r0 []errwrap.FieldError
+	if rf, ok := ret.Get(0).(func() []errwrap.FieldError); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]errwrap.FieldError)
+		}
+	}
+
+	return r0
+}
+
 // HttpCode provides a mock function with given fields:
 func (_m *IError) HttpCode() int {
 	ret := _m.Called()
@@ -84,6 +104,32 @@ func (_m *IError) OriginErr() error {
 	return r0
 }
 
+// SetFieldErrors provides a mock function with given fields: errs
+func (_m *IError) SetFieldErrors(errs ...errwrap.FieldError) errwrap.IError {
+	_va := make([]interface{}, len(errs))
+	for _i := range errs {
+		_va[_i] = errs[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetFieldErrors")
+	}
+
+	var r0 errwrap.IError
+	if rf, ok := ret.Get(0).(func(...errwrap.FieldError) errwrap.IError); ok {
+		r0 = rf(errs...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(errwrap.IError)
+		}
+	}
+
+	return r0
+}
+
 // SetHttpCode provides a mock function with given fields: code
 func (_m *IError) SetHttpCode(code int) errwrap.IError {
 	ret := _m.Called(code)